@@ -0,0 +1,73 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package getty
+
+import (
+	"net"
+	"testing"
+	"time"
+)
+
+import (
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAcceptLimiter(t *testing.T) {
+	l := newTokenBucket(10, 2)
+	assert.Equal(t, time.Duration(0), l.reserve())
+	assert.Equal(t, time.Duration(0), l.reserve())
+	delay := l.reserve()
+	assert.True(t, delay > 50*time.Millisecond && delay <= 100*time.Millisecond, delay)
+
+	done := make(chan struct{})
+	close(done)
+	start := time.Now()
+	l.wait(done)
+	assert.True(t, time.Since(start) < 50*time.Millisecond)
+}
+
+func TestAcceptRateLimit(t *testing.T) {
+	s := NewTCPServer(WithLocalAddress("127.0.0.1:0"), WithAcceptRateLimit(20, 1)).(*server)
+	defer s.Close()
+	accepted := make(chan time.Time, 3)
+	s.RunEventLoop(func(session Session) error {
+		session.SetPkgHandler(&stringPkgHandler{})
+		session.SetEventListener(newChanMessageHandler())
+		accepted <- time.Now()
+		return nil
+	})
+
+	for i := 0; i < 3; i++ {
+		conn, err := net.Dial("tcp", s.addr)
+		assert.Nil(t, err)
+		defer conn.Close()
+	}
+	var first time.Time
+	for i := 0; i < 3; i++ {
+		select {
+		case at := <-accepted:
+			if i == 0 {
+				first = at
+			}
+		case <-time.After(3 * time.Second):
+			t.Fatal("server did not accept the connection")
+		}
+	}
+	// a token every 50ms
+	assert.True(t, time.Since(first) >= 90*time.Millisecond)
+}
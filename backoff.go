@@ -0,0 +1,88 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package getty
+
+import (
+	"math"
+	"math/rand"
+	"time"
+)
+
+// ReconnectFailedCallback is called when the client gives up reconnecting after the max attempts
+// of WithReconnectMaxAttempts, @err is a *DialError wrapping the error of the last attempt.
+type ReconnectFailedCallback func(client Client, err error)
+
+// reconnectHook is set by WithReconnectHook.
+type reconnectHook struct {
+	onAttempt func(client Client, attempt int, err error)
+	onSuccess func(session Session)
+	onGiveUp  ReconnectFailedCallback
+}
+
+// reconnectBackoff computes the delay before the next dialing attempt after a failure.
+type reconnectBackoff struct {
+	initial    time.Duration
+	max        time.Duration
+	multiplier float64
+	jitter     float64
+}
+
+// delay returns the delay after the @attempt-th consecutive failure, which starts from 1.
+// The default is the fixed connectInterval.
+func (b reconnectBackoff) delay(attempt int) time.Duration {
+	if b.initial <= 0 {
+		return connectInterval
+	}
+
+	d := float64(b.initial) * math.Pow(b.multiplier, float64(attempt-1))
+	if b.max > 0 && d > float64(b.max) {
+		d = float64(b.max)
+	}
+	if b.jitter > 0 {
+		// spread the delay over [d*(1-jitter), d*(1+jitter)]
+		d *= 1 + b.jitter*(2*rand.Float64()-1)
+	}
+	return time.Duration(d)
+}
+
+// retryDial counts the failed dialing attempt of @err, and waits for the backoff delay. It returns
+// false if the client is closed, or the attempts run out and the ReconnectFailedCallback is called.
+func (c *client) retryDial(attempts *int, err error) bool {
+	*attempts++
+	if c.reconnectHook.onAttempt != nil {
+		c.reconnectHook.onAttempt(c, *attempts, err)
+	}
+	if c.reconnectMaxAttempts > 0 && *attempts >= c.reconnectMaxAttempts {
+		endPointLogger(poolLog, c).Warnw("give up reconnecting", "attempts", *attempts, "err", err)
+		err = &DialError{Addr: c.addr, Attempts: *attempts, Err: err}
+		if c.onReconnectFailed != nil {
+			c.onReconnectFailed(c, err)
+		}
+		if c.reconnectHook.onGiveUp != nil {
+			c.reconnectHook.onGiveUp(c, err)
+		}
+		return false
+	}
+
+	select {
+	case <-c.done:
+		return false
+	case <-clockOrSystem(c.clock).After(c.reconnectBackoff.delay(*attempts)):
+		return true
+	}
+}
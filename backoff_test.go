@@ -0,0 +1,135 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package getty
+
+import (
+	"errors"
+	"net"
+	"testing"
+	"time"
+)
+
+import (
+	"github.com/stretchr/testify/assert"
+)
+
+func TestReconnectBackoff(t *testing.T) {
+	assert.Equal(t, time.Duration(connectInterval), reconnectBackoff{}.delay(3))
+
+	b := reconnectBackoff{initial: 10 * time.Millisecond, max: 50 * time.Millisecond, multiplier: 2}
+	assert.Equal(t, 10*time.Millisecond, b.delay(1))
+	assert.Equal(t, 20*time.Millisecond, b.delay(2))
+	assert.Equal(t, 40*time.Millisecond, b.delay(3))
+	assert.Equal(t, 50*time.Millisecond, b.delay(4))
+
+	b.jitter = 0.5
+	for i := 0; i < 100; i++ {
+		d := b.delay(4)
+		assert.True(t, 25*time.Millisecond <= d && d <= 75*time.Millisecond, d)
+	}
+}
+
+func TestClientReconnectMaxAttempts(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	assert.Nil(t, err)
+	addr := ln.Addr().String()
+	ln.Close()
+
+	var (
+		failed    Client
+		failedErr error
+	)
+	clt := NewTCPClient(WithServerAddress(addr), WithConnectionNumber(1),
+		WithReconnectBackoff(time.Millisecond, 5*time.Millisecond, 2, 0.2),
+		WithReconnectMaxAttempts(3),
+		WithOnReconnectFailed(func(client Client, err error) {
+			failed, failedErr = client, err
+		})).(PoolClient)
+	defer clt.Close()
+	// RunEventLoop returns once the client gives up
+	clt.RunEventLoop(func(session Session) error {
+		return nil
+	})
+	assert.Equal(t, clt, failed)
+	assert.True(t, errors.Is(failedErr, ErrDialFailed))
+	var dialErr *DialError
+	assert.True(t, errors.As(failedErr, &dialErr))
+	assert.Equal(t, addr, dialErr.Addr)
+	assert.Equal(t, 3, dialErr.Attempts)
+	_, err = clt.PickSession("")
+	assert.Equal(t, ErrNoSession, err)
+}
+
+func TestClientReconnectHook(t *testing.T) {
+	s := NewTCPServer(WithLocalAddress("127.0.0.1:0")).(*server)
+	defer s.Close()
+	s.RunEventLoop(func(session Session) error {
+		session.SetPkgHandler(&stringPkgHandler{})
+		session.SetEventListener(newChanMessageHandler())
+		return nil
+	})
+
+	reconnected := make(chan Session, 1)
+	clt := NewTCPClient(WithServerAddress(s.addr), WithConnectionNumber(1), WithReconnectInterval(1e7),
+		WithReconnectHook(nil, func(session Session) {
+			reconnected <- session
+		}, nil)).(PoolClient)
+	defer clt.Close()
+	clt.RunEventLoop(func(session Session) error {
+		session.SetPkgHandler(&stringPkgHandler{})
+		session.SetEventListener(newChanMessageHandler())
+		session.SetReadTimeout(50 * time.Millisecond)
+		return nil
+	})
+	ss, err := clt.PickSession("")
+	assert.Nil(t, err)
+	assert.Equal(t, 0, len(reconnected))
+
+	ss.Close()
+	select {
+	case rs := <-reconnected:
+		assert.NotEqual(t, ss.ID(), rs.ID())
+		assert.False(t, rs.IsClosed())
+	case <-time.After(time.Second):
+		t.Fatal("no reconnected session")
+	}
+
+	// the attempts to a dead address
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	assert.Nil(t, err)
+	addr := ln.Addr().String()
+	ln.Close()
+	var (
+		attempts []int
+		gaveUp   error
+	)
+	dead := NewTCPClient(WithServerAddress(addr), WithConnectionNumber(1),
+		WithReconnectBackoff(time.Millisecond, time.Millisecond, 1, 0), WithReconnectMaxAttempts(2),
+		WithReconnectHook(func(client Client, attempt int, err error) {
+			assert.NotNil(t, err)
+			attempts = append(attempts, attempt)
+		}, nil, func(client Client, err error) {
+			gaveUp = err
+		}))
+	defer dead.Close()
+	dead.RunEventLoop(func(session Session) error {
+		return nil
+	})
+	assert.Equal(t, []int{1, 2}, attempts)
+	assert.NotNil(t, gaveUp)
+}
@@ -0,0 +1,154 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package getty
+
+import (
+	"hash/crc32"
+	"math/rand"
+	"sort"
+	"strconv"
+	"sync"
+)
+
+import (
+	uatomic "go.uber.org/atomic"
+)
+
+// LoadBalancer picks the session of the client pool for a package, see PoolClient.PickSession.
+type LoadBalancer interface {
+	// Pick returns one of @sessions, which are open and sorted by their ids. @key is the key
+	// of the package passed to PickSession.
+	Pick(sessions []Session, key string) Session
+}
+
+type roundRobinBalancer struct {
+	next uatomic.Uint64
+}
+
+// NewRoundRobinBalancer returns the LoadBalancer which picks the sessions by turns, it is
+// the default one of the clients.
+func NewRoundRobinBalancer() LoadBalancer {
+	return &roundRobinBalancer{}
+}
+
+func (b *roundRobinBalancer) Pick(sessions []Session, key string) Session {
+	return sessions[(b.next.Inc()-1)%uint64(len(sessions))]
+}
+
+type randomBalancer struct{}
+
+// NewRandomBalancer returns the LoadBalancer which picks a session at random.
+func NewRandomBalancer() LoadBalancer {
+	return randomBalancer{}
+}
+
+func (randomBalancer) Pick(sessions []Session, key string) Session {
+	return sessions[rand.Intn(len(sessions))]
+}
+
+type leastPendingBalancer struct{}
+
+// NewLeastPendingBalancer returns the LoadBalancer which picks the session of the least pending
+// write bytes, eg: to steer away from a connection stalled by a slow peer.
+func NewLeastPendingBalancer() LoadBalancer {
+	return leastPendingBalancer{}
+}
+
+func (leastPendingBalancer) Pick(sessions []Session, key string) Session {
+	var (
+		least   Session
+		pending int
+	)
+	for _, s := range sessions {
+		if n := s.Stats().PendingWriteBytes; least == nil || n < pending {
+			least, pending = s, n
+		}
+	}
+	return least
+}
+
+type hashRing struct {
+	hashes   []uint32
+	sessions map[uint32]Session
+}
+
+type consistentHashBalancer struct {
+	replicas int
+
+	lock sync.Mutex
+	ids  []uint32 // the ids of the sessions of @ring
+	ring *hashRing
+}
+
+// NewConsistentHashBalancer returns the LoadBalancer which picks the session by the consistent
+// hashing of the key, so the packages of a key go through the same session while it is open and
+// only the keys of a closed session move. @replicas is the number of the virtual nodes per session.
+func NewConsistentHashBalancer(replicas int) LoadBalancer {
+	if replicas < 1 {
+		replicas = 1
+	}
+	return &consistentHashBalancer{replicas: replicas}
+}
+
+func (b *consistentHashBalancer) Pick(sessions []Session, key string) Session {
+	ring := b.hashRing(sessions)
+	h := crc32.ChecksumIEEE([]byte(key))
+	idx := sort.Search(len(ring.hashes), func(i int) bool { return ring.hashes[i] >= h })
+	if idx == len(ring.hashes) {
+		idx = 0
+	}
+	return ring.sessions[ring.hashes[idx]]
+}
+
+// hashRing returns the ring of @sessions, which is rebuilt only if the sessions change.
+func (b *consistentHashBalancer) hashRing(sessions []Session) *hashRing {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+
+	if b.ring != nil && len(b.ids) == len(sessions) {
+		same := true
+		for i, s := range sessions {
+			if b.ids[i] != s.ID() {
+				same = false
+				break
+			}
+		}
+		if same {
+			return b.ring
+		}
+	}
+
+	ring := &hashRing{
+		hashes:   make([]uint32, 0, len(sessions)*b.replicas),
+		sessions: make(map[uint32]Session, len(sessions)*b.replicas),
+	}
+	b.ids = b.ids[:0]
+	for _, s := range sessions {
+		b.ids = append(b.ids, s.ID())
+		for i := 0; i < b.replicas; i++ {
+			h := crc32.ChecksumIEEE([]byte(strconv.Itoa(int(s.ID())) + "#" + strconv.Itoa(i)))
+			if _, ok := ring.sessions[h]; !ok {
+				ring.hashes = append(ring.hashes, h)
+				ring.sessions[h] = s
+			}
+		}
+	}
+	sort.Slice(ring.hashes, func(i, j int) bool { return ring.hashes[i] < ring.hashes[j] })
+	b.ring = ring
+	return ring
+}
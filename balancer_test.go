@@ -0,0 +1,108 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package getty
+
+import (
+	"strconv"
+	"testing"
+)
+
+import (
+	"github.com/stretchr/testify/assert"
+)
+
+type balancerSession struct {
+	Session
+	id      uint32
+	pending int
+}
+
+func (s *balancerSession) ID() uint32 {
+	return s.id
+}
+
+func (s *balancerSession) Stats() SessionStats {
+	return SessionStats{PendingWriteBytes: s.pending}
+}
+
+func newBalancerSessions(n int) []Session {
+	sessions := make([]Session, 0, n)
+	for i := 0; i < n; i++ {
+		sessions = append(sessions, &balancerSession{id: uint32(i + 1)})
+	}
+	return sessions
+}
+
+func TestRoundRobinBalancer(t *testing.T) {
+	sessions := newBalancerSessions(3)
+	lb := NewRoundRobinBalancer()
+	for i := 0; i < 6; i++ {
+		assert.Equal(t, sessions[i%3], lb.Pick(sessions, ""))
+	}
+}
+
+func TestRandomBalancer(t *testing.T) {
+	sessions := newBalancerSessions(3)
+	lb := NewRandomBalancer()
+	for i := 0; i < 10; i++ {
+		assert.Contains(t, sessions, lb.Pick(sessions, ""))
+	}
+}
+
+func TestLeastPendingBalancer(t *testing.T) {
+	sessions := newBalancerSessions(3)
+	sessions[0].(*balancerSession).pending = 100
+	sessions[1].(*balancerSession).pending = 10
+	sessions[2].(*balancerSession).pending = 50
+	lb := NewLeastPendingBalancer()
+	assert.Equal(t, sessions[1], lb.Pick(sessions, ""))
+
+	sessions[1].(*balancerSession).pending = 200
+	assert.Equal(t, sessions[2], lb.Pick(sessions, ""))
+}
+
+func TestConsistentHashBalancer(t *testing.T) {
+	sessions := newBalancerSessions(4)
+	lb := NewConsistentHashBalancer(16)
+
+	picked := make(map[string]Session)
+	for i := 0; i < 100; i++ {
+		key := "key-" + strconv.Itoa(i)
+		picked[key] = lb.Pick(sessions, key)
+		assert.Equal(t, picked[key], lb.Pick(sessions, key))
+	}
+
+	// only the keys of the removed session move
+	removed := sessions[2]
+	rest := []Session{sessions[0], sessions[1], sessions[3]}
+	for key, s := range picked {
+		if s != removed {
+			assert.Equal(t, s, lb.Pick(rest, key))
+		} else {
+			assert.NotEqual(t, removed, lb.Pick(rest, key))
+		}
+	}
+}
+
+func TestClientPickSessionNoSession(t *testing.T) {
+	clt := NewTCPClient(WithServerAddress("127.0.0.1:0"), WithConnectionNumber(1), WithLoadBalancer(NewRandomBalancer())).(PoolClient)
+	ss, err := clt.PickSession("")
+	assert.Nil(t, ss)
+	assert.Equal(t, ErrNoSession, err)
+	clt.Close()
+}
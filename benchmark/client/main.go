@@ -19,7 +19,6 @@ package main
 
 import (
 	"encoding/binary"
-	"errors"
 	"flag"
 	"fmt"
 	"log"
@@ -87,7 +86,7 @@ func main() {
 
 			var tmpSession getty.Session
 			NewHelloClientSession := func(session getty.Session) (err error) {
-				pkgHandler := &PackageHandler{}
+				pkgHandler := &getty.LengthFieldCodec{ByteOrder: binary.LittleEndian, LengthFieldSize: 4}
 				EventListener := &MessageHandler{}
 
 				EventListener.SessionOnOpen = func(session getty.Session) {
@@ -203,7 +202,7 @@ func (h *MessageHandler) OnClose(session getty.Session) {
 
 func (h *MessageHandler) OnMessage(session getty.Session, pkg interface{}) {
 	log.Printf("OnMessage....")
-	s, ok := pkg.(string)
+	s, ok := pkg.([]byte)
 	if !ok {
 		log.Printf("illegal package{%#v}", pkg)
 		return
@@ -215,51 +214,6 @@ func (h *MessageHandler) OnCron(session getty.Session) {
 	log.Printf("OnCron....")
 }
 
-type PackageHandler struct{}
-
-func (h *PackageHandler) Read(ss getty.Session, data []byte) (interface{}, int, error) {
-	dataLen := len(data)
-	if dataLen < 4 {
-		return nil, 0, nil
-	}
-
-	start := 0
-	pos := start + 4
-	pkgLen := int(binary.LittleEndian.Uint32(data[start:pos]))
-	if dataLen < pos+pkgLen {
-		return nil, pos + pkgLen, nil
-	}
-	start = pos
-
-	pos = start + pkgLen
-	s := string(data[start:pos])
-
-	return s, pos, nil
-}
-
-func (h *PackageHandler) Write(ss getty.Session, p interface{}) ([]byte, error) {
-	pkg, ok := p.(string)
-	if !ok {
-		log.Printf("illegal pkg:%+v", p)
-		return nil, errors.New("invalid package")
-	}
-
-	pkgLen := int32(len(pkg))
-	pkgStreams := make([]byte, 0, 4+len(pkg))
-
-	// pkg len
-	start := 0
-	pos := start + 4
-	binary.LittleEndian.PutUint32(pkgStreams[start:pos], uint32(pkgLen))
-	start = pos
-
-	// pkg
-	pos = start + int(pkgLen)
-	copy(pkgStreams[start:pos], pkg[:])
-
-	return pkgStreams[:pos], nil
-}
-
 func buildSendMsg() string {
 	return "hello getty"
 }
@@ -0,0 +1,218 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+// Package caller correlates the requests written to getty sessions with their responses, which
+// is the boilerplate of the RPC users on top of WritePkg and OnMessage.
+package caller
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+import (
+	perrors "github.com/pkg/errors"
+
+	uatomic "go.uber.org/atomic"
+)
+
+import (
+	getty "github.com/apache/dubbo-getty"
+)
+
+// StampFunc returns the request @req carrying the request id @id.
+type StampFunc func(req interface{}, id uint64) interface{}
+
+// IDFunc returns the request id of the response @rsp, or false if @rsp is not a response.
+type IDFunc func(rsp interface{}) (uint64, bool)
+
+type Option func(*Caller)
+
+// WithTimeout sets the timeout of the calls whose context has no deadline.
+func WithTimeout(timeout time.Duration) Option {
+	return func(c *Caller) {
+		c.timeout = timeout
+	}
+}
+
+type result struct {
+	rsp interface{}
+	err error
+}
+
+type pendingCall struct {
+	session getty.Session
+	done    chan result
+}
+
+// Caller is the EventListener of the sessions which the calls are written to. It passes the
+// events and the messages which are not responses to the wrapped EventListener. The calls are
+// written to the open sessions by turns and wait for a session while there is none, eg: before
+// the client reconnects. The calls in flight on a closed session fail with getty.ErrSessionClosed
+// and are not retried as they may be not idempotent.
+type Caller struct {
+	getty.EventListener
+
+	stamp   StampFunc
+	id      IDFunc
+	timeout time.Duration
+
+	seq      uatomic.Uint64
+	lock     sync.Mutex
+	sessions []getty.Session
+	next     int
+	opened   chan struct{} // closed and replaced when a session is opened
+	calls    map[uint64]*pendingCall
+}
+
+// New returns the Caller wrapping @listener.
+func New(listener getty.EventListener, stamp StampFunc, id IDFunc, opts ...Option) *Caller {
+	c := &Caller{
+		EventListener: listener,
+		stamp:         stamp,
+		id:            id,
+		opened:        make(chan struct{}),
+		calls:         make(map[uint64]*pendingCall),
+	}
+	for _, opt := range opts {
+		opt(c)
+	}
+	return c
+}
+
+func (c *Caller) OnOpen(session getty.Session) error {
+	if err := c.EventListener.OnOpen(session); err != nil {
+		return err
+	}
+
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	c.sessions = append(c.sessions, session)
+	close(c.opened)
+	c.opened = make(chan struct{})
+	return nil
+}
+
+func (c *Caller) OnClose(session getty.Session) {
+	c.lock.Lock()
+	for i, s := range c.sessions {
+		if s == session {
+			c.sessions = append(c.sessions[:i], c.sessions[i+1:]...)
+			break
+		}
+	}
+	for id, call := range c.calls {
+		if call.session == session {
+			delete(c.calls, id)
+			call.done <- result{err: getty.ErrSessionClosed}
+		}
+	}
+	c.lock.Unlock()
+
+	c.EventListener.OnClose(session)
+}
+
+func (c *Caller) OnMessage(session getty.Session, pkg interface{}) {
+	if id, ok := c.id(pkg); ok {
+		c.lock.Lock()
+		call, ok := c.calls[id]
+		delete(c.calls, id)
+		c.lock.Unlock()
+		if ok {
+			call.done <- result{rsp: pkg}
+			return
+		}
+	}
+
+	c.EventListener.OnMessage(session, pkg)
+}
+
+// session returns the next open session, or waits for one until @ctx is done.
+func (c *Caller) session(ctx context.Context) (getty.Session, error) {
+	for {
+		c.lock.Lock()
+		for len(c.sessions) > 0 {
+			c.next = (c.next + 1) % len(c.sessions)
+			s := c.sessions[c.next]
+			if !s.IsClosed() {
+				c.lock.Unlock()
+				return s, nil
+			}
+			c.sessions = append(c.sessions[:c.next], c.sessions[c.next+1:]...)
+		}
+		opened := c.opened
+		c.lock.Unlock()
+
+		select {
+		case <-opened:
+		case <-ctx.Done():
+			return nil, perrors.WithMessage(ctx.Err(), "wait for session")
+		}
+	}
+}
+
+// Call writes the request @req stamped with a new request id and returns its response.
+func (c *Caller) Call(ctx context.Context, req interface{}) (interface{}, error) {
+	if _, ok := ctx.Deadline(); !ok && c.timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, c.timeout)
+		defer cancel()
+	}
+
+	session, err := c.session(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	id := c.seq.Inc()
+	call := &pendingCall{session: session, done: make(chan result, 1)}
+	c.lock.Lock()
+	c.calls[id] = call
+	c.lock.Unlock()
+
+	timeout := time.Duration(0)
+	if deadline, ok := ctx.Deadline(); ok {
+		timeout = time.Until(deadline)
+	}
+	if _, _, err = session.WritePkg(c.stamp(req, id), timeout); err != nil {
+		c.cancel(id)
+		return nil, err
+	}
+
+	select {
+	case r := <-call.done:
+		return r.rsp, r.err
+	case <-ctx.Done():
+		c.cancel(id)
+		return nil, perrors.WithMessagef(ctx.Err(), "call %d", id)
+	}
+}
+
+func (c *Caller) cancel(id uint64) {
+	c.lock.Lock()
+	delete(c.calls, id)
+	c.lock.Unlock()
+}
+
+// Pending returns the number of the calls waiting for their responses.
+func (c *Caller) Pending() int {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	return len(c.calls)
+}
@@ -0,0 +1,150 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package caller
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+import (
+	perrors "github.com/pkg/errors"
+
+	"github.com/stretchr/testify/assert"
+)
+
+import (
+	getty "github.com/apache/dubbo-getty"
+)
+
+// lineCodec reads and writes the lines as string.
+type lineCodec struct{}
+
+func (lineCodec) Read(ss getty.Session, data []byte) (interface{}, int, error) {
+	if idx := bytes.IndexByte(data, '\n'); idx >= 0 {
+		return string(data[:idx]), idx + 1, nil
+	}
+	return nil, 0, nil
+}
+
+func (lineCodec) Write(ss getty.Session, pkg interface{}) ([]byte, error) {
+	return []byte(pkg.(string) + "\n"), nil
+}
+
+type nopListener struct {
+	msgs chan interface{}
+}
+
+func (l *nopListener) OnOpen(getty.Session) error                       { return nil }
+func (l *nopListener) OnClose(getty.Session)                            {}
+func (l *nopListener) OnError(getty.Session, error)                     {}
+func (l *nopListener) OnCron(getty.Session)                             {}
+func (l *nopListener) OnMessage(session getty.Session, pkg interface{}) { l.msgs <- pkg }
+
+// echoListener replies "id:rsp:body" to the request "id:body", but not to "id:drop".
+type echoListener struct {
+	nopListener
+}
+
+func (l *echoListener) OnMessage(session getty.Session, pkg interface{}) {
+	id, body, _ := strings.Cut(pkg.(string), ":")
+	if body != "drop" {
+		session.WritePkg(id+":rsp:"+body, 0)
+	}
+}
+
+func stamp(req interface{}, id uint64) interface{} {
+	return fmt.Sprintf("%d:%s", id, req)
+}
+
+func responseID(rsp interface{}) (uint64, bool) {
+	prefix, _, ok := strings.Cut(rsp.(string), ":rsp:")
+	if !ok {
+		return 0, false
+	}
+	id, err := strconv.ParseUint(prefix, 10, 64)
+	return id, err == nil
+}
+
+func TestCaller(t *testing.T) {
+	listener := &nopListener{msgs: make(chan interface{}, 1)}
+	c := New(listener, stamp, responseID, WithTimeout(50*time.Millisecond))
+
+	// there is no session
+	_, err := c.Call(context.Background(), "hello")
+	assert.Equal(t, context.DeadlineExceeded, perrors.Cause(err))
+
+	// the call waits for the session
+	rsps := make(chan interface{}, 1)
+	go func() {
+		rsp, err := c.Call(context.Background(), "hello")
+		assert.Nil(t, err)
+		rsps <- rsp
+	}()
+	time.Sleep(10 * time.Millisecond)
+	clientEndPoint, serverEndPoint := getty.NewPipeEndpoint()
+	defer clientEndPoint.Close()
+	serverEndPoint.RunEventLoop(func(session getty.Session) error {
+		session.SetReadTimeout(50 * time.Millisecond)
+		session.SetPkgHandler(lineCodec{})
+		session.SetEventListener(&echoListener{})
+		return nil
+	})
+	clientEndPoint.RunEventLoop(func(session getty.Session) error {
+		session.SetReadTimeout(50 * time.Millisecond)
+		session.SetPkgHandler(lineCodec{})
+		session.SetEventListener(c)
+		return nil
+	})
+	assert.Equal(t, "1:rsp:hello", <-rsps)
+
+	rsp, err := c.Call(context.Background(), "world")
+	assert.Nil(t, err)
+	assert.Equal(t, "2:rsp:world", rsp)
+
+	// the call without the response times out
+	_, err = c.Call(context.Background(), "drop")
+	assert.Equal(t, context.DeadlineExceeded, perrors.Cause(err))
+	assert.Equal(t, 0, c.Pending())
+
+	// the message which is not a response goes to the wrapped listener
+	c.OnMessage(nil, "notice")
+	assert.Equal(t, "notice", <-listener.msgs)
+
+	// the calls in flight fail when the session is closed
+	errs := make(chan error, 1)
+	go func() {
+		ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+		defer cancel()
+		_, err := c.Call(ctx, "drop")
+		errs <- err
+	}()
+	time.Sleep(10 * time.Millisecond)
+	serverEndPoint.Close()
+	select {
+	case err = <-errs:
+		assert.Equal(t, getty.ErrSessionClosed, err)
+	case <-time.After(5 * time.Second):
+		t.Fatal("the call does not fail")
+	}
+}
@@ -0,0 +1,174 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package getty
+
+import (
+	"math/rand"
+	"net"
+	"sync"
+	"time"
+)
+
+import (
+	perrors "github.com/pkg/errors"
+
+	uatomic "go.uber.org/atomic"
+)
+
+// ErrChaosDisconnect is the error of the reads and writes of the connections disconnected by Chaos.
+var ErrChaosDisconnect = perrors.New("connection disconnected by chaos")
+
+// ChaosConfig is the faults Chaos injects. The rates are the probabilities in [0, 1] per read or
+// write, and the zero ChaosConfig injects nothing.
+type ChaosConfig struct {
+	// Latency delays every read and write, plus a random duration up to Jitter.
+	Latency time.Duration
+	Jitter  time.Duration
+	// CorruptRate is the probability to flip a random bit of the written bytes.
+	CorruptRate float64
+	// PartialWriteRate is the probability to write the bytes in two parts with Latency and Jitter
+	// between them, so that the peer reads the partial packages.
+	PartialWriteRate float64
+	// DisconnectRate is the probability to close the connection before a read or write.
+	DisconnectRate float64
+	// Seed seeds the random faults to replay a run, 0 means a random seed.
+	Seed int64
+}
+
+// ChaosStats is the counts of the faults injected by Chaos.
+type ChaosStats struct {
+	Delays        uint64
+	Corruptions   uint64
+	PartialWrites uint64
+	Disconnects   uint64
+}
+
+// Chaos injects the faults of its ChaosConfig into the connections, eg: to soak test a protocol
+// for the resilience in CI. It wraps the stream connections of the endpoints of WithChaos and
+// WithClientChaos, whose socket options and tls state are unavailable then.
+type Chaos struct {
+	config ChaosConfig
+	lock   sync.Mutex
+	rnd    *rand.Rand
+
+	delays        uatomic.Uint64
+	corruptions   uatomic.Uint64
+	partialWrites uatomic.Uint64
+	disconnects   uatomic.Uint64
+}
+
+// NewChaos returns a Chaos injecting the faults of @config.
+func NewChaos(config ChaosConfig) *Chaos {
+	seed := config.Seed
+	if seed == 0 {
+		seed = time.Now().UnixNano()
+	}
+	return &Chaos{config: config, rnd: rand.New(rand.NewSource(seed))}
+}
+
+// Wrap returns @conn with the faults injected.
+func (c *Chaos) Wrap(conn net.Conn) net.Conn {
+	if c == nil {
+		return conn
+	}
+	return &chaosConn{Conn: conn, chaos: c}
+}
+
+// Stats returns the counts of the injected faults.
+func (c *Chaos) Stats() ChaosStats {
+	return ChaosStats{
+		Delays:        c.delays.Load(),
+		Corruptions:   c.corruptions.Load(),
+		PartialWrites: c.partialWrites.Load(),
+		Disconnects:   c.disconnects.Load(),
+	}
+}
+
+func (c *Chaos) hit(rate float64) bool {
+	if rate <= 0 {
+		return false
+	}
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	return c.rnd.Float64() < rate
+}
+
+func (c *Chaos) intn(n int) int {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	return c.rnd.Intn(n)
+}
+
+func (c *Chaos) delay() {
+	d := c.config.Latency
+	if c.config.Jitter > 0 {
+		d += time.Duration(c.intn(int(c.config.Jitter)))
+	}
+	if d > 0 {
+		c.delays.Inc()
+		time.Sleep(d)
+	}
+}
+
+type chaosConn struct {
+	net.Conn
+	chaos *Chaos
+}
+
+// disconnect closes the connection if the chaos hits.
+func (c *chaosConn) disconnect() error {
+	if !c.chaos.hit(c.chaos.config.DisconnectRate) {
+		return nil
+	}
+	c.chaos.disconnects.Inc()
+	c.Conn.Close()
+	return perrors.WithStack(ErrChaosDisconnect)
+}
+
+func (c *chaosConn) Read(p []byte) (int, error) {
+	if err := c.disconnect(); err != nil {
+		return 0, err
+	}
+	c.chaos.delay()
+	return c.Conn.Read(p)
+}
+
+func (c *chaosConn) Write(p []byte) (int, error) {
+	if err := c.disconnect(); err != nil {
+		return 0, err
+	}
+	c.chaos.delay()
+	if len(p) > 0 && c.chaos.hit(c.chaos.config.CorruptRate) {
+		c.chaos.corruptions.Inc()
+		p = append([]byte(nil), p...)
+		i := c.chaos.intn(len(p) * 8)
+		p[i/8] ^= 1 << (i % 8)
+	}
+	if len(p) > 1 && c.chaos.hit(c.chaos.config.PartialWriteRate) {
+		c.chaos.partialWrites.Inc()
+		split := 1 + c.chaos.intn(len(p)-1)
+		n, err := c.Conn.Write(p[:split])
+		if err != nil {
+			return n, err
+		}
+		c.chaos.delay()
+		m, err := c.Conn.Write(p[split:])
+		return n + m, err
+	}
+	return c.Conn.Write(p)
+}
@@ -0,0 +1,112 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package getty
+
+import (
+	"errors"
+	"io"
+	"net"
+	"testing"
+	"time"
+)
+
+import (
+	"github.com/stretchr/testify/assert"
+)
+
+func TestChaosConn(t *testing.T) {
+	readAll := func(conn net.Conn, n int) ([]byte, int) {
+		buf, reads := make([]byte, 0, n), 0
+		for len(buf) < n {
+			p := make([]byte, n)
+			m, err := conn.Read(p)
+			assert.Nil(t, err)
+			buf, reads = append(buf, p[:m]...), reads+1
+		}
+		return buf, reads
+	}
+
+	// the corrupted and partial writes
+	chaos := NewChaos(ChaosConfig{CorruptRate: 1, PartialWriteRate: 1, Seed: 1})
+	local, peer := net.Pipe()
+	defer peer.Close()
+	conn := chaos.Wrap(local)
+	msg := []byte("hello chaos")
+	go conn.Write(msg)
+	got, reads := readAll(peer, len(msg))
+	assert.Equal(t, 2, reads)
+	assert.NotEqual(t, msg, got)
+	assert.Equal(t, "hello chaos", string(msg))
+	diff := 0
+	for i := range msg {
+		for b := msg[i] ^ got[i]; b != 0; b &= b - 1 {
+			diff++
+		}
+	}
+	assert.Equal(t, 1, diff)
+
+	// the disconnects
+	chaos = NewChaos(ChaosConfig{DisconnectRate: 1, Latency: time.Millisecond})
+	conn = chaos.Wrap(peer)
+	_, err := conn.Write(msg)
+	assert.True(t, errors.Is(err, ErrChaosDisconnect))
+	_, err = local.Read(make([]byte, 1))
+	assert.Equal(t, io.EOF, err)
+	assert.Equal(t, ChaosStats{Disconnects: 1}, chaos.Stats())
+
+	// the zero config injects nothing
+	var nilChaos *Chaos
+	assert.Equal(t, local, nilChaos.Wrap(local))
+}
+
+func TestChaosEndpoints(t *testing.T) {
+	serverHandler := newChanMessageHandler()
+	s := NewTCPServer(WithLocalAddress("127.0.0.1:0"),
+		WithChaos(NewChaos(ChaosConfig{Latency: time.Millisecond, PartialWriteRate: 1}))).(*server)
+	defer s.Close()
+	s.RunEventLoop(func(session Session) error {
+		session.SetPkgHandler(&stringPkgHandler{})
+		session.SetEventListener(serverHandler)
+		session.SetReadTimeout(50 * time.Millisecond)
+		return nil
+	})
+
+	chaos := NewChaos(ChaosConfig{Jitter: time.Millisecond, PartialWriteRate: 1})
+	clientHandler := newChanMessageHandler()
+	clt := NewTCPClient(WithServerAddress(s.addr), WithConnectionNumber(1), WithClientChaos(chaos))
+	defer clt.Close()
+	clt.RunEventLoop(func(session Session) error {
+		session.SetPkgHandler(&stringPkgHandler{})
+		session.SetEventListener(clientHandler)
+		session.SetReadTimeout(50 * time.Millisecond)
+		return nil
+	})
+
+	// the server reassembles the packages written in parts
+	for _, msg := range []string{"hello", "chaos"} {
+		_, _, err := clientHandler.array[0].WritePkg(msg, 0)
+		assert.Nil(t, err)
+		select {
+		case pkg := <-serverHandler.msgs:
+			assert.Equal(t, msg, pkg)
+		case <-time.After(3 * time.Second):
+			t.Fatal("the server does not receive the package")
+		}
+	}
+	assert.Equal(t, uint64(2), chaos.Stats().PartialWrites)
+}
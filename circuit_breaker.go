@@ -0,0 +1,182 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package getty
+
+import (
+	"sync"
+	"time"
+)
+
+// CircuitBreakerOptions are the thresholds of the circuit breaker of a server address, see
+// WithCircuitBreaker.
+type CircuitBreakerOptions struct {
+	// ConsecutiveFailures opens the breaker after the failed writes in a row, 0 means 5.
+	ConsecutiveFailures int
+	// ErrorRate opens the breaker when the ratio of the failed writes in Window reaches it,
+	// 0 means never.
+	ErrorRate float64
+	// MinRequests is the writes in Window before ErrorRate applies, 0 means 20.
+	MinRequests int
+	// Window is the interval to reset the counts of ErrorRate, 0 means 10s.
+	Window time.Duration
+	// OpenTimeout is how long the breaker keeps open before the half-open probes, 0 means 5s.
+	OpenTimeout time.Duration
+	// HalfOpenProbes is the writes let through when the breaker is half-open, which close the
+	// breaker if all of them succeed, 0 means 1.
+	HalfOpenProbes int
+}
+
+type circuitState int
+
+const (
+	circuitClosed circuitState = iota
+	circuitOpen
+	circuitHalfOpen
+)
+
+// circuitBreaker counts the write results of the sessions to a server address.
+type circuitBreaker struct {
+	opts CircuitBreakerOptions
+
+	lock        sync.Mutex
+	state       circuitState
+	consecutive int
+	requests    int
+	failures    int
+	windowStart time.Time
+	openedAt    time.Time
+	probes      int // the probes let through in the half-open state
+	succeeded   int // the succeeded probes
+}
+
+func newCircuitBreaker(opts CircuitBreakerOptions) *circuitBreaker {
+	if opts.ConsecutiveFailures <= 0 {
+		opts.ConsecutiveFailures = 5
+	}
+	if opts.MinRequests <= 0 {
+		opts.MinRequests = 20
+	}
+	if opts.Window <= 0 {
+		opts.Window = 10 * time.Second
+	}
+	if opts.OpenTimeout <= 0 {
+		opts.OpenTimeout = 5 * time.Second
+	}
+	if opts.HalfOpenProbes <= 0 {
+		opts.HalfOpenProbes = 1
+	}
+	return &circuitBreaker{opts: opts, windowStart: time.Now()}
+}
+
+// allow returns whether a write can go, every allowed write should be recorded by record.
+func (b *circuitBreaker) allow() bool {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+
+	switch b.state {
+	case circuitOpen:
+		if time.Since(b.openedAt) < b.opts.OpenTimeout {
+			return false
+		}
+		b.state, b.probes, b.succeeded = circuitHalfOpen, 0, 0
+		fallthrough
+	case circuitHalfOpen:
+		if b.probes >= b.opts.HalfOpenProbes {
+			return false
+		}
+		b.probes++
+	}
+	return true
+}
+
+// isOpen returns true if the writes are short-circuited, without taking a half-open probe.
+func (b *circuitBreaker) isOpen() bool {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+
+	switch b.state {
+	case circuitOpen:
+		return time.Since(b.openedAt) < b.opts.OpenTimeout
+	case circuitHalfOpen:
+		return b.probes >= b.opts.HalfOpenProbes
+	}
+	return false
+}
+
+// record counts the result of an allowed write.
+func (b *circuitBreaker) record(err error) {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+
+	now := time.Now()
+	switch b.state {
+	case circuitOpen:
+		// the writes allowed before the breaker opened
+		return
+	case circuitHalfOpen:
+		if err != nil {
+			b.trip(now)
+			return
+		}
+		b.succeeded++
+		if b.succeeded >= b.opts.HalfOpenProbes {
+			b.state, b.consecutive, b.requests, b.failures, b.windowStart = circuitClosed, 0, 0, 0, now
+		}
+		return
+	}
+
+	if now.Sub(b.windowStart) >= b.opts.Window {
+		b.requests, b.failures, b.windowStart = 0, 0, now
+	}
+	b.requests++
+	if err == nil {
+		b.consecutive = 0
+		return
+	}
+	b.failures++
+	b.consecutive++
+	if b.consecutive >= b.opts.ConsecutiveFailures ||
+		(b.opts.ErrorRate > 0 && b.requests >= b.opts.MinRequests &&
+			float64(b.failures)/float64(b.requests) >= b.opts.ErrorRate) {
+		b.trip(now)
+	}
+}
+
+func (b *circuitBreaker) trip(now time.Time) {
+	b.state, b.openedAt = circuitOpen, now
+}
+
+// breakerOf returns the circuit breaker of the server address @addr, or nil if
+// WithCircuitBreaker is not set.
+func (c *client) breakerOf(addr string) *circuitBreaker {
+	if c.breakerOptions == nil {
+		return nil
+	}
+
+	c.Lock()
+	defer c.Unlock()
+	if c.breakers == nil {
+		c.breakers = make(map[string]*circuitBreaker)
+	}
+	b, ok := c.breakers[addr]
+	if !ok {
+		b = newCircuitBreaker(*c.breakerOptions)
+		c.breakers[addr] = b
+	}
+	return b
+}
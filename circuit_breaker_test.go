@@ -0,0 +1,120 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package getty
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+import (
+	"github.com/stretchr/testify/assert"
+)
+
+var errBreakerTest = errors.New("write error")
+
+func TestCircuitBreakerConsecutiveFailures(t *testing.T) {
+	b := newCircuitBreaker(CircuitBreakerOptions{ConsecutiveFailures: 3, OpenTimeout: 20 * time.Millisecond})
+	for i := 0; i < 2; i++ {
+		assert.True(t, b.allow())
+		b.record(errBreakerTest)
+	}
+	// a success resets the consecutive failures
+	assert.True(t, b.allow())
+	b.record(nil)
+	for i := 0; i < 3; i++ {
+		assert.True(t, b.allow())
+		b.record(errBreakerTest)
+	}
+	assert.True(t, b.isOpen())
+	assert.False(t, b.allow())
+
+	// a failed probe opens the breaker again
+	time.Sleep(20 * time.Millisecond)
+	assert.False(t, b.isOpen())
+	assert.True(t, b.allow())
+	assert.False(t, b.allow())
+	assert.True(t, b.isOpen())
+	b.record(errBreakerTest)
+	assert.False(t, b.allow())
+
+	// a succeeded probe closes it
+	time.Sleep(20 * time.Millisecond)
+	assert.True(t, b.allow())
+	b.record(nil)
+	assert.False(t, b.isOpen())
+	assert.True(t, b.allow())
+	assert.True(t, b.allow())
+}
+
+func TestCircuitBreakerErrorRate(t *testing.T) {
+	b := newCircuitBreaker(CircuitBreakerOptions{ErrorRate: 0.5, MinRequests: 4})
+	for i := 0; i < 3; i++ {
+		assert.True(t, b.allow())
+		if i%2 == 0 {
+			b.record(errBreakerTest)
+		} else {
+			b.record(nil)
+		}
+	}
+	assert.False(t, b.isOpen())
+	b.record(nil)
+	assert.False(t, b.isOpen())
+	// 3 of 5 writes fail
+	b.record(errBreakerTest)
+	assert.True(t, b.isOpen())
+}
+
+func TestClientCircuitBreaker(t *testing.T) {
+	s := NewTCPServer(WithLocalAddress("127.0.0.1:0")).(*server)
+	defer s.Close()
+	s.RunEventLoop(func(session Session) error {
+		session.SetPkgHandler(&stringPkgHandler{})
+		session.SetEventListener(newChanMessageHandler())
+		return nil
+	})
+
+	clt := NewTCPClient(WithServerAddress(s.addr), WithConnectionNumber(1),
+		WithCircuitBreaker(CircuitBreakerOptions{ConsecutiveFailures: 1, OpenTimeout: time.Hour})).(PoolClient)
+	defer clt.Close()
+	clt.RunEventLoop(func(session Session) error {
+		session.SetPkgHandler(&stringPkgHandler{})
+		session.SetEventListener(newChanMessageHandler())
+		session.SetReadTimeout(50 * time.Millisecond)
+		return nil
+	})
+	ss, err := clt.PickSession("")
+	assert.Nil(t, err)
+	_, _, err = ss.WritePkg("hello", 0)
+	assert.Nil(t, err)
+
+	b := ss.(*session).breaker
+	assert.Equal(t, clt.(*client).breakerOf(ss.RemoteAddr()), b)
+	b.record(errBreakerTest)
+	_, _, err = ss.WritePkg("hello", 0)
+	assert.Equal(t, ErrCircuitOpen, err)
+	_, err = ss.WriteBatchPkg([]interface{}{"hello"}, 0)
+	assert.Equal(t, ErrCircuitOpen, err)
+	done := make(chan error, 1)
+	ss.WritePkgAsync("hello", 0, func(err error) { done <- err })
+	assert.Equal(t, ErrCircuitOpen, <-done)
+
+	_, err = clt.PickSession("")
+	assert.Equal(t, ErrCircuitOpen, err)
+}
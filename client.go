@@ -18,12 +18,14 @@
 package getty
 
 import (
+	"context"
 	"crypto/tls"
 	"crypto/x509"
 	"encoding/pem"
 	"fmt"
 	"io/ioutil"
 	"net"
+	"sort"
 	"strings"
 	"sync"
 	"sync/atomic"
@@ -34,7 +36,6 @@ import (
 	"github.com/dubbogo/gost/bytes"
 	"github.com/dubbogo/gost/net"
 	gxsync "github.com/dubbogo/gost/sync"
-	gxtime "github.com/dubbogo/gost/time"
 
 	"github.com/gorilla/websocket"
 
@@ -59,6 +60,31 @@ type Client interface {
 	EndPoint
 }
 
+// PoolClient is the Client of a session pool, eg: the clients built by NewTCPClient, NewUDPClient,
+// NewWSClient, NewWSSClient, NewUDSClient and NewTransportClient, pls get it by
+// client.(getty.PoolClient).
+type PoolClient interface {
+	Client
+	// PickSession returns an open session of the pool picked by the LoadBalancer set by
+	// WithLoadBalancer, @key is the key of the package for the consistent hashing.
+	PickSession(key string) (Session, error)
+	// ResizePool sets the number of the sessions of the pool, see WithMinConnNum and WithMaxConnNum.
+	ResizePool(n int)
+	// ConnectAsync fills the pool in the background, see WithLazyConnect.
+	ConnectAsync(ctx context.Context) <-chan error
+	// ConnectContext fills the pool like ConnectAsync, and waits for it until the deadline of @ctx.
+	ConnectContext(ctx context.Context) error
+	// WritePkg writes @pkg by the session of PickSession(@key), and it is resent by another session
+	// if the write fails for the connection loss and WithWriteRetry is set.
+	WritePkg(key string, pkg interface{}, timeout time.Duration) (int, int, error)
+	// Status returns the health of the server addresses, see WithHealthCheck.
+	Status() []EndpointStatus
+	// SetTrafficTap replaces the TrafficTap of WithClientTrafficTap, nil disables the tapping.
+	SetTrafficTap(tap TrafficTap)
+}
+
+var _ PoolClient = (*client)(nil)
+
 type client struct {
 	ClientOptions
 
@@ -71,6 +97,29 @@ type client struct {
 
 	newSession NewSessionCallback
 	ssMap      map[Session]struct{}
+	trafficTapper
+
+	proxyDialer *proxyDialer
+	// the re-resolved addresses of the server, guarded by the client lock
+	resolvedAddrs []string
+	// the resolver of the hostname of the server address if WithReResolveInterval is set
+	dnsResolver *DNSResolver
+	// the pool has been filled by RunEventLoop or ConnectAsync
+	filled      atomic.Bool
+	connectOnce sync.Once
+	connected   chan struct{} // closed when the pool has been filled
+	readyOnce   sync.Once
+	ready       chan struct{} // closed when the first session is open
+	// the circuit breakers of the server addresses, guarded by the client lock
+	breakers map[string]*circuitBreaker
+	limiter  *writeLimiter
+	// the sessions closed for the probe timeouts of the server addresses, guarded by the client lock
+	probeTimeouts map[string]uint64
+	// the health of the addresses of WithServerAddresses
+	failover *failover
+
+	// the transport of the clients of NewTransportClient
+	transport Transport
 
 	sync.Once
 	done chan struct{}
@@ -88,15 +137,48 @@ func newClient(t EndPointType, opts ...ClientOption) *client {
 		endPointID:   atomic.AddInt32(&clientID, 1),
 		endPointType: t,
 		done:         make(chan struct{}),
+		ready:        make(chan struct{}),
 	}
 
 	c.init(opts...)
+	c.SetTrafficTap(c.trafficTap)
 
-	if c.number <= 0 || c.addr == "" {
+	if c.maxConnNum > 0 && c.minConnNum > c.maxConnNum {
+		panic(fmt.Sprintf("client type:%s, @minConnNum:%d > @maxConnNum:%d", t, c.minConnNum, c.maxConnNum))
+	}
+	if c.number <= 0 {
+		c.number = c.minConnNum
+	}
+	if c.number > 0 && c.number < c.minConnNum {
+		c.number = c.minConnNum
+	}
+	if c.maxConnNum > 0 && c.number > c.maxConnNum {
+		c.number = c.maxConnNum
+	}
+	if c.number <= 0 || (c.addr == "" && c.resolver == nil) {
 		panic(fmt.Sprintf("client type:%s, @connNum:%d, @serverAddr:%s", t, c.number, c.addr))
 	}
 
+	if c.proxyURL != "" {
+		dialer, err := newProxyDialer(c.proxyURL, c.attemptTimeout())
+		if err != nil {
+			panic(fmt.Sprintf("client type:%s, @proxyURL:%s, err:%+v", t, c.proxyURL, err))
+		}
+		c.proxyDialer = dialer
+	}
+
+	if len(c.serverAddrs) > 0 {
+		if t != TCP_CLIENT {
+			panic(fmt.Sprintf("client type:%s does not support multiple server addresses %v", t, c.serverAddrs))
+		}
+		c.failover = newFailover(c.serverAddrs, c.failoverMaxFails)
+	}
+
+	c.limiter = newWriteLimiter(c.rateLimit, c.rateBurst, c.maxInflight)
 	c.ssMap = make(map[Session]struct{}, c.number)
+	if c.loadBalancer == nil {
+		c.loadBalancer = NewRoundRobinBalancer()
+	}
 
 	return c
 }
@@ -113,7 +195,7 @@ func NewUDPClient(opts ...ClientOption) Client {
 
 // NewWSClient builds a ws client.
 func NewWSClient(opts ...ClientOption) Client {
-	c := newClient(WS_CLIENT, opts...)
+	c := newClient(WS_CLIENT, append([]ClientOption{WithClientWSCompression(DefaultWSCompressionOptions)}, opts...)...)
 
 	if !strings.HasPrefix(c.addr, "ws://") {
 		panic(fmt.Sprintf("the prefix @serverAddr:%s is not ws://", c.addr))
@@ -124,9 +206,9 @@ func NewWSClient(opts ...ClientOption) Client {
 
 // NewWSSClient function builds a wss client.
 func NewWSSClient(opts ...ClientOption) Client {
-	c := newClient(WSS_CLIENT, opts...)
+	c := newClient(WSS_CLIENT, append([]ClientOption{WithClientWSCompression(DefaultWSCompressionOptions)}, opts...)...)
 
-	if c.cert == "" {
+	if c.cert == "" && c.tlsConfigBuilder == nil {
 		panic(fmt.Sprintf("@cert:%s", c.cert))
 	}
 	if !strings.HasPrefix(c.addr, "wss://") {
@@ -136,6 +218,26 @@ func NewWSSClient(opts ...ClientOption) Client {
 	return c
 }
 
+// NewUDSClient builds a unix domain socket client. The server path should be set by WithUnixAddress.
+func NewUDSClient(opts ...ClientOption) Client {
+	c := newClient(UDS_CLIENT, opts...)
+	c.network = "unix"
+
+	return c
+}
+
+// NewTransportClient builds a client of type @t whose sessions are the connections dialed by
+// @transport, eg: the quic, kcp and sctp clients of the modules under contrib/.
+func NewTransportClient(t EndPointType, transport Transport, opts ...ClientOption) Client {
+	if transport == nil {
+		panic(fmt.Sprintf("client type:%s, @transport is nil", t))
+	}
+	c := newClient(t, opts...)
+	c.transport = transport
+
+	return c
+}
+
 func (c *client) ID() EndPointID {
 	return c.endPointID
 }
@@ -144,40 +246,121 @@ func (c *client) EndPointType() EndPointType {
 	return c.endPointType
 }
 
+// dialAddr connects @addr through the proxy if it is configured, or else dials it directly in the
+// happy eyeballs way.
+func (c *client) dialAddr(network, addr string) (net.Conn, error) {
+	addr = c.pickAddr(addr)
+	if c.proxyDialer != nil {
+		return c.proxyDialer.Dial(network, addr)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), c.attemptTimeout())
+	defer cancel()
+	var dialer net.Dialer
+	if c.tcpFastOpen {
+		dialer.Control = fastOpenDialControl
+	}
+	return dialHappyEyeballs(ctx, &dialer, network, addr, c.connAttemptDelay)
+}
+
+// dialTCPConn dials the server, sends the PROXY header if it is configured, and then
+// does the tls handshake if ssl is enabled.
+func (c *client) dialTCPConn(network string) (net.Conn, error) {
+	var (
+		err       error
+		conn      net.Conn
+		sslConfig *tls.Config
+	)
+
+	if c.sslEnabled {
+		if sslConfig, err = c.tlsConfigBuilder.BuildTlsConfig(); err != nil {
+			return nil, perrors.Wrapf(err, "BuildTlsConfig()")
+		}
+	}
+
+	addr := c.addr
+	if c.failover != nil {
+		addr = c.failover.pick()
+	}
+	conn, err = c.dialNetwork(network, addr)
+	if c.failover != nil && c.failover.report(addr, err) {
+		endPointLogger(poolLog, c).Warnw("eject the address after consecutive failures", "addr", addr)
+	}
+	if err != nil {
+		return nil, perrors.WithStack(err)
+	}
+	conn.SetDeadline(time.Now().Add(c.attemptTimeout()))
+	defer conn.SetDeadline(time.Time{})
+
+	if c.proxyProtocolVersion != 0 {
+		if err = writeProxyHeader(conn, c.proxyProtocolVersion, conn.LocalAddr(), conn.RemoteAddr()); err != nil {
+			conn.Close()
+			return nil, perrors.WithStack(err)
+		}
+	}
+	if sslConfig != nil {
+		if sslConfig.ServerName == "" {
+			// the same as tls.DialWithDialer
+			colonPos := strings.LastIndex(addr, ":")
+			if colonPos == -1 {
+				colonPos = len(addr)
+			}
+			sslConfig = sslConfig.Clone()
+			sslConfig.ServerName = addr[:colonPos]
+		}
+		tlsConn := tls.Client(conn, sslConfig)
+		if err = tlsConn.Handshake(); err != nil {
+			conn.Close()
+			return nil, perrors.WithStack(err)
+		}
+		conn = tlsConn
+	}
+
+	return conn, nil
+}
+
 func (c *client) dialTCP() Session {
 	var (
-		err  error
-		conn net.Conn
+		err      error
+		attempts int
+		conn     net.Conn
+		network  string
+		ss       Session
 	)
 
+	network = c.network
+	if network == "" {
+		network = "tcp"
+	}
 	for {
 		if c.IsClosed() {
 			return nil
 		}
-		if c.sslEnabled {
-			if sslConfig, buildTlsConfErr := c.tlsConfigBuilder.BuildTlsConfig(); buildTlsConfErr == nil && sslConfig != nil {
-				d := &net.Dialer{Timeout: connectTimeout}
-				conn, err = tls.DialWithDialer(d, "tcp", c.addr, sslConfig)
-			}
-		} else {
-			conn, err = net.DialTimeout("tcp", c.addr, connectTimeout)
-		}
+		conn, err = c.dialTCPConn(network)
 		if err == nil && gxnet.IsSameAddr(conn.RemoteAddr(), conn.LocalAddr()) {
 			conn.Close()
 			err = errSelfConnect
 		}
 		if err == nil {
-			return newTCPSession(conn, c)
+			ss = newTCPSession(c.chaos.Wrap(conn), c)
+			if network == "unix" {
+				ss.SetName(defaultUDSSessionName)
+			}
+
+			return ss
 		}
 
-		log.Infof("net.DialTimeout(addr:%s, timeout:%v) = error:%+v", c.addr, connectTimeout, perrors.WithStack(err))
-		<-gxtime.After(connectInterval)
+		endPointLogger(poolLog, c).Infow("dial failed", "timeout", c.attemptTimeout(), "err", err)
+		if !c.retryDial(&attempts, err) {
+			return nil
+		}
 	}
 }
 
 func (c *client) dialUDP() Session {
 	var (
 		err       error
+		attempts  int
 		conn      *net.UDPConn
 		localAddr *net.UDPAddr
 		peerAddr  *net.UDPAddr
@@ -190,19 +373,26 @@ func (c *client) dialUDP() Session {
 	defer gxbytes.PutBytes(bufp)
 	buf = *bufp
 	localAddr = &net.UDPAddr{IP: net.IPv4zero, Port: 0}
-	peerAddr, _ = net.ResolveUDPAddr("udp", c.addr)
 	for {
 		if c.IsClosed() {
 			return nil
 		}
+		peerAddr, _ = net.ResolveUDPAddr("udp", c.pickAddr(c.addr))
 		conn, err = net.DialUDP("udp", localAddr, peerAddr)
 		if err == nil && gxnet.IsSameAddr(conn.RemoteAddr(), conn.LocalAddr()) {
 			conn.Close()
 			err = errSelfConnect
 		}
+		if err == nil && c.broadcast {
+			if err = setBroadcast(conn, true); err != nil {
+				conn.Close()
+			}
+		}
 		if err != nil {
-			log.Warnf("net.DialTimeout(addr:%s, timeout:%v) = error:%+v", c.addr, perrors.WithStack(err))
-			<-gxtime.After(connectInterval)
+			endPointLogger(poolLog, c).Warnw("dial failed", "timeout", c.attemptTimeout(), "err", err)
+			if !c.retryDial(&attempts, err) {
+				return nil
+			}
 			continue
 		}
 
@@ -210,8 +400,10 @@ func (c *client) dialUDP() Session {
 		conn.SetWriteDeadline(time.Now().Add(1e9))
 		if length, err = conn.Write(connectPingPackage[:]); err != nil {
 			conn.Close()
-			log.Warnf("conn.Write(%s) = {length:%d, err:%+v}", string(connectPingPackage), length, perrors.WithStack(err))
-			<-gxtime.After(connectInterval)
+			endPointLogger(poolLog, c).Warnw("write connectPingPackage failed", "length", length, "err", err)
+			if !c.retryDial(&attempts, err) {
+				return nil
+			}
 			continue
 		}
 		conn.SetReadDeadline(time.Now().Add(1e9))
@@ -220,9 +412,11 @@ func (c *client) dialUDP() Session {
 			err = nil
 		}
 		if err != nil {
-			log.Infof("conn{%#v}.Read() = {length:%d, err:%+v}", conn, length, perrors.WithStack(err))
+			endPointLogger(poolLog, c).Infow("read the reply of connectPingPackage failed", "length", length, "err", err)
 			conn.Close()
-			<-gxtime.After(connectInterval)
+			if !c.retryDial(&attempts, err) {
+				return nil
+			}
 			continue
 		}
 		return newUDPSession(conn, c)
@@ -231,25 +425,29 @@ func (c *client) dialUDP() Session {
 
 func (c *client) dialWS() Session {
 	var (
-		err    error
-		dialer websocket.Dialer
-		conn   *websocket.Conn
-		ss     Session
+		err      error
+		attempts int
+		dialer   websocket.Dialer
+		conn     *websocket.Conn
+		ss       Session
 	)
 
-	dialer.EnableCompression = true
+	dialer.EnableCompression = c.wsCompression.Enabled
+	dialer.NetDial = c.dialAddr
+	dialer.Subprotocols = c.wsSubprotocols
 	for {
 		if c.IsClosed() {
 			return nil
 		}
-		conn, _, err = dialer.Dial(c.addr, nil)
-		log.Infof("websocket.dialer.Dial(addr:%s) = error:%+v", c.addr, perrors.WithStack(err))
+		conn, _, err = dialer.Dial(c.addr, c.wsHeader)
+		endPointLogger(poolLog, c).Infow("websocket dial", "err", err)
 		if err == nil && gxnet.IsSameAddr(conn.RemoteAddr(), conn.LocalAddr()) {
 			conn.Close()
 			err = errSelfConnect
 		}
 		if err == nil {
 			ss = newWSSession(conn, c)
+			c.wsCompression.apply(conn)
 			if ss.(*session).maxMsgLen > 0 {
 				conn.SetReadLimit(int64(ss.(*session).maxMsgLen))
 			}
@@ -257,14 +455,17 @@ func (c *client) dialWS() Session {
 			return ss
 		}
 
-		log.Infof("websocket.dialer.Dial(addr:%s) = error:%+v", c.addr, perrors.WithStack(err))
-		<-gxtime.After(connectInterval)
+		endPointLogger(poolLog, c).Infow("websocket dial failed", "err", err)
+		if !c.retryDial(&attempts, err) {
+			return nil
+		}
 	}
 }
 
 func (c *client) dialWSS() Session {
 	var (
 		err      error
+		attempts int
 		root     *x509.Certificate
 		roots    []*x509.Certificate
 		certPool *x509.CertPool
@@ -274,45 +475,54 @@ func (c *client) dialWSS() Session {
 		ss       Session
 	)
 
-	dialer.EnableCompression = true
-
-	config = &tls.Config{
-		InsecureSkipVerify: true,
-	}
+	dialer.EnableCompression = c.wsCompression.Enabled
+	dialer.NetDial = c.dialAddr
+	dialer.Subprotocols = c.wsSubprotocols
 
-	if c.cert != "" {
-		certPEMBlock, err := ioutil.ReadFile(c.cert)
-		if err != nil {
-			panic(fmt.Sprintf("ioutil.ReadFile(cert:%s) = error:%+v", c.cert, perrors.WithStack(err)))
+	if c.tlsConfigBuilder != nil {
+		// the client certificate and the root CAs of the mTLS gateways
+		if config, err = c.tlsConfigBuilder.BuildTlsConfig(); err != nil {
+			panic(fmt.Sprintf("BuildTlsConfig() = error:%+v", perrors.WithStack(err)))
+		}
+	} else {
+		config = &tls.Config{
+			InsecureSkipVerify: true,
 		}
 
-		var cert tls.Certificate
-		for {
-			var certDERBlock *pem.Block
-			certDERBlock, certPEMBlock = pem.Decode(certPEMBlock)
-			if certDERBlock == nil {
-				break
+		if c.cert != "" {
+			certPEMBlock, err := ioutil.ReadFile(c.cert)
+			if err != nil {
+				panic(fmt.Sprintf("ioutil.ReadFile(cert:%s) = error:%+v", c.cert, perrors.WithStack(err)))
 			}
-			if certDERBlock.Type == "CERTIFICATE" {
-				cert.Certificate = append(cert.Certificate, certDERBlock.Bytes)
+
+			var cert tls.Certificate
+			for {
+				var certDERBlock *pem.Block
+				certDERBlock, certPEMBlock = pem.Decode(certPEMBlock)
+				if certDERBlock == nil {
+					break
+				}
+				if certDERBlock.Type == "CERTIFICATE" {
+					cert.Certificate = append(cert.Certificate, certDERBlock.Bytes)
+				}
 			}
+			config.Certificates = make([]tls.Certificate, 1)
+			config.Certificates[0] = cert
 		}
-		config.Certificates = make([]tls.Certificate, 1)
-		config.Certificates[0] = cert
-	}
 
-	certPool = x509.NewCertPool()
-	for _, c := range config.Certificates {
-		roots, err = x509.ParseCertificates(c.Certificate[len(c.Certificate)-1])
-		if err != nil {
-			panic(fmt.Sprintf("error parsing server's root cert: %+v\n", perrors.WithStack(err)))
-		}
-		for _, root = range roots {
-			certPool.AddCert(root)
+		certPool = x509.NewCertPool()
+		for _, c := range config.Certificates {
+			roots, err = x509.ParseCertificates(c.Certificate[len(c.Certificate)-1])
+			if err != nil {
+				panic(fmt.Sprintf("error parsing server's root cert: %+v\n", perrors.WithStack(err)))
+			}
+			for _, root = range roots {
+				certPool.AddCert(root)
+			}
 		}
+		config.InsecureSkipVerify = true
+		config.RootCAs = certPool
 	}
-	config.InsecureSkipVerify = true
-	config.RootCAs = certPool
 
 	// dialer.EnableCompression = true
 	dialer.TLSClientConfig = config
@@ -320,13 +530,14 @@ func (c *client) dialWSS() Session {
 		if c.IsClosed() {
 			return nil
 		}
-		conn, _, err = dialer.Dial(c.addr, nil)
+		conn, _, err = dialer.Dial(c.addr, c.wsHeader)
 		if err == nil && gxnet.IsSameAddr(conn.RemoteAddr(), conn.LocalAddr()) {
 			conn.Close()
 			err = errSelfConnect
 		}
 		if err == nil {
 			ss = newWSSession(conn, c)
+			c.wsCompression.apply(conn)
 			if ss.(*session).maxMsgLen > 0 {
 				conn.SetReadLimit(int64(ss.(*session).maxMsgLen))
 			}
@@ -335,14 +546,76 @@ func (c *client) dialWSS() Session {
 			return ss
 		}
 
-		log.Infof("websocket.dialer.Dial(addr:%s) = error:%+v", c.addr, perrors.WithStack(err))
-		<-gxtime.After(connectInterval)
+		endPointLogger(poolLog, c).Infow("websocket dial failed", "err", err)
+		if !c.retryDial(&attempts, err) {
+			return nil
+		}
+	}
+}
+
+// dialTransport dials a connection of c.transport and sends the connect ping on it.
+func (c *client) dialTransport() (net.Conn, error) {
+	var (
+		err       error
+		sslConfig *tls.Config
+		conn      net.Conn
+	)
+
+	if c.tlsConfigBuilder != nil {
+		if sslConfig, err = c.tlsConfigBuilder.BuildTlsConfig(); err != nil {
+			return nil, perrors.Wrapf(err, "BuildTlsConfig()")
+		}
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), c.attemptTimeout())
+	defer cancel()
+	if conn, err = c.transport.Dial(ctx, c.addr, sslConfig); err != nil {
+		return nil, perrors.WithStack(err)
+	}
+	if err = writeConnectPing(conn); err != nil {
+		conn.Close()
+		return nil, perrors.WithStack(err)
+	}
+
+	return conn, nil
+}
+
+func (c *client) dialStream() Session {
+	var (
+		err      error
+		attempts int
+		conn     net.Conn
+		ss       Session
+	)
+
+	for {
+		if c.IsClosed() {
+			return nil
+		}
+		conn, err = c.dialTransport()
+		if err == nil && gxnet.IsSameAddr(conn.RemoteAddr(), conn.LocalAddr()) {
+			conn.Close()
+			err = errSelfConnect
+		}
+		if err == nil {
+			ss = newTCPSession(conn, c)
+			ss.SetName(transportSessionName(c.endPointType))
+
+			return ss
+		}
+
+		endPointLogger(poolLog, c).Infow("dial failed", "timeout", c.attemptTimeout(), "err", err)
+		if !c.retryDial(&attempts, err) {
+			return nil
+		}
 	}
 }
 
 func (c *client) dial() Session {
+	if c.transport != nil {
+		return c.dialStream()
+	}
 	switch c.endPointType {
-	case TCP_CLIENT:
+	case TCP_CLIENT, UDS_CLIENT:
 		return c.dialTCP()
 	case UDP_CLIENT:
 		return c.dialUDP()
@@ -374,30 +647,94 @@ func (c *client) sessionNum() int {
 	return num
 }
 
-func (c *client) connect() {
+func (c *client) PickSession(key string) (Session, error) {
+	return c.pickSession(key, nil)
+}
+
+// pickSession picks a session except the ones of @excluded.
+func (c *client) pickSession(key string, excluded map[Session]struct{}) (Session, error) {
+	if c.lazyConnect && !c.filled.Load() {
+		c.waitFirstSession(connectTimeout)
+	}
+
+	c.Lock()
+	sessions := make([]Session, 0, len(c.ssMap))
+	var open int
+	for s := range c.ssMap {
+		if _, ok := excluded[s]; ok || s.IsClosed() {
+			continue
+		}
+		if b := s.(*session).breaker; b != nil && b.isOpen() {
+			open++
+			continue
+		}
+		sessions = append(sessions, s)
+	}
+	c.Unlock()
+	if len(sessions) == 0 {
+		if open > 0 {
+			return nil, ErrCircuitOpen
+		}
+		return nil, ErrNoSession
+	}
+
+	sort.Slice(sessions, func(i, j int) bool { return sessions[i].ID() < sessions[j].ID() })
+	return c.loadBalancer.Pick(sessions, key), nil
+}
+
+// connect adds a session to the pool, which replaces a closed session if @reconnect is true.
+// It returns false if the client is closed or the dialing attempts run out.
+func (c *client) connect(reconnect bool) bool {
 	var (
-		err error
-		ss  Session
+		err      error
+		attempts int
+		ss       Session
 	)
 
 	for {
 		ss = c.dial()
 		if ss == nil {
-			// client has been closed
-			break
+			// client has been closed, or the dialing attempts run out
+			return false
+		}
+		if c.lingerSet {
+			ss.(*session).setLinger(c.linger)
+		}
+		ss.(*session).breaker = c.breakerOf(ss.RemoteAddr())
+		ss.(*session).limiter = c.limiter
+		ss.(*session).reconnected = reconnect
+		if c.healthCheck != nil {
+			ss.(*session).health = &sessionHealth{opts: c.healthCheck}
+		}
+		if err = c.socketOptions.apply(ss.Conn()); err != nil {
+			poolLog.with(ss.Logger()).Warnw("set socket options failed", "err", err)
 		}
 		err = c.newSession(ss)
 		if err == nil {
 			ss.(*session).run()
+			if c.warmup != nil {
+				if err = c.warmup(ss); err != nil {
+					poolLog.with(ss.Logger()).Warnw("warmup failed", "err", err)
+					ss.Close()
+					if !c.retryDial(&attempts, err) {
+						return false
+					}
+					continue
+				}
+			}
 			c.Lock()
 			if c.ssMap == nil {
 				c.Unlock()
-				break
+				return false
 			}
 			c.ssMap[ss] = struct{}{}
 			c.Unlock()
 			ss.SetAttribute(sessionClientKey, c)
-			break
+			c.readyOnce.Do(func() { close(c.ready) })
+			if reconnect && c.reconnectHook.onSuccess != nil {
+				c.reconnectHook.onSuccess(ss)
+			}
+			return true
 		}
 		// don't distinguish between tcp connection and websocket connection. Because
 		// gorilla/websocket/conn.go:(Conn)Close also invoke net.Conn.Close()
@@ -416,34 +753,92 @@ func (c *client) RunEventLoop(newSession NewSessionCallback) {
 	c.Lock()
 	c.newSession = newSession
 	c.Unlock()
-	c.reConnect()
+	c.startReResolve()
+	if c.failover != nil {
+		interval := c.failoverProbeInterval
+		if interval <= 0 {
+			interval = defaultFailoverProbeInterval
+		}
+		c.wg.Add(1)
+		go c.probeEjected(interval)
+	}
+	if c.maxConnNum > 0 {
+		c.wg.Add(1)
+		go c.runPoolScaler()
+	}
+	if c.maxConnIdleTime > 0 {
+		c.wg.Add(1)
+		go c.runIdleReaper()
+	}
+	if c.healthCheck != nil {
+		c.wg.Add(1)
+		go c.runHealthCheck()
+	}
+	if c.lazyConnect {
+		return
+	}
+	if connected := c.startConnect(); connected != nil {
+		<-connected
+	}
+}
+
+// startReResolve resolves the server address at first, and then applies the changes from the
+// Resolver of WithResolver, or from the DNSResolver if WithReResolveInterval is set and the
+// server address is a hostname.
+func (c *client) startReResolve() {
+	switch c.endPointType {
+	case TCP_CLIENT, UDP_CLIENT, WS_CLIENT, WSS_CLIENT:
+	default:
+		return
+	}
+	r := c.resolver
+	if r == nil {
+		if c.reResolveInterval <= 0 || c.network == "unix" || c.failover != nil {
+			return
+		}
+		host, _, err := net.SplitHostPort(c.serverHostPort())
+		if err != nil || net.ParseIP(host) != nil {
+			return
+		}
+		c.dnsResolver = NewDNSResolver(c.serverHostPort(), c.reResolveInterval)
+		r = c.dnsResolver
+	}
+
+	addrs := endpointAddrs(r.Resolve())
+	c.Lock()
+	c.resolvedAddrs = addrs
+	c.Unlock()
+	c.wg.Add(1)
+	go c.watchResolver(r)
 }
 
-// a for-loop connect to make sure the connection pool is valid
-func (c *client) reConnect() {
+// a for-loop connect to make sure the connection pool is valid, @reconnect is true if it
+// replaces the closed sessions, or false if it fills or grows the pool.
+func (c *client) reConnect(reconnect bool) {
 	var num, max, times, interval int
 
-	max = c.number
 	interval = c.reconnectInterval
 	if interval == 0 {
 		interval = reconnectInterval
 	}
 	for {
 		if c.IsClosed() {
-			log.Warnf("client{peer:%s} goroutine exit now.", c.addr)
+			endPointLogger(poolLog, c).Warnw("reconnect goroutine exits")
 			break
 		}
 
-		num = c.sessionNum()
+		num, max = c.sessionNum(), c.poolSize()
 		if max <= num {
 			break
 		}
-		c.connect()
+		if !c.connect(reconnect) {
+			break
+		}
 		times++
 		if maxTimes < times {
 			times = maxTimes
 		}
-		<-gxtime.After(time.Duration(int64(times) * int64(interval)))
+		<-clockOrSystem(c.clock).After(time.Duration(int64(times) * int64(interval)))
 	}
 }
 
@@ -462,6 +857,16 @@ func (c *client) stop() {
 			c.ssMap = nil
 
 			c.Unlock()
+
+			if c.transport != nil {
+				if err := c.transport.Close(); err != nil {
+					endPointLogger(log, c).Warnw("close transport failed", "err", err)
+				}
+			}
+
+			if c.dnsResolver != nil {
+				c.dnsResolver.Close()
+			}
 		})
 	}
 }
@@ -0,0 +1,92 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package getty
+
+import (
+	uatomic "go.uber.org/atomic"
+)
+
+// writeLimiter caps the writing rate and the inflight packages of all the sessions of a client.
+type writeLimiter struct {
+	bucket      *tokenBucket // nil if no rate limit
+	maxInflight int32        // 0 if no inflight limit
+	inflight    uatomic.Int32
+}
+
+// newWriteLimiter returns nil if neither WithRateLimit nor WithMaxInflight is set.
+func newWriteLimiter(rps float64, burst, maxInflight int) *writeLimiter {
+	if rps <= 0 && maxInflight <= 0 {
+		return nil
+	}
+
+	l := &writeLimiter{maxInflight: int32(maxInflight)}
+	if rps > 0 {
+		l.bucket = newTokenBucket(rps, burst)
+	}
+	return l
+}
+
+// acquire admits @n packages, which should be released by release once they are written or fail.
+func (l *writeLimiter) acquire(n int) error {
+	if l.maxInflight > 0 {
+		if l.inflight.Add(int32(n)) > l.maxInflight {
+			l.inflight.Sub(int32(n))
+			return ErrInflightLimit
+		}
+	}
+	if l.bucket != nil && !l.bucket.take(n) {
+		l.release(n)
+		return ErrRateLimited
+	}
+	return nil
+}
+
+func (l *writeLimiter) release(n int) {
+	if l.maxInflight > 0 {
+		l.inflight.Sub(int32(n))
+	}
+}
+
+// admitWrite checks the write limits and the circuit breaker of a client session for @n packages,
+// and returns the function to be called with the result of the write, which is nil if there
+// is nothing to check.
+func (s *session) admitWrite(n int) (func(err error), error) {
+	if s.limiter == nil && s.breaker == nil {
+		return nil, nil
+	}
+
+	if s.limiter != nil {
+		if err := s.limiter.acquire(n); err != nil {
+			return nil, err
+		}
+	}
+	if s.breaker != nil && !s.breaker.allow() {
+		if s.limiter != nil {
+			s.limiter.release(n)
+		}
+		return nil, ErrCircuitOpen
+	}
+	return func(err error) {
+		if s.breaker != nil {
+			s.breaker.record(err)
+		}
+		if s.limiter != nil {
+			s.limiter.release(n)
+		}
+	}, nil
+}
@@ -0,0 +1,79 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package getty
+
+import (
+	"testing"
+	"time"
+)
+
+import (
+	"github.com/stretchr/testify/assert"
+)
+
+func TestWriteLimiter(t *testing.T) {
+	assert.Nil(t, newWriteLimiter(0, 0, 0))
+
+	l := newWriteLimiter(0, 0, 3)
+	assert.Nil(t, l.acquire(2))
+	assert.Equal(t, ErrInflightLimit, l.acquire(2))
+	assert.Nil(t, l.acquire(1))
+	l.release(2)
+	assert.Nil(t, l.acquire(2))
+
+	l = newWriteLimiter(1000, 2, 0)
+	assert.Nil(t, l.acquire(2))
+	assert.Equal(t, ErrRateLimited, l.acquire(1))
+	time.Sleep(5 * time.Millisecond)
+	assert.Nil(t, l.acquire(1))
+}
+
+func TestClientWriteLimit(t *testing.T) {
+	s := NewTCPServer(WithLocalAddress("127.0.0.1:0")).(*server)
+	defer s.Close()
+	s.RunEventLoop(func(session Session) error {
+		session.SetPkgHandler(&stringPkgHandler{})
+		session.SetEventListener(newChanMessageHandler())
+		return nil
+	})
+
+	clt := NewTCPClient(WithServerAddress(s.addr), WithConnectionNumber(1), WithRateLimit(0.001, 2), WithMaxInflight(8)).(PoolClient)
+	defer clt.Close()
+	clt.RunEventLoop(func(session Session) error {
+		session.SetPkgHandler(&stringPkgHandler{})
+		session.SetEventListener(newChanMessageHandler())
+		session.SetReadTimeout(50 * time.Millisecond)
+		return nil
+	})
+	ss, err := clt.PickSession("")
+	assert.Nil(t, err)
+	_, _, err = ss.WritePkg("hello", 0)
+	assert.Nil(t, err)
+	done := make(chan error, 1)
+	ss.WritePkgAsync("hello", 0, func(err error) { done <- err })
+	assert.Nil(t, <-done)
+
+	_, _, err = ss.WritePkg("hello", 0)
+	assert.Equal(t, ErrRateLimited, err)
+	_, err = ss.WriteBatchPkg([]interface{}{"hello"}, 0)
+	assert.Equal(t, ErrRateLimited, err)
+	ss.WritePkgAsync("hello", 0, func(err error) { done <- err })
+	assert.Equal(t, ErrRateLimited, <-done)
+	// all of the inflight packages are released
+	assert.Equal(t, int32(0), clt.(*client).limiter.inflight.Load())
+}
@@ -93,6 +93,21 @@ func newSessionCallback(session Session, handler *MessageHandler) error {
 	return nil
 }
 
+func TestPoolClient(t *testing.T) {
+	clt := NewTCPClient(WithServerAddress("127.0.0.1:0"), WithConnectionNumber(1))
+	defer clt.Close()
+	_, ok := clt.(PoolClient)
+	assert.True(t, ok)
+
+	// a udp endpoint works as a Client, eg: the unconnected client of the udp echo example
+	var endPoint EndPoint = NewUDPEndPoint(WithLocalAddress("127.0.0.1:0"))
+	defer endPoint.Close()
+	_, ok = endPoint.(Client)
+	assert.True(t, ok)
+	_, ok = endPoint.(PoolClient)
+	assert.False(t, ok)
+}
+
 func TestTCPClient(t *testing.T) {
 	listenLocalServer := func() (net.Listener, error) {
 		listener, err := net.Listen("tcp", ":0")
@@ -483,3 +498,41 @@ func TestNewWSSClient(t *testing.T) {
 	// server.Close()
 	// assert.True(t, server.IsClosed())
 }
+
+func TestClientWarmup(t *testing.T) {
+	s := NewTCPServer(WithLocalAddress("127.0.0.1:0")).(*server)
+	defer s.Close()
+	serverHandler := newChanMessageHandler()
+	s.RunEventLoop(func(session Session) error {
+		session.SetPkgHandler(&stringPkgHandler{})
+		session.SetEventListener(serverHandler)
+		return nil
+	})
+
+	var warmed []Session
+	clt := NewTCPClient(WithServerAddress(s.addr), WithConnectionNumber(1),
+		WithReconnectBackoff(time.Millisecond, time.Millisecond, 1, 0),
+		WithWarmupFunc(func(session Session) error {
+			warmed = append(warmed, session)
+			if len(warmed) == 1 {
+				return errSelfConnect
+			}
+			_, _, err := session.WritePkg("auth", 0)
+			return err
+		})).(PoolClient)
+	defer clt.Close()
+	clt.RunEventLoop(func(session Session) error {
+		session.SetPkgHandler(&stringPkgHandler{})
+		session.SetEventListener(newChanMessageHandler())
+		session.SetReadTimeout(50 * time.Millisecond)
+		return nil
+	})
+
+	// the session failing the warmup is reconnected
+	assert.Equal(t, 2, len(warmed))
+	assert.True(t, warmed[0].IsClosed())
+	ss, err := clt.PickSession("")
+	assert.Nil(t, err)
+	assert.Equal(t, warmed[1], ss)
+	assert.Equal(t, "auth", <-serverHandler.msgs)
+}
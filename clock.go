@@ -0,0 +1,194 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package getty
+
+import (
+	"sort"
+	"sync"
+	"time"
+)
+
+import (
+	gxtime "github.com/dubbogo/gost/time"
+)
+
+// Clock is the time source of the crons, the idle timeouts and the reconnecting backoff, see
+// WithClock and WithClientClock.
+type Clock interface {
+	Now() time.Time
+	// After waits for @d and then sends the current time on the returned channel.
+	After(d time.Duration) <-chan time.Time
+	// AfterFunc calls @f after @d.
+	AfterFunc(d time.Duration, f func()) ClockTimer
+}
+
+// ClockTimer is the timer of Clock.AfterFunc.
+type ClockTimer interface {
+	// Stop prevents the timer from firing, it returns false if the timer has fired or been stopped.
+	Stop() bool
+}
+
+type systemClock struct{}
+
+func (systemClock) Now() time.Time {
+	return time.Now()
+}
+
+func (systemClock) After(d time.Duration) <-chan time.Time {
+	return gxtime.After(d)
+}
+
+func (systemClock) AfterFunc(d time.Duration, f func()) ClockTimer {
+	return time.AfterFunc(d, f)
+}
+
+// clocked is implemented by the endpoints which have a Clock.
+type clocked interface {
+	currentClock() Clock
+}
+
+func (s *server) currentClock() Clock {
+	return s.clock
+}
+
+func (c *client) currentClock() Clock {
+	return c.clock
+}
+
+// clockOf returns the Clock of @endPoint, or nil if it uses the system clock.
+func clockOf(endPoint EndPoint) Clock {
+	if c, ok := endPoint.(clocked); ok {
+		return c.currentClock()
+	}
+
+	return nil
+}
+
+// clockOrSystem returns @clock, or the system clock if it is nil.
+func clockOrSystem(clock Clock) Clock {
+	if clock == nil {
+		return systemClock{}
+	}
+
+	return clock
+}
+
+// FakeClock is a Clock which moves only by Advance, so that the tests of the crons, the idle
+// timeouts and the reconnecting backoff run instantly and deterministically.
+type FakeClock struct {
+	lock   sync.Mutex
+	now    time.Time
+	seq    int
+	timers []*fakeTimer
+}
+
+type fakeTimer struct {
+	clock *FakeClock
+	at    time.Time
+	seq   int // orders the timers firing at the same time
+	f     func()
+}
+
+// NewFakeClock returns a FakeClock starting at @now.
+func NewFakeClock(now time.Time) *FakeClock {
+	return &FakeClock{now: now}
+}
+
+func (c *FakeClock) Now() time.Time {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	return c.now
+}
+
+func (c *FakeClock) After(d time.Duration) <-chan time.Time {
+	ch := make(chan time.Time, 1)
+	c.AfterFunc(d, func() { ch <- c.Now() })
+	return ch
+}
+
+// AfterFunc calls @f in the goroutine of the Advance which reaches @d.
+func (c *FakeClock) AfterFunc(d time.Duration, f func()) ClockTimer {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	c.seq++
+	t := &fakeTimer{clock: c, at: c.now.Add(d), seq: c.seq, f: f}
+	c.timers = append(c.timers, t)
+	return t
+}
+
+// Timers returns the number of the pending timers, eg: to wait for a goroutine to block on After.
+func (c *FakeClock) Timers() int {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	return len(c.timers)
+}
+
+// Advance moves the clock forward by @d, and fires the timers due in order, including the ones
+// added by the fired timers.
+func (c *FakeClock) Advance(d time.Duration) {
+	c.lock.Lock()
+	end := c.now.Add(d)
+	for {
+		t := c.popDue(end)
+		if t == nil {
+			break
+		}
+		if t.at.After(c.now) {
+			c.now = t.at
+		}
+		c.lock.Unlock()
+		t.f()
+		c.lock.Lock()
+	}
+	c.now = end
+	c.lock.Unlock()
+}
+
+// popDue removes and returns the earliest timer due by @end, or nil if there is none.
+func (c *FakeClock) popDue(end time.Time) *fakeTimer {
+	if len(c.timers) == 0 {
+		return nil
+	}
+	sort.Slice(c.timers, func(i, j int) bool {
+		ti, tj := c.timers[i], c.timers[j]
+		return ti.at.Before(tj.at) || (ti.at.Equal(tj.at) && ti.seq < tj.seq)
+	})
+	t := c.timers[0]
+	if t.at.After(end) {
+		return nil
+	}
+	c.timers = c.timers[1:]
+	return t
+}
+
+func (t *fakeTimer) Stop() bool {
+	c := t.clock
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	for i, pending := range c.timers {
+		if pending == t {
+			c.timers = append(c.timers[:i], c.timers[i+1:]...)
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,136 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package getty
+
+import (
+	"net"
+	"testing"
+	"time"
+)
+
+import (
+	"github.com/stretchr/testify/assert"
+
+	uatomic "go.uber.org/atomic"
+)
+
+func TestFakeClock(t *testing.T) {
+	start := time.Unix(1000, 0)
+	clock := NewFakeClock(start)
+	var fired []int
+	clock.AfterFunc(2*time.Second, func() { fired = append(fired, 2) })
+	clock.AfterFunc(time.Second, func() {
+		fired = append(fired, 1)
+		// the timer added by a fired timer is due in the same Advance
+		clock.AfterFunc(time.Second, func() { fired = append(fired, 3) })
+	})
+	stopped := clock.AfterFunc(time.Second, func() { fired = append(fired, 0) })
+	assert.True(t, stopped.Stop())
+	assert.False(t, stopped.Stop())
+	after := clock.After(5 * time.Second)
+	assert.Equal(t, 3, clock.Timers())
+
+	clock.Advance(2 * time.Second)
+	assert.Equal(t, []int{1, 2, 3}, fired)
+	assert.Equal(t, start.Add(2*time.Second), clock.Now())
+	select {
+	case <-after:
+		t.Fatal("After fires before its time")
+	default:
+	}
+	clock.Advance(3 * time.Second)
+	assert.Equal(t, start.Add(5*time.Second), <-after)
+	assert.Equal(t, 0, clock.Timers())
+}
+
+type clockMessageHandler struct {
+	*chanMessageHandler
+	crons uatomic.Int32
+	idles uatomic.Int32
+}
+
+func (h *clockMessageHandler) OnCron(session Session) {
+	h.crons.Inc()
+}
+
+func (h *clockMessageHandler) OnIdle(session Session, kind IdleKind) {
+	h.idles.Inc()
+}
+
+func TestClockSession(t *testing.T) {
+	clock := NewFakeClock(time.Now())
+	handler := &clockMessageHandler{chanMessageHandler: newChanMessageHandler()}
+	s := NewTCPServer(WithLocalAddress("127.0.0.1:0"), WithClock(clock)).(*server)
+	defer s.Close()
+	s.RunEventLoop(func(session Session) error {
+		session.SetPkgHandler(&stringPkgHandler{})
+		session.SetEventListener(handler)
+		session.SetReadTimeout(50 * time.Millisecond)
+		session.SetCronPeriod(1000)
+		return nil
+	})
+	conn, err := net.Dial("tcp", s.addr)
+	assert.Nil(t, err)
+	defer conn.Close()
+	assert.Eventually(t, func() bool { return handler.SessionNumber() == 1 }, time.Second, 10*time.Millisecond)
+	ss := handler.array[0]
+	ss.SetReadIdleTimeout(time.Minute)
+
+	clock.Advance(time.Second)
+	assert.Equal(t, int32(1), handler.crons.Load())
+	assert.Equal(t, int32(0), handler.idles.Load())
+	clock.Advance(time.Minute)
+	assert.Equal(t, int32(61), handler.crons.Load())
+	assert.Equal(t, int32(1), handler.idles.Load())
+
+	ss.SetCronPeriod(10000)
+	clock.Advance(10 * time.Second)
+	assert.Equal(t, int32(62), handler.crons.Load())
+}
+
+func TestClockReconnectBackoff(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	assert.Nil(t, err)
+	addr := ln.Addr().String()
+	ln.Close()
+
+	clock := NewFakeClock(time.Now())
+	attempts := uatomic.NewInt32(0)
+	clt := NewTCPClient(WithServerAddress(addr), WithConnectionNumber(1), WithClientClock(clock),
+		WithReconnectBackoff(time.Hour, time.Hour, 2, 0), WithReconnectMaxAttempts(3),
+		WithReconnectHook(func(Client, int, error) { attempts.Inc() }, nil, nil))
+	defer clt.Close()
+	done := make(chan struct{})
+	go func() {
+		clt.RunEventLoop(func(session Session) error { return nil })
+		close(done)
+	}()
+
+	// every hour of the backoff passes instantly
+	for i := 1; i < 3; i++ {
+		assert.Eventually(t, func() bool { return clock.Timers() == 1 }, time.Second, time.Millisecond)
+		assert.Equal(t, int32(i), attempts.Load())
+		clock.Advance(time.Hour)
+	}
+	select {
+	case <-done:
+	case <-time.After(3 * time.Second):
+		t.Fatal("the client does not give up")
+	}
+	assert.Equal(t, int32(3), attempts.Load())
+}
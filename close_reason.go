@@ -0,0 +1,182 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package getty
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net"
+	"syscall"
+)
+
+import (
+	"github.com/gorilla/websocket"
+)
+
+// ErrorKind is the class of the error which fails a session.
+type ErrorKind int
+
+const (
+	// ErrorIO means the connection fails, eg: the peer resets it.
+	ErrorIO ErrorKind = iota
+	// ErrorDecode means the codec fails to read a package, eg: a corrupted or too long package.
+	ErrorDecode
+	// ErrorTimeout means an operation of the session times out.
+	ErrorTimeout
+)
+
+func (k ErrorKind) String() string {
+	switch k {
+	case ErrorIO:
+		return "IO"
+	case ErrorDecode:
+		return "Decode"
+	case ErrorTimeout:
+		return "Timeout"
+	}
+
+	return "UnknownError"
+}
+
+// CloseReason is why a session is closed, see Session.CloseReason.
+type CloseReason int32
+
+const (
+	// CloseReasonUnknown is the reason of the open sessions.
+	CloseReasonUnknown CloseReason = iota
+	// CloseReasonLocal means the session is closed by Close, eg: by the application or the endpoint.
+	CloseReasonLocal
+	// CloseReasonPeerClosed means the peer closes the connection gracefully.
+	CloseReasonPeerClosed
+	// CloseReasonPeerReset means the peer resets the connection.
+	CloseReasonPeerReset
+	// CloseReasonIdleTimeout means the session is closed as it is idle, eg: its health probe times out.
+	CloseReasonIdleTimeout
+	// CloseReasonDrain means the session is closed by Server.GracefulClose.
+	CloseReasonDrain
+	// CloseReasonDecodeError means the codec fails to read a package.
+	CloseReasonDecodeError
+	// CloseReasonIOError means the connection fails by the other I/O errors.
+	CloseReasonIOError
+)
+
+func (r CloseReason) String() string {
+	switch r {
+	case CloseReasonUnknown:
+		return "Unknown"
+	case CloseReasonLocal:
+		return "Local"
+	case CloseReasonPeerClosed:
+		return "PeerClosed"
+	case CloseReasonPeerReset:
+		return "PeerReset"
+	case CloseReasonIdleTimeout:
+		return "IdleTimeout"
+	case CloseReasonDrain:
+		return "Drain"
+	case CloseReasonDecodeError:
+		return "DecodeError"
+	case CloseReasonIOError:
+		return "IOError"
+	}
+
+	return "UnknownReason"
+}
+
+// ClassifiedErrorListener is implemented by the EventListener which wants the class of the errors.
+// OnClassifiedError is invoked instead of OnError.
+type ClassifiedErrorListener interface {
+	OnClassifiedError(session Session, kind ErrorKind, err error)
+}
+
+// CloseReasonListener is implemented by the EventListener which wants to know why the sessions are
+// closed. OnCloseReason is invoked instead of OnClose.
+type CloseReasonListener interface {
+	OnCloseReason(session Session, reason CloseReason)
+}
+
+// ClassifyError returns the class of @err which fails a session. The errors of the connection
+// are ErrorIO, and the rest of the errors of the reading are returned by the codec.
+func ClassifyError(err error) ErrorKind {
+	var netErr net.Error
+	if errors.Is(err, ErrHandshakeTimeout) || errors.Is(err, ErrWriteExpired) ||
+		errors.Is(err, context.DeadlineExceeded) || (errors.As(err, &netErr) && netErr.Timeout()) {
+		return ErrorTimeout
+	}
+	if errors.Is(err, ErrMaxMsgLenExceeded) {
+		return ErrorDecode
+	}
+	var (
+		errno    syscall.Errno
+		closeErr *websocket.CloseError
+	)
+	if netErr != nil || errors.As(err, &errno) || errors.As(err, &closeErr) || errors.Is(err, io.EOF) ||
+		errors.Is(err, io.ErrUnexpectedEOF) || errors.Is(err, net.ErrClosed) || errors.Is(err, ErrSessionClosed) {
+		return ErrorIO
+	}
+
+	return ErrorDecode
+}
+
+// closeReasonOf returns the reason of the session failed by @err.
+func closeReasonOf(err error) CloseReason {
+	var closeErr *websocket.CloseError
+	switch {
+	case errors.As(err, &closeErr) || errors.Is(err, io.EOF):
+		return CloseReasonPeerClosed
+	case errors.Is(err, syscall.ECONNRESET) || errors.Is(err, syscall.EPIPE) || errors.Is(err, syscall.ECONNABORTED):
+		return CloseReasonPeerReset
+	case ClassifyError(err) == ErrorDecode:
+		return CloseReasonDecodeError
+	}
+
+	return CloseReasonIOError
+}
+
+// reasonListener routes OnError and OnClose of @EventListener to its ClassifiedErrorListener and
+// CloseReasonListener. It is the innermost of the interceptors.
+type reasonListener struct {
+	EventListener
+}
+
+func withReasons(listener EventListener) EventListener {
+	_, classified := listener.(ClassifiedErrorListener)
+	_, reasoned := listener.(CloseReasonListener)
+	if !classified && !reasoned {
+		return listener
+	}
+
+	return reasonListener{listener}
+}
+
+func (l reasonListener) OnError(session Session, err error) {
+	if cl, ok := l.EventListener.(ClassifiedErrorListener); ok {
+		cl.OnClassifiedError(session, ClassifyError(err), err)
+		return
+	}
+	l.EventListener.OnError(session, err)
+}
+
+func (l reasonListener) OnClose(session Session) {
+	if cl, ok := l.EventListener.(CloseReasonListener); ok {
+		cl.OnCloseReason(session, session.CloseReason())
+		return
+	}
+	l.EventListener.OnClose(session)
+}
@@ -0,0 +1,155 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package getty
+
+import (
+	"context"
+	"io"
+	"net"
+	"syscall"
+	"testing"
+	"time"
+)
+
+import (
+	perrors "github.com/pkg/errors"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// reasonHandler records the classified errors and the close reasons.
+type reasonHandler struct {
+	*chanMessageHandler
+	kinds   chan ErrorKind
+	reasons chan CloseReason
+}
+
+func newReasonHandler(handler *chanMessageHandler) *reasonHandler {
+	return &reasonHandler{chanMessageHandler: handler, kinds: make(chan ErrorKind, 1), reasons: make(chan CloseReason, 1)}
+}
+
+func (h *reasonHandler) OnClassifiedError(session Session, kind ErrorKind, err error) {
+	h.kinds <- kind
+}
+
+func (h *reasonHandler) OnCloseReason(session Session, reason CloseReason) {
+	h.reasons <- reason
+}
+
+func TestClassifyError(t *testing.T) {
+	assert.Equal(t, ErrorIO, ClassifyError(io.EOF))
+	assert.Equal(t, ErrorIO, ClassifyError(&net.OpError{Op: "read", Err: syscall.ECONNRESET}))
+	assert.Equal(t, ErrorTimeout, ClassifyError(perrors.WithStack(context.DeadlineExceeded)))
+	assert.Equal(t, ErrorTimeout, ClassifyError(perrors.Wrap(ErrHandshakeTimeout, "tls")))
+	assert.Equal(t, ErrorDecode, ClassifyError(perrors.Wrap(ErrMaxMsgLenExceeded, "pkgLen")))
+	assert.Equal(t, ErrorDecode, ClassifyError(ErrChecksumMismatch))
+
+	assert.Equal(t, CloseReasonPeerReset, closeReasonOf(&net.OpError{Op: "read", Err: syscall.ECONNRESET}))
+	assert.Equal(t, CloseReasonPeerClosed, closeReasonOf(io.EOF))
+	assert.Equal(t, CloseReasonDecodeError, closeReasonOf(ErrChecksumMismatch))
+	assert.Equal(t, CloseReasonIOError, closeReasonOf(net.ErrClosed))
+}
+
+func TestCloseReason(t *testing.T) {
+	waitReason := func(h *reasonHandler) CloseReason {
+		select {
+		case reason := <-h.reasons:
+			return reason
+		case <-time.After(3 * time.Second):
+			t.Fatal("OnCloseReason is not invoked")
+		}
+		return CloseReasonUnknown
+	}
+
+	// the too long package fails the session by a decode error
+	ss, handler := newPipeSessions(t)
+	h := newReasonHandler(handler)
+	handler.array[0].SetEventListener(h)
+	handler.array[0].SetMaxMsgLen(4)
+	_, _, err := ss.WritePkg("hello", 0)
+	assert.Nil(t, err)
+	select {
+	case kind := <-h.kinds:
+		assert.Equal(t, ErrorDecode, kind)
+	case <-time.After(3 * time.Second):
+		t.Fatal("OnClassifiedError is not invoked")
+	}
+	assert.Equal(t, CloseReasonDecodeError, waitReason(h))
+
+	// the peer closes the session
+	ss, handler = newPipeSessions(t)
+	h = newReasonHandler(handler)
+	handler.array[0].SetEventListener(h)
+	assert.Equal(t, CloseReasonUnknown, ss.CloseReason())
+	ss.Close()
+	assert.Equal(t, CloseReasonLocal, ss.CloseReason())
+	assert.Equal(t, CloseReasonPeerClosed, waitReason(h))
+
+	// the reason set first wins
+	ss, _ = newPipeSessions(t)
+	ss.CloseWithReason(CloseReasonIdleTimeout)
+	ss.Close()
+	assert.Equal(t, CloseReasonIdleTimeout, ss.CloseReason())
+}
+
+// dataEOFConn returns io.EOF together with the data it reads.
+type dataEOFConn struct {
+	net.Conn
+}
+
+func (c *dataEOFConn) Read(p []byte) (int, error) {
+	n, err := c.Conn.Read(p)
+	if n > 0 {
+		return n, io.EOF
+	}
+	return n, err
+}
+
+func TestCloseReasonDataEOF(t *testing.T) {
+	clientConn, serverConn := net.Pipe()
+	server := newPipeEndPoint(PIPE_SERVER, &dataEOFConn{Conn: serverConn})
+	defer server.Close()
+	handler := newChanMessageHandler()
+	h := newReasonHandler(handler)
+	server.RunEventLoop(func(ss Session) error {
+		ss.SetPkgHandler(&stringPkgHandler{})
+		ss.SetEventListener(h)
+		// the reason decided before the EOF is kept
+		ss.(*session).setCloseReason(CloseReasonIdleTimeout)
+		return nil
+	})
+
+	pkg, err := (&stringPkgHandler{}).Write(nil, "hello")
+	assert.Nil(t, err)
+	_, err = clientConn.Write(pkg)
+	assert.Nil(t, err)
+	select {
+	case msg := <-handler.msgs:
+		assert.Equal(t, "hello", msg)
+	case <-time.After(3 * time.Second):
+		t.Fatal("the package read with the EOF is not handled")
+	}
+
+	clientConn.Close()
+	select {
+	case reason := <-h.reasons:
+		assert.Equal(t, CloseReasonIdleTimeout, reason)
+	case <-time.After(3 * time.Second):
+		t.Fatal("OnCloseReason is not invoked")
+	}
+}
@@ -0,0 +1,219 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+// Package dubbo implements the getty ReadWriter of the Dubbo binary protocol:
+//
+//	+--------+------+--------+------------+-------------+------+
+//	| 0xdabb | flag | status | request id | body length | body |
+//	+--------+------+--------+------------+-------------+------+
+//	 2 bytes  1 byte  1 byte    8 bytes      4 bytes
+//
+// The flag holds the request, two-way and event bits and the serialization id of the body.
+package dubbo
+
+import (
+	"encoding/binary"
+	"sync"
+)
+
+import (
+	perrors "github.com/pkg/errors"
+)
+
+import (
+	getty "github.com/apache/dubbo-getty"
+)
+
+const (
+	Magic     uint16 = 0xdabb
+	HeaderLen        = 16
+
+	flagRequest  byte = 0x80
+	flagTwoWay   byte = 0x40
+	flagEvent    byte = 0x20
+	serialIDMask byte = 0x1f
+)
+
+// the serialization ids of the body
+const (
+	SerialHessian2 byte = 2
+	SerialFastJSON byte = 6
+)
+
+// the status of the responses
+const (
+	StatusOK                byte = 20
+	StatusClientTimeout     byte = 30
+	StatusServerTimeout     byte = 31
+	StatusBadRequest        byte = 40
+	StatusBadResponse       byte = 50
+	StatusServiceNotFound   byte = 60
+	StatusServiceError      byte = 70
+	StatusServerError       byte = 80
+	StatusClientError       byte = 90
+	StatusThreadpoolExhaust byte = 100
+)
+
+// Header is the header of a Dubbo frame.
+type Header struct {
+	Request bool
+	TwoWay  bool
+	// Event is set for the heartbeat frames.
+	Event    bool
+	SerialID byte
+	// Status is the status of the responses.
+	Status byte
+	ID     uint64
+}
+
+// Package is a Dubbo frame. Body is unmarshaled by the Serializer of Header.SerialID if it is
+// registered, otherwise it is the raw []byte.
+type Package struct {
+	Header Header
+	Body   interface{}
+}
+
+// IsHeartbeat returns whether @p is a heartbeat request or response.
+func (p *Package) IsHeartbeat() bool {
+	return p.Header.Event
+}
+
+// NewHeartbeatRequest returns a two-way heartbeat request.
+func NewHeartbeatRequest(id uint64, serialID byte) *Package {
+	return &Package{Header: Header{Request: true, TwoWay: true, Event: true, SerialID: serialID, ID: id}}
+}
+
+// NewHeartbeatResponse returns the response of the heartbeat request @req.
+func NewHeartbeatResponse(req *Package) *Package {
+	return &Package{Header: Header{Event: true, SerialID: req.Header.SerialID, Status: StatusOK, ID: req.Header.ID}}
+}
+
+// Serializer marshals and unmarshals the body of the Packages of a serialization id.
+type Serializer interface {
+	Marshal(p *Package) ([]byte, error)
+	Unmarshal(data []byte, p *Package) error
+}
+
+var serializers sync.Map // serialization id -> Serializer
+
+// SetSerializer registers the Serializer of @serialID.
+func SetSerializer(serialID byte, s Serializer) {
+	serializers.Store(serialID, s)
+}
+
+// GetSerializer returns the Serializer of @serialID.
+func GetSerializer(serialID byte) (Serializer, bool) {
+	s, ok := serializers.Load(serialID)
+	if !ok {
+		return nil, false
+	}
+	return s.(Serializer), true
+}
+
+// Codec is the getty ReadWriter of the Dubbo frames, which reads and writes *Package.
+type Codec struct {
+	// MaxBodyLen is the max bytes of a body, 0 means no limit.
+	MaxBodyLen int
+}
+
+var _ getty.ReadWriter = (*Codec)(nil)
+
+func (c *Codec) Read(ss getty.Session, data []byte) (interface{}, int, error) {
+	if len(data) < HeaderLen {
+		return nil, 0, nil
+	}
+	if magic := binary.BigEndian.Uint16(data); magic != Magic {
+		return nil, 0, perrors.Errorf("illegal dubbo magic %#x", magic)
+	}
+	bodyLen := binary.BigEndian.Uint32(data[12:])
+	if c.MaxBodyLen > 0 && int64(bodyLen) > int64(c.MaxBodyLen) {
+		return nil, 0, perrors.Errorf("dubbo body length %d > max body length %d", bodyLen, c.MaxBodyLen)
+	}
+	frameLen := HeaderLen + int(bodyLen)
+	if len(data) < frameLen {
+		return nil, frameLen, nil
+	}
+
+	flag := data[2]
+	p := &Package{
+		Header: Header{
+			Request:  flag&flagRequest != 0,
+			TwoWay:   flag&flagTwoWay != 0,
+			Event:    flag&flagEvent != 0,
+			SerialID: flag & serialIDMask,
+			Status:   data[3],
+			ID:       binary.BigEndian.Uint64(data[4:]),
+		},
+	}
+	body := data[HeaderLen:frameLen]
+	if s, ok := GetSerializer(p.Header.SerialID); ok {
+		if err := s.Unmarshal(body, p); err != nil {
+			return nil, 0, perrors.WithMessagef(err, "unmarshal dubbo package %d", p.Header.ID)
+		}
+	} else {
+		p.Body = append([]byte(nil), body...)
+	}
+
+	return p, frameLen, nil
+}
+
+func (c *Codec) Write(ss getty.Session, pkg interface{}) ([]byte, error) {
+	p, ok := pkg.(*Package)
+	if !ok {
+		return nil, perrors.Errorf("illegal @pkg{%#v} type", pkg)
+	}
+	if p.Header.SerialID > serialIDMask {
+		return nil, perrors.Errorf("illegal dubbo serialization id %d", p.Header.SerialID)
+	}
+
+	var (
+		err  error
+		body []byte
+	)
+	if raw, ok := p.Body.([]byte); ok {
+		body = raw
+	} else if s, ok := GetSerializer(p.Header.SerialID); ok {
+		if body, err = s.Marshal(p); err != nil {
+			return nil, perrors.WithMessagef(err, "marshal dubbo package %d", p.Header.ID)
+		}
+	} else if p.Body != nil {
+		return nil, perrors.Errorf("no serializer of the dubbo serialization id %d", p.Header.SerialID)
+	}
+	if c.MaxBodyLen > 0 && len(body) > c.MaxBodyLen {
+		return nil, perrors.Errorf("dubbo body length %d > max body length %d", len(body), c.MaxBodyLen)
+	}
+
+	flag := p.Header.SerialID
+	if p.Header.Request {
+		flag |= flagRequest
+	}
+	if p.Header.TwoWay {
+		flag |= flagTwoWay
+	}
+	if p.Header.Event {
+		flag |= flagEvent
+	}
+	buf := make([]byte, HeaderLen+len(body))
+	binary.BigEndian.PutUint16(buf, Magic)
+	buf[2] = flag
+	buf[3] = p.Header.Status
+	binary.BigEndian.PutUint64(buf[4:], p.Header.ID)
+	binary.BigEndian.PutUint32(buf[12:], uint32(len(body)))
+	copy(buf[HeaderLen:], body)
+
+	return buf, nil
+}
@@ -0,0 +1,98 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package dubbo
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+import (
+	"github.com/stretchr/testify/assert"
+)
+
+const serialTestJSON byte = 30
+
+type jsonSerializer struct{}
+
+func (jsonSerializer) Marshal(p *Package) ([]byte, error) {
+	return json.Marshal(p.Body)
+}
+
+func (jsonSerializer) Unmarshal(data []byte, p *Package) error {
+	var body map[string]string
+	if err := json.Unmarshal(data, &body); err != nil {
+		return err
+	}
+	p.Body = body
+	return nil
+}
+
+func TestCodec(t *testing.T) {
+	codec := &Codec{MaxBodyLen: 64}
+
+	req := NewHeartbeatRequest(7, SerialHessian2)
+	frame, err := codec.Write(nil, req)
+	assert.Nil(t, err)
+	assert.Equal(t, []byte{0xda, 0xbb, 0xe2, 0, 0, 0, 0, 0, 0, 0, 0, 7, 0, 0, 0, 0}, frame)
+	pkg, n, err := codec.Read(nil, frame)
+	assert.Nil(t, err)
+	assert.Equal(t, HeaderLen, n)
+	assert.True(t, pkg.(*Package).IsHeartbeat())
+	assert.Equal(t, req.Header, pkg.(*Package).Header)
+
+	rsp := NewHeartbeatResponse(pkg.(*Package))
+	frame, err = codec.Write(nil, rsp)
+	assert.Nil(t, err)
+	assert.Equal(t, []byte{0xda, 0xbb, 0x22, StatusOK}, frame[:4])
+
+	// the body is raw without the serializer
+	frame, err = codec.Write(nil, &Package{Header: Header{Request: true, SerialID: SerialHessian2, ID: 8}, Body: []byte("N")})
+	assert.Nil(t, err)
+	pkg, n, err = codec.Read(nil, frame[:HeaderLen-1])
+	assert.Nil(t, err)
+	assert.Nil(t, pkg)
+	assert.Equal(t, 0, n)
+	pkg, n, err = codec.Read(nil, frame[:HeaderLen])
+	assert.Nil(t, err)
+	assert.Nil(t, pkg)
+	assert.Equal(t, HeaderLen+1, n)
+	pkg, n, err = codec.Read(nil, frame)
+	assert.Nil(t, err)
+	assert.Equal(t, HeaderLen+1, n)
+	assert.Equal(t, []byte("N"), pkg.(*Package).Body)
+
+	// the body is dispatched to the serializer of its serialization id
+	body := map[string]string{"method": "sayHello"}
+	_, err = codec.Write(nil, &Package{Header: Header{SerialID: serialTestJSON}, Body: body})
+	assert.NotNil(t, err)
+	SetSerializer(serialTestJSON, jsonSerializer{})
+	frame, err = codec.Write(nil, &Package{Header: Header{Request: true, TwoWay: true, SerialID: serialTestJSON, ID: 9}, Body: body})
+	assert.Nil(t, err)
+	pkg, _, err = codec.Read(nil, frame)
+	assert.Nil(t, err)
+	assert.Equal(t, body, pkg.(*Package).Body)
+	assert.Equal(t, uint64(9), pkg.(*Package).Header.ID)
+
+	_, _, err = codec.Read(nil, make([]byte, HeaderLen))
+	assert.NotNil(t, err)
+	_, err = codec.Write(nil, &Package{Body: make([]byte, 65)})
+	assert.NotNil(t, err)
+	_, err = codec.Write(nil, "hello")
+	assert.NotNil(t, err)
+}
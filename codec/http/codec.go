@@ -0,0 +1,203 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+// Package http implements the getty ReadWriter of the HTTP/1.1 server side, which reads the
+// requests and writes the responses, eg: for the lightweight gateways.
+package http
+
+import (
+	"bufio"
+	"bytes"
+	"io"
+	"net/http"
+	"strconv"
+)
+
+import (
+	perrors "github.com/pkg/errors"
+)
+
+import (
+	getty "github.com/apache/dubbo-getty"
+)
+
+const (
+	DefaultMaxHeaderLen = 8 * 1024
+	DefaultMaxBodyLen   = 1024 * 1024
+)
+
+var crlf = []byte("\r\n")
+
+// Response is the response to write, whose Content-Length is set by the Codec.
+type Response struct {
+	StatusCode int
+	Header     http.Header
+	Body       []byte
+}
+
+// Codec reads the requests as *http.Request whose Body is in memory, including the pipelined
+// requests, and writes *Response or *http.Response. The handler should close the session after
+// writing the response if Close of the request is true.
+type Codec struct {
+	// MaxHeaderLen is the max bytes of the request line and the headers, the default is
+	// DefaultMaxHeaderLen.
+	MaxHeaderLen int
+	// MaxBodyLen is the max bytes of the request body, the default is DefaultMaxBodyLen.
+	MaxBodyLen int
+}
+
+var _ getty.ReadWriter = (*Codec)(nil)
+
+func (c *Codec) maxHeaderLen() int {
+	if c.MaxHeaderLen > 0 {
+		return c.MaxHeaderLen
+	}
+	return DefaultMaxHeaderLen
+}
+
+func (c *Codec) maxBodyLen() int {
+	if c.MaxBodyLen > 0 {
+		return c.MaxBodyLen
+	}
+	return DefaultMaxBodyLen
+}
+
+func (c *Codec) Read(ss getty.Session, data []byte) (interface{}, int, error) {
+	idx := bytes.Index(data, []byte("\r\n\r\n"))
+	if idx < 0 {
+		if len(data) > c.maxHeaderLen() {
+			return nil, 0, perrors.Errorf("http header length > max header length %d", c.maxHeaderLen())
+		}
+		return nil, 0, nil
+	}
+	headerLen := idx + 4
+	if headerLen > c.maxHeaderLen() {
+		return nil, 0, perrors.Errorf("http header length %d > max header length %d", headerLen, c.maxHeaderLen())
+	}
+	header, err := http.ReadRequest(bufio.NewReader(bytes.NewReader(data[:headerLen])))
+	if err != nil {
+		return nil, 0, perrors.WithStack(err)
+	}
+
+	var reqLen int
+	switch {
+	case len(header.TransferEncoding) > 0:
+		bodyLen, ok, err := chunkedLen(data[headerLen:], c.maxBodyLen())
+		if err != nil {
+			return nil, 0, err
+		}
+		if !ok {
+			return nil, 0, nil
+		}
+		reqLen = headerLen + bodyLen
+	case header.ContentLength > int64(c.maxBodyLen()):
+		return nil, 0, perrors.Errorf("http body length %d > max body length %d", header.ContentLength, c.maxBodyLen())
+	default:
+		reqLen = headerLen + int(header.ContentLength)
+		if len(data) < reqLen {
+			return nil, reqLen, nil
+		}
+	}
+
+	req, err := http.ReadRequest(bufio.NewReader(bytes.NewReader(data[:reqLen])))
+	if err != nil {
+		return nil, 0, perrors.WithStack(err)
+	}
+	body, err := io.ReadAll(req.Body)
+	if err != nil {
+		return nil, 0, perrors.WithStack(err)
+	}
+	req.Body = io.NopCloser(bytes.NewReader(body))
+	if req.ContentLength < 0 {
+		req.ContentLength = int64(len(body))
+	}
+	if ss != nil {
+		req.RemoteAddr = ss.RemoteAddr()
+	}
+
+	return req, reqLen, nil
+}
+
+// chunkedLen returns the length of the complete chunked body at the beginning of @data.
+func chunkedLen(data []byte, maxLen int) (int, bool, error) {
+	pos := 0
+	for {
+		idx := bytes.Index(data[pos:], crlf)
+		if idx < 0 {
+			if len(data) > maxLen {
+				return 0, false, perrors.Errorf("http body length > max body length %d", maxLen)
+			}
+			return 0, false, nil
+		}
+		line := data[pos : pos+idx]
+		if ext := bytes.IndexByte(line, ';'); ext >= 0 {
+			line = line[:ext]
+		}
+		size, err := strconv.ParseInt(string(bytes.TrimSpace(line)), 16, 64)
+		if err != nil || size < 0 {
+			return 0, false, perrors.Errorf("illegal http chunk size %q", line)
+		}
+		pos += idx + 2
+		if size > int64(maxLen-pos) {
+			return 0, false, perrors.Errorf("http body length > max body length %d", maxLen)
+		}
+
+		if size == 0 {
+			// the trailers end with an empty line
+			if bytes.HasPrefix(data[pos:], crlf) {
+				return pos + 2, true, nil
+			}
+			if end := bytes.Index(data[pos:], []byte("\r\n\r\n")); end >= 0 {
+				return pos + end + 4, true, nil
+			}
+			return 0, false, nil
+		}
+
+		pos += int(size) + 2
+		if len(data) < pos {
+			return 0, false, nil
+		}
+		if !bytes.Equal(data[pos-2:pos], crlf) {
+			return 0, false, perrors.New("http chunk is not ended by CRLF")
+		}
+	}
+}
+
+func (c *Codec) Write(ss getty.Session, pkg interface{}) ([]byte, error) {
+	var rsp *http.Response
+	switch p := pkg.(type) {
+	case *http.Response:
+		rsp = p
+	case *Response:
+		rsp = &http.Response{
+			StatusCode:    p.StatusCode,
+			ProtoMajor:    1,
+			ProtoMinor:    1,
+			Header:        p.Header,
+			ContentLength: int64(len(p.Body)),
+			Body:          io.NopCloser(bytes.NewReader(p.Body)),
+		}
+	default:
+		return nil, perrors.Errorf("illegal @pkg{%#v} type", pkg)
+	}
+
+	var buf bytes.Buffer
+	if err := rsp.Write(&buf); err != nil {
+		return nil, perrors.WithStack(err)
+	}
+	return buf.Bytes(), nil
+}
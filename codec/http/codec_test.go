@@ -0,0 +1,86 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package http
+
+import (
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+)
+
+import (
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCodecRead(t *testing.T) {
+	codec := &Codec{MaxHeaderLen: 128, MaxBodyLen: 64}
+
+	get := "GET /ping HTTP/1.1\r\nHost: getty\r\n\r\n"
+	post := "POST /echo HTTP/1.1\r\nHost: getty\r\nContent-Length: 5\r\n\r\nhello"
+	chunked := "POST /echo HTTP/1.1\r\nHost: getty\r\nTransfer-Encoding: chunked\r\n\r\n" +
+		"3;ext=1\r\nhel\r\n2\r\nlo\r\n0\r\nX-Trailer: 1\r\n\r\n"
+	// the pipelined requests
+	data := []byte(get + post + chunked)
+	for _, c := range []struct {
+		raw, method, body string
+	}{
+		{get, http.MethodGet, ""},
+		{post, http.MethodPost, "hello"},
+		{chunked, http.MethodPost, "hello"},
+	} {
+		for _, l := range []int{10, len(c.raw) - 1} {
+			pkg, _, err := codec.Read(nil, []byte(c.raw[:l]))
+			assert.Nil(t, err)
+			assert.Nil(t, pkg)
+		}
+		pkg, n, err := codec.Read(nil, data)
+		assert.Nil(t, err)
+		assert.Equal(t, len(c.raw), n)
+		req := pkg.(*http.Request)
+		assert.Equal(t, c.method, req.Method)
+		assert.Equal(t, "getty", req.Host)
+		body, _ := io.ReadAll(req.Body)
+		assert.Equal(t, c.body, string(body))
+		assert.Equal(t, int64(len(c.body)), req.ContentLength)
+		data = data[n:]
+	}
+
+	_, _, err := codec.Read(nil, []byte("GET / HTTP/1.1\r\nX: "+strings.Repeat("x", 128)))
+	assert.NotNil(t, err)
+	_, _, err = codec.Read(nil, []byte("POST / HTTP/1.1\r\nContent-Length: 65\r\n\r\n"))
+	assert.NotNil(t, err)
+	_, _, err = codec.Read(nil, []byte("POST / HTTP/1.1\r\nTransfer-Encoding: chunked\r\n\r\nzz\r\n"))
+	assert.NotNil(t, err)
+	_, _, err = codec.Read(nil, []byte("NOT HTTP\r\n\r\n"))
+	assert.NotNil(t, err)
+}
+
+func TestCodecWrite(t *testing.T) {
+	codec := &Codec{}
+	data, err := codec.Write(nil, &Response{
+		StatusCode: http.StatusOK,
+		Header:     http.Header{"Content-Type": []string{"text/plain"}},
+		Body:       []byte("pong"),
+	})
+	assert.Nil(t, err)
+	assert.Equal(t, "HTTP/1.1 200 OK\r\nContent-Length: 4\r\nContent-Type: text/plain\r\n\r\npong", string(data))
+
+	_, err = codec.Write(nil, "pong")
+	assert.NotNil(t, err)
+}
@@ -0,0 +1,85 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+// Package jsonl implements the getty ReadWriter of the newline-delimited JSON values.
+package jsonl
+
+import (
+	"bytes"
+	"encoding/json"
+)
+
+import (
+	perrors "github.com/pkg/errors"
+)
+
+import (
+	getty "github.com/apache/dubbo-getty"
+)
+
+// Codec reads a JSON value from every line and writes a package as a line of JSON. The blank
+// lines are skipped and the "\r\n" line endings are accepted.
+type Codec struct {
+	// New returns the pointer to unmarshal a line into, eg: func() interface{} { return &Event{} }.
+	// The lines are unmarshaled into interface{} if it is nil.
+	New func() interface{}
+	// MaxLineLen is the max bytes of a line, 0 means no limit.
+	MaxLineLen int
+}
+
+var _ getty.ReadWriter = (*Codec)(nil)
+
+func (c *Codec) Read(ss getty.Session, data []byte) (interface{}, int, error) {
+	start := len(data) - len(bytes.TrimLeft(data, " \t\r\n"))
+	idx := bytes.IndexByte(data[start:], '\n')
+	if idx < 0 {
+		if c.MaxLineLen > 0 && len(data)-start > c.MaxLineLen {
+			return nil, 0, perrors.Errorf("json line length > max line length %d", c.MaxLineLen)
+		}
+		return nil, 0, nil
+	}
+	if c.MaxLineLen > 0 && idx > c.MaxLineLen {
+		return nil, 0, perrors.Errorf("json line length %d > max line length %d", idx, c.MaxLineLen)
+	}
+
+	line := data[start : start+idx]
+	if c.New != nil {
+		v := c.New()
+		if err := json.Unmarshal(line, v); err != nil {
+			return nil, 0, perrors.WithStack(err)
+		}
+		return v, start + idx + 1, nil
+	}
+
+	var v interface{}
+	if err := json.Unmarshal(line, &v); err != nil {
+		return nil, 0, perrors.WithStack(err)
+	}
+	return v, start + idx + 1, nil
+}
+
+func (c *Codec) Write(ss getty.Session, pkg interface{}) ([]byte, error) {
+	data, err := json.Marshal(pkg)
+	if err != nil {
+		return nil, perrors.WithStack(err)
+	}
+	if c.MaxLineLen > 0 && len(data) > c.MaxLineLen {
+		return nil, perrors.Errorf("json line length %d > max line length %d", len(data), c.MaxLineLen)
+	}
+
+	return append(data, '\n'), nil
+}
@@ -0,0 +1,63 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package jsonl
+
+import (
+	"testing"
+)
+
+import (
+	"github.com/stretchr/testify/assert"
+)
+
+type event struct {
+	Name  string `json:"name"`
+	Count int    `json:"count"`
+}
+
+func TestCodec(t *testing.T) {
+	codec := &Codec{New: func() interface{} { return &event{} }, MaxLineLen: 32}
+	line, err := codec.Write(nil, &event{Name: "click", Count: 2})
+	assert.Nil(t, err)
+	assert.Equal(t, "{\"name\":\"click\",\"count\":2}\n", string(line))
+
+	pkg, n, err := codec.Read(nil, line[:10])
+	assert.Nil(t, err)
+	assert.Nil(t, pkg)
+	assert.Equal(t, 0, n)
+	data := append([]byte("\r\n\n"), line...)
+	pkg, n, err = codec.Read(nil, append(data, '{'))
+	assert.Nil(t, err)
+	assert.Equal(t, len(data), n)
+	assert.Equal(t, &event{Name: "click", Count: 2}, pkg)
+
+	// the lines are unmarshaled into interface{} without New
+	pkg, n, err = (&Codec{}).Read(nil, []byte("[1,\"a\"]\r\n"))
+	assert.Nil(t, err)
+	assert.Equal(t, 9, n)
+	assert.Equal(t, []interface{}{float64(1), "a"}, pkg)
+
+	_, _, err = codec.Read(nil, []byte("{\"name\":\"0123456789012345678901234\"}"))
+	assert.NotNil(t, err)
+	_, _, err = codec.Read(nil, []byte("{]\n"))
+	assert.NotNil(t, err)
+	_, err = codec.Write(nil, &event{Name: "0123456789012345678901234"})
+	assert.NotNil(t, err)
+	_, err = codec.Write(nil, func() {})
+	assert.NotNil(t, err)
+}
@@ -0,0 +1,404 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+// Package mqtt implements the getty ReadWriter of the MQTT 3.1.1 and 5.0 control packets, so
+// the broker logic can live in OnMessage while getty handles the connections.
+package mqtt
+
+import (
+	"encoding/binary"
+)
+
+import (
+	perrors "github.com/pkg/errors"
+
+	uatomic "go.uber.org/atomic"
+)
+
+import (
+	getty "github.com/apache/dubbo-getty"
+)
+
+// MaxRemainingLen is the max remaining length of the MQTT packets.
+const MaxRemainingLen = 268435455
+
+var errMalformed = perrors.New("malformed mqtt packet")
+
+// Codec reads *Connect, *Publish, *Subscribe and *Packet of the other types, and writes them.
+// The protocol level of the PUBLISH and SUBSCRIBE packets is the one of the CONNECT packet read
+// or written, so every session needs its own Codec.
+type Codec struct {
+	// MaxPacketLen is the max bytes of a packet, 0 means no limit.
+	MaxPacketLen int
+
+	version uatomic.Uint32
+}
+
+var _ getty.ReadWriter = (*Codec)(nil)
+
+// Version returns the protocol level of the session, which is Version311 before CONNECT.
+func (c *Codec) Version() byte {
+	if v := c.version.Load(); v != 0 {
+		return byte(v)
+	}
+	return Version311
+}
+
+func (c *Codec) Read(ss getty.Session, data []byte) (interface{}, int, error) {
+	if len(data) < 2 {
+		return nil, 0, nil
+	}
+	remaining, n, err := readVarint(data[1:])
+	if err != nil {
+		return nil, 0, err
+	}
+	if n == 0 {
+		return nil, 0, nil
+	}
+	packetLen := 1 + n + remaining
+	if c.MaxPacketLen > 0 && packetLen > c.MaxPacketLen {
+		return nil, 0, perrors.Errorf("mqtt packet length %d > max packet length %d", packetLen, c.MaxPacketLen)
+	}
+	if len(data) < packetLen {
+		return nil, packetLen, nil
+	}
+
+	typ, flags := PacketType(data[0]>>4), data[0]&0x0f
+	body := data[1+n : packetLen]
+	var pkg interface{}
+	switch typ {
+	case CONNECT:
+		pkg, err = c.decodeConnect(body)
+	case PUBLISH:
+		pkg, err = c.decodePublish(flags, body)
+	case SUBSCRIBE:
+		if flags != 0x02 {
+			return nil, 0, perrors.Errorf("illegal mqtt SUBSCRIBE flags %#x", flags)
+		}
+		pkg, err = c.decodeSubscribe(body)
+	case 0:
+		err = perrors.New("reserved mqtt packet type")
+	default:
+		pkg = &Packet{Type: typ, Flags: flags, Body: append([]byte{}, body...)}
+	}
+	if err != nil {
+		return nil, 0, perrors.WithMessagef(err, "decode mqtt %s", typ)
+	}
+
+	return pkg, packetLen, nil
+}
+
+func (c *Codec) Write(ss getty.Session, pkg interface{}) ([]byte, error) {
+	var (
+		typ   PacketType
+		flags byte
+		body  []byte
+	)
+	switch p := pkg.(type) {
+	case *Connect:
+		typ, body = CONNECT, c.encodeConnect(p)
+	case *Publish:
+		if p.QoS > 2 {
+			return nil, perrors.Errorf("illegal mqtt QoS %d", p.QoS)
+		}
+		typ, body = PUBLISH, c.encodePublish(p)
+		flags = p.QoS << 1
+		if p.Dup {
+			flags |= 0x08
+		}
+		if p.Retain {
+			flags |= 0x01
+		}
+	case *Subscribe:
+		typ, flags, body = SUBSCRIBE, 0x02, c.encodeSubscribe(p)
+	case *Packet:
+		typ, flags, body = p.Type, p.Flags&0x0f, p.Body
+	default:
+		return nil, perrors.Errorf("illegal @pkg{%#v} type", pkg)
+	}
+	if len(body) > MaxRemainingLen {
+		return nil, perrors.Errorf("mqtt remaining length %d > %d", len(body), MaxRemainingLen)
+	}
+
+	buf := make([]byte, 0, 5+len(body))
+	buf = append(buf, byte(typ)<<4|flags)
+	buf = appendVarint(buf, len(body))
+	buf = append(buf, body...)
+	if c.MaxPacketLen > 0 && len(buf) > c.MaxPacketLen {
+		return nil, perrors.Errorf("mqtt packet length %d > max packet length %d", len(buf), c.MaxPacketLen)
+	}
+
+	return buf, nil
+}
+
+func (c *Codec) decodeConnect(body []byte) (*Connect, error) {
+	r := &decoder{b: body}
+	p := &Connect{ProtocolName: r.string(), ProtocolLevel: r.byte()}
+	flags := r.byte()
+	p.KeepAlive = r.uint16()
+	if r.err == nil && p.ProtocolLevel == Version5 {
+		p.Properties = r.properties()
+	}
+	p.CleanSession = flags&0x02 != 0
+	p.WillFlag = flags&0x04 != 0
+	p.WillQoS = flags >> 3 & 0x03
+	p.WillRetain = flags&0x20 != 0
+	p.PasswordFlag = flags&0x40 != 0
+	p.UsernameFlag = flags&0x80 != 0
+
+	p.ClientID = r.string()
+	if p.WillFlag {
+		if p.ProtocolLevel == Version5 {
+			p.WillProperties = r.properties()
+		}
+		p.WillTopic = r.string()
+		p.WillPayload = r.binary()
+	}
+	if p.UsernameFlag {
+		p.Username = r.string()
+	}
+	if p.PasswordFlag {
+		p.Password = r.binary()
+	}
+	if err := r.done(); err != nil {
+		return nil, err
+	}
+
+	c.version.Store(uint32(p.ProtocolLevel))
+	return p, nil
+}
+
+func (c *Codec) encodeConnect(p *Connect) []byte {
+	name, level := p.ProtocolName, p.ProtocolLevel
+	if name == "" {
+		name = "MQTT"
+	}
+	if level == 0 {
+		level = Version311
+	}
+	c.version.Store(uint32(level))
+
+	flags := p.WillQoS & 0x03 << 3
+	for _, f := range []struct {
+		set bool
+		bit byte
+	}{
+		{p.CleanSession, 0x02}, {p.WillFlag, 0x04}, {p.WillRetain, 0x20},
+		{p.PasswordFlag, 0x40}, {p.UsernameFlag, 0x80},
+	} {
+		if f.set {
+			flags |= f.bit
+		}
+	}
+
+	b := appendString(nil, name)
+	b = append(b, level, flags)
+	b = binary.BigEndian.AppendUint16(b, p.KeepAlive)
+	if level == Version5 {
+		b = appendProperties(b, p.Properties)
+	}
+	b = appendString(b, p.ClientID)
+	if p.WillFlag {
+		if level == Version5 {
+			b = appendProperties(b, p.WillProperties)
+		}
+		b = appendString(b, p.WillTopic)
+		b = appendBinary(b, p.WillPayload)
+	}
+	if p.UsernameFlag {
+		b = appendString(b, p.Username)
+	}
+	if p.PasswordFlag {
+		b = appendBinary(b, p.Password)
+	}
+	return b
+}
+
+func (c *Codec) decodePublish(flags byte, body []byte) (*Publish, error) {
+	p := &Publish{Dup: flags&0x08 != 0, QoS: flags >> 1 & 0x03, Retain: flags&0x01 != 0}
+	if p.QoS > 2 {
+		return nil, perrors.Errorf("illegal mqtt QoS %d", p.QoS)
+	}
+
+	r := &decoder{b: body}
+	p.Topic = r.string()
+	if p.QoS > 0 {
+		p.PacketID = r.uint16()
+	}
+	if r.err == nil && c.Version() == Version5 {
+		p.Properties = r.properties()
+	}
+	if r.err != nil {
+		return nil, r.err
+	}
+	p.Payload = append([]byte{}, r.b...)
+	return p, nil
+}
+
+func (c *Codec) encodePublish(p *Publish) []byte {
+	b := appendString(nil, p.Topic)
+	if p.QoS > 0 {
+		b = binary.BigEndian.AppendUint16(b, p.PacketID)
+	}
+	if c.Version() == Version5 {
+		b = appendProperties(b, p.Properties)
+	}
+	return append(b, p.Payload...)
+}
+
+func (c *Codec) decodeSubscribe(body []byte) (*Subscribe, error) {
+	r := &decoder{b: body}
+	p := &Subscribe{PacketID: r.uint16()}
+	if r.err == nil && c.Version() == Version5 {
+		p.Properties = r.properties()
+	}
+	for r.err == nil && len(r.b) > 0 {
+		p.Subscriptions = append(p.Subscriptions, Subscription{Topic: r.string(), Options: r.byte()})
+	}
+	if err := r.done(); err != nil {
+		return nil, err
+	}
+	if len(p.Subscriptions) == 0 {
+		return nil, perrors.New("no topic filter")
+	}
+	return p, nil
+}
+
+func (c *Codec) encodeSubscribe(p *Subscribe) []byte {
+	b := binary.BigEndian.AppendUint16(nil, p.PacketID)
+	if c.Version() == Version5 {
+		b = appendProperties(b, p.Properties)
+	}
+	for _, s := range p.Subscriptions {
+		b = appendString(b, s.Topic)
+		b = append(b, s.Options)
+	}
+	return b
+}
+
+// readVarint reads the variable byte integer, the returned length is 0 if @b is incomplete.
+func readVarint(b []byte) (int, int, error) {
+	var v int
+	for i := 0; i < 4; i++ {
+		if i == len(b) {
+			return 0, 0, nil
+		}
+		v |= int(b[i]&0x7f) << (7 * i)
+		if b[i]&0x80 == 0 {
+			return v, i + 1, nil
+		}
+	}
+	return 0, 0, perrors.New("malformed mqtt variable byte integer")
+}
+
+func appendVarint(b []byte, v int) []byte {
+	for {
+		c := byte(v & 0x7f)
+		if v >>= 7; v > 0 {
+			c |= 0x80
+		}
+		b = append(b, c)
+		if v == 0 {
+			return b
+		}
+	}
+}
+
+func appendString(b []byte, s string) []byte {
+	b = binary.BigEndian.AppendUint16(b, uint16(len(s)))
+	return append(b, s...)
+}
+
+func appendBinary(b []byte, data []byte) []byte {
+	b = binary.BigEndian.AppendUint16(b, uint16(len(data)))
+	return append(b, data...)
+}
+
+func appendProperties(b []byte, props []byte) []byte {
+	b = appendVarint(b, len(props))
+	return append(b, props...)
+}
+
+// decoder reads the fields of a packet body, the first error stops the reading.
+type decoder struct {
+	b   []byte
+	err error
+}
+
+func (r *decoder) next(n int) []byte {
+	if r.err != nil {
+		return nil
+	}
+	if len(r.b) < n {
+		r.err = errMalformed
+		return nil
+	}
+	data := r.b[:n]
+	r.b = r.b[n:]
+	return data
+}
+
+func (r *decoder) byte() byte {
+	if b := r.next(1); b != nil {
+		return b[0]
+	}
+	return 0
+}
+
+func (r *decoder) uint16() uint16 {
+	if b := r.next(2); b != nil {
+		return binary.BigEndian.Uint16(b)
+	}
+	return 0
+}
+
+func (r *decoder) binary() []byte {
+	n := r.uint16()
+	if b := r.next(int(n)); b != nil {
+		return append([]byte{}, b...)
+	}
+	return nil
+}
+
+func (r *decoder) string() string {
+	n := r.uint16()
+	return string(r.next(int(n)))
+}
+
+func (r *decoder) properties() []byte {
+	if r.err != nil {
+		return nil
+	}
+	n, size, err := readVarint(r.b)
+	if err != nil || size == 0 {
+		r.err = errMalformed
+		return nil
+	}
+	r.b = r.b[size:]
+	if b := r.next(n); b != nil {
+		return append([]byte{}, b...)
+	}
+	return nil
+}
+
+// done returns the error or errMalformed if there are bytes left.
+func (r *decoder) done() error {
+	if r.err == nil && len(r.b) > 0 {
+		return errMalformed
+	}
+	return r.err
+}
@@ -0,0 +1,125 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package mqtt
+
+import (
+	"strings"
+	"testing"
+)
+
+import (
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCodec311(t *testing.T) {
+	codec := &Codec{}
+	// CONNECT of client "c1" with the clean session and 60s keepalive
+	data := []byte{0x10, 14, 0, 4, 'M', 'Q', 'T', 'T', 4, 0x02, 0, 60, 0, 2, 'c', '1'}
+	for _, l := range []int{0, 1, 5} {
+		pkg, _, err := codec.Read(nil, data[:l])
+		assert.Nil(t, err)
+		assert.Nil(t, pkg)
+	}
+	pkg, n, err := codec.Read(nil, data)
+	assert.Nil(t, err)
+	assert.Equal(t, len(data), n)
+	connect := &Connect{ProtocolName: "MQTT", ProtocolLevel: Version311, CleanSession: true, KeepAlive: 60, ClientID: "c1"}
+	assert.Equal(t, connect, pkg)
+	frame, err := codec.Write(nil, connect)
+	assert.Nil(t, err)
+	assert.Equal(t, data, frame)
+
+	publish := &Publish{QoS: 1, Retain: true, Topic: "a/b", PacketID: 10, Payload: []byte(strings.Repeat("x", 200))}
+	frame, err = codec.Write(nil, publish)
+	assert.Nil(t, err)
+	// the 2-byte remaining length of 207
+	assert.Equal(t, []byte{0x33, 0xcf, 0x01}, frame[:3])
+	pkg, n, err = codec.Read(nil, frame)
+	assert.Nil(t, err)
+	assert.Equal(t, len(frame), n)
+	assert.Equal(t, publish, pkg)
+
+	subscribe := &Subscribe{PacketID: 11, Subscriptions: []Subscription{{"a/#", 1}, {"b/+", 0}}}
+	frame, err = codec.Write(nil, subscribe)
+	assert.Nil(t, err)
+	assert.Equal(t, byte(0x82), frame[0])
+	pkg, _, err = codec.Read(nil, frame)
+	assert.Nil(t, err)
+	assert.Equal(t, subscribe, pkg)
+
+	frame, err = codec.Write(nil, &Packet{Type: PINGREQ})
+	assert.Nil(t, err)
+	assert.Equal(t, []byte{0xc0, 0}, frame)
+	pkg, _, err = codec.Read(nil, frame)
+	assert.Nil(t, err)
+	assert.Equal(t, &Packet{Type: PINGREQ, Body: []byte{}}, pkg)
+	assert.Equal(t, "PINGREQ", pkg.(*Packet).Type.String())
+
+	_, _, err = codec.Read(nil, []byte{0x30, 0xff, 0xff, 0xff, 0xff})
+	assert.NotNil(t, err)
+	_, _, err = codec.Read(nil, []byte{0x80, 3, 0, 1, 'a'})
+	assert.NotNil(t, err)
+	_, _, err = codec.Read(nil, []byte{0x36, 3, 0, 1, 'a'})
+	assert.NotNil(t, err)
+	_, _, err = (&Codec{MaxPacketLen: 8}).Read(nil, []byte{0x30, 9})
+	assert.NotNil(t, err)
+}
+
+func TestCodec5(t *testing.T) {
+	server, client := &Codec{}, &Codec{}
+	connect := &Connect{
+		ProtocolName:   "MQTT",
+		ProtocolLevel:  Version5,
+		KeepAlive:      30,
+		Properties:     []byte{0x11, 0, 0, 0, 10}, // session expiry interval
+		ClientID:       "c5",
+		WillFlag:       true,
+		WillQoS:        1,
+		WillProperties: []byte{},
+		WillTopic:      "will",
+		WillPayload:    []byte("bye"),
+		UsernameFlag:   true,
+		Username:       "user",
+		PasswordFlag:   true,
+		Password:       []byte("pass"),
+	}
+	frame, err := client.Write(nil, connect)
+	assert.Nil(t, err)
+	assert.Equal(t, Version5, client.Version())
+	assert.Equal(t, Version311, server.Version())
+	pkg, _, err := server.Read(nil, frame)
+	assert.Nil(t, err)
+	assert.Equal(t, connect, pkg)
+	assert.Equal(t, Version5, server.Version())
+
+	// the properties of PUBLISH and SUBSCRIBE follow the CONNECT version
+	publish := &Publish{Topic: "t", Properties: []byte{0x01, 1}, Payload: []byte("hi")}
+	frame, err = client.Write(nil, publish)
+	assert.Nil(t, err)
+	assert.Equal(t, []byte{0x30, 8, 0, 1, 't', 2, 0x01, 1, 'h', 'i'}, frame)
+	pkg, _, err = server.Read(nil, frame)
+	assert.Nil(t, err)
+	assert.Equal(t, publish, pkg)
+
+	subscribe := &Subscribe{PacketID: 1, Properties: []byte{}, Subscriptions: []Subscription{{"t", 0x2c}}}
+	frame, err = client.Write(nil, subscribe)
+	assert.Nil(t, err)
+	pkg, _, err = server.Read(nil, frame)
+	assert.Nil(t, err)
+	assert.Equal(t, subscribe, pkg)
+}
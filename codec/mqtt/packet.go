@@ -0,0 +1,118 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package mqtt
+
+// PacketType is the type of the MQTT control packets.
+type PacketType byte
+
+const (
+	CONNECT PacketType = iota + 1
+	CONNACK
+	PUBLISH
+	PUBACK
+	PUBREC
+	PUBREL
+	PUBCOMP
+	SUBSCRIBE
+	SUBACK
+	UNSUBSCRIBE
+	UNSUBACK
+	PINGREQ
+	PINGRESP
+	DISCONNECT
+	// AUTH is only in MQTT 5.0.
+	AUTH
+)
+
+var packetTypeStrings = [...]string{
+	"RESERVED", "CONNECT", "CONNACK", "PUBLISH", "PUBACK", "PUBREC", "PUBREL", "PUBCOMP",
+	"SUBSCRIBE", "SUBACK", "UNSUBSCRIBE", "UNSUBACK", "PINGREQ", "PINGRESP", "DISCONNECT", "AUTH",
+}
+
+func (t PacketType) String() string {
+	if int(t) < len(packetTypeStrings) {
+		return packetTypeStrings[t]
+	}
+	return "UNKNOWN"
+}
+
+// the protocol levels
+const (
+	Version311 byte = 4
+	Version5   byte = 5
+)
+
+// Packet is a control packet which is not decoded, whose Body is the variable header
+// and the payload.
+type Packet struct {
+	Type  PacketType
+	Flags byte
+	Body  []byte
+}
+
+// Connect is the CONNECT packet.
+type Connect struct {
+	ProtocolName  string
+	ProtocolLevel byte
+	CleanSession  bool
+	KeepAlive     uint16
+	// Properties is the encoded properties of MQTT 5.0, without the length.
+	Properties []byte
+	ClientID   string
+
+	WillFlag   bool
+	WillQoS    byte
+	WillRetain bool
+	// WillProperties is the encoded will properties of MQTT 5.0, without the length.
+	WillProperties []byte
+	WillTopic      string
+	WillPayload    []byte
+
+	UsernameFlag bool
+	Username     string
+	PasswordFlag bool
+	Password     []byte
+}
+
+// Publish is the PUBLISH packet.
+type Publish struct {
+	Dup    bool
+	QoS    byte
+	Retain bool
+	Topic  string
+	// PacketID is only present if QoS > 0.
+	PacketID uint16
+	// Properties is the encoded properties of MQTT 5.0, without the length.
+	Properties []byte
+	Payload    []byte
+}
+
+// Subscription is a topic filter of the SUBSCRIBE packet.
+type Subscription struct {
+	Topic string
+	// Options is the requested QoS of MQTT 3.1.1, or the subscription options of MQTT 5.0.
+	Options byte
+}
+
+// Subscribe is the SUBSCRIBE packet.
+type Subscribe struct {
+	PacketID uint16
+	// Properties is the encoded properties of MQTT 5.0, without the length.
+	Properties    []byte
+	Subscriptions []Subscription
+}
@@ -0,0 +1,66 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+// Package msgpack implements the getty ReadWriter of the msgpack values prefixed by their
+// 4-byte big endian length.
+package msgpack
+
+import (
+	perrors "github.com/pkg/errors"
+)
+
+import (
+	getty "github.com/apache/dubbo-getty"
+)
+
+// Codec reads and writes the values supported by Marshal and Unmarshal.
+type Codec struct {
+	// MaxLen is the max bytes of a msgpack value, 0 means no limit.
+	MaxLen int
+}
+
+var _ getty.ReadWriter = (*Codec)(nil)
+
+func (c *Codec) frameCodec() *getty.LengthFieldCodec {
+	codec := &getty.LengthFieldCodec{LengthFieldSize: 4}
+	if c.MaxLen > 0 {
+		codec.MaxFrameLen = c.MaxLen + 4
+	}
+	return codec
+}
+
+func (c *Codec) Read(ss getty.Session, data []byte) (interface{}, int, error) {
+	frame, frameLen, err := c.frameCodec().Read(ss, data)
+	if err != nil || frame == nil {
+		return nil, frameLen, err
+	}
+
+	v, err := Unmarshal(frame.([]byte))
+	if err != nil {
+		return nil, 0, perrors.WithMessage(err, "msgpack unmarshal")
+	}
+	return v, frameLen, nil
+}
+
+func (c *Codec) Write(ss getty.Session, pkg interface{}) ([]byte, error) {
+	data, err := Marshal(pkg)
+	if err != nil {
+		return nil, err
+	}
+
+	return c.frameCodec().Write(ss, data)
+}
@@ -0,0 +1,96 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package msgpack
+
+import (
+	"math"
+	"strings"
+	"testing"
+)
+
+import (
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMarshal(t *testing.T) {
+	for _, c := range []struct {
+		v    interface{}
+		data []byte
+	}{
+		{nil, []byte{0xc0}},
+		{true, []byte{0xc3}},
+		{7, []byte{0x07}},
+		{-1, []byte{0xff}},
+		{200, []byte{0xcc, 0xc8}},
+		{-200, []byte{0xd1, 0xff, 0x38}},
+		{1.5, []byte{0xcb, 0x3f, 0xf8, 0, 0, 0, 0, 0, 0}},
+		{"hi", []byte{0xa2, 'h', 'i'}},
+		{[]byte{1}, []byte{0xc4, 1, 1}},
+		{[]interface{}{1, "a"}, []byte{0x92, 1, 0xa1, 'a'}},
+		{map[string]interface{}{"b": 2, "a": 1}, []byte{0x82, 0xa1, 'a', 1, 0xa1, 'b', 2}},
+	} {
+		data, err := Marshal(c.v)
+		assert.Nil(t, err)
+		assert.Equal(t, c.data, data)
+	}
+
+	v := map[string]interface{}{
+		"int":    []interface{}{int64(-33), int64(math.MinInt32), int64(math.MaxInt64), uint64(math.MaxUint64)},
+		"float":  float32(0.5),
+		"str":    strings.Repeat("s", 300),
+		"bin":    make([]byte, 70000),
+		"nested": map[interface{}]interface{}{int64(1): "one", "two": false},
+	}
+	data, err := Marshal(v)
+	assert.Nil(t, err)
+	u, err := Unmarshal(data)
+	assert.Nil(t, err)
+	assert.Equal(t, v, u)
+
+	_, err = Marshal(struct{}{})
+	assert.NotNil(t, err)
+	_, err = Unmarshal(data[:len(data)-1])
+	assert.NotNil(t, err)
+	_, err = Unmarshal([]byte{0xdd, 0xff, 0xff, 0xff, 0xff})
+	assert.NotNil(t, err)
+	_, err = Unmarshal([]byte{0xd4, 0, 0})
+	assert.NotNil(t, err)
+	_, err = Unmarshal([]byte{0xc0, 0xc0})
+	assert.NotNil(t, err)
+}
+
+func TestCodec(t *testing.T) {
+	codec := &Codec{MaxLen: 16}
+	frame, err := codec.Write(nil, []interface{}{"ping", 1})
+	assert.Nil(t, err)
+	assert.Equal(t, []byte{0, 0, 0, 7, 0x92, 0xa4, 'p', 'i', 'n', 'g', 1}, frame)
+
+	pkg, n, err := codec.Read(nil, frame[:6])
+	assert.Nil(t, err)
+	assert.Nil(t, pkg)
+	assert.Equal(t, len(frame), n)
+	pkg, n, err = codec.Read(nil, frame)
+	assert.Nil(t, err)
+	assert.Equal(t, len(frame), n)
+	assert.Equal(t, []interface{}{"ping", int64(1)}, pkg)
+
+	_, err = codec.Write(nil, strings.Repeat("x", 16))
+	assert.NotNil(t, err)
+	_, _, err = codec.Read(nil, []byte{0, 0, 0, 1, 0xc1})
+	assert.NotNil(t, err)
+}
@@ -0,0 +1,354 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package msgpack
+
+import (
+	"encoding/binary"
+	"math"
+	"sort"
+)
+
+import (
+	perrors "github.com/pkg/errors"
+)
+
+// maxDepth is the max nesting depth of the arrays and the maps.
+const maxDepth = 64
+
+var errTruncated = perrors.New("msgpack data is truncated")
+
+// Marshal returns the msgpack encoding of @v, which is built of nil, bool, the integers, float32,
+// float64, string, []byte, []interface{}, map[string]interface{} and map[interface{}]interface{}.
+// The keys of map[string]interface{} are sorted.
+func Marshal(v interface{}) ([]byte, error) {
+	return appendValue(nil, v, 0)
+}
+
+// Unmarshal decodes the msgpack @data. The integers are decoded as int64, or uint64 if it
+// overflows int64, and the maps as map[string]interface{}, or map[interface{}]interface{}
+// if any key is not a string. The extension types are not supported.
+func Unmarshal(data []byte) (interface{}, error) {
+	v, rest, err := decodeValue(data, 0)
+	if err != nil {
+		return nil, err
+	}
+	if len(rest) != 0 {
+		return nil, perrors.Errorf("%d bytes left after the msgpack value", len(rest))
+	}
+	return v, nil
+}
+
+func appendValue(b []byte, v interface{}, depth int) ([]byte, error) {
+	if depth > maxDepth {
+		return nil, perrors.New("msgpack value is nested too deeply")
+	}
+
+	var err error
+	switch x := v.(type) {
+	case nil:
+		return append(b, 0xc0), nil
+	case bool:
+		if x {
+			return append(b, 0xc3), nil
+		}
+		return append(b, 0xc2), nil
+	case int:
+		return appendInt(b, int64(x)), nil
+	case int8:
+		return appendInt(b, int64(x)), nil
+	case int16:
+		return appendInt(b, int64(x)), nil
+	case int32:
+		return appendInt(b, int64(x)), nil
+	case int64:
+		return appendInt(b, x), nil
+	case uint:
+		return appendUint(b, uint64(x)), nil
+	case uint8:
+		return appendUint(b, uint64(x)), nil
+	case uint16:
+		return appendUint(b, uint64(x)), nil
+	case uint32:
+		return appendUint(b, uint64(x)), nil
+	case uint64:
+		return appendUint(b, x), nil
+	case float32:
+		return binary.BigEndian.AppendUint32(append(b, 0xca), math.Float32bits(x)), nil
+	case float64:
+		return binary.BigEndian.AppendUint64(append(b, 0xcb), math.Float64bits(x)), nil
+	case string:
+		b = appendLen(b, len(x), 0xa0, 32, 0xd9, 0xda, 0xdb)
+		return append(b, x...), nil
+	case []byte:
+		b = appendLen(b, len(x), 0, 0, 0xc4, 0xc5, 0xc6)
+		return append(b, x...), nil
+	case []interface{}:
+		b = appendLen(b, len(x), 0x90, 16, 0, 0xdc, 0xdd)
+		for _, e := range x {
+			if b, err = appendValue(b, e, depth+1); err != nil {
+				return nil, err
+			}
+		}
+		return b, nil
+	case map[string]interface{}:
+		keys := make([]string, 0, len(x))
+		for k := range x {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		b = appendLen(b, len(x), 0x80, 16, 0, 0xde, 0xdf)
+		for _, k := range keys {
+			b = appendLen(b, len(k), 0xa0, 32, 0xd9, 0xda, 0xdb)
+			b = append(b, k...)
+			if b, err = appendValue(b, x[k], depth+1); err != nil {
+				return nil, err
+			}
+		}
+		return b, nil
+	case map[interface{}]interface{}:
+		b = appendLen(b, len(x), 0x80, 16, 0, 0xde, 0xdf)
+		for k, e := range x {
+			if b, err = appendValue(b, k, depth+1); err != nil {
+				return nil, err
+			}
+			if b, err = appendValue(b, e, depth+1); err != nil {
+				return nil, err
+			}
+		}
+		return b, nil
+	default:
+		return nil, perrors.Errorf("msgpack can not encode %T", v)
+	}
+}
+
+func appendInt(b []byte, x int64) []byte {
+	switch {
+	case x >= 0:
+		return appendUint(b, uint64(x))
+	case x >= -32:
+		return append(b, byte(x))
+	case x >= math.MinInt8:
+		return append(b, 0xd0, byte(x))
+	case x >= math.MinInt16:
+		return binary.BigEndian.AppendUint16(append(b, 0xd1), uint16(x))
+	case x >= math.MinInt32:
+		return binary.BigEndian.AppendUint32(append(b, 0xd2), uint32(x))
+	default:
+		return binary.BigEndian.AppendUint64(append(b, 0xd3), uint64(x))
+	}
+}
+
+func appendUint(b []byte, x uint64) []byte {
+	switch {
+	case x < 128:
+		return append(b, byte(x))
+	case x <= math.MaxUint8:
+		return append(b, 0xcc, byte(x))
+	case x <= math.MaxUint16:
+		return binary.BigEndian.AppendUint16(append(b, 0xcd), uint16(x))
+	case x <= math.MaxUint32:
+		return binary.BigEndian.AppendUint32(append(b, 0xce), uint32(x))
+	default:
+		return binary.BigEndian.AppendUint64(append(b, 0xcf), x)
+	}
+}
+
+// appendLen appends the header of a string, binary, array or map of @n elements, whose fix
+// format is @fix|n if @n < @fixMax, and 8, 16 and 32 bits formats are @f8, @f16 and @f32.
+// 0 @fixMax or @f8 means there is no such format.
+func appendLen(b []byte, n int, fix byte, fixMax int, f8, f16, f32 byte) []byte {
+	switch {
+	case n < fixMax:
+		return append(b, fix|byte(n))
+	case f8 != 0 && n <= math.MaxUint8:
+		return append(b, f8, byte(n))
+	case n <= math.MaxUint16:
+		return binary.BigEndian.AppendUint16(append(b, f16), uint16(n))
+	default:
+		return binary.BigEndian.AppendUint32(append(b, f32), uint32(n))
+	}
+}
+
+func decodeValue(b []byte, depth int) (interface{}, []byte, error) {
+	if depth > maxDepth {
+		return nil, nil, perrors.New("msgpack value is nested too deeply")
+	}
+	if len(b) == 0 {
+		return nil, nil, errTruncated
+	}
+
+	c, b := b[0], b[1:]
+	switch {
+	case c <= 0x7f:
+		return int64(c), b, nil
+	case c >= 0xe0:
+		return int64(int8(c)), b, nil
+	case c&0xf0 == 0x80:
+		return decodeMap(b, int(c&0x0f), depth)
+	case c&0xf0 == 0x90:
+		return decodeArray(b, int(c&0x0f), depth)
+	case c&0xe0 == 0xa0:
+		return decodeBytes(b, int(c&0x1f), true)
+	}
+
+	switch c {
+	case 0xc0:
+		return nil, b, nil
+	case 0xc2:
+		return false, b, nil
+	case 0xc3:
+		return true, b, nil
+	case 0xc4, 0xc5, 0xc6, 0xd9, 0xda, 0xdb, 0xdc, 0xdd, 0xde, 0xdf:
+		var n uint64
+		switch c {
+		case 0xc4, 0xd9:
+			n, b = readUint(b, 1)
+		case 0xc5, 0xda, 0xdc, 0xde:
+			n, b = readUint(b, 2)
+		default:
+			n, b = readUint(b, 4)
+		}
+		if b == nil {
+			return nil, nil, errTruncated
+		}
+		switch c {
+		case 0xc4, 0xc5, 0xc6:
+			return decodeBytes(b, int(n), false)
+		case 0xd9, 0xda, 0xdb:
+			return decodeBytes(b, int(n), true)
+		case 0xdc, 0xdd:
+			return decodeArray(b, int(n), depth)
+		default:
+			return decodeMap(b, int(n), depth)
+		}
+	case 0xca:
+		n, b := readUint(b, 4)
+		if b == nil {
+			return nil, nil, errTruncated
+		}
+		return math.Float32frombits(uint32(n)), b, nil
+	case 0xcb:
+		n, b := readUint(b, 8)
+		if b == nil {
+			return nil, nil, errTruncated
+		}
+		return math.Float64frombits(n), b, nil
+	case 0xcc, 0xcd, 0xce, 0xcf:
+		n, b := readUint(b, 1<<(c-0xcc))
+		if b == nil {
+			return nil, nil, errTruncated
+		}
+		if n > math.MaxInt64 {
+			return n, b, nil
+		}
+		return int64(n), b, nil
+	case 0xd0, 0xd1, 0xd2, 0xd3:
+		size := 1 << (c - 0xd0)
+		n, b := readUint(b, size)
+		if b == nil {
+			return nil, nil, errTruncated
+		}
+		// sign extend the @size bytes integer
+		shift := 64 - 8*size
+		return int64(n<<shift) >> shift, b, nil
+	default:
+		return nil, nil, perrors.Errorf("unsupported msgpack format %#x", c)
+	}
+}
+
+// readUint reads a big endian unsigned integer of @size bytes, the returned bytes are nil if
+// @b is too short.
+func readUint(b []byte, size int) (uint64, []byte) {
+	if len(b) < size {
+		return 0, nil
+	}
+	var n uint64
+	for _, c := range b[:size] {
+		n = n<<8 | uint64(c)
+	}
+	return n, b[size:]
+}
+
+func decodeBytes(b []byte, n int, str bool) (interface{}, []byte, error) {
+	if len(b) < n {
+		return nil, nil, errTruncated
+	}
+	if str {
+		return string(b[:n]), b[n:], nil
+	}
+	return append([]byte{}, b[:n]...), b[n:], nil
+}
+
+func decodeArray(b []byte, n int, depth int) (interface{}, []byte, error) {
+	// every element is at least 1 byte
+	if len(b) < n {
+		return nil, nil, errTruncated
+	}
+
+	var (
+		err error
+		arr = make([]interface{}, n)
+	)
+	for i := range arr {
+		if arr[i], b, err = decodeValue(b, depth+1); err != nil {
+			return nil, nil, err
+		}
+	}
+	return arr, b, nil
+}
+
+func decodeMap(b []byte, n int, depth int) (interface{}, []byte, error) {
+	// every key and value is at least 1 byte
+	if len(b) < 2*n {
+		return nil, nil, errTruncated
+	}
+
+	var (
+		err  error
+		k, v interface{}
+		m    = make(map[string]interface{}, n)
+		im   map[interface{}]interface{}
+	)
+	for i := 0; i < n; i++ {
+		if k, b, err = decodeValue(b, depth+1); err != nil {
+			return nil, nil, err
+		}
+		if v, b, err = decodeValue(b, depth+1); err != nil {
+			return nil, nil, err
+		}
+		if s, ok := k.(string); ok && im == nil {
+			m[s] = v
+			continue
+		}
+		switch k.(type) {
+		case []interface{}, map[string]interface{}, map[interface{}]interface{}, []byte:
+			return nil, nil, perrors.Errorf("msgpack map key %T is not hashable", k)
+		}
+		if im == nil {
+			im = make(map[interface{}]interface{}, n)
+			for s, e := range m {
+				im[s] = e
+			}
+		}
+		im[k] = v
+	}
+	if im != nil {
+		return im, b, nil
+	}
+	return m, b, nil
+}
@@ -0,0 +1,81 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package getty
+
+import (
+	"encoding/binary"
+	"hash/crc32"
+)
+
+import (
+	perrors "github.com/pkg/errors"
+
+	uatomic "go.uber.org/atomic"
+)
+
+// ErrChecksumMismatch is the cause of the error passed to OnError when a ChecksumCodec reads a
+// corrupted frame.
+var ErrChecksumMismatch = perrors.New("frame checksum mismatch")
+
+var castagnoliTable = crc32.MakeTable(crc32.Castagnoli)
+
+// ChecksumCodec is a Pipeline stage which appends the big endian CRC32C of the written frames and
+// verifies the read ones, eg: Pipeline(frameCodec, &ChecksumCodec{}, jsonCodec). A corrupted frame
+// fails the session with ErrChecksumMismatch. Sharing one ChecksumCodec among the sessions sums
+// up their corrupted frames.
+type ChecksumCodec struct {
+	mismatches uatomic.Uint64
+}
+
+// Mismatches returns the number of the corrupted frames read.
+func (c *ChecksumCodec) Mismatches() uint64 {
+	return c.mismatches.Load()
+}
+
+func (c *ChecksumCodec) Read(ss Session, data []byte) (interface{}, int, error) {
+	if len(data) < crc32.Size {
+		c.mismatches.Inc()
+		return nil, 0, perrors.Wrapf(ErrChecksumMismatch, "frame length %d < checksum size", len(data))
+	}
+
+	payload := data[:len(data)-crc32.Size]
+	expected := binary.BigEndian.Uint32(data[len(payload):])
+	if actual := crc32.Checksum(payload, castagnoliTable); actual != expected {
+		c.mismatches.Inc()
+		return nil, 0, perrors.Wrapf(ErrChecksumMismatch, "crc32c %#08x != %#08x", actual, expected)
+	}
+
+	return append([]byte{}, payload...), len(data), nil
+}
+
+func (c *ChecksumCodec) Write(ss Session, pkg interface{}) ([]byte, error) {
+	var data []byte
+	switch p := pkg.(type) {
+	case []byte:
+		data = p
+	case string:
+		data = []byte(p)
+	default:
+		return nil, perrors.Errorf("illegal @pkg{%#v} type", pkg)
+	}
+
+	frame := make([]byte, len(data), len(data)+crc32.Size)
+	copy(frame, data)
+
+	return binary.BigEndian.AppendUint32(frame, crc32.Checksum(data, castagnoliTable)), nil
+}
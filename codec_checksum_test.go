@@ -0,0 +1,72 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package getty
+
+import (
+	"testing"
+	"time"
+)
+
+import (
+	perrors "github.com/pkg/errors"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type errMessageHandler struct {
+	*chanMessageHandler
+	errs chan error
+}
+
+func (h *errMessageHandler) OnError(session Session, err error) {
+	h.errs <- err
+}
+
+func TestChecksumCodec(t *testing.T) {
+	codec := &ChecksumCodec{}
+	frame, err := codec.Write(nil, "hello")
+	assert.Nil(t, err)
+	// crc32c("hello")
+	assert.Equal(t, []byte{'h', 'e', 'l', 'l', 'o', 0x9a, 0x71, 0xbb, 0x4c}, frame)
+	pkg, n, err := codec.Read(nil, frame)
+	assert.Nil(t, err)
+	assert.Equal(t, len(frame), n)
+	assert.Equal(t, []byte("hello"), pkg)
+
+	frame[0] = 'j'
+	_, _, err = codec.Read(nil, frame)
+	assert.Equal(t, ErrChecksumMismatch, perrors.Cause(err))
+	_, _, err = codec.Read(nil, frame[:3])
+	assert.Equal(t, ErrChecksumMismatch, perrors.Cause(err))
+	assert.Equal(t, uint64(2), codec.Mismatches())
+
+	// the corrupted frame fails the session by OnError
+	ss, handler := newPipeSessions(t)
+	errHandler := &errMessageHandler{chanMessageHandler: handler, errs: make(chan error, 1)}
+	handler.array[0].SetEventListener(errHandler)
+	handler.array[0].SetPkgPipeline(&LengthFieldCodec{LengthFieldSize: 4}, codec, textCodec{})
+	_, _, err = ss.WritePkg(string(frame), 0)
+	assert.Nil(t, err)
+	select {
+	case err = <-errHandler.errs:
+		assert.Equal(t, ErrChecksumMismatch, perrors.Cause(err))
+	case <-time.After(3 * time.Second):
+		t.Fatal("OnError is not invoked")
+	}
+	assert.Equal(t, uint64(3), codec.Mismatches())
+}
@@ -0,0 +1,225 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package getty
+
+import (
+	"bytes"
+	"compress/flate"
+	"compress/gzip"
+	"io"
+	"sync"
+)
+
+import (
+	"github.com/golang/snappy"
+
+	perrors "github.com/pkg/errors"
+)
+
+// CodecCompressType is how a CompressCodec compresses the written frames, which is also the flag
+// byte before the payload of a frame. The payload of CodecCompressFlate is raw deflate(RFC 1951)
+// and the one of CodecCompressGzip is gzip(RFC 1952), the lz4 payload is a lz4 frame and the
+// zstd payload is a zstd frame. The flags from 6 to 255 are reserved.
+type CodecCompressType byte
+
+const (
+	CodecCompressNone CodecCompressType = iota
+	CodecCompressFlate
+	CodecCompressSnappy
+	// CodecCompressLZ4 and CodecCompressZstd need a Compressor set by SetCompressor,
+	// eg: the ones of github.com/apache/dubbo-getty/contrib/compress.
+	CodecCompressLZ4
+	CodecCompressZstd
+	CodecCompressGzip
+)
+
+// Compressor compresses and decompresses the payloads of the CompressCodec frames of a CodecCompressType.
+type Compressor interface {
+	// Compress appends the compressed @data to @dst.
+	Compress(dst *bytes.Buffer, data []byte) error
+	// NewReader returns the reader of the payload decompressed from @r.
+	NewReader(r io.Reader) (io.ReadCloser, error)
+}
+
+var compressors sync.Map // CodecCompressType -> Compressor
+
+// SetCompressor registers the Compressor of @typ, which is used for the types other than
+// CodecCompressNone, CodecCompressFlate, CodecCompressSnappy and CodecCompressGzip.
+func SetCompressor(typ CodecCompressType, c Compressor) {
+	compressors.Store(typ, c)
+}
+
+// GetCompressor returns the Compressor of @typ.
+func GetCompressor(typ CodecCompressType) (Compressor, bool) {
+	c, ok := compressors.Load(typ)
+	if !ok {
+		return nil, false
+	}
+	return c.(Compressor), true
+}
+
+// CompressCodec is a Pipeline stage which compresses the written frames and decompresses
+// the read frames, eg: Pipeline(frameCodec, &CompressCodec{Type: CodecCompressSnappy, Threshold: 1024}, jsonCodec).
+// Every frame starts with a flag byte of how its payload is compressed, so the peers can
+// choose the Type and the Threshold per session and still read the frames of each other.
+type CompressCodec struct {
+	// Type is how the written frames are compressed.
+	Type CodecCompressType
+	// Level is the flate level of CodecCompressFlate, 0 means flate.DefaultCompression.
+	Level int
+	// Threshold is the min bytes of the written frames to be compressed.
+	Threshold int
+	// MaxLen is the max bytes of a decompressed frame, 0 means no limit.
+	MaxLen int
+}
+
+func (c *CompressCodec) Read(ss Session, data []byte) (interface{}, int, error) {
+	if len(data) == 0 {
+		return nil, 0, perrors.New("empty compressed frame")
+	}
+
+	var (
+		err     error
+		payload []byte
+	)
+	switch typ := CodecCompressType(data[0]); typ {
+	case CodecCompressNone:
+		payload = bytes.Clone(data[1:])
+
+	case CodecCompressFlate:
+		if payload, err = c.readAll(flate.NewReader(bytes.NewReader(data[1:]))); err != nil {
+			return nil, 0, perrors.WithMessage(err, "flate decompress")
+		}
+
+	case CodecCompressGzip:
+		r, err := gzip.NewReader(bytes.NewReader(data[1:]))
+		if err != nil {
+			return nil, 0, perrors.WithMessage(err, "gzip decompress")
+		}
+		if payload, err = c.readAll(r); err != nil {
+			return nil, 0, perrors.WithMessage(err, "gzip decompress")
+		}
+
+	case CodecCompressSnappy:
+		n, err := snappy.DecodedLen(data[1:])
+		if err != nil {
+			return nil, 0, perrors.WithMessage(err, "snappy decompress")
+		}
+		if c.MaxLen > 0 && n > c.MaxLen {
+			return nil, 0, perrors.Errorf("decompressed frame length %d > max length %d", n, c.MaxLen)
+		}
+		if payload, err = snappy.Decode(nil, data[1:]); err != nil {
+			return nil, 0, perrors.WithMessage(err, "snappy decompress")
+		}
+
+	default:
+		compressor, ok := GetCompressor(typ)
+		if !ok {
+			return nil, 0, perrors.Errorf("illegal compress flag %d", data[0])
+		}
+		r, err := compressor.NewReader(bytes.NewReader(data[1:]))
+		if err != nil {
+			return nil, 0, perrors.WithMessagef(err, "decompress flag %d", data[0])
+		}
+		payload, err = c.readAll(r)
+		r.Close()
+		if err != nil {
+			return nil, 0, perrors.WithMessagef(err, "decompress flag %d", data[0])
+		}
+	}
+	if c.MaxLen > 0 && len(payload) > c.MaxLen {
+		return nil, 0, perrors.Errorf("decompressed frame length > max length %d", c.MaxLen)
+	}
+
+	return payload, len(data), nil
+}
+
+// readAll reads the decompressed payload from @r, at most one byte beyond MaxLen.
+func (c *CompressCodec) readAll(r io.Reader) ([]byte, error) {
+	if c.MaxLen > 0 {
+		r = io.LimitReader(r, int64(c.MaxLen)+1)
+	}
+
+	return io.ReadAll(r)
+}
+
+func (c *CompressCodec) Write(ss Session, pkg interface{}) ([]byte, error) {
+	var data []byte
+	switch p := pkg.(type) {
+	case []byte:
+		data = p
+	case string:
+		data = []byte(p)
+	default:
+		return nil, perrors.Errorf("illegal @pkg{%#v} type", pkg)
+	}
+
+	if c.Type != CodecCompressNone && len(data) >= c.Threshold {
+		var buf bytes.Buffer
+		buf.WriteByte(byte(c.Type))
+		switch c.Type {
+		case CodecCompressSnappy:
+			buf.Write(snappy.Encode(nil, data))
+
+		case CodecCompressGzip:
+			if err := writeAll(gzip.NewWriter(&buf), data); err != nil {
+				return nil, perrors.WithMessage(err, "gzip compress")
+			}
+
+		case CodecCompressFlate:
+			level := c.Level
+			if level == 0 {
+				level = flate.DefaultCompression
+			}
+			w, err := flate.NewWriter(&buf, level)
+			if err != nil {
+				return nil, perrors.WithStack(err)
+			}
+			if err = writeAll(w, data); err != nil {
+				return nil, perrors.WithMessage(err, "flate compress")
+			}
+
+		default:
+			compressor, ok := GetCompressor(c.Type)
+			if !ok {
+				return nil, perrors.Errorf("illegal compress type %d", c.Type)
+			}
+			if err := compressor.Compress(&buf, data); err != nil {
+				return nil, perrors.WithMessagef(err, "compress type %d", c.Type)
+			}
+		}
+		// send the incompressible frames as they are
+		if buf.Len() < len(data)+1 {
+			return buf.Bytes(), nil
+		}
+	}
+
+	frame := make([]byte, 0, len(data)+1)
+	frame = append(frame, byte(CodecCompressNone))
+
+	return append(frame, data...), nil
+}
+
+// writeAll writes @data to the compressor @w and closes it to flush the compressed frame.
+func writeAll(w io.WriteCloser, data []byte) error {
+	if _, err := w.Write(data); err != nil {
+		return err
+	}
+
+	return w.Close()
+}
@@ -0,0 +1,95 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package getty
+
+import (
+	"bytes"
+	"compress/flate"
+	"io"
+	"testing"
+)
+
+import (
+	"github.com/stretchr/testify/assert"
+)
+
+// testCompressor is a Compressor of raw deflate.
+type testCompressor struct{}
+
+func (testCompressor) Compress(dst *bytes.Buffer, data []byte) error {
+	w, err := flate.NewWriter(dst, flate.BestSpeed)
+	if err != nil {
+		return err
+	}
+	return writeAll(w, data)
+}
+
+func (testCompressor) NewReader(r io.Reader) (io.ReadCloser, error) {
+	return flate.NewReader(r), nil
+}
+
+func TestCompressCodec(t *testing.T) {
+	const testCompressType CodecCompressType = 200
+	SetCompressor(testCompressType, testCompressor{})
+
+	data := bytes.Repeat([]byte("getty"), 100)
+	codecs := []*CompressCodec{
+		{Type: CodecCompressSnappy},
+		{Type: CodecCompressFlate},
+		{Type: CodecCompressFlate, Level: flate.BestSpeed},
+		{Type: CodecCompressGzip},
+		{Type: testCompressType},
+	}
+	for _, codec := range codecs {
+		codec.Threshold, codec.MaxLen = 64, len(data)
+		frame, err := codec.Write(nil, data)
+		assert.Nil(t, err)
+		assert.Equal(t, byte(codec.Type), frame[0])
+		assert.Less(t, len(frame), len(data))
+		pkg, n, err := codec.Read(nil, frame)
+		assert.Nil(t, err)
+		assert.Equal(t, len(frame), n)
+		assert.Equal(t, data, pkg)
+
+		// the frames under the threshold are not compressed
+		frame, err = codec.Write(nil, "ping")
+		assert.Nil(t, err)
+		assert.Equal(t, []byte("\x00ping"), frame)
+
+		// the frames of the peer are decompressed whatever the local type is
+		pkg, _, err = (&CompressCodec{}).Read(nil, frame)
+		assert.Nil(t, err)
+		assert.Equal(t, []byte("ping"), pkg)
+
+		frame, _ = codec.Write(nil, data)
+		_, _, err = (&CompressCodec{MaxLen: len(data) - 1}).Read(nil, frame)
+		assert.NotNil(t, err)
+	}
+
+	_, _, err := (&CompressCodec{}).Read(nil, []byte{0xff})
+	assert.NotNil(t, err)
+	// the flags without a Compressor are refused
+	_, _, err = (&CompressCodec{}).Read(nil, []byte{byte(CodecCompressLZ4), 'x'})
+	assert.NotNil(t, err)
+	_, _, err = (&CompressCodec{}).Read(nil, []byte{6, 'x'})
+	assert.NotNil(t, err)
+	_, err = (&CompressCodec{Type: CodecCompressZstd, Threshold: 1}).Write(nil, data)
+	assert.NotNil(t, err)
+	_, err = (&CompressCodec{Type: 42, Threshold: 1}).Write(nil, data)
+	assert.NotNil(t, err)
+}
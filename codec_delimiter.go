@@ -0,0 +1,123 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package getty
+
+import (
+	"bytes"
+	"fmt"
+)
+
+import (
+	perrors "github.com/pkg/errors"
+)
+
+type delimiterCodec struct {
+	delim  []byte
+	maxLen int
+}
+
+// DelimiterCodec returns a ReadWriter of the frames ended by @delim, eg: "\r\n" for line-oriented
+// protocols. Read returns the frame without @delim as []byte and Write takes []byte or string
+// and appends @delim. @maxLen is the max bytes of a frame excluding @delim, 0 means no limit.
+func DelimiterCodec(delim []byte, maxLen int) ReadWriter {
+	if len(delim) == 0 {
+		panic("getty: empty delimiter")
+	}
+	if maxLen < 0 {
+		panic(fmt.Sprintf("getty: illegal delimiter codec max length %d", maxLen))
+	}
+
+	return &delimiterCodec{delim: bytes.Clone(delim), maxLen: maxLen}
+}
+
+func (c *delimiterCodec) Read(ss Session, data []byte) (interface{}, int, error) {
+	idx := bytes.Index(data, c.delim)
+	if idx < 0 {
+		if c.maxLen > 0 && len(data) >= c.maxLen+len(c.delim) {
+			return nil, 0, perrors.Errorf("no delimiter in the first %d bytes", len(data))
+		}
+		return nil, 0, nil
+	}
+	if c.maxLen > 0 && idx > c.maxLen {
+		return nil, 0, perrors.Errorf("frame length %d > max frame length %d", idx, c.maxLen)
+	}
+
+	return bytes.Clone(data[:idx]), idx + len(c.delim), nil
+}
+
+func (c *delimiterCodec) Write(ss Session, pkg interface{}) ([]byte, error) {
+	var frame []byte
+	switch p := pkg.(type) {
+	case []byte:
+		frame = p
+	case string:
+		frame = []byte(p)
+	default:
+		return nil, perrors.Errorf("illegal @pkg{%#v} type", pkg)
+	}
+	if c.maxLen > 0 && len(frame) > c.maxLen {
+		return nil, perrors.Errorf("frame length %d > max frame length %d", len(frame), c.maxLen)
+	}
+	if bytes.Contains(frame, c.delim) {
+		return nil, perrors.Errorf("@pkg contains the delimiter %q", c.delim)
+	}
+
+	buf := make([]byte, 0, len(frame)+len(c.delim))
+	buf = append(buf, frame...)
+
+	return append(buf, c.delim...), nil
+}
+
+type fixedLengthCodec struct {
+	n int
+}
+
+// FixedLengthCodec returns a ReadWriter of the frames of @n bytes. Read returns the frame
+// as []byte and Write takes []byte or string of exactly @n bytes.
+func FixedLengthCodec(n int) ReadWriter {
+	if n <= 0 {
+		panic(fmt.Sprintf("getty: illegal fixed frame length %d", n))
+	}
+
+	return &fixedLengthCodec{n: n}
+}
+
+func (c *fixedLengthCodec) Read(ss Session, data []byte) (interface{}, int, error) {
+	if len(data) < c.n {
+		return nil, c.n, nil
+	}
+
+	return bytes.Clone(data[:c.n]), c.n, nil
+}
+
+func (c *fixedLengthCodec) Write(ss Session, pkg interface{}) ([]byte, error) {
+	var frame []byte
+	switch p := pkg.(type) {
+	case []byte:
+		frame = p
+	case string:
+		frame = []byte(p)
+	default:
+		return nil, perrors.Errorf("illegal @pkg{%#v} type", pkg)
+	}
+	if len(frame) != c.n {
+		return nil, perrors.Errorf("frame length %d != fixed frame length %d", len(frame), c.n)
+	}
+
+	return frame, nil
+}
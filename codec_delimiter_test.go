@@ -0,0 +1,79 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package getty
+
+import (
+	"testing"
+)
+
+import (
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDelimiterCodec(t *testing.T) {
+	codec := DelimiterCodec([]byte("\r\n"), 8)
+	frame, err := codec.Write(nil, "PING")
+	assert.Nil(t, err)
+	assert.Equal(t, []byte("PING\r\n"), frame)
+
+	pkg, n, err := codec.Read(nil, []byte("PING\r"))
+	assert.Nil(t, err)
+	assert.Nil(t, pkg)
+	assert.Equal(t, 0, n)
+	pkg, n, err = codec.Read(nil, []byte("PING\r\nSET"))
+	assert.Nil(t, err)
+	assert.Equal(t, 6, n)
+	assert.Equal(t, []byte("PING"), pkg)
+	pkg, n, err = codec.Read(nil, []byte("\r\n"))
+	assert.Nil(t, err)
+	assert.Equal(t, 2, n)
+	assert.Equal(t, []byte{}, pkg)
+
+	_, _, err = codec.Read(nil, []byte("123456789\r\n"))
+	assert.NotNil(t, err)
+	_, _, err = codec.Read(nil, []byte("1234567890"))
+	assert.NotNil(t, err)
+	_, err = codec.Write(nil, []byte("123456789"))
+	assert.NotNil(t, err)
+	_, err = codec.Write(nil, "a\r\nb")
+	assert.NotNil(t, err)
+	_, err = codec.Write(nil, 1)
+	assert.NotNil(t, err)
+
+	assert.Panics(t, func() { DelimiterCodec(nil, 0) })
+}
+
+func TestFixedLengthCodec(t *testing.T) {
+	codec := FixedLengthCodec(4)
+	pkg, n, err := codec.Read(nil, []byte("ab"))
+	assert.Nil(t, err)
+	assert.Nil(t, pkg)
+	assert.Equal(t, 4, n)
+	pkg, n, err = codec.Read(nil, []byte("abcdef"))
+	assert.Nil(t, err)
+	assert.Equal(t, 4, n)
+	assert.Equal(t, []byte("abcd"), pkg)
+
+	frame, err := codec.Write(nil, "wxyz")
+	assert.Nil(t, err)
+	assert.Equal(t, []byte("wxyz"), frame)
+	_, err = codec.Write(nil, "xyz")
+	assert.NotNil(t, err)
+
+	assert.Panics(t, func() { FixedLengthCodec(0) })
+}
@@ -0,0 +1,127 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package getty
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"sync"
+)
+
+import (
+	perrors "github.com/pkg/errors"
+)
+
+// EncryptCodec is a Pipeline stage which seals the written frames and opens the read ones by
+// AES-GCM, for the deployments which can not use TLS, eg: Pipeline(frameCodec, &EncryptCodec{
+// Handshake: keyOf}, jsonCodec). A sealed frame is the random nonce followed by the ciphertext.
+// Every session needs its own EncryptCodec as the key is per session.
+type EncryptCodec struct {
+	// Handshake returns the 16, 24 or 32 bytes AES key of the session, eg: derived from the key
+	// provisioned on the device. It is called on the first read or write of the session.
+	Handshake func(Session) ([]byte, error)
+
+	lock sync.Mutex
+	aead cipher.AEAD
+}
+
+func (c *EncryptCodec) gcm(ss Session) (cipher.AEAD, error) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	if c.aead != nil {
+		return c.aead, nil
+	}
+	if c.Handshake == nil {
+		return nil, perrors.New("no encryption handshake")
+	}
+	key, err := c.Handshake(ss)
+	if err != nil {
+		return nil, perrors.WithMessage(err, "encryption handshake")
+	}
+	if err = c.setKey(key); err != nil {
+		return nil, err
+	}
+	return c.aead, nil
+}
+
+// SetKey sets the AES key, eg: after a key exchange, and the later frames use it.
+func (c *EncryptCodec) SetKey(key []byte) error {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	return c.setKey(key)
+}
+
+func (c *EncryptCodec) setKey(key []byte) error {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return perrors.WithStack(err)
+	}
+	aead, err := cipher.NewGCM(block)
+	if err != nil {
+		return perrors.WithStack(err)
+	}
+	c.aead = aead
+	return nil
+}
+
+func (c *EncryptCodec) Read(ss Session, data []byte) (interface{}, int, error) {
+	aead, err := c.gcm(ss)
+	if err != nil {
+		return nil, 0, err
+	}
+	if len(data) < aead.NonceSize()+aead.Overhead() {
+		return nil, 0, perrors.Errorf("encrypted frame length %d is too short", len(data))
+	}
+
+	nonce := data[:aead.NonceSize()]
+	plain, err := aead.Open(nil, nonce, data[aead.NonceSize():], nil)
+	if err != nil {
+		return nil, 0, perrors.WithMessage(err, "decrypt frame")
+	}
+	if plain == nil {
+		plain = []byte{}
+	}
+
+	return plain, len(data), nil
+}
+
+func (c *EncryptCodec) Write(ss Session, pkg interface{}) ([]byte, error) {
+	var data []byte
+	switch p := pkg.(type) {
+	case []byte:
+		data = p
+	case string:
+		data = []byte(p)
+	default:
+		return nil, perrors.Errorf("illegal @pkg{%#v} type", pkg)
+	}
+
+	aead, err := c.gcm(ss)
+	if err != nil {
+		return nil, err
+	}
+	frame := make([]byte, aead.NonceSize(), aead.NonceSize()+len(data)+aead.Overhead())
+	if _, err = rand.Read(frame); err != nil {
+		return nil, perrors.WithStack(err)
+	}
+
+	return aead.Seal(frame, frame, data, nil), nil
+}
@@ -0,0 +1,75 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package getty
+
+import (
+	"bytes"
+	"testing"
+)
+
+import (
+	perrors "github.com/pkg/errors"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestEncryptCodec(t *testing.T) {
+	key := bytes.Repeat([]byte{7}, 16)
+	handshakes := 0
+	handshake := func(Session) ([]byte, error) {
+		handshakes++
+		return key, nil
+	}
+	sender, receiver := &EncryptCodec{Handshake: handshake}, &EncryptCodec{Handshake: handshake}
+
+	frame, err := sender.Write(nil, "hello")
+	assert.Nil(t, err)
+	assert.False(t, bytes.Contains(frame, []byte("hello")))
+	another, err := sender.Write(nil, "hello")
+	assert.Nil(t, err)
+	// the nonces are random
+	assert.NotEqual(t, frame, another)
+
+	pkg, n, err := receiver.Read(nil, frame)
+	assert.Nil(t, err)
+	assert.Equal(t, len(frame), n)
+	assert.Equal(t, []byte("hello"), pkg)
+	pkg, _, err = receiver.Read(nil, another)
+	assert.Nil(t, err)
+	assert.Equal(t, []byte("hello"), pkg)
+	assert.Equal(t, 2, handshakes)
+
+	frame[len(frame)-1] ^= 1
+	_, _, err = receiver.Read(nil, frame)
+	assert.NotNil(t, err)
+	_, _, err = receiver.Read(nil, frame[:8])
+	assert.NotNil(t, err)
+
+	// the frames of the former key can not be opened after the key exchange
+	assert.Nil(t, receiver.SetKey(bytes.Repeat([]byte{8}, 32)))
+	_, _, err = receiver.Read(nil, another)
+	assert.NotNil(t, err)
+	assert.NotNil(t, receiver.SetKey([]byte("short")))
+
+	_, err = (&EncryptCodec{}).Write(nil, "hello")
+	assert.NotNil(t, err)
+	_, err = (&EncryptCodec{Handshake: func(Session) ([]byte, error) {
+		return nil, perrors.New("no key provisioned")
+	}}).Write(nil, "hello")
+	assert.NotNil(t, err)
+}
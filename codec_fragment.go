@@ -0,0 +1,128 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package getty
+
+import (
+	"encoding/binary"
+)
+
+import (
+	perrors "github.com/pkg/errors"
+)
+
+// the header of a fragment is the flag of whether more fragments follow and its index
+const fragmentHeaderLen = 5
+
+// fragment is the package read by FragmentCodec for the non-final fragments, which consumes
+// their bytes without a message.
+type fragment struct{}
+
+// FragmentCodec splits the written packages larger than FragmentLen into the numbered fragments,
+// which are the frames of Frame, and reassembles them on the peer, so the occasional huge packages
+// need not raise the max message length of the sessions. It is the first stage of a Pipeline
+// if the packages are not []byte or string, eg: Pipeline(&FragmentCodec{Frame: frameCodec,
+// FragmentLen: 4096}, jsonCodec). Every session needs its own FragmentCodec as the reassembly
+// is per session.
+type FragmentCodec struct {
+	// Frame reads and writes the fragments as []byte, eg: &LengthFieldCodec{LengthFieldSize: 4}.
+	Frame ReadWriter
+	// FragmentLen is the max bytes of the package carried by a fragment.
+	FragmentLen int
+	// MaxLen is the max bytes of a reassembled package, 0 means no limit.
+	MaxLen int
+
+	partial []byte
+	next    uint32
+}
+
+func (c *FragmentCodec) Read(ss Session, data []byte) (interface{}, int, error) {
+	pkg, pkgLen, err := c.Frame.Read(ss, data)
+	if err != nil || pkg == nil {
+		return nil, pkgLen, err
+	}
+	frame, ok := pkg.([]byte)
+	if !ok || len(frame) < fragmentHeaderLen {
+		return nil, 0, perrors.Errorf("illegal fragment %#v", pkg)
+	}
+
+	more := frame[0] != 0
+	if idx := binary.BigEndian.Uint32(frame[1:]); idx != c.next {
+		return nil, 0, perrors.Errorf("fragment %d is out of order, expect %d", idx, c.next)
+	}
+	if c.MaxLen > 0 && len(c.partial)+len(frame)-fragmentHeaderLen > c.MaxLen {
+		return nil, 0, perrors.Errorf("reassembled package length > max length %d", c.MaxLen)
+	}
+	if more {
+		c.partial = append(c.partial, frame[fragmentHeaderLen:]...)
+		c.next++
+		return fragment{}, pkgLen, nil
+	}
+
+	whole := frame[fragmentHeaderLen:]
+	if c.partial != nil {
+		whole = append(c.partial, whole...)
+	}
+	c.partial, c.next = nil, 0
+
+	return whole, pkgLen, nil
+}
+
+func (c *FragmentCodec) Write(ss Session, pkg interface{}) ([]byte, error) {
+	var data []byte
+	switch p := pkg.(type) {
+	case []byte:
+		data = p
+	case string:
+		data = []byte(p)
+	default:
+		return nil, perrors.Errorf("illegal @pkg{%#v} type", pkg)
+	}
+	if c.FragmentLen <= 0 {
+		return nil, perrors.Errorf("illegal fragment length %d", c.FragmentLen)
+	}
+	if c.MaxLen > 0 && len(data) > c.MaxLen {
+		return nil, perrors.Errorf("package length %d > max length %d", len(data), c.MaxLen)
+	}
+
+	var (
+		buf  []byte
+		frag = make([]byte, 0, fragmentHeaderLen+min(len(data), c.FragmentLen))
+	)
+	for idx := uint32(0); ; idx++ {
+		n := min(len(data), c.FragmentLen)
+		more := byte(0)
+		if n < len(data) {
+			more = 1
+		}
+		frag = append(frag[:0], more)
+		frag = binary.BigEndian.AppendUint32(frag, idx)
+		frag = append(frag, data[:n]...)
+		frame, err := c.Frame.Write(ss, frag)
+		if err != nil {
+			return nil, perrors.WithMessagef(err, "write fragment %d", idx)
+		}
+		data = data[n:]
+		if buf == nil && len(data) == 0 {
+			return frame, nil
+		}
+		buf = append(buf, frame...)
+		if len(data) == 0 {
+			return buf, nil
+		}
+	}
+}
@@ -0,0 +1,82 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package getty
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+import (
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFragmentCodec(t *testing.T) {
+	codec := &FragmentCodec{Frame: &LengthFieldCodec{LengthFieldSize: 1}, FragmentLen: 4, MaxLen: 16}
+	data, err := codec.Write(nil, "0123456789")
+	assert.Nil(t, err)
+	assert.Equal(t, []byte("\x09\x01\x00\x00\x00\x000123"+"\x09\x01\x00\x00\x00\x014567"+"\x07\x00\x00\x00\x00\x0289"), data)
+
+	pkg, n, err := codec.Read(nil, data[:12])
+	assert.Nil(t, err)
+	assert.Equal(t, 10, n)
+	assert.Equal(t, fragment{}, pkg)
+	pkg, n, err = codec.Read(nil, data[10:])
+	assert.Nil(t, err)
+	assert.Equal(t, 10, n)
+	assert.Equal(t, fragment{}, pkg)
+	pkg, n, err = codec.Read(nil, data[20:])
+	assert.Nil(t, err)
+	assert.Equal(t, 8, n)
+	assert.Equal(t, []byte("0123456789"), pkg)
+
+	// the small package is one fragment
+	data, err = codec.Write(nil, "ab")
+	assert.Nil(t, err)
+	pkg, _, err = codec.Read(nil, data)
+	assert.Nil(t, err)
+	assert.Equal(t, []byte("ab"), pkg)
+
+	_, err = codec.Write(nil, strings.Repeat("x", 17))
+	assert.NotNil(t, err)
+	_, _, err = codec.Read(nil, []byte("\x09\x01\x00\x00\x00\x050123"))
+	assert.NotNil(t, err)
+}
+
+func TestSessionFragment(t *testing.T) {
+	ss, handler := newPipeSessions(t)
+	newCodec := func() *FragmentCodec {
+		return &FragmentCodec{Frame: &LengthFieldCodec{LengthFieldSize: 4}, FragmentLen: 1024}
+	}
+	ss.SetPkgPipeline(newCodec(), textCodec{})
+	handler.array[0].SetPkgPipeline(newCodec(), textCodec{})
+
+	// the message is much larger than the max message length of the session
+	msg := strings.Repeat("getty", 4096)
+	for _, m := range []string{msg, "ping"} {
+		_, _, err := ss.WritePkg(m, 0)
+		assert.Nil(t, err)
+		select {
+		case pkg := <-handler.msgs:
+			assert.Equal(t, m, pkg)
+		case <-time.After(3 * time.Second):
+			t.Fatal("the message is not reassembled")
+		}
+	}
+}
@@ -0,0 +1,157 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package getty
+
+import (
+	"encoding/binary"
+	"math"
+)
+
+import (
+	perrors "github.com/pkg/errors"
+)
+
+// LengthFieldCodec is a ReadWriter of the frames whose length is given by a field of their header:
+//
+//	+--------------------------+--------------+------------------------+
+//	| header LengthFieldOffset | length field | body                   |
+//	+--------------------------+--------------+------------------------+
+//
+// The body is (length field + LengthAdjustment) bytes, eg: LengthAdjustment is -6 if the 2-byte
+// length field at offset 4 counts the whole frame. Read returns the frame without its length field
+// as []byte, which is the header followed by the body, and Write takes the same []byte or string
+// and inserts the length field. So they are just the body if LengthFieldOffset is 0.
+type LengthFieldCodec struct {
+	// ByteOrder of the length field, the default is big endian.
+	ByteOrder binary.ByteOrder
+	// LengthFieldOffset is the bytes of the header before the length field.
+	LengthFieldOffset int
+	// LengthFieldSize is the bytes of the length field, which is 1, 2, 4 or 8.
+	LengthFieldSize  int
+	LengthAdjustment int
+	// MaxFrameLen is the max bytes of a frame, 0 means no limit.
+	MaxFrameLen int
+}
+
+func (c *LengthFieldCodec) byteOrder() binary.ByteOrder {
+	if c.ByteOrder == nil {
+		return binary.BigEndian
+	}
+	return c.ByteOrder
+}
+
+func (c *LengthFieldCodec) headerLen() (int, error) {
+	switch c.LengthFieldSize {
+	case 1, 2, 4, 8:
+	default:
+		return 0, perrors.Errorf("illegal length field size %d", c.LengthFieldSize)
+	}
+	if c.LengthFieldOffset < 0 {
+		return 0, perrors.Errorf("illegal length field offset %d", c.LengthFieldOffset)
+	}
+
+	return c.LengthFieldOffset + c.LengthFieldSize, nil
+}
+
+func (c *LengthFieldCodec) Read(ss Session, data []byte) (interface{}, int, error) {
+	headerLen, err := c.headerLen()
+	if err != nil {
+		return nil, 0, err
+	}
+	if len(data) < headerLen {
+		return nil, 0, nil
+	}
+
+	var length uint64
+	field := data[c.LengthFieldOffset:headerLen]
+	switch c.LengthFieldSize {
+	case 1:
+		length = uint64(field[0])
+	case 2:
+		length = uint64(c.byteOrder().Uint16(field))
+	case 4:
+		length = uint64(c.byteOrder().Uint32(field))
+	case 8:
+		length = c.byteOrder().Uint64(field)
+	}
+	if length > math.MaxInt32 {
+		return nil, 0, perrors.Errorf("illegal frame length field %d", length)
+	}
+	frameLen := headerLen + int(length) + c.LengthAdjustment
+	if frameLen < headerLen {
+		return nil, 0, perrors.Errorf("frame length %d is less than the header length %d", frameLen, headerLen)
+	}
+	if c.MaxFrameLen > 0 && frameLen > c.MaxFrameLen {
+		return nil, 0, perrors.Errorf("frame length %d > max frame length %d", frameLen, c.MaxFrameLen)
+	}
+	if len(data) < frameLen {
+		return nil, frameLen, nil
+	}
+
+	frame := make([]byte, 0, frameLen-c.LengthFieldSize)
+	frame = append(frame, data[:c.LengthFieldOffset]...)
+	frame = append(frame, data[headerLen:frameLen]...)
+
+	return frame, frameLen, nil
+}
+
+func (c *LengthFieldCodec) Write(ss Session, pkg interface{}) ([]byte, error) {
+	headerLen, err := c.headerLen()
+	if err != nil {
+		return nil, err
+	}
+	var frame []byte
+	switch p := pkg.(type) {
+	case []byte:
+		frame = p
+	case string:
+		frame = []byte(p)
+	default:
+		return nil, perrors.Errorf("illegal @pkg{%#v} type", pkg)
+	}
+	if len(frame) < c.LengthFieldOffset {
+		return nil, perrors.Errorf("@pkg length %d is less than the length field offset %d", len(frame), c.LengthFieldOffset)
+	}
+
+	bodyLen := len(frame) - c.LengthFieldOffset
+	frameLen := headerLen + bodyLen
+	if c.MaxFrameLen > 0 && frameLen > c.MaxFrameLen {
+		return nil, perrors.Errorf("frame length %d > max frame length %d", frameLen, c.MaxFrameLen)
+	}
+	length := int64(bodyLen) - int64(c.LengthAdjustment)
+	if length < 0 || (c.LengthFieldSize < 8 && length >= int64(1)<<(8*c.LengthFieldSize)) {
+		return nil, perrors.Errorf("length %d overflows the %d-byte length field", length, c.LengthFieldSize)
+	}
+
+	buf := make([]byte, frameLen)
+	copy(buf, frame[:c.LengthFieldOffset])
+	field := buf[c.LengthFieldOffset:headerLen]
+	switch c.LengthFieldSize {
+	case 1:
+		field[0] = byte(length)
+	case 2:
+		c.byteOrder().PutUint16(field, uint16(length))
+	case 4:
+		c.byteOrder().PutUint32(field, uint32(length))
+	case 8:
+		c.byteOrder().PutUint64(field, uint64(length))
+	}
+	copy(buf[headerLen:], frame[c.LengthFieldOffset:])
+
+	return buf, nil
+}
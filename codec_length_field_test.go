@@ -0,0 +1,82 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package getty
+
+import (
+	"encoding/binary"
+	"testing"
+)
+
+import (
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLengthFieldCodec(t *testing.T) {
+	// 2-byte magic, 2-byte little endian length of the whole frame, body
+	codec := &LengthFieldCodec{
+		ByteOrder:         binary.LittleEndian,
+		LengthFieldOffset: 2,
+		LengthFieldSize:   2,
+		LengthAdjustment:  -4,
+		MaxFrameLen:       16,
+	}
+	frame, err := codec.Write(nil, "MGhello")
+	assert.Nil(t, err)
+	assert.Equal(t, []byte{'M', 'G', 9, 0, 'h', 'e', 'l', 'l', 'o'}, frame)
+
+	for i := 0; i < 4; i++ {
+		pkg, n, err := codec.Read(nil, frame[:i])
+		assert.Nil(t, err)
+		assert.Nil(t, pkg)
+		assert.Equal(t, 0, n)
+	}
+	pkg, n, err := codec.Read(nil, frame[:6])
+	assert.Nil(t, err)
+	assert.Nil(t, pkg)
+	assert.Equal(t, len(frame), n)
+
+	pkg, n, err = codec.Read(nil, append(frame, 'M', 'G'))
+	assert.Nil(t, err)
+	assert.Equal(t, len(frame), n)
+	assert.Equal(t, []byte("MGhello"), pkg)
+
+	_, err = codec.Write(nil, "MGhello, world!")
+	assert.NotNil(t, err)
+	_, _, err = codec.Read(nil, []byte{'M', 'G', 17, 0})
+	assert.NotNil(t, err)
+	_, _, err = codec.Read(nil, []byte{'M', 'G', 3, 0})
+	assert.NotNil(t, err)
+	_, err = codec.Write(nil, "M")
+	assert.NotNil(t, err)
+	_, err = codec.Write(nil, 1)
+	assert.NotNil(t, err)
+
+	codec = &LengthFieldCodec{LengthFieldSize: 1}
+	frame, err = codec.Write(nil, []byte("ping"))
+	assert.Nil(t, err)
+	assert.Equal(t, []byte{4, 'p', 'i', 'n', 'g'}, frame)
+	pkg, n, err = codec.Read(nil, frame)
+	assert.Nil(t, err)
+	assert.Equal(t, 5, n)
+	assert.Equal(t, []byte("ping"), pkg)
+	_, err = codec.Write(nil, make([]byte, 256))
+	assert.NotNil(t, err)
+
+	_, _, err = (&LengthFieldCodec{LengthFieldSize: 3}).Read(nil, frame)
+	assert.NotNil(t, err)
+}
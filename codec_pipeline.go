@@ -0,0 +1,82 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package getty
+
+import (
+	perrors "github.com/pkg/errors"
+)
+
+type pipelineCodec struct {
+	stages []ReadWriter
+}
+
+// Pipeline composes @stages into one ReadWriter, eg: Pipeline(frameCodec, compressCodec, jsonCodec).
+// The first stage splits the stream into frames and each following stage transforms the []byte
+// output of the previous one, so it must take the whole frame. Write runs the stages in reverse
+// order and passes the []byte output of each stage as the package of the previous one.
+func Pipeline(stages ...ReadWriter) ReadWriter {
+	if len(stages) == 0 {
+		panic("getty: empty pipeline")
+	}
+	if len(stages) == 1 {
+		return stages[0]
+	}
+
+	return &pipelineCodec{stages: append([]ReadWriter(nil), stages...)}
+}
+
+func (p *pipelineCodec) Read(ss Session, data []byte) (interface{}, int, error) {
+	pkg, pkgLen, err := p.stages[0].Read(ss, data)
+	if err != nil || pkg == nil {
+		return pkg, pkgLen, err
+	}
+	if _, ok := pkg.(fragment); ok {
+		return pkg, pkgLen, nil
+	}
+
+	for i, stage := range p.stages[1:] {
+		frame, ok := pkg.([]byte)
+		if !ok {
+			return nil, 0, perrors.Errorf("pipeline stage %d output %T, not []byte", i, pkg)
+		}
+		if pkg, _, err = stage.Read(ss, frame); err != nil {
+			return nil, 0, perrors.WithMessagef(err, "pipeline stage %d", i+1)
+		}
+		if pkg == nil {
+			return nil, 0, perrors.Errorf("pipeline stage %d got an incomplete frame", i+1)
+		}
+	}
+
+	return pkg, pkgLen, nil
+}
+
+func (p *pipelineCodec) Write(ss Session, pkg interface{}) ([]byte, error) {
+	var (
+		err  error
+		data []byte
+	)
+
+	for i := len(p.stages) - 1; i >= 0; i-- {
+		if data, err = p.stages[i].Write(ss, pkg); err != nil {
+			return nil, perrors.WithMessagef(err, "pipeline stage %d", i)
+		}
+		pkg = data
+	}
+
+	return data, nil
+}
@@ -0,0 +1,90 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package getty
+
+import (
+	"testing"
+)
+
+import (
+	perrors "github.com/pkg/errors"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// xorCodec is a transform stage, eg: for the crypto.
+type xorCodec byte
+
+func (c xorCodec) Read(ss Session, data []byte) (interface{}, int, error) {
+	return c.xor(data), len(data), nil
+}
+
+func (c xorCodec) Write(ss Session, pkg interface{}) ([]byte, error) {
+	return c.xor(pkg.([]byte)), nil
+}
+
+func (c xorCodec) xor(data []byte) []byte {
+	out := make([]byte, len(data))
+	for i := range data {
+		out[i] = data[i] ^ byte(c)
+	}
+	return out
+}
+
+// textCodec is the object stage which converts the frame to string.
+type textCodec struct{}
+
+func (textCodec) Read(ss Session, data []byte) (interface{}, int, error) {
+	return string(data), len(data), nil
+}
+
+func (textCodec) Write(ss Session, pkg interface{}) ([]byte, error) {
+	s, ok := pkg.(string)
+	if !ok {
+		return nil, perrors.Errorf("illegal @pkg{%#v} type", pkg)
+	}
+	return []byte(s), nil
+}
+
+func TestPipeline(t *testing.T) {
+	codec := Pipeline(&LengthFieldCodec{LengthFieldSize: 1}, xorCodec(0x20), textCodec{})
+	frame, err := codec.Write(nil, "hello")
+	assert.Nil(t, err)
+	assert.Equal(t, []byte{5, 'H', 'E', 'L', 'L', 'O'}, frame)
+
+	pkg, n, err := codec.Read(nil, frame[:3])
+	assert.Nil(t, err)
+	assert.Nil(t, pkg)
+	assert.Equal(t, 6, n)
+	pkg, n, err = codec.Read(nil, frame)
+	assert.Nil(t, err)
+	assert.Equal(t, 6, n)
+	assert.Equal(t, "hello", pkg)
+
+	_, err = codec.Write(nil, 1)
+	assert.NotNil(t, err)
+	_, _, err = Pipeline(textCodec{}, textCodec{}).Read(nil, []byte("x"))
+	assert.NotNil(t, err)
+
+	ss, handler := newPipeSessions(t)
+	ss.SetPkgPipeline(DelimiterCodec([]byte("\n"), 0), xorCodec(0x20), textCodec{})
+	handler.array[0].SetPkgPipeline(DelimiterCodec([]byte("\n"), 0), xorCodec(0x20), textCodec{})
+	_, _, err = ss.WritePkg("ping", 0)
+	assert.Nil(t, err)
+	assert.Equal(t, "ping", <-handler.msgs)
+}
@@ -0,0 +1,89 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package getty
+
+import (
+	"encoding/binary"
+)
+
+import (
+	perrors "github.com/pkg/errors"
+)
+
+// maxProtoLen is the max size of the protobuf messages, which is 2GB.
+const maxProtoLen = 1<<31 - 1
+
+// ProtoMessage is the message of the ProtoCodec, eg: the messages generated by gogo/protobuf, or
+// a wrapper calling proto.Marshal and proto.Unmarshal of google.golang.org/protobuf.
+type ProtoMessage interface {
+	Marshal() ([]byte, error)
+	Unmarshal([]byte) error
+}
+
+// ProtoCodec is a ReadWriter of the protobuf messages delimited by their varint length prefix,
+// as written by writeDelimitedTo of the protobuf java library.
+type ProtoCodec struct {
+	// NewMessage returns the message to unmarshal the read bytes into.
+	NewMessage func() ProtoMessage
+	// MaxLen is the max bytes of a message, 0 means no limit.
+	MaxLen int
+}
+
+func (c *ProtoCodec) Read(ss Session, data []byte) (interface{}, int, error) {
+	length, n := binary.Uvarint(data)
+	if n == 0 {
+		return nil, 0, nil
+	}
+	if n < 0 || length > uint64(maxProtoLen) {
+		return nil, 0, perrors.New("illegal protobuf message length prefix")
+	}
+	if c.MaxLen > 0 && int(length) > c.MaxLen {
+		return nil, 0, perrors.Errorf("protobuf message length %d > max length %d", length, c.MaxLen)
+	}
+	frameLen := n + int(length)
+	if len(data) < frameLen {
+		return nil, frameLen, nil
+	}
+
+	msg := c.NewMessage()
+	if err := msg.Unmarshal(data[n:frameLen]); err != nil {
+		return nil, 0, perrors.WithMessage(err, "unmarshal protobuf message")
+	}
+
+	return msg, frameLen, nil
+}
+
+func (c *ProtoCodec) Write(ss Session, pkg interface{}) ([]byte, error) {
+	msg, ok := pkg.(ProtoMessage)
+	if !ok {
+		return nil, perrors.Errorf("illegal @pkg{%#v} type", pkg)
+	}
+	data, err := msg.Marshal()
+	if err != nil {
+		return nil, perrors.WithMessage(err, "marshal protobuf message")
+	}
+	if c.MaxLen > 0 && len(data) > c.MaxLen {
+		return nil, perrors.Errorf("protobuf message length %d > max length %d", len(data), c.MaxLen)
+	}
+
+	buf := make([]byte, binary.MaxVarintLen64+len(data))
+	n := binary.PutUvarint(buf, uint64(len(data)))
+	n += copy(buf[n:], data)
+
+	return buf[:n], nil
+}
@@ -0,0 +1,84 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package getty
+
+import (
+	"strings"
+	"testing"
+)
+
+import (
+	perrors "github.com/pkg/errors"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// textMessage is a ProtoMessage of the text, whose encoding is the text itself.
+type textMessage struct {
+	text string
+}
+
+func (m *textMessage) Marshal() ([]byte, error) {
+	if m.text == "" {
+		return nil, perrors.New("empty text")
+	}
+	return []byte(m.text), nil
+}
+
+func (m *textMessage) Unmarshal(data []byte) error {
+	m.text = string(data)
+	return nil
+}
+
+func TestProtoCodec(t *testing.T) {
+	codec := &ProtoCodec{NewMessage: func() ProtoMessage { return &textMessage{} }, MaxLen: 256}
+
+	frame, err := codec.Write(nil, &textMessage{text: "hello"})
+	assert.Nil(t, err)
+	assert.Equal(t, []byte("\x05hello"), frame)
+	pkg, n, err := codec.Read(nil, frame)
+	assert.Nil(t, err)
+	assert.Equal(t, 6, n)
+	assert.Equal(t, &textMessage{text: "hello"}, pkg)
+
+	// the length prefix of 200 is 2 bytes
+	frame, err = codec.Write(nil, &textMessage{text: strings.Repeat("x", 200)})
+	assert.Nil(t, err)
+	assert.Equal(t, []byte{0xc8, 0x01}, frame[:2])
+	for _, l := range []int{0, 1} {
+		pkg, n, err = codec.Read(nil, frame[:l])
+		assert.Nil(t, err)
+		assert.Nil(t, pkg)
+		assert.Equal(t, 0, n)
+	}
+	pkg, n, err = codec.Read(nil, frame[:100])
+	assert.Nil(t, err)
+	assert.Nil(t, pkg)
+	assert.Equal(t, 202, n)
+
+	_, err = codec.Write(nil, &textMessage{text: strings.Repeat("x", 257)})
+	assert.NotNil(t, err)
+	_, err = codec.Write(nil, &textMessage{})
+	assert.NotNil(t, err)
+	_, err = codec.Write(nil, "hello")
+	assert.NotNil(t, err)
+	_, _, err = codec.Read(nil, []byte{0x81, 0x02})
+	assert.NotNil(t, err)
+	_, _, err = codec.Read(nil, []byte{0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0x01})
+	assert.NotNil(t, err)
+}
@@ -0,0 +1,111 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package getty
+
+import (
+	"context"
+	"time"
+)
+
+import (
+	gxtime "github.com/dubbogo/gost/time"
+
+	perrors "github.com/pkg/errors"
+)
+
+var errNotRunning = perrors.New("RunEventLoop has not been called")
+
+// startConnect fills the pool in the background at the first call, and returns the channel
+// which is closed when it is done. It returns nil before RunEventLoop.
+func (c *client) startConnect() <-chan struct{} {
+	c.Lock()
+	running := c.newSession != nil
+	c.Unlock()
+	if !running {
+		return nil
+	}
+
+	c.connectOnce.Do(func() {
+		c.connected = make(chan struct{})
+		c.wg.Add(1)
+		go func() {
+			defer c.wg.Done()
+			c.reConnect(false)
+			c.filled.Store(true)
+			close(c.connected)
+		}()
+	})
+	return c.connected
+}
+
+// ConnectAsync starts filling the pool after RunEventLoop if it is not started yet, eg: with
+// WithLazyConnect, and returns the channel which receives nil once the pool is filled, ctx.Err()
+// if @ctx is done first, or the error why the pool can not be filled.
+func (c *client) ConnectAsync(ctx context.Context) <-chan error {
+	result := make(chan error, 1)
+	connected := c.startConnect()
+	if connected == nil {
+		result <- errNotRunning
+		return result
+	}
+
+	go func() {
+		select {
+		case <-ctx.Done():
+			result <- ctx.Err()
+		case <-connected:
+			switch {
+			case c.IsClosed():
+				result <- ErrClientClosed
+			case c.sessionNum() < c.poolSize():
+				result <- perrors.WithMessage(ErrNoSession, "the dialing attempts run out")
+			default:
+				result <- nil
+			}
+		}
+	}()
+	return result
+}
+
+// ConnectContext waits for ConnectAsync. Every dialing attempt is bounded by WithDialTimeout,
+// and @ctx bounds the whole connecting, eg: the startup budget of the application. The pool
+// keeps connecting in the background after @ctx is done.
+func (c *client) ConnectContext(ctx context.Context) error {
+	return <-c.ConnectAsync(ctx)
+}
+
+// attemptTimeout returns the timeout of a dialing attempt.
+func (c *client) attemptTimeout() time.Duration {
+	if c.dialTimeout > 0 {
+		return c.dialTimeout
+	}
+	return connectTimeout
+}
+
+// waitFirstSession starts connecting the lazy client, and waits for its first session for up
+// to @timeout.
+func (c *client) waitFirstSession(timeout time.Duration) {
+	if c.startConnect() == nil {
+		return
+	}
+	select {
+	case <-c.ready:
+	case <-c.done:
+	case <-gxtime.After(timeout):
+	}
+}
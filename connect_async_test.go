@@ -0,0 +1,112 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package getty
+
+import (
+	"context"
+	"net"
+	"testing"
+	"time"
+)
+
+import (
+	"github.com/stretchr/testify/assert"
+)
+
+func TestClientLazyConnect(t *testing.T) {
+	s := NewTCPServer(WithLocalAddress("127.0.0.1:0")).(*server)
+	defer s.Close()
+	s.RunEventLoop(func(session Session) error {
+		session.SetPkgHandler(&stringPkgHandler{})
+		session.SetEventListener(newChanMessageHandler())
+		return nil
+	})
+
+	clt := NewTCPClient(WithServerAddress(s.addr), WithConnectionNumber(2), WithLazyConnect(true),
+		WithReconnectInterval(1e7)).(*client)
+	defer clt.Close()
+	clt.RunEventLoop(func(session Session) error {
+		session.SetPkgHandler(&stringPkgHandler{})
+		session.SetEventListener(newChanMessageHandler())
+		session.SetReadTimeout(50 * time.Millisecond)
+		return nil
+	})
+	assert.Equal(t, 0, clt.sessionNum())
+
+	// the first PickSession dials
+	ss, err := clt.PickSession("")
+	assert.Nil(t, err)
+	assert.False(t, ss.IsClosed())
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	assert.Nil(t, <-clt.ConnectAsync(ctx))
+	assert.Equal(t, 2, clt.sessionNum())
+}
+
+func TestClientConnectAsync(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	assert.Nil(t, err)
+	addr := ln.Addr().String()
+	ln.Close()
+
+	clt := NewTCPClient(WithServerAddress(addr), WithConnectionNumber(1), WithLazyConnect(true),
+		WithReconnectBackoff(time.Millisecond, time.Millisecond, 1, 0)).(PoolClient)
+	assert.Equal(t, errNotRunning, <-clt.ConnectAsync(context.Background()))
+	clt.RunEventLoop(func(session Session) error {
+		return nil
+	})
+
+	// the server is down
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	result := clt.ConnectAsync(ctx)
+	assert.Equal(t, context.DeadlineExceeded, <-result)
+
+	clt.Close()
+	assert.Equal(t, ErrClientClosed, <-clt.ConnectAsync(context.Background()))
+}
+
+func TestClientConnectContext(t *testing.T) {
+	assert.Equal(t, time.Duration(connectTimeout), newClient(TCP_CLIENT, WithServerAddress("127.0.0.1:1"),
+		WithConnectionNumber(1)).attemptTimeout())
+	assert.Equal(t, 100*time.Millisecond, newClient(TCP_CLIENT, WithServerAddress("127.0.0.1:1"),
+		WithConnectionNumber(1), WithDialTimeout(100*time.Millisecond)).attemptTimeout())
+
+	s := NewTCPServer(WithLocalAddress("127.0.0.1:0")).(*server)
+	defer s.Close()
+	s.RunEventLoop(func(session Session) error {
+		session.SetPkgHandler(&stringPkgHandler{})
+		session.SetEventListener(newChanMessageHandler())
+		return nil
+	})
+
+	clt := NewTCPClient(WithServerAddress(s.addr), WithConnectionNumber(2), WithLazyConnect(true),
+		WithDialTimeout(100*time.Millisecond), WithReconnectInterval(1e7)).(PoolClient)
+	defer clt.Close()
+	clt.RunEventLoop(func(session Session) error {
+		session.SetPkgHandler(&stringPkgHandler{})
+		session.SetEventListener(newChanMessageHandler())
+		session.SetReadTimeout(50 * time.Millisecond)
+		return nil
+	})
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	assert.Nil(t, clt.ConnectContext(ctx))
+	assert.Equal(t, 2, clt.(*client).sessionNum())
+}
@@ -81,10 +81,14 @@ type gettyConn struct {
 	active        uatomic.Int64    // last active, in milliseconds
 	rTimeout      uatomic.Duration // network current limiting
 	wTimeout      uatomic.Duration
-	rLastDeadline uatomic.Time // last network read time
-	wLastDeadline uatomic.Time // last network write time
-	local         string       // local address
-	peer          string       // peer address
+	rLastDeadline uatomic.Time  // last network read time
+	wLastDeadline uatomic.Time  // last network write time
+	lastRead      uatomic.Int64 // last read, in nanoseconds since launchTime
+	lastWrite     uatomic.Int64 // last write, in nanoseconds since launchTime
+	connected     time.Time
+	clock         Clock  // the clock of the endpoint, nil means the system clock
+	local         string // local address
+	peer          string // peer address
 	ss            Session
 }
 
@@ -108,8 +112,31 @@ func (c *gettyConn) incWritePkgNum() {
 	c.writePkgNum.Add(1)
 }
 
+// onRead counts the @n read bytes.
+func (c *gettyConn) onRead(n int) {
+	if n > 0 {
+		c.readBytes.Add(uint32(n))
+		c.lastRead.Store(c.sinceLaunch())
+	}
+}
+
+// onWrite counts the @n written bytes of @pkgNum packages.
+func (c *gettyConn) onWrite(n, pkgNum int) {
+	c.writeBytes.Add(uint32(n))
+	c.writePkgNum.Add(uint32(pkgNum))
+	c.lastWrite.Store(c.sinceLaunch())
+}
+
 func (c *gettyConn) UpdateActive() {
-	c.active.Store(int64(time.Since(launchTime)))
+	c.active.Store(c.sinceLaunch())
+}
+
+// sinceLaunch returns the nanoseconds since launchTime on the clock of the connection.
+func (c *gettyConn) sinceLaunch() int64 {
+	if c.clock != nil {
+		return int64(c.clock.Now().Sub(launchTime))
+	}
+	return int64(time.Since(launchTime))
 }
 
 func (c *gettyConn) GetActive() time.Time {
@@ -128,6 +155,12 @@ func (c gettyConn) readTimeout() time.Duration {
 
 func (c *gettyConn) setSession(ss Session) {
 	c.ss = ss
+	if ss == nil {
+		return
+	}
+	if clock := clockOf(ss.EndPoint()); clock != nil {
+		c.clock, c.connected = clock, clock.Now()
+	}
 }
 
 // SetReadTimeout Pls do not set read deadline for websocket connection. AlexStocks 20180310
@@ -139,7 +172,10 @@ func (c *gettyConn) SetReadTimeout(rTimeout time.Duration) {
 		panic("@rTimeout < 1")
 	}
 
-	c.rTimeout.Store(rTimeout)
+	if c.rTimeout.Swap(rTimeout) != rTimeout {
+		// the next read sets the deadline by the new timeout
+		c.rLastDeadline.Store(time.Time{})
+	}
 	if c.wTimeout.Load() == 0 {
 		c.wTimeout.Store(rTimeout)
 	}
@@ -158,7 +194,10 @@ func (c *gettyConn) SetWriteTimeout(wTimeout time.Duration) {
 		panic("@wTimeout < 1")
 	}
 
-	c.wTimeout.Store(wTimeout)
+	if c.wTimeout.Swap(wTimeout) != wTimeout {
+		// the next write sets the deadline by the new timeout
+		c.wLastDeadline.Store(time.Time{})
+	}
 	if c.rTimeout.Load() == 0 {
 		c.rTimeout.Store(wTimeout)
 	}
@@ -194,12 +233,13 @@ func newGettyTCPConn(conn net.Conn) *gettyTCPConn {
 		reader: io.Reader(conn),
 		writer: io.Writer(conn),
 		gettyConn: gettyConn{
-			id:       connID.Add(1),
-			rTimeout: *uatomic.NewDuration(netIOTimeout),
-			wTimeout: *uatomic.NewDuration(netIOTimeout),
-			local:    localAddr,
-			peer:     peerAddr,
-			compress: CompressNone,
+			id:        connID.Add(1),
+			rTimeout:  *uatomic.NewDuration(netIOTimeout),
+			wTimeout:  *uatomic.NewDuration(netIOTimeout),
+			local:     localAddr,
+			peer:      peerAddr,
+			compress:  CompressNone,
+			connected: time.Now(),
 		},
 	}
 }
@@ -278,7 +318,8 @@ func (t *gettyTCPConn) recv(p []byte) (int, error) {
 	}
 
 	length, err = t.reader.Read(p)
-	t.readBytes.Add(uint32(length))
+	t.onRead(length)
+	t.tap(TrafficIn, p[:length])
 	return length, perrors.WithStack(err)
 }
 
@@ -306,14 +347,28 @@ func (t *gettyTCPConn) send(pkg interface{}) (int, error) {
 		}
 	}
 
+	if buffers, ok := pkg.([][]byte); ok && t.writer != io.Writer(t.conn) {
+		// the compressor should not be bypassed by writev
+		for _, p = range buffers {
+			if length, err = t.writer.Write(p); err != nil {
+				return int(lg), perrors.WithStack(err)
+			}
+			lg += int64(length)
+			t.onWrite(len(p), 1)
+			t.tap(TrafficOut, p)
+		}
+		return int(lg), nil
+	}
 	if buffers, ok := pkg.([][]byte); ok {
 		netBuf := net.Buffers(buffers)
 		lg, err = netBuf.WriteTo(t.conn)
 		if err == nil {
-			t.writeBytes.Add((uint32)(lg))
-			t.writePkgNum.Add((uint32)(len(buffers)))
+			t.onWrite(int(lg), len(buffers))
+			for _, p = range buffers {
+				t.tap(TrafficOut, p)
+			}
 		}
-		log.Debugf("localAddr: %s, remoteAddr:%s, now:%s, length:%d, err:%s",
+		transportLog.Debugf("localAddr: %s, remoteAddr:%s, now:%s, length:%d, err:%s",
 			t.conn.LocalAddr(), t.conn.RemoteAddr(), currentTime, length, err)
 		return int(lg), perrors.WithStack(err)
 	}
@@ -321,10 +376,10 @@ func (t *gettyTCPConn) send(pkg interface{}) (int, error) {
 	if p, ok = pkg.([]byte); ok {
 		length, err = t.writer.Write(p)
 		if err == nil {
-			t.writeBytes.Add((uint32)(len(p)))
-			t.writePkgNum.Add(1)
+			t.onWrite(len(p), 1)
+			t.tap(TrafficOut, p)
 		}
-		log.Debugf("localAddr: %s, remoteAddr:%s, now:%s, length:%d, err:%v",
+		transportLog.Debugf("localAddr: %s, remoteAddr:%s, now:%s, length:%d, err:%v",
 			t.conn.LocalAddr(), t.conn.RemoteAddr(), currentTime, length, err)
 		return length, perrors.WithStack(err)
 	}
@@ -341,15 +396,14 @@ func (t *gettyTCPConn) close(waitSec int) {
 	if t.conn != nil {
 		if writer, ok := t.writer.(*snappy.Writer); ok {
 			if err := writer.Close(); err != nil {
-				log.Errorf("snappy.Writer.Close() = error:%+v", err)
+				transportLog.Errorf("snappy.Writer.Close() = error:%+v", err)
 			}
 		}
-		if conn, ok := t.conn.(*net.TCPConn); ok {
+		// the tcp conn wrapped by tls.Conn or a PROXY connection lingers too
+		if conn := tcpConnOf(t.conn); conn != nil {
 			_ = conn.SetLinger(waitSec)
-			_ = conn.Close()
-		} else {
-			_ = t.conn.(*tls.Conn).Close()
 		}
+		_ = t.conn.Close()
 		t.conn = nil
 	}
 }
@@ -358,8 +412,12 @@ func (t *gettyTCPConn) close(waitSec int) {
 // getty udp connection
 // ///////////////////////////////////////
 
+// UDPContext is the package given to OnMessage by the udp session, and the package to write
+// by the unconnected udp session.
 type UDPContext struct {
-	Pkg      interface{}
+	Pkg interface{}
+	// the sender of the received package, or the receiver of the package to write which
+	// may be a multicast or broadcast address
 	PeerAddr *net.UDPAddr
 }
 
@@ -370,11 +428,11 @@ func (c UDPContext) String() string {
 type gettyUDPConn struct {
 	gettyConn
 	compressType CompressType
-	conn         *net.UDPConn // for server
+	conn         udpConn // for server
 }
 
 // create gettyUDPConn
-func newGettyUDPConn(conn *net.UDPConn) *gettyUDPConn {
+func newGettyUDPConn(conn udpConn) *gettyUDPConn {
 	if conn == nil {
 		panic("newGettyUDPConn(conn):@conn is nil")
 	}
@@ -392,12 +450,13 @@ func newGettyUDPConn(conn *net.UDPConn) *gettyUDPConn {
 	return &gettyUDPConn{
 		conn: conn,
 		gettyConn: gettyConn{
-			id:       connID.Add(1),
-			rTimeout: *uatomic.NewDuration(netIOTimeout),
-			wTimeout: *uatomic.NewDuration(netIOTimeout),
-			local:    localAddr,
-			peer:     peerAddr,
-			compress: CompressNone,
+			id:        connID.Add(1),
+			rTimeout:  *uatomic.NewDuration(netIOTimeout),
+			wTimeout:  *uatomic.NewDuration(netIOTimeout),
+			local:     localAddr,
+			peer:      peerAddr,
+			compress:  CompressNone,
+			connected: time.Now(),
 		},
 	}
 }
@@ -428,9 +487,10 @@ func (u *gettyUDPConn) recv(p []byte) (int, *net.UDPAddr, error) {
 	}
 
 	length, addr, err := u.conn.ReadFromUDP(p) // connected udp also can get return @addr
-	log.Debugf("ReadFromUDP(p:%d) = {length:%d, peerAddr:%s, error:%v}", len(p), length, addr, err)
+	transportLog.Debugf("ReadFromUDP(p:%d) = {length:%d, peerAddr:%s, error:%v}", len(p), length, addr, err)
 	if err == nil {
-		u.readBytes.Add(uint32(length))
+		u.onRead(length)
+		u.tap(TrafficIn, p[:length])
 	}
 
 	return length, addr, perrors.WithStack(err)
@@ -454,7 +514,8 @@ func (u *gettyUDPConn) send(udpCtx interface{}) (int, error) {
 	if buf, ok = ctx.Pkg.([]byte); !ok {
 		return 0, perrors.Errorf("illegal @udpCtx.Pkg{%#v} type", udpCtx)
 	}
-	if u.ss.EndPoint().EndPointType() == UDP_ENDPOINT {
+	// the peer session of udp endpoint is connected to its peer
+	if u.ss.EndPoint().EndPointType() == UDP_ENDPOINT && u.peer == "" {
 		peerAddr = ctx.PeerAddr
 		if peerAddr == nil {
 			return 0, ErrNullPeerAddr
@@ -475,10 +536,10 @@ func (u *gettyUDPConn) send(udpCtx interface{}) (int, error) {
 	}
 
 	if length, _, err = u.conn.WriteMsgUDP(buf, nil, peerAddr); err == nil {
-		u.writeBytes.Add((uint32)(len(buf)))
-		u.writePkgNum.Add(1)
+		u.onWrite(len(buf), 1)
+		u.tap(TrafficOut, buf)
 	}
-	log.Debugf("WriteMsgUDP(peerAddr:%s) = {length:%d, error:%v}", peerAddr, length, err)
+	transportLog.Debugf("WriteMsgUDP(peerAddr:%s) = {length:%d, error:%v}", peerAddr, length, err)
 
 	return length, perrors.WithStack(err)
 }
@@ -495,6 +556,37 @@ func (u *gettyUDPConn) close(_ int) {
 // getty websocket connection
 // ///////////////////////////////////////
 
+// WSCompressionOptions is the permessage-deflate(RFC 7692) options of websocket endpoints. The
+// compression context is not taken over between messages, that is, server_no_context_takeover
+// and client_no_context_takeover are always negotiated, because gorilla/websocket does not
+// support context takeover.
+type WSCompressionOptions struct {
+	// Enabled negotiates permessage-deflate with the peer, so that the peer may compress its messages.
+	Enabled bool
+	// CompressWrites compresses the written messages if permessage-deflate is negotiated.
+	// Session.SetCompressType overrides it per session.
+	CompressWrites bool
+	// Level is the flate level of the written messages, from flate.HuffmanOnly to flate.BestCompression.
+	Level int
+}
+
+// DefaultWSCompressionOptions negotiates permessage-deflate, but does not compress the written messages.
+var DefaultWSCompressionOptions = WSCompressionOptions{
+	Enabled: true,
+	Level:   flate.BestSpeed,
+}
+
+// apply should be called after newGettyWSConn, which turns off the write compression of @conn.
+func (o *WSCompressionOptions) apply(conn *websocket.Conn) {
+	compress := o.Enabled && o.CompressWrites
+	conn.EnableWriteCompression(compress)
+	if compress {
+		if err := conn.SetCompressionLevel(o.Level); err != nil {
+			transportLog.Warnf("websocket conn{%s}.SetCompressionLevel(%d) = error:%+v", conn.RemoteAddr(), o.Level, err)
+		}
+	}
+}
+
 type gettyWSConn struct {
 	gettyConn
 	conn *websocket.Conn
@@ -517,12 +609,13 @@ func newGettyWSConn(conn *websocket.Conn) *gettyWSConn {
 	gettyWSConn := &gettyWSConn{
 		conn: conn,
 		gettyConn: gettyConn{
-			id:       connID.Add(1),
-			rTimeout: *uatomic.NewDuration(netIOTimeout),
-			wTimeout: *uatomic.NewDuration(netIOTimeout),
-			local:    localAddr,
-			peer:     peerAddr,
-			compress: CompressNone,
+			id:        connID.Add(1),
+			rTimeout:  *uatomic.NewDuration(netIOTimeout),
+			wTimeout:  *uatomic.NewDuration(netIOTimeout),
+			local:     localAddr,
+			peer:      peerAddr,
+			compress:  CompressNone,
+			connected: time.Now(),
 		},
 	}
 	conn.EnableWriteCompression(false)
@@ -535,7 +628,10 @@ func newGettyWSConn(conn *websocket.Conn) *gettyWSConn {
 // SetCompressType set compress type
 func (w *gettyWSConn) SetCompressType(c CompressType) {
 	switch c {
-	case CompressNone, CompressZip, CompressBestSpeed, CompressBestCompression, CompressHuffman:
+	case CompressNone:
+		w.conn.EnableWriteCompression(false)
+
+	case CompressZip, CompressBestSpeed, CompressBestCompression, CompressHuffman:
 		w.conn.EnableWriteCompression(true)
 		w.conn.SetCompressionLevel(int(c))
 
@@ -570,10 +666,11 @@ func (w *gettyWSConn) recv() ([]byte, error) {
 	// gorilla/websocket/conn.go:NextReader will always fail when got a timeout error.
 	_, b, e := w.conn.ReadMessage() // the first return value is message type.
 	if e == nil {
-		w.readBytes.Add((uint32)(len(b)))
+		w.onRead(len(b))
+		w.tap(TrafficIn, b)
 	} else {
 		if websocket.IsUnexpectedCloseError(e, websocket.CloseGoingAway) {
-			log.Warnf("websocket unexpected close error: %v", e)
+			transportLog.Warnf("websocket unexpected close error: %v", e)
 		}
 	}
 
@@ -616,8 +713,8 @@ func (w *gettyWSConn) send(pkg interface{}) (int, error) {
 
 	w.updateWriteDeadline()
 	if err = w.conn.WriteMessage(websocket.BinaryMessage, p); err == nil {
-		w.writeBytes.Add((uint32)(len(p)))
-		w.writePkgNum.Add(1)
+		w.onWrite(len(p), 1)
+		w.tap(TrafficOut, p)
 	}
 	return len(p), perrors.WithStack(err)
 }
@@ -33,20 +33,40 @@ const (
 	TCP_CLIENT   EndPointType = 2
 	WS_CLIENT    EndPointType = 3
 	WSS_CLIENT   EndPointType = 4
+	QUIC_CLIENT  EndPointType = 5
+	UDS_CLIENT   EndPointType = 6
 	TCP_SERVER   EndPointType = 7
 	WS_SERVER    EndPointType = 8
 	WSS_SERVER   EndPointType = 9
+	QUIC_SERVER  EndPointType = 10
+	UDS_SERVER   EndPointType = 11
+	KCP_CLIENT   EndPointType = 12
+	KCP_SERVER   EndPointType = 13
+	PIPE_CLIENT  EndPointType = 14
+	PIPE_SERVER  EndPointType = 15
+	SCTP_CLIENT  EndPointType = 16
+	SCTP_SERVER  EndPointType = 17
 )
 
 var EndPointType_name = map[int32]string{
-	0: "UDP_ENDPOINT",
-	1: "UDP_CLIENT",
-	2: "TCP_CLIENT",
-	3: "WS_CLIENT",
-	4: "WSS_CLIENT",
-	7: "TCP_SERVER",
-	8: "WS_SERVER",
-	9: "WSS_SERVER",
+	0:  "UDP_ENDPOINT",
+	1:  "UDP_CLIENT",
+	2:  "TCP_CLIENT",
+	3:  "WS_CLIENT",
+	4:  "WSS_CLIENT",
+	5:  "QUIC_CLIENT",
+	6:  "UDS_CLIENT",
+	7:  "TCP_SERVER",
+	8:  "WS_SERVER",
+	9:  "WSS_SERVER",
+	10: "QUIC_SERVER",
+	11: "UDS_SERVER",
+	12: "KCP_CLIENT",
+	13: "KCP_SERVER",
+	14: "PIPE_CLIENT",
+	15: "PIPE_SERVER",
+	16: "SCTP_CLIENT",
+	17: "SCTP_SERVER",
 }
 
 var EndPointType_value = map[string]int32{
@@ -55,9 +75,19 @@ var EndPointType_value = map[string]int32{
 	"TCP_CLIENT":   2,
 	"WS_CLIENT":    3,
 	"WSS_CLIENT":   4,
+	"QUIC_CLIENT":  5,
+	"UDS_CLIENT":   6,
 	"TCP_SERVER":   7,
 	"WS_SERVER":    8,
 	"WSS_SERVER":   9,
+	"QUIC_SERVER":  10,
+	"UDS_SERVER":   11,
+	"KCP_CLIENT":   12,
+	"KCP_SERVER":   13,
+	"PIPE_CLIENT":  14,
+	"PIPE_SERVER":  15,
+	"SCTP_CLIENT":  16,
+	"SCTP_SERVER":  17,
 }
 
 func (x EndPointType) String() string {
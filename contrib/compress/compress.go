@@ -0,0 +1,87 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+// Package compress provides the lz4 and zstd Compressors of getty.CompressCodec.
+package compress
+
+import (
+	"bytes"
+	"io"
+	"sync"
+)
+
+import (
+	"github.com/klauspost/compress/zstd"
+
+	"github.com/pierrec/lz4/v4"
+)
+
+import (
+	getty "github.com/apache/dubbo-getty"
+)
+
+// Register sets the Compressors of getty.CodecCompressLZ4 and getty.CodecCompressZstd.
+func Register() {
+	getty.SetCompressor(getty.CodecCompressLZ4, LZ4{})
+	getty.SetCompressor(getty.CodecCompressZstd, Zstd{})
+}
+
+// LZ4 compresses the payloads into lz4 frames.
+type LZ4 struct{}
+
+func (LZ4) Compress(dst *bytes.Buffer, data []byte) error {
+	w := lz4.NewWriter(dst)
+	if _, err := w.Write(data); err != nil {
+		return err
+	}
+
+	return w.Close()
+}
+
+func (LZ4) NewReader(r io.Reader) (io.ReadCloser, error) {
+	return io.NopCloser(lz4.NewReader(r)), nil
+}
+
+var (
+	zstdEncoderOnce sync.Once
+	zstdEncoder     *zstd.Encoder
+)
+
+// sharedZstdEncoder returns the zstd encoder shared by the sessions, whose EncodeAll is goroutine safe.
+func sharedZstdEncoder() *zstd.Encoder {
+	zstdEncoderOnce.Do(func() {
+		zstdEncoder, _ = zstd.NewWriter(nil, zstd.WithEncoderConcurrency(1))
+	})
+	return zstdEncoder
+}
+
+// Zstd compresses the payloads into zstd frames.
+type Zstd struct{}
+
+func (Zstd) Compress(dst *bytes.Buffer, data []byte) error {
+	dst.Write(sharedZstdEncoder().EncodeAll(data, nil))
+	return nil
+}
+
+func (Zstd) NewReader(r io.Reader) (io.ReadCloser, error) {
+	decoder, err := zstd.NewReader(r, zstd.WithDecoderConcurrency(1))
+	if err != nil {
+		return nil, err
+	}
+
+	return decoder.IOReadCloser(), nil
+}
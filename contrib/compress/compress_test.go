@@ -0,0 +1,55 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package compress
+
+import (
+	"bytes"
+	"testing"
+)
+
+import (
+	"github.com/stretchr/testify/assert"
+)
+
+import (
+	getty "github.com/apache/dubbo-getty"
+)
+
+func TestCompressors(t *testing.T) {
+	Register()
+
+	data := bytes.Repeat([]byte("getty"), 100)
+	for _, typ := range []getty.CodecCompressType{getty.CodecCompressLZ4, getty.CodecCompressZstd} {
+		codec := &getty.CompressCodec{Type: typ, Threshold: 64, MaxLen: len(data)}
+		frame, err := codec.Write(nil, data)
+		assert.Nil(t, err)
+		assert.Equal(t, byte(typ), frame[0])
+		assert.Less(t, len(frame), len(data))
+
+		// the peer reads the frames whatever its own type is
+		pkg, n, err := (&getty.CompressCodec{MaxLen: len(data)}).Read(nil, frame)
+		assert.Nil(t, err)
+		assert.Equal(t, len(frame), n)
+		assert.Equal(t, data, pkg)
+
+		_, _, err = (&getty.CompressCodec{MaxLen: len(data) - 1}).Read(nil, frame)
+		assert.NotNil(t, err)
+		_, _, err = codec.Read(nil, append([]byte{byte(typ)}, "broken"...))
+		assert.NotNil(t, err)
+	}
+}
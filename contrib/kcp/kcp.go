@@ -0,0 +1,128 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+// Package kcp provides the kcp transport of getty, pls see https://github.com/skywind3000/kcp/wiki
+// for the details of kcp.
+package kcp
+
+import (
+	"context"
+	"crypto/tls"
+	"net"
+)
+
+import (
+	perrors "github.com/pkg/errors"
+
+	"github.com/xtaci/kcp-go/v5"
+)
+
+import (
+	getty "github.com/apache/dubbo-getty"
+)
+
+// Options tunes the kcp sessions.
+type Options struct {
+	// SndWnd & RcvWnd are the window sizes in packets, the default is 128.
+	SndWnd int
+	RcvWnd int
+	// NoDelay mode: set NoDelay 1, Interval 10(ms), Resend 2 and NoCongestion 1 for the fastest mode.
+	NoDelay      int
+	Interval     int
+	Resend       int
+	NoCongestion int
+	// MTU is the max transmission unit, the default is 1400.
+	MTU int
+	// DataShards & ParityShards are the FEC parameters. FEC is disabled if one of them is 0.
+	DataShards   int
+	ParityShards int
+}
+
+// DefaultOptions is the normal mode of kcp.
+var DefaultOptions = Options{
+	SndWnd:   128,
+	RcvWnd:   128,
+	Interval: 40,
+	MTU:      1400,
+}
+
+func (o *Options) apply(sess *kcp.UDPSession) {
+	// getty does the framing, so kcp works in stream mode like tcp.
+	sess.SetStreamMode(true)
+	sess.SetWindowSize(o.SndWnd, o.RcvWnd)
+	sess.SetNoDelay(o.NoDelay, o.Interval, o.Resend, o.NoCongestion)
+	if o.MTU > 0 {
+		sess.SetMtu(o.MTU)
+	}
+}
+
+// NewServer builds a kcp server whose sessions are tuned by @options.
+func NewServer(options Options, opts ...getty.ServerOption) getty.Server {
+	return getty.NewTransportServer(getty.KCP_SERVER, &transport{options: options}, opts...)
+}
+
+// NewClient builds a kcp client whose sessions are tuned by @options.
+func NewClient(options Options, opts ...getty.ClientOption) getty.Client {
+	return getty.NewTransportClient(getty.KCP_CLIENT, &transport{options: options}, opts...)
+}
+
+// transport is the getty.Transport of kcp, the tls config is ignored.
+type transport struct {
+	options Options
+}
+
+// Listen returns a listener which applies the options to every accepted kcp session. A kcp
+// session is created by the first segment from its peer, which is the connect ping of getty.
+func (t *transport) Listen(addr string, _ *tls.Config) (net.Listener, error) {
+	ln, err := kcp.ListenWithOptions(addr, nil, t.options.DataShards, t.options.ParityShards)
+	if err != nil {
+		return nil, perrors.Wrapf(err, "kcp.Listen(addr:%s)", addr)
+	}
+
+	return &listener{Listener: ln, options: t.options}, nil
+}
+
+func (t *transport) Dial(_ context.Context, addr string, _ *tls.Config) (net.Conn, error) {
+	sess, err := kcp.DialWithOptions(addr, nil, t.options.DataShards, t.options.ParityShards)
+	if err != nil {
+		return nil, perrors.Wrapf(err, "kcp.Dial(addr:%s)", addr)
+	}
+	t.options.apply(sess)
+
+	return sess, nil
+}
+
+func (t *transport) Close() error {
+	return nil
+}
+
+type listener struct {
+	net.Listener
+	options Options
+}
+
+func (l *listener) Accept() (net.Conn, error) {
+	conn, err := l.Listener.Accept()
+	if err != nil {
+		return nil, perrors.WithStack(err)
+	}
+	if sess, ok := conn.(*kcp.UDPSession); ok {
+		l.options.apply(sess)
+	}
+
+	return conn, nil
+}
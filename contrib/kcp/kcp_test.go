@@ -0,0 +1,122 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package kcp
+
+import (
+	"testing"
+	"time"
+)
+
+import (
+	"github.com/stretchr/testify/assert"
+
+	"github.com/xtaci/kcp-go/v5"
+)
+
+import (
+	getty "github.com/apache/dubbo-getty"
+)
+
+// msgListener sends the messages of the sessions to its channel.
+type msgListener struct {
+	msgs chan interface{}
+}
+
+func (l *msgListener) OnOpen(getty.Session) error {
+	return nil
+}
+
+func (l *msgListener) OnClose(getty.Session) {}
+
+func (l *msgListener) OnError(getty.Session, error) {}
+
+func (l *msgListener) OnCron(getty.Session) {}
+
+func (l *msgListener) OnMessage(_ getty.Session, pkg interface{}) {
+	l.msgs <- pkg
+}
+
+func newServer(sessions chan getty.Session) (getty.StreamServer, *msgListener) {
+	s := NewServer(Options{SndWnd: 256, RcvWnd: 256, NoDelay: 1, Interval: 10, Resend: 2, NoCongestion: 1},
+		getty.WithLocalAddress("127.0.0.1:0"))
+	listener := &msgListener{msgs: make(chan interface{}, 4)}
+	s.RunEventLoop(func(session getty.Session) error {
+		session.SetPkgHandler(&getty.LengthFieldCodec{LengthFieldSize: 4})
+		session.SetEventListener(listener)
+		if sessions != nil {
+			sessions <- session
+		}
+		return nil
+	})
+
+	return s.(getty.StreamServer), listener
+}
+
+func TestKCPServer(t *testing.T) {
+	s, serverListener := newServer(nil)
+	defer s.Close()
+
+	sessions := make(chan getty.Session, 1)
+	clt := NewClient(DefaultOptions, getty.WithServerAddress(s.Listener().Addr().String()), getty.WithConnectionNumber(1))
+	defer clt.Close()
+	clt.RunEventLoop(func(session getty.Session) error {
+		session.SetPkgHandler(&getty.LengthFieldCodec{LengthFieldSize: 4})
+		session.SetEventListener(&msgListener{msgs: make(chan interface{}, 4)})
+		sessions <- session
+		return nil
+	})
+
+	session := <-sessions
+	assert.Equal(t, getty.KCP_CLIENT, clt.EndPointType())
+	_, _, err := session.WritePkg("hello", 0)
+	assert.Nil(t, err)
+	select {
+	case msg := <-serverListener.msgs:
+		assert.Equal(t, "hello", string(msg.([]byte)))
+	case <-time.After(3 * time.Second):
+		t.Fatal("server did not receive the package")
+	}
+}
+
+func TestKCPServerSilentPeer(t *testing.T) {
+	sessions := make(chan getty.Session, 2)
+	s, _ := newServer(sessions)
+	defer s.Close()
+	addr := s.Listener().Addr().String()
+
+	// the peer which never completes its connect ping does not block the accepting
+	silent, err := kcp.DialWithOptions(addr, nil, 0, 0)
+	assert.Nil(t, err)
+	defer silent.Close()
+	_, err = silent.Write([]byte("ping"))
+	assert.Nil(t, err)
+	time.Sleep(50 * time.Millisecond)
+
+	clt := NewClient(DefaultOptions, getty.WithServerAddress(addr), getty.WithConnectionNumber(1))
+	defer clt.Close()
+	clt.RunEventLoop(func(session getty.Session) error {
+		session.SetPkgHandler(&getty.LengthFieldCodec{LengthFieldSize: 4})
+		session.SetEventListener(&msgListener{msgs: make(chan interface{}, 4)})
+		return nil
+	})
+	select {
+	case <-sessions:
+	case <-time.After(time.Second):
+		t.Fatal("server did not accept the session")
+	}
+}
@@ -0,0 +1,80 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+// Package logrus adapts the logrus loggers to getty.StructuredLogger.
+package logrus
+
+import (
+	"fmt"
+)
+
+import (
+	"github.com/sirupsen/logrus"
+)
+
+import (
+	getty "github.com/apache/dubbo-getty"
+)
+
+type logger struct {
+	e *logrus.Entry
+}
+
+// NewLogger adapts @l to getty.StructuredLogger, the key-value pairs are the fields of the entries,
+// eg: getty.SetStructuredLogger(NewLogger(logrus.StandardLogger())).
+func NewLogger(l *logrus.Logger) getty.StructuredLogger {
+	return logger{e: logrus.NewEntry(l)}
+}
+
+func (l logger) Debugw(msg string, keysAndValues ...interface{}) {
+	l.with(keysAndValues).Debug(msg)
+}
+
+func (l logger) Infow(msg string, keysAndValues ...interface{}) {
+	l.with(keysAndValues).Info(msg)
+}
+
+func (l logger) Warnw(msg string, keysAndValues ...interface{}) {
+	l.with(keysAndValues).Warn(msg)
+}
+
+func (l logger) Errorw(msg string, keysAndValues ...interface{}) {
+	l.with(keysAndValues).Error(msg)
+}
+
+func (l logger) With(keysAndValues ...interface{}) getty.StructuredLogger {
+	return logger{e: l.with(keysAndValues)}
+}
+
+// with returns the entry with the fields of @keysAndValues. The keys are formatted by fmt.Sprint,
+// and a value without its key is the field "!BADKEY" as log/slog does.
+func (l logger) with(keysAndValues []interface{}) *logrus.Entry {
+	if len(keysAndValues) == 0 {
+		return l.e
+	}
+
+	fields := make(logrus.Fields, (len(keysAndValues)+1)/2)
+	for i := 0; i < len(keysAndValues); i += 2 {
+		if i+1 == len(keysAndValues) {
+			fields["!BADKEY"] = keysAndValues[i]
+			break
+		}
+		fields[fmt.Sprint(keysAndValues[i])] = keysAndValues[i+1]
+	}
+
+	return l.e.WithFields(fields)
+}
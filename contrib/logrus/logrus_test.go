@@ -0,0 +1,42 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package logrus
+
+import (
+	"bytes"
+	"testing"
+)
+
+import (
+	"github.com/sirupsen/logrus"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLogger(t *testing.T) {
+	var buf bytes.Buffer
+	l := logrus.New()
+	l.SetOutput(&buf)
+	l.SetLevel(logrus.WarnLevel)
+	l.SetFormatter(&logrus.TextFormatter{DisableTimestamp: true})
+	logger := NewLogger(l)
+	logger.Infow("dropped")
+	logger.With("session_id", 1).Warnw("slow write", "size", 10, "dangling")
+	assert.NotContains(t, buf.String(), "dropped")
+	assert.Equal(t, `level=warning msg="slow write" !BADKEY=dangling session_id=1 size=10`+"\n", buf.String())
+}
@@ -0,0 +1,138 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+// Package otel records getty.Metrics and getty.Tracing by the opentelemetry API.
+package otel
+
+import (
+	"context"
+	"errors"
+	"time"
+)
+
+import (
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/metric"
+)
+
+import (
+	getty "github.com/apache/dubbo-getty"
+)
+
+// MetricsObserver is a getty.MetricsObserver which records the handler latency and the package
+// size by the histograms of a metric.Meter. The histograms carry the endpoint type of the session.
+type MetricsObserver struct {
+	latency metric.Float64Histogram
+	pkgSize metric.Int64Histogram
+}
+
+// NewMetricsObserver returns the MetricsObserver whose histograms are created by @meter, and
+// bucketed by getty.DefaultLatencyBuckets and getty.DefaultSizeBuckets.
+func NewMetricsObserver(meter metric.Meter) (*MetricsObserver, error) {
+	latency, err := meter.Float64Histogram("getty.handler.latency", metric.WithUnit("s"),
+		metric.WithDescription("The latency of OnMessage."),
+		metric.WithExplicitBucketBoundaries(getty.DefaultLatencyBuckets...))
+	if err != nil {
+		return nil, err
+	}
+	pkgSize, err := meter.Int64Histogram("getty.write.pkg.size", metric.WithUnit("By"),
+		metric.WithDescription("The encoded size of the packages written by WritePkg."),
+		metric.WithExplicitBucketBoundaries(getty.DefaultSizeBuckets...))
+	if err != nil {
+		return nil, err
+	}
+
+	return &MetricsObserver{latency: latency, pkgSize: pkgSize}, nil
+}
+
+func (o *MetricsObserver) ObserveHandlerLatency(session getty.Session, latency time.Duration) {
+	ctx, attrs := sessionContext(session)
+	o.latency.Record(ctx, latency.Seconds(), attrs)
+}
+
+func (o *MetricsObserver) ObserveWritePkgSize(session getty.Session, size int) {
+	ctx, attrs := sessionContext(session)
+	o.pkgSize.Record(ctx, int64(size), attrs)
+}
+
+// sessionContext returns the context and the attributes of the observations of @session.
+func sessionContext(session getty.Session) (context.Context, metric.MeasurementOption) {
+	if session == nil || session.EndPoint() == nil {
+		return context.Background(), metric.WithAttributes()
+	}
+
+	return session.Context(), metric.WithAttributes(endPointAttribute(getty.EndPointName(session.EndPoint())))
+}
+
+// endPointAttribute returns the attribute of the endpoint named @name by getty.EndPointName.
+func endPointAttribute(name string) attribute.KeyValue {
+	return attribute.String("getty.endpoint", name)
+}
+
+// InstrumentMetrics records @metrics by the instruments of @meter. It sets the Observer of @metrics,
+// so it should be called before the Interceptor of @metrics is used, and registers the observable
+// counters and gauges which read the other metrics from Snapshot on every collection.
+func InstrumentMetrics(meter metric.Meter, metrics *getty.Metrics) error {
+	observer, err := NewMetricsObserver(meter)
+	if err != nil {
+		return err
+	}
+
+	var errs []error
+	counter := func(name, unit, description string) metric.Int64ObservableCounter {
+		c, err := meter.Int64ObservableCounter(name, metric.WithUnit(unit), metric.WithDescription(description))
+		errs = append(errs, err)
+		return c
+	}
+	gauge := func(name, description string) metric.Int64ObservableGauge {
+		g, err := meter.Int64ObservableGauge(name, metric.WithDescription(description))
+		errs = append(errs, err)
+		return g
+	}
+	var (
+		sessions        = gauge("getty.sessions", "The live sessions.")
+		opened          = counter("getty.sessions.opened", "{session}", "The sessions accepted by the servers or connected by the clients.")
+		reconnects      = counter("getty.reconnects", "{session}", "The sessions reconnected by the client pools.")
+		readBytes       = counter("getty.read.bytes", "By", "The bytes read by the sessions.")
+		writeBytes      = counter("getty.write.bytes", "By", "The bytes written by the sessions.")
+		writeQueueDepth = gauge("getty.write_queue.depth", "The packages queued by WritePkgAsync but not written yet.")
+		taskQueueLength = gauge("getty.task_queue.length", "The packages being handled by OnMessage or queued in the task pools.")
+	)
+	if err = errors.Join(errs...); err != nil {
+		return err
+	}
+
+	_, err = meter.RegisterCallback(func(_ context.Context, o metric.Observer) error {
+		for _, e := range metrics.Snapshot().EndPoints {
+			attrs := metric.WithAttributes(endPointAttribute(e.EndPoint))
+			o.ObserveInt64(sessions, int64(e.Sessions), attrs)
+			o.ObserveInt64(opened, int64(e.Opened), attrs)
+			o.ObserveInt64(reconnects, int64(e.Reconnects), attrs)
+			o.ObserveInt64(readBytes, int64(e.ReadBytes), attrs)
+			o.ObserveInt64(writeBytes, int64(e.WriteBytes), attrs)
+			o.ObserveInt64(writeQueueDepth, int64(e.WriteQueueDepth), attrs)
+			o.ObserveInt64(taskQueueLength, int64(e.TaskQueueLength), attrs)
+		}
+		return nil
+	}, sessions, opened, reconnects, readBytes, writeBytes, writeQueueDepth, taskQueueLength)
+	if err != nil {
+		return err
+	}
+	metrics.Observer = observer
+
+	return nil
+}
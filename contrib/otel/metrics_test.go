@@ -0,0 +1,99 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package otel
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+import (
+	sdkmetric "go.opentelemetry.io/otel/sdk/metric"
+	"go.opentelemetry.io/otel/sdk/metric/metricdata"
+
+	"github.com/stretchr/testify/assert"
+)
+
+import (
+	getty "github.com/apache/dubbo-getty"
+	"github.com/apache/dubbo-getty/gettytest"
+)
+
+type nopListener struct {
+	getty.EventListener
+}
+
+func (nopListener) OnOpen(getty.Session) error { return nil }
+
+func (nopListener) OnMessage(getty.Session, interface{}) {}
+
+func TestInstrumentMetrics(t *testing.T) {
+	reader := sdkmetric.NewManualReader()
+	provider := sdkmetric.NewMeterProvider(sdkmetric.WithReader(reader))
+	defer provider.Shutdown(context.Background())
+
+	metrics := getty.NewMetrics()
+	assert.Nil(t, InstrumentMetrics(provider.Meter("getty"), metrics))
+	assert.NotNil(t, metrics.Observer)
+
+	session := gettytest.NewMockSession("127.0.0.1:8080", "127.0.0.1:9090")
+	server := getty.NewTCPServer()
+	session.SetEndPoint(server)
+	assert.Nil(t, metrics.Interceptor().Listener(nopListener{}).OnOpen(session))
+	write := metrics.Interceptor().Write(func(getty.Session, interface{}, time.Duration) (int, int, error) {
+		return 100, 100, nil
+	})
+	_, _, err := write(session, "ping", 0)
+	assert.Nil(t, err)
+	metrics.Interceptor().Listener(nopListener{}).OnMessage(session, "ping")
+
+	var rm metricdata.ResourceMetrics
+	assert.Nil(t, reader.Collect(context.Background(), &rm))
+	found := make(map[string]metricdata.Aggregation)
+	for _, sm := range rm.ScopeMetrics {
+		for _, m := range sm.Metrics {
+			found[m.Name] = m.Data
+		}
+	}
+	for _, name := range []string{
+		"getty.handler.latency", "getty.sessions", "getty.sessions.opened", "getty.reconnects",
+		"getty.read.bytes", "getty.write.bytes", "getty.write_queue.depth", "getty.task_queue.length",
+	} {
+		assert.Contains(t, found, name)
+	}
+
+	size, ok := found["getty.write.pkg.size"].(metricdata.Histogram[int64])
+	assert.True(t, ok)
+	assert.Equal(t, 1, len(size.DataPoints))
+	assert.Equal(t, uint64(1), size.DataPoints[0].Count)
+	assert.Equal(t, int64(100), size.DataPoints[0].Sum)
+	assert.Equal(t, getty.DefaultSizeBuckets, size.DataPoints[0].Bounds)
+	endpoint, _ := size.DataPoints[0].Attributes.Value("getty.endpoint")
+	assert.Equal(t, getty.EndPointName(server), endpoint.AsString())
+	latency, ok := found["getty.handler.latency"].(metricdata.Histogram[float64])
+	assert.True(t, ok)
+	assert.Equal(t, uint64(1), latency.DataPoints[0].Count)
+
+	sessions, ok := found["getty.sessions"].(metricdata.Gauge[int64])
+	assert.True(t, ok)
+	assert.Equal(t, 1, len(sessions.DataPoints))
+	assert.Equal(t, int64(1), sessions.DataPoints[0].Value)
+	endpoint, _ = sessions.DataPoints[0].Attributes.Value("getty.endpoint")
+	assert.Equal(t, getty.EndPointName(server), endpoint.AsString())
+}
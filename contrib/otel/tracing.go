@@ -0,0 +1,94 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package otel
+
+import (
+	"context"
+)
+
+import (
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/trace"
+)
+
+import (
+	getty "github.com/apache/dubbo-getty"
+)
+
+// NewTracer returns the getty.Tracer which starts the spans of getty.Tracing by @tracer, eg:
+// otel.Tracer("getty").
+func NewTracer(tracer trace.Tracer) getty.Tracer {
+	return &tracerAdapter{tracer: tracer}
+}
+
+type tracerAdapter struct {
+	tracer trace.Tracer
+}
+
+func (t *tracerAdapter) Start(ctx context.Context, name string) (context.Context, getty.Span) {
+	ctx, span := t.tracer.Start(ctx, name)
+	return ctx, spanAdapter{span: span}
+}
+
+type spanAdapter struct {
+	span trace.Span
+}
+
+func (s spanAdapter) SetAttributes(attrs map[string]string) {
+	kvs := make([]attribute.KeyValue, 0, len(attrs))
+	for k, v := range attrs {
+		kvs = append(kvs, attribute.String(k, v))
+	}
+	s.span.SetAttributes(kvs...)
+}
+
+func (s spanAdapter) RecordError(err error) {
+	s.span.RecordError(err)
+	s.span.SetStatus(codes.Error, err.Error())
+}
+
+func (s spanAdapter) End() {
+	s.span.End()
+}
+
+// NewTraceCarrier returns the getty.TraceCarrier which propagates the trace context by @propagator,
+// eg: propagation.TraceContext{}, in the headers of the packages returned by @headersOf. @headersOf
+// returns nil for the packages without headers.
+func NewTraceCarrier(propagator propagation.TextMapPropagator, headersOf func(pkg interface{}) propagation.TextMapCarrier) getty.TraceCarrier {
+	return &traceCarrier{propagator: propagator, headersOf: headersOf}
+}
+
+type traceCarrier struct {
+	propagator propagation.TextMapPropagator
+	headersOf  func(pkg interface{}) propagation.TextMapCarrier
+}
+
+func (c *traceCarrier) Extract(ctx context.Context, pkg interface{}) context.Context {
+	if headers := c.headersOf(pkg); headers != nil {
+		return c.propagator.Extract(ctx, headers)
+	}
+	return ctx
+}
+
+func (c *traceCarrier) Inject(ctx context.Context, pkg interface{}) {
+	if headers := c.headersOf(pkg); headers != nil {
+		c.propagator.Inject(ctx, headers)
+	}
+}
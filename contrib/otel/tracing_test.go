@@ -0,0 +1,100 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package otel
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+import (
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/propagation"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+
+	"github.com/stretchr/testify/assert"
+)
+
+import (
+	getty "github.com/apache/dubbo-getty"
+	"github.com/apache/dubbo-getty/gettytest"
+)
+
+// headersPkg is a package whose headers carry the trace context.
+type headersPkg struct {
+	headers propagation.MapCarrier
+}
+
+// replyListener replies the received package with its trace context.
+type replyListener struct {
+	getty.EventListener
+	reply *headersPkg
+}
+
+func (l *replyListener) OnMessage(_ getty.Session, pkg interface{}) {
+	l.reply = &headersPkg{headers: propagation.MapCarrier{}}
+	for k, v := range pkg.(*headersPkg).headers {
+		l.reply.headers[k] = v
+	}
+}
+
+func TestTracing(t *testing.T) {
+	recorder := tracetest.NewSpanRecorder()
+	provider := sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder))
+	tracing := &getty.Tracing{
+		Tracer: NewTracer(provider.Tracer("getty")),
+		Carrier: NewTraceCarrier(propagation.TraceContext{}, func(pkg interface{}) propagation.TextMapCarrier {
+			if p, ok := pkg.(*headersPkg); ok {
+				return p.headers
+			}
+			return nil
+		}),
+	}
+	interceptor := tracing.Interceptor()
+
+	session := gettytest.NewMockSession("127.0.0.1:8080", "127.0.0.1:9090")
+	session.SetEndPoint(getty.NewTCPServer())
+	listener := &replyListener{}
+	interceptor.Listener(listener).OnMessage(session, &headersPkg{headers: propagation.MapCarrier{}})
+	errWrite := errors.New("write error")
+	write := interceptor.Write(func(getty.Session, interface{}, time.Duration) (int, int, error) {
+		return 0, 0, errWrite
+	})
+	_, _, err := write(session, listener.reply, 0)
+	assert.Equal(t, errWrite, err)
+
+	spans := recorder.Ended()
+	assert.Equal(t, 2, len(spans))
+	onMessage, writePkg := spans[0], spans[1]
+	assert.Equal(t, "getty.OnMessage", onMessage.Name())
+	assert.Equal(t, "getty.WritePkg", writePkg.Name())
+	// the span of WritePkg is the child of the one of OnMessage by the headers of the packages
+	assert.Equal(t, onMessage.SpanContext().TraceID(), writePkg.Parent().TraceID())
+	assert.Equal(t, onMessage.SpanContext().SpanID(), writePkg.Parent().SpanID())
+	assert.Equal(t, codes.Error, writePkg.Status().Code)
+	assert.Equal(t, 1, len(writePkg.Events()))
+
+	attrs := make(map[string]string)
+	for _, kv := range onMessage.Attributes() {
+		attrs[string(kv.Key)] = kv.Value.AsString()
+	}
+	assert.Equal(t, "127.0.0.1:9090", attrs["net.sock.peer.addr"])
+	assert.Equal(t, getty.TCP_SERVER.String(), attrs["getty.endpoint"])
+}
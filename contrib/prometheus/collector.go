@@ -0,0 +1,103 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+// Package prometheus exports getty.Metrics as prometheus metrics.
+package prometheus
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+import (
+	getty "github.com/apache/dubbo-getty"
+)
+
+// endPointLabel is the label of the metrics of every endpoint, whose value is getty.EndPointName.
+const endPointLabel = "endpoint"
+
+// Collector is a prometheus.Collector which converts the snapshot of a getty.Metrics to the const
+// metrics on every scrape, eg: prometheus.MustRegister(NewCollector(metrics, "getty", nil)).
+// The metrics of the sessions are labeled by their endpoints, and the histograms are not.
+type Collector struct {
+	metrics *getty.Metrics
+
+	sessions        *prometheus.Desc
+	opened          *prometheus.Desc
+	reconnects      *prometheus.Desc
+	readBytes       *prometheus.Desc
+	writeBytes      *prometheus.Desc
+	writeQueueDepth *prometheus.Desc
+	taskQueueLength *prometheus.Desc
+	writePkgSize    *prometheus.Desc
+	handlerLatency  *prometheus.Desc
+}
+
+// NewCollector returns the Collector of @metrics, whose metric names are prefixed by @namespace
+// and which carry @constLabels.
+func NewCollector(metrics *getty.Metrics, namespace string, constLabels prometheus.Labels) *Collector {
+	desc := func(name, help string, labels ...string) *prometheus.Desc {
+		return prometheus.NewDesc(prometheus.BuildFQName(namespace, "", name), help, labels, constLabels)
+	}
+
+	return &Collector{
+		metrics:         metrics,
+		sessions:        desc("sessions", "The live sessions.", endPointLabel),
+		opened:          desc("sessions_opened_total", "The sessions accepted by the servers or connected by the clients.", endPointLabel),
+		reconnects:      desc("reconnects_total", "The sessions reconnected by the client pools.", endPointLabel),
+		readBytes:       desc("read_bytes_total", "The bytes read by the sessions.", endPointLabel),
+		writeBytes:      desc("write_bytes_total", "The bytes written by the sessions.", endPointLabel),
+		writeQueueDepth: desc("write_queue_depth", "The packages queued by WritePkgAsync but not written yet.", endPointLabel),
+		taskQueueLength: desc("task_queue_length", "The packages being handled by OnMessage or queued in the task pools.", endPointLabel),
+		writePkgSize:    desc("write_pkg_size_bytes", "The encoded size of the packages written by WritePkg."),
+		handlerLatency:  desc("handler_latency_seconds", "The latency of OnMessage."),
+	}
+}
+
+// Describe implements prometheus.Collector.
+func (c *Collector) Describe(ch chan<- *prometheus.Desc) {
+	for _, desc := range []*prometheus.Desc{
+		c.sessions, c.opened, c.reconnects, c.readBytes, c.writeBytes,
+		c.writeQueueDepth, c.taskQueueLength, c.writePkgSize, c.handlerLatency,
+	} {
+		ch <- desc
+	}
+}
+
+// Collect implements prometheus.Collector.
+func (c *Collector) Collect(ch chan<- prometheus.Metric) {
+	s := c.metrics.Snapshot()
+	for _, e := range s.EndPoints {
+		ch <- prometheus.MustNewConstMetric(c.sessions, prometheus.GaugeValue, float64(e.Sessions), e.EndPoint)
+		ch <- prometheus.MustNewConstMetric(c.opened, prometheus.CounterValue, float64(e.Opened), e.EndPoint)
+		ch <- prometheus.MustNewConstMetric(c.reconnects, prometheus.CounterValue, float64(e.Reconnects), e.EndPoint)
+		ch <- prometheus.MustNewConstMetric(c.readBytes, prometheus.CounterValue, float64(e.ReadBytes), e.EndPoint)
+		ch <- prometheus.MustNewConstMetric(c.writeBytes, prometheus.CounterValue, float64(e.WriteBytes), e.EndPoint)
+		ch <- prometheus.MustNewConstMetric(c.writeQueueDepth, prometheus.GaugeValue, float64(e.WriteQueueDepth), e.EndPoint)
+		ch <- prometheus.MustNewConstMetric(c.taskQueueLength, prometheus.GaugeValue, float64(e.TaskQueueLength), e.EndPoint)
+	}
+	ch <- constHistogram(c.writePkgSize, s.WritePkgSize)
+	ch <- constHistogram(c.handlerLatency, s.HandlerLatency)
+}
+
+func constHistogram(desc *prometheus.Desc, h getty.HistogramSnapshot) prometheus.Metric {
+	buckets := make(map[float64]uint64, len(h.Buckets))
+	for i, bound := range h.Buckets {
+		buckets[bound] = h.Counts[i]
+	}
+
+	return prometheus.MustNewConstHistogram(desc, h.Count, h.Sum, buckets)
+}
@@ -0,0 +1,104 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package prometheus
+
+import (
+	"testing"
+	"time"
+)
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+
+	dto "github.com/prometheus/client_model/go"
+
+	"github.com/stretchr/testify/assert"
+)
+
+import (
+	getty "github.com/apache/dubbo-getty"
+)
+
+func TestCollector(t *testing.T) {
+	metrics := getty.NewMetrics()
+	write := metrics.Interceptor().Write(func(getty.Session, interface{}, time.Duration) (int, int, error) {
+		return 100, 100, nil
+	})
+	_, _, err := write(nil, "ping", 0)
+	assert.Nil(t, err)
+	client, server := getty.NewPipeEndpoint()
+	defer client.Close()
+	defer server.Close()
+	sessions := make(chan getty.Session, 2)
+	for _, endPoint := range []getty.EndPoint{server, client} {
+		endPoint.RunEventLoop(func(session getty.Session) error {
+			session.SetPkgHandler(&getty.LengthFieldCodec{LengthFieldSize: 4})
+			session.SetEventListener(&nopListener{})
+			sessions <- session
+			return nil
+		})
+		assert.Nil(t, metrics.Interceptor().Listener(&nopListener{}).OnOpen(<-sessions))
+	}
+
+	registry := prometheus.NewRegistry()
+	assert.Nil(t, registry.Register(NewCollector(metrics, "getty", prometheus.Labels{"app": "test"})))
+	families, err := registry.Gather()
+	assert.Nil(t, err)
+
+	found := make(map[string]*dto.MetricFamily, len(families))
+	for _, f := range families {
+		found[f.GetName()] = f
+	}
+	for _, name := range []string{
+		"getty_sessions", "getty_sessions_opened_total", "getty_reconnects_total", "getty_read_bytes_total",
+		"getty_write_bytes_total", "getty_write_queue_depth", "getty_task_queue_length",
+		"getty_write_pkg_size_bytes", "getty_handler_latency_seconds",
+	} {
+		assert.Contains(t, found, name)
+	}
+
+	size := found["getty_write_pkg_size_bytes"].GetMetric()[0]
+	assert.Equal(t, "app", size.GetLabel()[0].GetName())
+	histogram := size.GetHistogram()
+	assert.Equal(t, uint64(1), histogram.GetSampleCount())
+	assert.Equal(t, float64(100), histogram.GetSampleSum())
+	assert.Equal(t, len(getty.DefaultSizeBuckets), len(histogram.GetBucket()))
+	// 100 bytes is in the bucket of 256 bytes
+	assert.Equal(t, uint64(0), histogram.GetBucket()[0].GetCumulativeCount())
+	assert.Equal(t, uint64(1), histogram.GetBucket()[1].GetCumulativeCount())
+	assert.Equal(t, dto.MetricType_GAUGE, found["getty_sessions"].GetType())
+
+	// the metrics of the sessions are labeled by the endpoints
+	endPoints := make(map[string]float64)
+	for _, m := range found["getty_sessions"].GetMetric() {
+		for _, label := range m.GetLabel() {
+			if label.GetName() == "endpoint" {
+				endPoints[label.GetValue()] = m.GetGauge().GetValue()
+			}
+		}
+	}
+	assert.Equal(t, map[string]float64{getty.EndPointName(client): 1, getty.EndPointName(server): 1}, endPoints)
+}
+
+type nopListener struct{}
+
+func (l *nopListener) OnOpen(getty.Session) error           { return nil }
+func (l *nopListener) OnClose(getty.Session)                {}
+func (l *nopListener) OnError(getty.Session, error)         {}
+func (l *nopListener) OnCron(getty.Session)                 {}
+func (l *nopListener) OnMessage(getty.Session, interface{}) {}
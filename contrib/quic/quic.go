@@ -0,0 +1,258 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+// Package quic provides the quic transport of getty. Every stream of a quic connection is
+// served as a session, so the sessions of a client share one quic connection.
+package quic
+
+import (
+	"context"
+	"crypto/tls"
+	"net"
+	"sync"
+)
+
+import (
+	perrors "github.com/pkg/errors"
+
+	"github.com/quic-go/quic-go"
+)
+
+import (
+	getty "github.com/apache/dubbo-getty"
+)
+
+const (
+	// nextProto is the ALPN protocol used when the tls config does not set one.
+	nextProto = "getty"
+	// streamBacklog is the number of accepted but not yet served streams.
+	streamBacklog = 128
+)
+
+// NewServer builds a quic server. Every stream opened by the peer is served as a session, and
+// @builder provides the tls config which is required by quic.
+func NewServer(builder getty.TlsConfigBuilder, opts ...getty.ServerOption) getty.Server {
+	if builder == nil {
+		panic("quic server requires a TlsConfigBuilder")
+	}
+
+	return getty.NewTransportServer(getty.QUIC_SERVER, &transport{},
+		append(opts, getty.WithServerTlsConfigBuilder(builder))...)
+}
+
+// NewClient builds a quic client. Every session is a stream of the same quic connection, and
+// the tls config of @builder is required to verify the server.
+func NewClient(builder getty.TlsConfigBuilder, opts ...getty.ClientOption) getty.Client {
+	if builder == nil {
+		panic("quic client requires a TlsConfigBuilder")
+	}
+
+	return getty.NewTransportClient(getty.QUIC_CLIENT, &transport{},
+		append(opts, getty.WithClientTlsConfigBuilder(builder))...)
+}
+
+// tlsConfig returns a copy of @config which can be used by quic-go. The nil @config verifies
+// the peer by the system roots.
+func tlsConfig(config *tls.Config) *tls.Config {
+	if config == nil {
+		config = &tls.Config{}
+	} else {
+		config = config.Clone()
+	}
+	if len(config.NextProtos) == 0 {
+		config.NextProtos = []string{nextProto}
+	}
+
+	return config
+}
+
+// transport is the getty.Transport of quic. The streams dialed by a client are opened on its
+// quic connection, which is redialed after it has been closed.
+type transport struct {
+	lock sync.Mutex
+	conn *quic.Conn
+}
+
+func (t *transport) Listen(addr string, config *tls.Config) (net.Listener, error) {
+	ln, err := quic.ListenAddr(addr, tlsConfig(config), nil)
+	if err != nil {
+		return nil, perrors.Wrapf(err, "quic.ListenAddr(addr:%s)", addr)
+	}
+
+	return newListener(ln), nil
+}
+
+func (t *transport) Dial(ctx context.Context, addr string, config *tls.Config) (net.Conn, error) {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+
+	if t.conn == nil || t.conn.Context().Err() != nil {
+		conn, err := quic.DialAddr(ctx, addr, tlsConfig(config), nil)
+		if err != nil {
+			return nil, perrors.Wrapf(err, "quic.DialAddr(addr:%s)", addr)
+		}
+		t.conn = conn
+	}
+
+	stream, err := t.conn.OpenStreamSync(ctx)
+	if err != nil {
+		return nil, perrors.Wrapf(err, "OpenStreamSync()")
+	}
+
+	return &streamConn{Stream: stream, conn: t.conn}, nil
+}
+
+func (t *transport) Close() error {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+
+	if t.conn == nil {
+		return nil
+	}
+	err := t.conn.CloseWithError(0, "client closed")
+	t.conn = nil
+
+	return perrors.WithStack(err)
+}
+
+// streamConn maps a quic stream to a net.Conn, so that it can be served as a tcp connection.
+type streamConn struct {
+	*quic.Stream
+	conn *quic.Conn
+	// release is called once the stream is closed, nil for the streams of a client.
+	release func()
+	once    sync.Once
+}
+
+// ConnectionState returns the tls state of the quic connection.
+func (c *streamConn) ConnectionState() tls.ConnectionState {
+	return c.conn.ConnectionState().TLS
+}
+
+func (c *streamConn) LocalAddr() net.Addr {
+	return c.conn.LocalAddr()
+}
+
+func (c *streamConn) RemoteAddr() net.Addr {
+	return c.conn.RemoteAddr()
+}
+
+// Close closes both directions of the stream. The quic connection is kept alive for the other
+// streams of a client, and the accepted one is closed with its last stream.
+func (c *streamConn) Close() error {
+	c.Stream.CancelRead(0)
+	err := c.Stream.Close()
+	if c.release != nil {
+		c.once.Do(c.release)
+	}
+
+	return err
+}
+
+// listener is a net.Listener which returns every stream of every accepted quic connection
+// as a net.Conn.
+type listener struct {
+	ln      *quic.Listener
+	streams chan net.Conn
+	ctx     context.Context
+	cancel  context.CancelFunc
+	once    sync.Once
+}
+
+func newListener(ln *quic.Listener) *listener {
+	ctx, cancel := context.WithCancel(context.Background())
+	l := &listener{
+		ln:      ln,
+		streams: make(chan net.Conn, streamBacklog),
+		ctx:     ctx,
+		cancel:  cancel,
+	}
+	go l.acceptConns()
+
+	return l
+}
+
+func (l *listener) acceptConns() {
+	for {
+		conn, err := l.ln.Accept(l.ctx)
+		if err != nil {
+			getty.GetLogger().Infof("quic listener{%s} stop accepting connections, err:%v", l.ln.Addr(), err)
+			return
+		}
+		go l.acceptStreams(conn)
+	}
+}
+
+// acceptStreams returns the streams of @conn by Accept, whose connect pings are read by getty
+// in their own goroutines. @conn is closed once all of its accepted streams are closed.
+func (l *listener) acceptStreams(conn *quic.Conn) {
+	var (
+		lock    sync.Mutex
+		streams int
+	)
+	release := func() {
+		lock.Lock()
+		streams--
+		last := streams == 0
+		lock.Unlock()
+		if last {
+			conn.CloseWithError(0, "all streams closed")
+		}
+	}
+
+	for {
+		stream, err := conn.AcceptStream(l.ctx)
+		if err != nil {
+			getty.GetLogger().Debugf("quic connection{%s} stop accepting streams, err:%v", conn.RemoteAddr(), err)
+			return
+		}
+
+		lock.Lock()
+		streams++
+		lock.Unlock()
+		sc := &streamConn{Stream: stream, conn: conn, release: release}
+		select {
+		case l.streams <- sc:
+		case <-l.ctx.Done():
+			sc.Close()
+			return
+		}
+	}
+}
+
+func (l *listener) Accept() (net.Conn, error) {
+	select {
+	case conn := <-l.streams:
+		return conn, nil
+	case <-l.ctx.Done():
+		return nil, perrors.WithStack(net.ErrClosed)
+	}
+}
+
+func (l *listener) Close() error {
+	var err error
+	l.once.Do(func() {
+		l.cancel()
+		err = l.ln.Close()
+	})
+
+	return perrors.WithStack(err)
+}
+
+func (l *listener) Addr() net.Addr {
+	return l.ln.Addr()
+}
@@ -0,0 +1,188 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package quic
+
+import (
+	"context"
+	"crypto/tls"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+import (
+	"github.com/stretchr/testify/assert"
+)
+
+import (
+	getty "github.com/apache/dubbo-getty"
+)
+
+// msgListener sends the messages of the sessions to its channel.
+type msgListener struct {
+	msgs chan interface{}
+}
+
+func (l *msgListener) OnOpen(getty.Session) error {
+	return nil
+}
+
+func (l *msgListener) OnClose(getty.Session) {}
+
+func (l *msgListener) OnError(getty.Session, error) {}
+
+func (l *msgListener) OnCron(getty.Session) {}
+
+func (l *msgListener) OnMessage(_ getty.Session, pkg interface{}) {
+	l.msgs <- pkg
+}
+
+func certPath(name string) string {
+	path, _ := filepath.Abs(filepath.Join("../../demo/hello/tls/certs", name))
+	return path
+}
+
+func newServer(sessions chan getty.Session) (getty.StreamServer, *msgListener) {
+	s := NewServer(&getty.ServerTlsConfigBuilder{
+		ServerKeyCertChainPath: certPath("server0.pem"),
+		ServerPrivateKeyPath:   certPath("server0.key"),
+	}, getty.WithLocalAddress("127.0.0.1:0"))
+	listener := &msgListener{msgs: make(chan interface{}, 4)}
+	s.RunEventLoop(func(session getty.Session) error {
+		session.SetPkgHandler(&getty.LengthFieldCodec{LengthFieldSize: 4})
+		session.SetEventListener(listener)
+		if sessions != nil {
+			sessions <- session
+		}
+		return nil
+	})
+
+	return s.(getty.StreamServer), listener
+}
+
+func clientTlsConfig(t *testing.T) *tls.Config {
+	config, err := (&getty.ClientTlsConfigBuilder{
+		ClientKeyCertChainPath:        certPath("client.pem"),
+		ClientPrivateKeyPath:          certPath("client.key"),
+		ClientTrustCertCollectionPath: certPath("ca.pem"),
+	}).BuildTlsConfig()
+	assert.Nil(t, err)
+	return config
+}
+
+func TestQUICServer(t *testing.T) {
+	s, serverListener := newServer(nil)
+	defer s.Close()
+
+	sessions := make(chan getty.Session, 2)
+	clt := NewClient(&getty.ClientTlsConfigBuilder{
+		ClientKeyCertChainPath:        certPath("client.pem"),
+		ClientPrivateKeyPath:          certPath("client.key"),
+		ClientTrustCertCollectionPath: certPath("ca.pem"),
+	}, getty.WithServerAddress(s.Listener().Addr().String()), getty.WithConnectionNumber(2))
+	defer clt.Close()
+	clt.RunEventLoop(func(session getty.Session) error {
+		session.SetPkgHandler(&getty.LengthFieldCodec{LengthFieldSize: 4})
+		session.SetEventListener(&msgListener{msgs: make(chan interface{}, 4)})
+		sessions <- session
+		return nil
+	})
+
+	for i := 0; i < 2; i++ {
+		_, _, err := (<-sessions).WritePkg("hello", 0)
+		assert.Nil(t, err)
+	}
+	for i := 0; i < 2; i++ {
+		select {
+		case msg := <-serverListener.msgs:
+			assert.Equal(t, "hello", string(msg.([]byte)))
+		case <-time.After(3 * time.Second):
+			t.Fatal("server did not receive the package")
+		}
+	}
+}
+
+func TestQUICClientVerifiesServer(t *testing.T) {
+	assert.Panics(t, func() { NewClient(nil, getty.WithServerAddress("127.0.0.1:1")) })
+	assert.False(t, tlsConfig(nil).InsecureSkipVerify)
+}
+
+func TestQUICServerSilentStream(t *testing.T) {
+	sessions := make(chan getty.Session, 2)
+	s, _ := newServer(sessions)
+	defer s.Close()
+	addr := s.Listener().Addr().String()
+
+	tr := &transport{}
+	defer tr.Close()
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	// the stream which never completes its connect ping does not block the other streams
+	silent, err := tr.Dial(ctx, addr, clientTlsConfig(t))
+	assert.Nil(t, err)
+	_, err = silent.Write([]byte("ping"))
+	assert.Nil(t, err)
+	time.Sleep(50 * time.Millisecond)
+
+	clt := NewClient(&getty.ClientTlsConfigBuilder{
+		ClientKeyCertChainPath:        certPath("client.pem"),
+		ClientPrivateKeyPath:          certPath("client.key"),
+		ClientTrustCertCollectionPath: certPath("ca.pem"),
+	}, getty.WithServerAddress(addr), getty.WithConnectionNumber(1))
+	defer clt.Close()
+	clt.RunEventLoop(func(session getty.Session) error {
+		session.SetPkgHandler(&getty.LengthFieldCodec{LengthFieldSize: 4})
+		session.SetEventListener(&msgListener{msgs: make(chan interface{}, 4)})
+		return nil
+	})
+	select {
+	case <-sessions:
+	case <-time.After(time.Second):
+		t.Fatal("server did not accept the session")
+	}
+}
+
+func TestQUICServerClosesIdleConnection(t *testing.T) {
+	sessions := make(chan getty.Session, 2)
+	s, _ := newServer(sessions)
+	defer s.Close()
+
+	tr := &transport{}
+	defer tr.Close()
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+	conn, err := tr.Dial(ctx, s.Listener().Addr().String(), clientTlsConfig(t))
+	assert.Nil(t, err)
+	// the connect ping of getty
+	_, err = conn.Write([]byte("connect-ping"))
+	assert.Nil(t, err)
+	select {
+	case <-sessions:
+	case <-time.After(3 * time.Second):
+		t.Fatal("server did not accept the session")
+	}
+
+	// the server closes the quic connection with its last stream
+	conn.Close()
+	select {
+	case <-conn.(*streamConn).conn.Context().Done():
+	case <-time.After(3 * time.Second):
+		t.Fatal("server did not close the quic connection")
+	}
+}
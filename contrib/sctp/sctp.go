@@ -0,0 +1,559 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+// Package sctp provides the sctp transport of getty. Every stream of a sctp association is served
+// as a session, so the packages of different sessions do not block each other.
+package sctp
+
+import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"io"
+	"net"
+	"os"
+	"sync"
+	"syscall"
+	"time"
+)
+
+import (
+	"github.com/ishidawataru/sctp"
+
+	perrors "github.com/pkg/errors"
+)
+
+import (
+	getty "github.com/apache/dubbo-getty"
+)
+
+const (
+	// sctpMaxMsgLen is the max length of a sctp user message written by getty
+	sctpMaxMsgLen = 32 * 1024
+	// sctpPPIDData marks the user messages which carry session data
+	sctpPPIDData = 0
+	// sctpPPIDClose marks the user message which closes a stream
+	sctpPPIDClose = 1
+	// sctpMaxStreamBuffer is the max bytes buffered by a stream before it stops reading the association
+	sctpMaxStreamBuffer = 4 * 1024 * 1024
+	// sctpStreamBacklog is the number of accepted but not yet served streams
+	sctpStreamBacklog = 128
+)
+
+// Options sets the stream numbers of sctp associations.
+type Options struct {
+	// OutStreams is the number of outbound streams. Each client session uses one stream
+	// of the association, so it limits the client connection number.
+	OutStreams int
+	// MaxInStreams is the max number of inbound streams.
+	MaxInStreams int
+}
+
+// DefaultOptions is the default stream numbers of sctp associations.
+var DefaultOptions = Options{
+	OutStreams:   16,
+	MaxInStreams: 16,
+}
+
+// NewServer builds a sctp server whose associations are set by @options. Every stream of every
+// accepted association is served as a session.
+func NewServer(options Options, opts ...getty.ServerOption) getty.Server {
+	return getty.NewTransportServer(getty.SCTP_SERVER, &transport{options: options}, opts...)
+}
+
+// NewClient builds a sctp client whose association is set by @options. Every session is a stream
+// of the same association, so the sessions beyond Options.OutStreams fail to connect.
+func NewClient(options Options, opts ...getty.ClientOption) getty.Client {
+	return getty.NewTransportClient(getty.SCTP_CLIENT, &transport{options: options}, opts...)
+}
+
+// transport is the getty.Transport of sctp, the tls config is ignored. The streams dialed by a
+// client are opened on its association, which is redialed after it has been closed.
+type transport struct {
+	options Options
+
+	lock  sync.Mutex
+	assoc *sctpAssoc
+}
+
+func (t *transport) Listen(addr string, _ *tls.Config) (net.Listener, error) {
+	ln, err := listenSCTP(addr, t.options)
+	if err != nil {
+		return nil, perrors.Wrapf(err, "sctp.Listen(addr:%s)", addr)
+	}
+
+	return ln, nil
+}
+
+func (t *transport) Dial(_ context.Context, addr string, _ *tls.Config) (net.Conn, error) {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+
+	var (
+		err  error
+		conn net.Conn
+	)
+	t.assoc, conn, err = dialSCTPStream(t.assoc, addr, t.options)
+	if err != nil {
+		return nil, perrors.Wrapf(err, "sctp.Dial(addr:%s)", addr)
+	}
+
+	return conn, nil
+}
+
+func (t *transport) Close() error {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+
+	if t.assoc != nil {
+		t.assoc.close()
+		t.assoc = nil
+	}
+
+	return nil
+}
+
+func (o *Options) initMsg() sctp.InitMsg {
+	return sctp.InitMsg{
+		NumOstreams:  uint16(o.OutStreams),
+		MaxInstreams: uint16(o.MaxInStreams),
+	}
+}
+
+// sctpAssoc demultiplexes the user messages of a sctp association into streams.
+type sctpAssoc struct {
+	conn     *sctp.SCTPConn
+	onStream func(*sctpStreamConn) // invoked when the peer opens a new stream
+
+	lock    sync.Mutex
+	streams map[uint16]*sctpStreamConn
+
+	writeLock  sync.Mutex    // serializes the writes and SO_SNDTIMEO of the association
+	sndTimeout time.Duration // the current SO_SNDTIMEO
+
+	once sync.Once
+	done chan struct{}
+}
+
+func newSCTPAssoc(conn *sctp.SCTPConn, onStream func(*sctpStreamConn)) (*sctpAssoc, error) {
+	if err := conn.SubscribeEvents(sctp.SCTP_EVENT_DATA_IO); err != nil {
+		conn.Close()
+		return nil, perrors.WithStack(err)
+	}
+
+	a := &sctpAssoc{
+		conn:     conn,
+		onStream: onStream,
+		streams:  make(map[uint16]*sctpStreamConn),
+		done:     make(chan struct{}),
+	}
+	go a.demux()
+
+	return a, nil
+}
+
+func (a *sctpAssoc) demux() {
+	defer a.close()
+
+	buf := make([]byte, sctpMaxMsgLen)
+	for {
+		n, info, err := a.conn.SCTPRead(buf)
+		if err != nil {
+			if perrors.Cause(err) != io.EOF {
+				getty.GetLogger().Infof("sctp association{%s} read error:%v", a.conn.RemoteAddr(), err)
+			}
+			return
+		}
+		if info == nil {
+			continue
+		}
+
+		a.lock.Lock()
+		stream, ok := a.streams[info.Stream]
+		if !ok && info.PPID == sctpPPIDData && a.onStream != nil {
+			stream = newSCTPStreamConn(a, info.Stream)
+			a.streams[info.Stream] = stream
+			a.onStream(stream)
+		}
+		a.lock.Unlock()
+		if stream == nil {
+			continue
+		}
+
+		if info.PPID == sctpPPIDClose {
+			stream.closeLocal()
+			continue
+		}
+		p := make([]byte, n)
+		copy(p, buf[:n])
+		stream.push(p)
+	}
+}
+
+// write writes @p to stream @id, and fails with os.ErrDeadlineExceeded if it blocks beyond @deadline.
+func (a *sctpAssoc) write(p []byte, id uint16, ppid uint32, deadline time.Time) error {
+	a.writeLock.Lock()
+	defer a.writeLock.Unlock()
+
+	var timeout time.Duration
+	if !deadline.IsZero() {
+		if timeout = time.Until(deadline); timeout <= 0 {
+			return os.ErrDeadlineExceeded
+		}
+	}
+	if timeout != a.sndTimeout {
+		if err := setSendTimeout(a.conn, timeout); err != nil {
+			return perrors.WithStack(err)
+		}
+		a.sndTimeout = timeout
+	}
+
+	_, err := a.conn.SCTPWrite(p, &sctp.SndRcvInfo{Stream: id, PPID: ppid})
+	if errors.Is(err, syscall.EAGAIN) || errors.Is(err, syscall.EWOULDBLOCK) {
+		return os.ErrDeadlineExceeded
+	}
+	return perrors.WithStack(err)
+}
+
+// openStream opens stream @id from the local side.
+func (a *sctpAssoc) openStream(id uint16) *sctpStreamConn {
+	a.lock.Lock()
+	defer a.lock.Unlock()
+
+	if _, ok := a.streams[id]; ok {
+		return nil
+	}
+	stream := newSCTPStreamConn(a, id)
+	a.streams[id] = stream
+
+	return stream
+}
+
+func (a *sctpAssoc) removeStream(id uint16) {
+	a.lock.Lock()
+	delete(a.streams, id)
+	a.lock.Unlock()
+}
+
+func (a *sctpAssoc) isClosed() bool {
+	select {
+	case <-a.done:
+		return true
+	default:
+		return false
+	}
+}
+
+func (a *sctpAssoc) close() {
+	a.once.Do(func() {
+		close(a.done)
+		a.conn.Close()
+		a.lock.Lock()
+		streams := a.streams
+		a.streams = make(map[uint16]*sctpStreamConn)
+		a.lock.Unlock()
+		for _, stream := range streams {
+			stream.closeLocal()
+		}
+	})
+}
+
+// sctpStreamConn maps a sctp stream to a net.Conn, so that it can be served as a tcp connection.
+type sctpStreamConn struct {
+	assoc *sctpAssoc
+	id    uint16
+
+	lock      sync.Mutex
+	chunks    [][]byte
+	buffered  int // the bytes of chunks
+	limit     int // the max value of buffered
+	rDeadline time.Time
+	wDeadline time.Time
+	notify    chan struct{}
+	drained   chan struct{} // signalled when Read consumes chunks
+
+	once sync.Once
+	done chan struct{}
+}
+
+func newSCTPStreamConn(assoc *sctpAssoc, id uint16) *sctpStreamConn {
+	return &sctpStreamConn{
+		assoc:   assoc,
+		id:      id,
+		limit:   sctpMaxStreamBuffer,
+		notify:  make(chan struct{}, 1),
+		drained: make(chan struct{}, 1),
+		done:    make(chan struct{}),
+	}
+}
+
+// push buffers @p for Read. It blocks while the stream is full, which stops reading
+// the association, so the peer is slowed down by the sctp receive window.
+func (c *sctpStreamConn) push(p []byte) {
+	for {
+		c.lock.Lock()
+		if c.buffered == 0 || c.buffered+len(p) <= c.limit {
+			c.chunks = append(c.chunks, p)
+			c.buffered += len(p)
+			c.lock.Unlock()
+			break
+		}
+		c.lock.Unlock()
+
+		select {
+		case <-c.drained:
+		case <-c.done:
+			return
+		case <-c.assoc.done:
+			return
+		}
+	}
+
+	select {
+	case c.notify <- struct{}{}:
+	default:
+	}
+}
+
+func (c *sctpStreamConn) Read(p []byte) (int, error) {
+	for {
+		c.lock.Lock()
+		if len(c.chunks) > 0 {
+			n := copy(p, c.chunks[0])
+			if n == len(c.chunks[0]) {
+				c.chunks = c.chunks[1:]
+			} else {
+				c.chunks[0] = c.chunks[0][n:]
+			}
+			c.buffered -= n
+			c.lock.Unlock()
+			select {
+			case c.drained <- struct{}{}:
+			default:
+			}
+			return n, nil
+		}
+		deadline := c.rDeadline
+		c.lock.Unlock()
+
+		var timer *time.Timer
+		var timeout <-chan time.Time
+		if !deadline.IsZero() {
+			d := time.Until(deadline)
+			if d <= 0 {
+				return 0, os.ErrDeadlineExceeded
+			}
+			timer = time.NewTimer(d)
+			timeout = timer.C
+		}
+
+		var err error
+		select {
+		case <-c.notify:
+		case <-c.done:
+			c.lock.Lock()
+			if len(c.chunks) == 0 {
+				err = io.EOF
+			}
+			c.lock.Unlock()
+		case <-timeout:
+			err = os.ErrDeadlineExceeded
+		}
+		if timer != nil {
+			timer.Stop()
+		}
+		if err != nil {
+			return 0, err
+		}
+	}
+}
+
+func (c *sctpStreamConn) Write(p []byte) (int, error) {
+	var (
+		err    error
+		length int
+	)
+
+	c.lock.Lock()
+	deadline := c.wDeadline
+	c.lock.Unlock()
+	for length < len(p) {
+		select {
+		case <-c.done:
+			return length, perrors.WithStack(net.ErrClosed)
+		default:
+		}
+
+		end := length + sctpMaxMsgLen
+		if end > len(p) {
+			end = len(p)
+		}
+		if err = c.assoc.write(p[length:end], c.id, sctpPPIDData, deadline); err != nil {
+			return length, err
+		}
+		length = end
+	}
+
+	return length, nil
+}
+
+// closeLocal closes the stream without notifying the peer.
+func (c *sctpStreamConn) closeLocal() {
+	c.once.Do(func() {
+		close(c.done)
+		c.assoc.removeStream(c.id)
+	})
+}
+
+func (c *sctpStreamConn) Close() error {
+	var err error
+	if !c.assoc.isClosed() {
+		err = c.assoc.write([]byte{0}, c.id, sctpPPIDClose, time.Time{})
+	}
+	c.closeLocal()
+
+	return err
+}
+
+func (c *sctpStreamConn) LocalAddr() net.Addr {
+	return c.assoc.conn.LocalAddr()
+}
+
+func (c *sctpStreamConn) RemoteAddr() net.Addr {
+	return c.assoc.conn.RemoteAddr()
+}
+
+func (c *sctpStreamConn) SetDeadline(t time.Time) error {
+	c.lock.Lock()
+	c.rDeadline, c.wDeadline = t, t
+	c.lock.Unlock()
+	return nil
+}
+
+func (c *sctpStreamConn) SetReadDeadline(t time.Time) error {
+	c.lock.Lock()
+	c.rDeadline = t
+	c.lock.Unlock()
+	return nil
+}
+
+func (c *sctpStreamConn) SetWriteDeadline(t time.Time) error {
+	c.lock.Lock()
+	c.wDeadline = t
+	c.lock.Unlock()
+	return nil
+}
+
+// sctpListener is a net.Listener which returns every stream of every accepted
+// sctp association as a net.Conn.
+type sctpListener struct {
+	ln      *sctp.SCTPListener
+	streams chan net.Conn
+	once    sync.Once
+	done    chan struct{}
+}
+
+func listenSCTP(addr string, opts Options) (*sctpListener, error) {
+	laddr, err := sctp.ResolveSCTPAddr("sctp", addr)
+	if err != nil {
+		return nil, perrors.WithStack(err)
+	}
+	ln, err := sctp.ListenSCTPExt("sctp", laddr, opts.initMsg())
+	if err != nil {
+		return nil, perrors.WithStack(err)
+	}
+
+	l := &sctpListener{
+		ln:      ln,
+		streams: make(chan net.Conn, sctpStreamBacklog),
+		done:    make(chan struct{}),
+	}
+	go l.acceptAssocs()
+
+	return l, nil
+}
+
+func (l *sctpListener) acceptAssocs() {
+	for {
+		conn, err := l.ln.AcceptSCTP()
+		if err != nil {
+			getty.GetLogger().Infof("sctp listener stop accepting associations, err:%v", err)
+			return
+		}
+		_, err = newSCTPAssoc(conn, func(stream *sctpStreamConn) {
+			select {
+			case l.streams <- stream:
+			case <-l.done:
+			default:
+				getty.GetLogger().Warnf("sctp listener backlog is full, drop stream %d", stream.id)
+				go stream.Close()
+			}
+		})
+		if err != nil {
+			getty.GetLogger().Warnf("newSCTPAssoc() = error:%+v", err)
+		}
+	}
+}
+
+func (l *sctpListener) Accept() (net.Conn, error) {
+	select {
+	case conn := <-l.streams:
+		// the new stream is created by the connect ping from its peer, which is read by getty
+		return conn, nil
+	case <-l.done:
+		return nil, perrors.WithStack(net.ErrClosed)
+	}
+}
+
+func (l *sctpListener) Close() error {
+	var err error
+	l.once.Do(func() {
+		close(l.done)
+		err = l.ln.Close()
+	})
+
+	return perrors.WithStack(err)
+}
+
+func (l *sctpListener) Addr() net.Addr {
+	return l.ln.Addr()
+}
+
+// dialSCTPStream opens a new stream on @assoc, and dials a new association if @assoc has been closed.
+func dialSCTPStream(assoc *sctpAssoc, addr string, opts Options) (*sctpAssoc, net.Conn, error) {
+	if assoc == nil || assoc.isClosed() {
+		raddr, err := sctp.ResolveSCTPAddr("sctp", addr)
+		if err != nil {
+			return nil, nil, perrors.WithStack(err)
+		}
+		conn, err := sctp.DialSCTPExt("sctp", nil, raddr, opts.initMsg())
+		if err != nil {
+			return nil, nil, perrors.WithStack(err)
+		}
+		if assoc, err = newSCTPAssoc(conn, nil); err != nil {
+			return nil, nil, perrors.WithStack(err)
+		}
+	}
+
+	for id := 0; id < opts.OutStreams; id++ {
+		if stream := assoc.openStream(uint16(id)); stream != nil {
+			return assoc, stream, nil
+		}
+	}
+
+	return assoc, nil, perrors.Errorf("all of the %d sctp streams are in use", opts.OutStreams)
+}
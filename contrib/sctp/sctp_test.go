@@ -0,0 +1,171 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package sctp
+
+import (
+	"io"
+	"os"
+	"testing"
+	"time"
+)
+
+import (
+	"github.com/stretchr/testify/assert"
+)
+
+import (
+	getty "github.com/apache/dubbo-getty"
+)
+
+// msgListener sends the messages of the sessions to its channel.
+type msgListener struct {
+	msgs chan interface{}
+}
+
+func (l *msgListener) OnOpen(getty.Session) error {
+	return nil
+}
+
+func (l *msgListener) OnClose(getty.Session) {}
+
+func (l *msgListener) OnError(getty.Session, error) {}
+
+func (l *msgListener) OnCron(getty.Session) {}
+
+func (l *msgListener) OnMessage(_ getty.Session, pkg interface{}) {
+	l.msgs <- pkg
+}
+
+func TestSCTPStreamConnRead(t *testing.T) {
+	assoc := &sctpAssoc{streams: make(map[uint16]*sctpStreamConn), done: make(chan struct{})}
+	stream := assoc.openStream(3)
+	assert.NotNil(t, stream)
+	assert.Nil(t, assoc.openStream(3))
+
+	stream.push([]byte("hello"))
+	stream.push([]byte("world"))
+	buf := make([]byte, 3)
+	n, err := stream.Read(buf)
+	assert.Nil(t, err)
+	assert.Equal(t, "hel", string(buf[:n]))
+	n, err = stream.Read(buf)
+	assert.Nil(t, err)
+	assert.Equal(t, "lo", string(buf[:n]))
+	n, err = stream.Read(buf)
+	assert.Nil(t, err)
+	assert.Equal(t, "wor", string(buf[:n]))
+
+	stream.SetReadDeadline(time.Now().Add(10 * time.Millisecond))
+	n, err = stream.Read(buf)
+	assert.Nil(t, err)
+	assert.Equal(t, "ld", string(buf[:n]))
+	_, err = stream.Read(buf)
+	assert.ErrorIs(t, err, os.ErrDeadlineExceeded)
+
+	stream.SetReadDeadline(time.Time{})
+	go func() {
+		time.Sleep(10 * time.Millisecond)
+		stream.push([]byte("!"))
+		stream.closeLocal()
+	}()
+	n, err = stream.Read(buf)
+	assert.Nil(t, err)
+	assert.Equal(t, "!", string(buf[:n]))
+	_, err = stream.Read(buf)
+	assert.Equal(t, io.EOF, err)
+	assert.Nil(t, assoc.streams[3])
+}
+
+func TestSCTPServer(t *testing.T) {
+	ln, err := listenSCTP("127.0.0.1:0", DefaultOptions)
+	if err != nil {
+		t.Skipf("sctp is not supported: %v", err)
+	}
+	ln.Close()
+
+	s := NewServer(DefaultOptions, getty.WithLocalAddress("127.0.0.1:0"))
+	defer s.Close()
+	serverListener := &msgListener{msgs: make(chan interface{}, 4)}
+	s.RunEventLoop(func(session getty.Session) error {
+		session.SetPkgHandler(&getty.LengthFieldCodec{LengthFieldSize: 4})
+		session.SetEventListener(serverListener)
+		return nil
+	})
+
+	sessions := make(chan getty.Session, 2)
+	clt := NewClient(DefaultOptions, getty.WithServerAddress(s.(getty.StreamServer).Listener().Addr().String()), getty.WithConnectionNumber(2))
+	defer clt.Close()
+	clt.RunEventLoop(func(session getty.Session) error {
+		session.SetPkgHandler(&getty.LengthFieldCodec{LengthFieldSize: 4})
+		session.SetEventListener(&msgListener{msgs: make(chan interface{}, 4)})
+		sessions <- session
+		return nil
+	})
+
+	for i := 0; i < 2; i++ {
+		_, _, err := (<-sessions).WritePkg("hello", 0)
+		assert.Nil(t, err)
+	}
+	for i := 0; i < 2; i++ {
+		select {
+		case msg := <-serverListener.msgs:
+			assert.Equal(t, "hello", string(msg.([]byte)))
+		case <-time.After(3 * time.Second):
+			t.Fatal("server did not receive the package")
+		}
+	}
+}
+
+func TestSCTPStreamConnBufferLimit(t *testing.T) {
+	assoc := &sctpAssoc{streams: make(map[uint16]*sctpStreamConn), done: make(chan struct{})}
+	stream := assoc.openStream(1)
+	stream.limit = 8
+
+	stream.push([]byte("hello"))
+	pushed := make(chan struct{})
+	go func() {
+		stream.push([]byte("world"))
+		close(pushed)
+	}()
+	select {
+	case <-pushed:
+		t.Fatal("push should block while the stream is full")
+	case <-time.After(20 * time.Millisecond):
+	}
+
+	buf := make([]byte, 5)
+	n, err := stream.Read(buf)
+	assert.Nil(t, err)
+	assert.Equal(t, "hello", string(buf[:n]))
+	select {
+	case <-pushed:
+	case <-time.After(time.Second):
+		t.Fatal("push should resume after Read")
+	}
+	n, err = stream.Read(buf)
+	assert.Nil(t, err)
+	assert.Equal(t, "world", string(buf[:n]))
+
+	// a blocked push returns when the stream is closed
+	stream.push([]byte("hello"))
+	go func() {
+		time.Sleep(10 * time.Millisecond)
+		stream.closeLocal()
+	}()
+	stream.push([]byte("world"))
+}
@@ -0,0 +1,52 @@
+//go:build linux || darwin || dragonfly || freebsd || netbsd || openbsd
+
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package sctp
+
+import (
+	"syscall"
+	"time"
+)
+
+import (
+	perrors "github.com/pkg/errors"
+
+	"golang.org/x/sys/unix"
+)
+
+// setSendTimeout sets SO_SNDTIMEO of @conn, 0 @timeout means no timeout.
+func setSendTimeout(conn interface{}, timeout time.Duration) error {
+	sc, ok := conn.(syscall.Conn)
+	if !ok {
+		return perrors.Errorf("%T has no raw socket", conn)
+	}
+	raw, err := sc.SyscallConn()
+	if err != nil {
+		return perrors.WithStack(err)
+	}
+
+	tv := unix.NsecToTimeval(timeout.Nanoseconds())
+	if ctrlErr := raw.Control(func(fd uintptr) {
+		err = unix.SetsockoptTimeval(int(fd), unix.SOL_SOCKET, unix.SO_SNDTIMEO, &tv)
+	}); ctrlErr != nil {
+		return perrors.WithStack(ctrlErr)
+	}
+
+	return perrors.WithStack(err)
+}
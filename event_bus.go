@@ -0,0 +1,134 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package getty
+
+import (
+	"sync"
+	"time"
+)
+
+import (
+	uatomic "go.uber.org/atomic"
+)
+
+// LifecycleEventType is the type of a LifecycleEvent.
+type LifecycleEventType int
+
+const (
+	// SessionOpened is published after OnOpen of a session succeeds.
+	SessionOpened LifecycleEventType = iota
+	// SessionClosed is published after OnClose of a session, with the error which closes it.
+	SessionClosed
+	// ReconnectStarted is published when a session of the client pool is lost and redialed.
+	ReconnectStarted
+	// DrainStarted is published when Server.GracefulClose starts.
+	DrainStarted
+	// LimitHit is published when the new connections are blocked or rejected by WithMaxSessions
+	// or WithMaxSessionsPerListener.
+	LimitHit
+)
+
+var lifecycleEventTypeStrings = [...]string{
+	"SessionOpened",
+	"SessionClosed",
+	"ReconnectStarted",
+	"DrainStarted",
+	"LimitHit",
+}
+
+func (t LifecycleEventType) String() string {
+	return lifecycleEventTypeStrings[t]
+}
+
+// LifecycleEvent is an event of the connection lifecycle of an endpoint.
+type LifecycleEvent struct {
+	Type     LifecycleEventType
+	Time     time.Time
+	EndPoint EndPoint
+	// the session of SessionOpened, SessionClosed and ReconnectStarted
+	Session Session
+	// the error which closes the session of SessionClosed, nil if it is closed normally
+	Reason error
+	// the active sessions of the server of LimitHit
+	Sessions int
+}
+
+// EventBus publishes the LifecycleEvents of the endpoints set by WithEventBus or WithClientEventBus
+// to its subscribers, independent of the EventListeners. The events are dropped for the subscriber
+// whose channel is full, so that the sessions are never blocked.
+type EventBus struct {
+	lock    sync.RWMutex
+	subs    map[chan LifecycleEvent]struct{}
+	dropped uatomic.Uint64
+}
+
+// NewEventBus returns an EventBus without subscribers.
+func NewEventBus() *EventBus {
+	return &EventBus{subs: make(map[chan LifecycleEvent]struct{})}
+}
+
+// Subscribe returns a channel buffering @size events and the func to unsubscribe it, which closes
+// the channel.
+func (b *EventBus) Subscribe(size int) (<-chan LifecycleEvent, func()) {
+	ch := make(chan LifecycleEvent, size)
+	b.lock.Lock()
+	b.subs[ch] = struct{}{}
+	b.lock.Unlock()
+
+	var once sync.Once
+	return ch, func() {
+		once.Do(func() {
+			b.lock.Lock()
+			delete(b.subs, ch)
+			close(ch)
+			b.lock.Unlock()
+		})
+	}
+}
+
+// Dropped returns the number of the events dropped for the full channels.
+func (b *EventBus) Dropped() uint64 {
+	return b.dropped.Load()
+}
+
+func (b *EventBus) publish(event LifecycleEvent) {
+	event.Time = time.Now()
+	b.lock.RLock()
+	defer b.lock.RUnlock()
+	for ch := range b.subs {
+		select {
+		case ch <- event:
+		default:
+			b.dropped.Inc()
+		}
+	}
+}
+
+// publishEvent publishes @event on the EventBus of its endpoint if there is one.
+func publishEvent(event LifecycleEvent) {
+	var bus *EventBus
+	switch ep := event.EndPoint.(type) {
+	case *server:
+		bus = ep.eventBus
+	case *client:
+		bus = ep.eventBus
+	}
+	if bus != nil {
+		bus.publish(event)
+	}
+}
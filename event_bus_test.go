@@ -0,0 +1,94 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package getty
+
+import (
+	"context"
+	"net"
+	"testing"
+	"time"
+)
+
+import (
+	"github.com/stretchr/testify/assert"
+)
+
+// waitEvent returns the first event of @typ from @events.
+func waitEvent(t *testing.T, events <-chan LifecycleEvent, typ LifecycleEventType) LifecycleEvent {
+	timeout := time.After(3 * time.Second)
+	for {
+		select {
+		case event := <-events:
+			if event.Type == typ {
+				return event
+			}
+		case <-timeout:
+			t.Fatalf("no %s event", typ)
+			return LifecycleEvent{}
+		}
+	}
+}
+
+func TestEventBus(t *testing.T) {
+	bus := NewEventBus()
+	events, unsubscribe := bus.Subscribe(64)
+	s := NewTCPServer(WithLocalAddress("127.0.0.1:0"), WithEventBus(bus),
+		WithMaxSessions(1), WithMaxSessionsPolicy(MaxSessionsReject)).(*server)
+	defer s.Close()
+	s.RunEventLoop(func(session Session) error {
+		session.SetPkgHandler(&stringPkgHandler{})
+		session.SetEventListener(newChanMessageHandler())
+		session.SetReadTimeout(50 * time.Millisecond)
+		return nil
+	})
+
+	clt := NewTCPClient(WithServerAddress(s.addr), WithConnectionNumber(1), WithReconnectInterval(1e7),
+		WithClientEventBus(bus))
+	defer clt.Close()
+	clientHandler := newChanMessageHandler()
+	clt.RunEventLoop(func(session Session) error {
+		session.SetPkgHandler(&stringPkgHandler{})
+		session.SetEventListener(clientHandler)
+		session.SetReadTimeout(50 * time.Millisecond)
+		return nil
+	})
+	event := waitEvent(t, events, SessionOpened)
+	assert.NotNil(t, event.Session)
+	assert.False(t, event.Time.IsZero())
+
+	// the second connection is rejected
+	conn, err := net.Dial("tcp", s.addr)
+	assert.Nil(t, err)
+	defer conn.Close()
+	event = waitEvent(t, events, LimitHit)
+	assert.Equal(t, s, event.EndPoint)
+	assert.Equal(t, 1, event.Sessions)
+
+	assert.Nil(t, s.GracefulClose(context.Background()))
+	assert.Equal(t, DrainStarted, waitEvent(t, events, DrainStarted).Type)
+	event = waitEvent(t, events, ReconnectStarted)
+	assert.Equal(t, clt, event.EndPoint)
+	assert.Equal(t, clientHandler.array[0], event.Session)
+
+	unsubscribe()
+	unsubscribe()
+	for range events {
+	}
+	bus.publish(LifecycleEvent{Type: SessionOpened})
+	assert.Equal(t, uint64(0), bus.Dropped())
+}
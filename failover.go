@@ -0,0 +1,148 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package getty
+
+import (
+	"net"
+	"sync"
+	"time"
+)
+
+const (
+	defaultFailoverMaxFails      = 3
+	defaultFailoverProbeInterval = 5e9 // 5s
+)
+
+type failoverPeer struct {
+	addr    string
+	fails   int
+	ejected bool
+}
+
+// failover keeps the health of the server addresses of a multi-address client, which are
+// dialed in priority order.
+type failover struct {
+	lock     sync.Mutex
+	peers    []*failoverPeer
+	maxFails int
+}
+
+func newFailover(addrs []string, maxFails int) *failover {
+	if maxFails <= 0 {
+		maxFails = defaultFailoverMaxFails
+	}
+	f := &failover{peers: make([]*failoverPeer, 0, len(addrs)), maxFails: maxFails}
+	for _, addr := range addrs {
+		f.peers = append(f.peers, &failoverPeer{addr: addr})
+	}
+	return f
+}
+
+// pick returns the first address which is not ejected. The address of the fewest consecutive
+// failures is returned if all of them are ejected, so that the client keeps trying.
+func (f *failover) pick() string {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+
+	var least *failoverPeer
+	for _, p := range f.peers {
+		if !p.ejected {
+			return p.addr
+		}
+		if least == nil || p.fails < least.fails {
+			least = p
+		}
+	}
+	return least.addr
+}
+
+// report records the dialing result of @addr, and returns true if @addr is ejected by it.
+func (f *failover) report(addr string, err error) bool {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+
+	for _, p := range f.peers {
+		if p.addr != addr {
+			continue
+		}
+		if err == nil {
+			p.fails, p.ejected = 0, false
+			return false
+		}
+		p.fails++
+		if !p.ejected && p.fails >= f.maxFails {
+			p.ejected = true
+			return true
+		}
+		return false
+	}
+	return false
+}
+
+// ejected returns the ejected addresses.
+func (f *failover) ejected() []string {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+
+	var addrs []string
+	for _, p := range f.peers {
+		if p.ejected {
+			addrs = append(addrs, p.addr)
+		}
+	}
+	return addrs
+}
+
+// dialNetwork connects @addr without the PROXY header and the tls handshake.
+func (c *client) dialNetwork(network, addr string) (net.Conn, error) {
+	if network == "tcp" {
+		return c.dialAddr(network, addr)
+	}
+	return net.DialTimeout(network, addr, c.attemptTimeout())
+}
+
+// probeEjected dials the ejected addresses every @interval, and an address is put back in
+// the dialing order once it accepts the connection again.
+func (c *client) probeEjected(interval time.Duration) {
+	defer c.wg.Done()
+
+	network := c.network
+	if network == "" {
+		network = "tcp"
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-c.done:
+			return
+		case <-ticker.C:
+		}
+
+		for _, addr := range c.failover.ejected() {
+			conn, err := c.dialNetwork(network, addr)
+			if err != nil {
+				endPointLogger(poolLog, c).Debugw("probe the ejected address failed", "addr", addr, "err", err)
+				continue
+			}
+			conn.Close()
+			c.failover.report(addr, nil)
+			endPointLogger(poolLog, c).Infow("the ejected address recovers", "addr", addr)
+		}
+	}
+}
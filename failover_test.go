@@ -0,0 +1,93 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package getty
+
+import (
+	"net"
+	"testing"
+	"time"
+)
+
+import (
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFailover(t *testing.T) {
+	f := newFailover([]string{"a", "b", "c"}, 2)
+	assert.Equal(t, "a", f.pick())
+
+	assert.False(t, f.report("a", errSelfConnect))
+	assert.Equal(t, "a", f.pick())
+	assert.True(t, f.report("a", errSelfConnect))
+	assert.Equal(t, "b", f.pick())
+	assert.Equal(t, []string{"a"}, f.ejected())
+
+	// a success resets the failures of b
+	f.report("b", errSelfConnect)
+	f.report("b", nil)
+	f.report("b", errSelfConnect)
+	assert.Equal(t, "b", f.pick())
+	f.report("b", errSelfConnect)
+	f.report("c", errSelfConnect)
+	assert.True(t, f.report("c", errSelfConnect))
+
+	// all of the addresses are ejected
+	f.report("a", errSelfConnect)
+	assert.Equal(t, "b", f.pick())
+
+	f.report("a", nil)
+	assert.Equal(t, "a", f.pick())
+	assert.Equal(t, []string{"b", "c"}, f.ejected())
+}
+
+func TestClientFailover(t *testing.T) {
+	dead, err := net.Listen("tcp", "127.0.0.1:0")
+	assert.Nil(t, err)
+	deadAddr := dead.Addr().String()
+	dead.Close()
+
+	s := NewTCPServer(WithLocalAddress("127.0.0.1:0")).(*server)
+	defer s.Close()
+	s.RunEventLoop(func(session Session) error {
+		session.SetPkgHandler(&stringPkgHandler{})
+		session.SetEventListener(newChanMessageHandler())
+		return nil
+	})
+
+	clt := NewTCPClient(WithServerAddresses(deadAddr, s.addr), WithConnectionNumber(1),
+		WithFailover(1, 20*time.Millisecond)).(*client)
+	defer clt.Close()
+	clt.RunEventLoop(func(session Session) error {
+		session.SetPkgHandler(&stringPkgHandler{})
+		session.SetEventListener(newChanMessageHandler())
+		return nil
+	})
+	ss, err := clt.PickSession("")
+	assert.Nil(t, err)
+	assert.Equal(t, s.addr, ss.RemoteAddr())
+	assert.Equal(t, []string{deadAddr}, clt.failover.ejected())
+
+	// the probe puts the address back once it recovers
+	ln, err := net.Listen("tcp", deadAddr)
+	if err != nil {
+		t.Skipf("listen %s again = error:%v", deadAddr, err)
+	}
+	defer ln.Close()
+	assert.Eventually(t, func() bool { return len(clt.failover.ejected()) == 0 }, time.Second, 10*time.Millisecond)
+	assert.Equal(t, deadAddr, clt.failover.pick())
+}
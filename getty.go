@@ -17,6 +17,11 @@
 
 package getty
 
+import (
+	"fmt"
+	"io"
+)
+
 import (
 	gxsync "github.com/dubbogo/gost/sync"
 
@@ -27,8 +32,45 @@ var (
 	ErrSessionClosed  = perrors.New("session Already Closed")
 	ErrSessionBlocked = perrors.New("session Full Blocked")
 	ErrNullPeerAddr   = perrors.New("peer address is nil")
+	ErrWriteOverflow  = perrors.New("session write queue overflow")
+	ErrWriteExpired   = perrors.New("package write deadline exceeded")
+	ErrHalfClose      = perrors.New("half-close is not supported by the connection")
+	ErrNoSession      = perrors.New("no open session")
+	ErrClientClosed   = perrors.New("client closed")
+	ErrCircuitOpen    = perrors.New("circuit breaker is open")
+	ErrRateLimited    = perrors.New("write rate limit exceeded")
+	ErrInflightLimit  = perrors.New("too many inflight packages")
+
+	// ErrMaxMsgLenExceeded is the cause of the read errors of the packages longer than the max message length.
+	ErrMaxMsgLenExceeded = perrors.New("max message length exceeded")
+	// ErrHandshakeTimeout is the cause of the tls and WithHandshake handshakes which time out.
+	ErrHandshakeTimeout = perrors.New("handshake timeout")
+	// ErrWriteQueueFull is an alias of ErrWriteOverflow.
+	ErrWriteQueueFull = ErrWriteOverflow
+	// ErrDialFailed matches every *DialError by errors.Is.
+	ErrDialFailed = perrors.New("dial failed")
 )
 
+// DialError is the error a client gives up reconnecting @Addr with after @Attempts attempts, @Err is
+// the error of the last attempt.
+type DialError struct {
+	Addr     string
+	Attempts int
+	Err      error
+}
+
+func (e *DialError) Error() string {
+	return fmt.Sprintf("dial %s failed after %d attempts: %v", e.Addr, e.Attempts, e.Err)
+}
+
+func (e *DialError) Unwrap() error {
+	return e.Err
+}
+
+func (e *DialError) Is(target error) bool {
+	return target == ErrDialFailed
+}
+
 // NewSessionCallback will be invoked when server accepts a new client connection or client connects to server successfully.
 // If there are too many client connections or u do not want to connect a server again, u can return non-nil error. And
 // then getty will close the new session.
@@ -64,6 +106,14 @@ type ReadWriter interface {
 	Writer
 }
 
+// StreamReader is an optional interface of the Reader of tcp sessions to decode the packages from
+// the stream incrementally, eg: the very large messages which need not be in one buffer. Decode is
+// used instead of Read if the Reader implements it. Decode should return io.EOF if the stream ends
+// before a package begins, and the session fails if it reads more than the max message length.
+type StreamReader interface {
+	Decode(io.Reader) (interface{}, error)
+}
+
 // EventListener is used to process pkg that received from remote session
 type EventListener interface {
 	// OnOpen invoked when session opened
@@ -93,6 +143,30 @@ type EventListener interface {
 	OnMessage(Session, interface{})
 }
 
+// WritableListener is implemented by the EventListener which wants to know when the session
+// turns writable again, see Session.IsWritable.
+type WritableListener interface {
+	// OnWritable invoked when the pending bytes of WritePkgAsync drop to the low watermark.
+	OnWritable(Session)
+}
+
+// HalfCloseListener is implemented by the EventListener which wants to keep the session open for
+// writing after the peer half-closes the connection, eg: for the protocols which signal the end of
+// a request stream by FIN. Without it the session is closed once the peer half-closes.
+type HalfCloseListener interface {
+	// OnHalfClose invoked when the peer shuts down its writing side. The session can still write,
+	// and OnClose is invoked when the session is closed, eg: by Close after the response is written.
+	OnHalfClose(Session)
+}
+
+// DrainListener is implemented by the EventListener which wants to be notified when the server
+// starts to close gracefully, eg: to tell the peer to reconnect to another server.
+type DrainListener interface {
+	// OnDrain invoked when Server.GracefulClose is called. The session keeps working until
+	// its in-flight packages are handled.
+	OnDrain(Session)
+}
+
 // EndPoint represents the identity of the client/server
 type EndPoint interface {
 	// ID get EndPoint ID
@@ -0,0 +1,139 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package gettytest
+
+import (
+	"reflect"
+	"runtime/debug"
+	"testing"
+)
+
+import (
+	getty "github.com/apache/dubbo-getty"
+)
+
+// FuzzCodec fuzzes the Read of @rw with the byte streams split into chunks, merged from several
+// frames and mutated into garbage. The frames of the seed packages @pkgs, which are encoded by
+// the Write of @rw, must be read back with their whole length. For every stream it checks:
+//   - Read does not panic;
+//   - a package is read with 0 < pkgLen <= len(data);
+//   - no package but a pkgLen means the frame needs more than len(data) bytes;
+//   - the stream read in chunks gives the same packages as the whole stream.
+//
+// It is called by a fuzz test, eg:
+//
+//	func FuzzMyCodec(f *testing.F) {
+//		gettytest.FuzzCodec(f, &MyCodec{}, "hello", "world")
+//	}
+func FuzzCodec(f *testing.F, rw getty.ReadWriter, pkgs ...interface{}) {
+	f.Helper()
+
+	ss := NewMockSession("127.0.0.1:0", "127.0.0.1:0")
+	var stream []byte
+	for _, pkg := range pkgs {
+		frame, err := rw.Write(ss, pkg)
+		if err != nil {
+			f.Fatalf("Write(%#v) = error:%v", pkg, err)
+		}
+		got, pkgLen, err := rw.Read(ss, append([]byte(nil), frame...))
+		if err != nil || got == nil || pkgLen != len(frame) {
+			f.Fatalf("Read(Write(%#v)) = pkg:%#v, pkgLen:%d, error:%v, the frame length is %d",
+				pkg, got, pkgLen, err, len(frame))
+		}
+		f.Add(frame, uint16(1))
+		stream = append(stream, frame...)
+	}
+	f.Add(stream, uint16(3))
+	f.Add([]byte{}, uint16(0))
+	f.Add([]byte{0xff, 0xff, 0xff, 0xff, 0x00, 0x01}, uint16(2))
+
+	f.Fuzz(func(t *testing.T, data []byte, chunk uint16) {
+		CheckStream(t, rw, data, int(chunk))
+	})
+}
+
+// CheckStream checks the invariants of FuzzCodec on reading @data by @rw as a whole and in the
+// chunks of @chunk bytes, 0 @chunk means the whole @data.
+func CheckStream(tb testing.TB, rw getty.ReadWriter, data []byte, chunk int) {
+	tb.Helper()
+
+	whole, wholeLen, wholeErr := readStream(tb, rw, data, 0)
+	if tb.Failed() || chunk <= 0 || chunk >= len(data) {
+		return
+	}
+	split, splitLen, splitErr := readStream(tb, rw, data, chunk)
+	if tb.Failed() || wholeErr != nil || splitErr != nil {
+		return
+	}
+	if wholeLen != splitLen || !reflect.DeepEqual(whole, split) {
+		tb.Fatalf("the stream %x read in the chunks of %d bytes = %d packages of %d bytes, but "+
+			"read as a whole = %d packages of %d bytes", data, chunk, len(split), splitLen, len(whole), wholeLen)
+	}
+}
+
+// readStream reads the packages of @data delivered in the chunks of @chunk bytes like a session,
+// and returns them with the read bytes, and the error of Read if any.
+func readStream(tb testing.TB, rw getty.ReadWriter, data []byte, chunk int) (pkgs []interface{}, read int, err error) {
+	tb.Helper()
+
+	ss := NewMockSession("127.0.0.1:0", "127.0.0.1:0")
+	if chunk <= 0 {
+		chunk = len(data)
+	}
+	var buf []byte
+	for len(data) > 0 {
+		n := chunk
+		if n > len(data) {
+			n = len(data)
+		}
+		buf, data = append(buf, data[:n]...), data[n:]
+		for len(buf) > 0 {
+			pkg, pkgLen, readErr := safeRead(tb, rw, ss, buf)
+			if tb.Failed() {
+				return nil, 0, nil
+			}
+			if readErr != nil {
+				return pkgs, read, readErr
+			}
+			if pkg == nil {
+				if pkgLen != 0 && pkgLen <= len(buf) {
+					tb.Fatalf("Read(%x) = no package but pkgLen %d <= %d buffered bytes", buf, pkgLen, len(buf))
+					return nil, 0, nil
+				}
+				break
+			}
+			if pkgLen <= 0 || pkgLen > len(buf) {
+				tb.Fatalf("Read(%x) = package %#v with pkgLen %d out of (0, %d]", buf, pkg, pkgLen, len(buf))
+				return nil, 0, nil
+			}
+			pkgs, read = append(pkgs, pkg), read+pkgLen
+			// the packages may alias the buffer
+			buf = append([]byte(nil), buf[pkgLen:]...)
+		}
+	}
+	return pkgs, read, nil
+}
+
+func safeRead(tb testing.TB, rw getty.ReadWriter, ss getty.Session, buf []byte) (pkg interface{}, pkgLen int, err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			tb.Fatalf("Read(%x) panics: %v\n%s", buf, r, debug.Stack())
+		}
+	}()
+	return rw.Read(ss, buf)
+}
@@ -0,0 +1,100 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package gettytest
+
+import (
+	"fmt"
+	"testing"
+)
+
+import (
+	"github.com/stretchr/testify/assert"
+)
+
+import (
+	getty "github.com/apache/dubbo-getty"
+)
+
+func FuzzLengthFieldCodec(f *testing.F) {
+	FuzzCodec(f, &getty.LengthFieldCodec{LengthFieldSize: 2, MaxFrameLen: 1 << 10}, []byte("hello"), []byte{})
+}
+
+// fatalTB records the failure of CheckStream instead of failing the test.
+type fatalTB struct {
+	testing.TB
+	msg string
+}
+
+func (tb *fatalTB) Helper() {}
+
+func (tb *fatalTB) Fatalf(format string, args ...interface{}) {
+	tb.msg = fmt.Sprintf(format, args...)
+}
+
+func (tb *fatalTB) Failed() bool {
+	return tb.msg != ""
+}
+
+// byteCodec frames the packages by a length byte, with the bugs of @bug.
+type byteCodec struct {
+	bug string
+}
+
+func (c byteCodec) Read(session getty.Session, data []byte) (interface{}, int, error) {
+	frameLen := 1 + int(data[0])
+	if c.bug == "panic" && len(data) >= frameLen {
+		_ = data[frameLen]
+	}
+	if c.bug == "wait" && len(data) <= frameLen {
+		return nil, frameLen, nil
+	}
+	if len(data) < frameLen {
+		return nil, frameLen, nil
+	}
+	if c.bug == "overrun" {
+		return string(data[1:frameLen]), frameLen + 1, nil
+	}
+	return string(data[1:frameLen]), frameLen, nil
+}
+
+func (c byteCodec) Write(session getty.Session, pkg interface{}) ([]byte, error) {
+	return append([]byte{byte(len(pkg.(string)))}, pkg.(string)...), nil
+}
+
+func TestCheckStream(t *testing.T) {
+	for bug, msg := range map[string]string{
+		"":        "",
+		"wait":    "no package but pkgLen 6 <= 6 buffered bytes",
+		"overrun": "with pkgLen 7 out of (0, 6]",
+		"panic":   "panics",
+	} {
+		tb := &fatalTB{}
+		CheckStream(tb, byteCodec{bug: bug}, []byte("\x05hello"), 4)
+		if msg == "" {
+			assert.Empty(t, tb.msg)
+			continue
+		}
+		assert.Contains(t, tb.msg, msg, bug)
+	}
+
+	stream := []byte("\x05hello\x05world")
+	pkgs, n, err := readStream(t, byteCodec{}, stream, 1)
+	assert.Nil(t, err)
+	assert.Equal(t, len(stream), n)
+	assert.Equal(t, []interface{}{"hello", "world"}, pkgs)
+}
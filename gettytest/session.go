@@ -0,0 +1,492 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+// Package gettytest provides the test doubles of getty, eg: MockSession for the unit tests of the
+// EventListeners and the ReadWriters.
+package gettytest
+
+import (
+	"context"
+	"crypto/tls"
+	"fmt"
+	"net"
+	"sync"
+	"syscall"
+	"time"
+)
+
+import (
+	perrors "github.com/pkg/errors"
+
+	uatomic "go.uber.org/atomic"
+)
+
+import (
+	getty "github.com/apache/dubbo-getty"
+)
+
+// MockSession is a getty.Session without a connection. It records the written packages, the
+// attributes and the closing, so that an EventListener or a ReadWriter can be tested by calling
+// its methods directly. The settings of the connection, eg: the timeouts and the cron period,
+// are ignored.
+type MockSession struct {
+	// Session provides the unexported methods of getty.Session, which are called only by the
+	// endpoints of getty, so a MockSession should not be passed to them.
+	getty.Session
+
+	// WriteFunc, if not nil, is called with every package written by WritePkg, WriteBatchPkg and
+	// WritePkgAsync, and its error fails the writing, eg: to script a broken connection.
+	WriteFunc func(pkg interface{}) error
+
+	lock      sync.Mutex
+	id        uint32
+	name      string
+	local     string
+	remote    string
+	endPoint  getty.EndPoint
+	listener  getty.EventListener
+	reader    getty.Reader
+	writer    getty.Writer
+	active    time.Time
+	connected time.Time
+	written   []interface{}
+	bytes     int
+	attrs     map[interface{}]interface{}
+	labels    map[string]string
+	ctx       context.Context
+	cancel    context.CancelFunc
+	paused    bool
+	closed    bool
+	reason    getty.CloseReason
+	closes    int
+}
+
+var _ getty.Session = (*MockSession)(nil)
+
+var mockSessionID uatomic.Uint32
+
+// NewMockSession returns an open MockSession between @local and @remote.
+func NewMockSession(local, remote string) *MockSession {
+	s := &MockSession{local: local, remote: remote}
+	s.Reset()
+	return s
+}
+
+// Reset clears the state of the session except its addresses and WriteFunc, and opens it again.
+// It should not be called concurrently with the other methods.
+func (s *MockSession) Reset() {
+	if s.cancel != nil {
+		s.cancel()
+	}
+	now := time.Now()
+	*s = MockSession{
+		WriteFunc: s.WriteFunc,
+		id:        mockSessionID.Inc(),
+		name:      "mock-session",
+		local:     s.local,
+		remote:    s.remote,
+		active:    now,
+		connected: now,
+		attrs:     make(map[interface{}]interface{}),
+	}
+	s.ctx, s.cancel = context.WithCancel(context.Background())
+}
+
+// Written returns the packages written successfully, the ones of WriteBytes are []byte.
+func (s *MockSession) Written() []interface{} {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	return append([]interface{}(nil), s.written...)
+}
+
+// Listener returns the EventListener set by SetEventListener.
+func (s *MockSession) Listener() getty.EventListener {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	return s.listener
+}
+
+// CloseCount returns how many times the session is closed, eg: to check a handler closes it once.
+func (s *MockSession) CloseCount() int {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	return s.closes
+}
+
+// SetEndPoint sets the EndPoint returned by EndPoint.
+func (s *MockSession) SetEndPoint(endPoint getty.EndPoint) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	s.endPoint = endPoint
+}
+
+func (s *MockSession) ID() uint32 {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	return s.id
+}
+
+func (s *MockSession) SetCompressType(getty.CompressType) {}
+
+func (s *MockSession) LocalAddr() string {
+	return s.local
+}
+
+func (s *MockSession) RemoteAddr() string {
+	return s.remote
+}
+
+func (s *MockSession) UpdateActive() {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	s.active = time.Now()
+}
+
+func (s *MockSession) GetActive() time.Time {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	return s.active
+}
+
+func (s *MockSession) SetReadTimeout(time.Duration) {}
+
+func (s *MockSession) SetWriteTimeout(time.Duration) {}
+
+// Conn returns nil as the session has no connection.
+func (s *MockSession) Conn() net.Conn {
+	return nil
+}
+
+func (s *MockSession) SyscallConn() (syscall.RawConn, error) {
+	return nil, perrors.New("mock session has no socket")
+}
+
+func (s *MockSession) Stat() string {
+	return fmt.Sprintf("session {%s, Local:%s, Remote:%s, packages:%d}", s.name, s.local, s.remote, len(s.Written()))
+}
+
+func (s *MockSession) Stats() getty.SessionStats {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	stats := getty.SessionStats{
+		WriteBytes:  uint64(s.bytes),
+		WritePkgs:   uint64(len(s.written)),
+		ConnectTime: s.connected,
+	}
+	if len(s.labels) > 0 {
+		stats.Labels = make(map[string]string, len(s.labels))
+		for k, v := range s.labels {
+			stats.Labels[k] = v
+		}
+	}
+	return stats
+}
+
+func (s *MockSession) IsClosed() bool {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	return s.closed
+}
+
+func (s *MockSession) EndPoint() getty.EndPoint {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	return s.endPoint
+}
+
+func (s *MockSession) SetMaxMsgLen(int) {}
+
+func (s *MockSession) SetName(name string) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	s.name = name
+}
+
+func (s *MockSession) SetEventListener(listener getty.EventListener) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	s.listener = listener
+}
+
+func (s *MockSession) SetPkgHandler(handler getty.ReadWriter) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	s.reader, s.writer = handler, handler
+}
+
+func (s *MockSession) SetPkgPipeline(stages ...getty.ReadWriter) {
+	s.SetPkgHandler(getty.Pipeline(stages...))
+}
+
+func (s *MockSession) SetReader(reader getty.Reader) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	s.reader = reader
+}
+
+func (s *MockSession) SetWriter(writer getty.Writer) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	s.writer = writer
+}
+
+func (s *MockSession) SetCronPeriod(int) {}
+
+func (s *MockSession) SetReadIdleTimeout(time.Duration) {}
+
+func (s *MockSession) SetWriteIdleTimeout(time.Duration) {}
+
+func (s *MockSession) SetWaitTime(time.Duration) {}
+
+func (s *MockSession) GetAttribute(key interface{}) interface{} {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	return s.attrs[key]
+}
+
+func (s *MockSession) SetAttribute(key interface{}, value interface{}) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	s.attrs[key] = value
+}
+
+func (s *MockSession) RemoveAttribute(key interface{}) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	delete(s.attrs, key)
+}
+
+func (s *MockSession) SetLabel(key, value string) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	if value == "" {
+		delete(s.labels, key)
+		return
+	}
+	if s.labels == nil {
+		s.labels = make(map[string]string)
+	}
+	s.labels[key] = value
+}
+
+func (s *MockSession) Label(key string) string {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	return s.labels[key]
+}
+
+func (s *MockSession) Context() context.Context {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	return s.ctx
+}
+
+func (s *MockSession) SetContext(ctx context.Context) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	s.cancel()
+	s.ctx, s.cancel = context.WithCancel(ctx)
+}
+
+func (s *MockSession) Logger() getty.StructuredLogger {
+	return getty.GetStructuredLogger().With("session_id", s.ID(), "local", s.local, "peer", s.remote)
+}
+
+func (s *MockSession) Subprotocol() string {
+	return ""
+}
+
+func (s *MockSession) TLSConnectionState() (*tls.ConnectionState, bool) {
+	return nil, false
+}
+
+// write records @pkg of @size bytes if WriteFunc accepts it.
+func (s *MockSession) write(pkg interface{}, size int) error {
+	if s.IsClosed() {
+		return getty.ErrSessionClosed
+	}
+	if s.WriteFunc != nil {
+		if err := s.WriteFunc(pkg); err != nil {
+			return err
+		}
+	}
+
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	s.written = append(s.written, pkg)
+	s.bytes += size
+	return nil
+}
+
+// encode returns the length of @pkg encoded by the Writer, or 0 if there is no Writer.
+func (s *MockSession) encode(pkg interface{}) (int, error) {
+	s.lock.Lock()
+	writer := s.writer
+	s.lock.Unlock()
+	if writer == nil {
+		return 0, nil
+	}
+	data, err := writer.Write(s, pkg)
+	if err != nil {
+		return 0, perrors.WithStack(err)
+	}
+	return len(data), nil
+}
+
+// WritePkg encodes @pkg by the Writer, if any, and records it.
+func (s *MockSession) WritePkg(pkg interface{}, timeout time.Duration, opts ...getty.WriteOption) (int, int, error) {
+	size, err := s.encode(pkg)
+	if err != nil {
+		return 0, 0, err
+	}
+	if err = s.write(pkg, size); err != nil {
+		return size, 0, err
+	}
+	return size, size, nil
+}
+
+func (s *MockSession) WriteBatchPkg(pkgs []interface{}, timeout time.Duration) (int, error) {
+	total := 0
+	for _, pkg := range pkgs {
+		_, n, err := s.WritePkg(pkg, timeout)
+		total += n
+		if err != nil {
+			return total, err
+		}
+	}
+	return total, nil
+}
+
+// WritePkgAsync writes @pkg like WritePkg and invokes @callback before it returns.
+func (s *MockSession) WritePkgAsync(pkg interface{}, timeout time.Duration, callback func(err error), opts ...getty.WriteOption) {
+	_, _, err := s.WritePkg(pkg, timeout, opts...)
+	if callback != nil {
+		callback(err)
+	}
+}
+
+func (s *MockSession) PendingWriteBytes() int {
+	return 0
+}
+
+func (s *MockSession) IsWritable() bool {
+	return true
+}
+
+func (s *MockSession) SetWriteOverflowPolicy(policy getty.WriteOverflowPolicy, limit int) {}
+
+func (s *MockSession) WriteOverflowStats() getty.WriteOverflowStats {
+	return getty.WriteOverflowStats{}
+}
+
+func (s *MockSession) SetWriteWatermark(low, high int) {}
+
+func (s *MockSession) WriteBytes(data []byte) (int, error) {
+	if err := s.write(append([]byte(nil), data...), len(data)); err != nil {
+		return 0, err
+	}
+	return len(data), nil
+}
+
+func (s *MockSession) WriteBytesArray(data ...[]byte) (int, error) {
+	var buf []byte
+	for _, d := range data {
+		buf = append(buf, d...)
+	}
+	return s.WriteBytes(buf)
+}
+
+func (s *MockSession) PauseRead() {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	s.paused = true
+}
+
+func (s *MockSession) ResumeRead() {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	s.paused = false
+}
+
+// IsReadPaused returns true between PauseRead and ResumeRead.
+func (s *MockSession) IsReadPaused() bool {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	return s.paused
+}
+
+func (s *MockSession) CloseWrite() error {
+	return getty.ErrHalfClose
+}
+
+func (s *MockSession) CloseRead() error {
+	return getty.ErrHalfClose
+}
+
+func (s *MockSession) CloseWithTimeout(timeout time.Duration) bool {
+	s.Close()
+	return true
+}
+
+// CloseWithReason closes the session by @reason, the first reason is kept.
+func (s *MockSession) CloseWithReason(reason getty.CloseReason) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	s.closes++
+	if s.closed {
+		return
+	}
+	s.closed, s.reason = true, reason
+	s.cancel()
+}
+
+func (s *MockSession) CloseReason() getty.CloseReason {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	return s.reason
+}
+
+func (s *MockSession) Close() {
+	s.CloseWithReason(getty.CloseReasonLocal)
+}
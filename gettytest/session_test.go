@@ -0,0 +1,109 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package gettytest
+
+import (
+	"testing"
+)
+
+import (
+	perrors "github.com/pkg/errors"
+
+	"github.com/stretchr/testify/assert"
+)
+
+import (
+	getty "github.com/apache/dubbo-getty"
+)
+
+type attrKey struct{}
+
+// echoHandler replies every package, and closes the session on "bye".
+type echoHandler struct{}
+
+func (h *echoHandler) OnOpen(session getty.Session) error {
+	session.SetAttribute(attrKey{}, "opened")
+	return nil
+}
+
+func (h *echoHandler) OnError(session getty.Session, err error) {}
+
+func (h *echoHandler) OnClose(session getty.Session) {}
+
+func (h *echoHandler) OnCron(session getty.Session) {}
+
+func (h *echoHandler) OnMessage(session getty.Session, pkg interface{}) {
+	if pkg == "bye" {
+		session.Close()
+		return
+	}
+	if _, _, err := session.WritePkg(pkg, 0); err != nil {
+		session.CloseWithReason(getty.CloseReasonIOError)
+	}
+}
+
+// lineCodec encodes the string packages with a trailing newline.
+type lineCodec struct{}
+
+func (c lineCodec) Read(session getty.Session, data []byte) (interface{}, int, error) {
+	return nil, 0, nil
+}
+
+func (c lineCodec) Write(session getty.Session, pkg interface{}) ([]byte, error) {
+	str, ok := pkg.(string)
+	if !ok {
+		return nil, perrors.Errorf("illegal package %#v", pkg)
+	}
+	return []byte(str + "\n"), nil
+}
+
+func TestMockSession(t *testing.T) {
+	ss := NewMockSession("127.0.0.1:1", "127.0.0.1:2")
+	handler := &echoHandler{}
+	ss.SetEventListener(handler)
+	ss.SetPkgHandler(lineCodec{})
+	assert.Equal(t, handler, ss.Listener())
+	assert.Nil(t, handler.OnOpen(ss))
+	assert.Equal(t, "opened", ss.GetAttribute(attrKey{}))
+
+	handler.OnMessage(ss, "hello")
+	assert.Equal(t, []interface{}{"hello"}, ss.Written())
+	assert.Equal(t, uint64(6), ss.Stats().WriteBytes)
+	total, sent, err := ss.WritePkg(1, 0)
+	assert.NotNil(t, err)
+	assert.Equal(t, 0, total+sent)
+
+	// the scripted write error closes the session
+	ss.WriteFunc = func(pkg interface{}) error { return getty.ErrWriteOverflow }
+	handler.OnMessage(ss, "world")
+	assert.True(t, ss.IsClosed())
+	assert.Equal(t, getty.CloseReasonIOError, ss.CloseReason())
+	assert.NotNil(t, ss.Context().Err())
+	_, err = ss.WriteBytes([]byte("late"))
+	assert.Equal(t, getty.ErrSessionClosed, err)
+
+	ss.Reset()
+	ss.WriteFunc = nil
+	assert.False(t, ss.IsClosed())
+	assert.Nil(t, ss.GetAttribute(attrKey{}))
+	handler.OnMessage(ss, "bye")
+	handler.OnMessage(ss, "bye")
+	assert.Equal(t, getty.CloseReasonLocal, ss.CloseReason())
+	assert.Equal(t, 2, ss.CloseCount())
+	assert.Empty(t, ss.Written())
+}
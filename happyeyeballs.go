@@ -0,0 +1,141 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package getty
+
+import (
+	"context"
+	"net"
+	"time"
+)
+
+import (
+	perrors "github.com/pkg/errors"
+)
+
+// defaultConnAttemptDelay is the recommended "Connection Attempt Delay" of RFC 8305.
+const defaultConnAttemptDelay = 250 * time.Millisecond
+
+// interleaveIPs sorts @ips per RFC 8305 section 4: the family of the first address
+// goes first, and then the two families take turns.
+func interleaveIPs(ips []net.IPAddr) []net.IPAddr {
+	if len(ips) == 0 {
+		return ips
+	}
+
+	var primaries, fallbacks []net.IPAddr
+	isV4 := ips[0].IP.To4() != nil
+	for _, ip := range ips {
+		if (ip.IP.To4() != nil) == isV4 {
+			primaries = append(primaries, ip)
+		} else {
+			fallbacks = append(fallbacks, ip)
+		}
+	}
+
+	ordered := make([]net.IPAddr, 0, len(ips))
+	for i := 0; i < len(primaries) || i < len(fallbacks); i++ {
+		if i < len(primaries) {
+			ordered = append(ordered, primaries[i])
+		}
+		if i < len(fallbacks) {
+			ordered = append(ordered, fallbacks[i])
+		}
+	}
+
+	return ordered
+}
+
+type dialResult struct {
+	conn net.Conn
+	err  error
+}
+
+// dialHappyEyeballs dials tcp @addr per RFC 8305. The resolved addresses are dialed one after
+// another with a stagger of @delay, or at once if the former attempt has failed, and the first
+// established connection wins. So a broken ipv6 path does not stall the dialing of ipv4.
+// The connections are dialed by @dialer, or the zero dialer if it is nil.
+func dialHappyEyeballs(ctx context.Context, dialer *net.Dialer, network, addr string, delay time.Duration) (net.Conn, error) {
+	if dialer == nil {
+		dialer = &net.Dialer{}
+	}
+
+	host, port, err := net.SplitHostPort(addr)
+	if err != nil {
+		return nil, perrors.WithStack(err)
+	}
+	if net.ParseIP(host) != nil {
+		return dialer.DialContext(ctx, network, addr)
+	}
+	ips, err := net.DefaultResolver.LookupIPAddr(ctx, host)
+	if err != nil {
+		return nil, perrors.WithStack(err)
+	}
+	ips = interleaveIPs(ips)
+	if len(ips) == 1 {
+		return dialer.DialContext(ctx, network, net.JoinHostPort(ips[0].String(), port))
+	}
+	if delay <= 0 {
+		delay = defaultConnAttemptDelay
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	results := make(chan dialResult, len(ips))
+	next, pending := 0, 0
+	attempt := func() {
+		target := net.JoinHostPort(ips[next].String(), port)
+		next++
+		pending++
+		go func() {
+			conn, err := dialer.DialContext(ctx, network, target)
+			results <- dialResult{conn: conn, err: err}
+		}()
+	}
+
+	attempt()
+	for pending > 0 {
+		var stagger <-chan time.Time
+		if next < len(ips) {
+			stagger = time.After(delay)
+		}
+		select {
+		case r := <-results:
+			pending--
+			if r.err == nil {
+				// the losers are canceled, close the ones established meanwhile
+				go func(n int) {
+					for ; n > 0; n-- {
+						if r := <-results; r.conn != nil {
+							r.conn.Close()
+						}
+					}
+				}(pending)
+				return r.conn, nil
+			}
+			err = r.err
+			if next < len(ips) {
+				attempt()
+			}
+		case <-stagger:
+			attempt()
+		}
+	}
+
+	return nil, perrors.WithStack(err)
+}
@@ -0,0 +1,56 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package getty
+
+import (
+	"context"
+	"net"
+	"strconv"
+	"testing"
+	"time"
+)
+
+import (
+	"github.com/stretchr/testify/assert"
+)
+
+func TestInterleaveIPs(t *testing.T) {
+	v6a, v6b := net.IPAddr{IP: net.ParseIP("::1")}, net.IPAddr{IP: net.ParseIP("::2")}
+	v4a, v4b := net.IPAddr{IP: net.ParseIP("10.0.0.1")}, net.IPAddr{IP: net.ParseIP("10.0.0.2")}
+
+	assert.Equal(t, []net.IPAddr{v6a, v4a, v6b, v4b}, interleaveIPs([]net.IPAddr{v6a, v6b, v4a, v4b}))
+	assert.Equal(t, []net.IPAddr{v4a, v6a, v4b}, interleaveIPs([]net.IPAddr{v4a, v4b, v6a}))
+	assert.Equal(t, []net.IPAddr{v4a}, interleaveIPs([]net.IPAddr{v4a}))
+}
+
+func TestDialHappyEyeballs(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	assert.Nil(t, err)
+	defer ln.Close()
+	port := strconv.Itoa(ln.Addr().(*net.TCPAddr).Port)
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	conn, err := dialHappyEyeballs(ctx, nil, "tcp", net.JoinHostPort("localhost", port), 0)
+	assert.Nil(t, err)
+	conn.Close()
+
+	ln.Close()
+	_, err = dialHappyEyeballs(ctx, nil, "tcp", net.JoinHostPort("localhost", port), 0)
+	assert.NotNil(t, err)
+}
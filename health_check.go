@@ -0,0 +1,218 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package getty
+
+import (
+	"sort"
+	"sync"
+	"time"
+)
+
+const (
+	defaultHealthCheckInterval = 10 * time.Second
+	defaultHealthCheckTimeout  = 3 * time.Second
+)
+
+// HealthCheckOptions sets the probes of the client sessions, see WithHealthCheck.
+type HealthCheckOptions struct {
+	// Interval is the idle time of a session before it is probed, 0 means 10s.
+	Interval time.Duration
+	// Timeout is how long to wait for the pong, the session is closed and reconnected
+	// if it times out, 0 means 3s.
+	Timeout time.Duration
+	// Ping returns the ping package of the protocol.
+	Ping func(Session) interface{}
+	// IsPong returns true if @pkg is the pong package, which is not passed to OnMessage.
+	IsPong func(session Session, pkg interface{}) bool
+}
+
+// EndpointStatus is the health of a server address of the client, see PoolClient.Status.
+type EndpointStatus struct {
+	Addr          string
+	Sessions      int           // the open sessions
+	RTT           time.Duration // the average rtt of the last probes of the sessions, 0 if none
+	ProbeTimeouts uint64        // the sessions closed for the probe timeouts
+	CircuitOpen   bool
+}
+
+// sessionHealth is the probe state of a session.
+type sessionHealth struct {
+	opts *HealthCheckOptions
+
+	lock     sync.Mutex
+	pingTime time.Time // zero if no probe is waiting for the pong
+	rtt      time.Duration
+}
+
+// onMessage returns true if @pkg is the pong, and records the rtt of the probe.
+func (h *sessionHealth) onMessage(ss Session, pkg interface{}) bool {
+	if !h.opts.IsPong(ss, pkg) {
+		return false
+	}
+
+	h.lock.Lock()
+	if !h.pingTime.IsZero() {
+		h.rtt = time.Since(h.pingTime)
+		h.pingTime = time.Time{}
+	}
+	h.lock.Unlock()
+	return true
+}
+
+// check probes the session @ss if it is idle, and returns false if the probe times out.
+func (h *sessionHealth) check(ss Session, now time.Time) bool {
+	h.lock.Lock()
+	if !h.pingTime.IsZero() {
+		expired := now.Sub(h.pingTime) > h.opts.Timeout
+		h.lock.Unlock()
+		return !expired
+	}
+	h.lock.Unlock()
+
+	stats := ss.Stats()
+	last := stats.ConnectTime
+	if stats.LastReadTime.After(last) {
+		last = stats.LastReadTime
+	}
+	if stats.LastWriteTime.After(last) {
+		last = stats.LastWriteTime
+	}
+	if now.Sub(last) < h.opts.Interval {
+		return true
+	}
+
+	h.lock.Lock()
+	h.pingTime = time.Now()
+	h.lock.Unlock()
+	if _, _, err := ss.WritePkg(h.opts.Ping(ss), h.opts.Timeout); err != nil {
+		poolLog.with(ss.Logger()).Warnw("write the ping failed", "err", err)
+	}
+	return true
+}
+
+func (h *sessionHealth) lastRTT() time.Duration {
+	h.lock.Lock()
+	defer h.lock.Unlock()
+	return h.rtt
+}
+
+// runHealthCheck probes the sessions of the pool, and closes the ones whose probes time out,
+// which are reconnected then.
+func (c *client) runHealthCheck() {
+	defer c.wg.Done()
+
+	tick := c.healthCheck.Interval
+	if c.healthCheck.Timeout < tick {
+		tick = c.healthCheck.Timeout
+	}
+	ticker := time.NewTicker(tick / 2)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-c.done:
+			return
+		case now := <-ticker.C:
+			c.checkHealth(now)
+		}
+	}
+}
+
+func (c *client) checkHealth(now time.Time) {
+	c.Lock()
+	sessions := make([]*session, 0, len(c.ssMap))
+	for s := range c.ssMap {
+		if ss := s.(*session); !ss.IsClosed() && ss.health != nil {
+			sessions = append(sessions, ss)
+		}
+	}
+	c.Unlock()
+
+	for _, ss := range sessions {
+		if ss.health.check(ss, now) {
+			continue
+		}
+		ss.logger(poolLog).Warnw("close the session as its probe times out")
+		c.Lock()
+		if c.probeTimeouts == nil {
+			c.probeTimeouts = make(map[string]uint64)
+		}
+		c.probeTimeouts[ss.RemoteAddr()]++
+		c.Unlock()
+		ss.CloseWithReason(CloseReasonIdleTimeout)
+	}
+}
+
+// Status returns the health of the server addresses of the open sessions and the ones whose
+// probes have timed out, which are sorted by the addresses.
+func (c *client) Status() []EndpointStatus {
+	type rttSum struct {
+		sum time.Duration
+		num int
+	}
+
+	c.Lock()
+	statuses := make(map[string]*EndpointStatus)
+	statusOf := func(addr string) *EndpointStatus {
+		st, ok := statuses[addr]
+		if !ok {
+			st = &EndpointStatus{Addr: addr}
+			statuses[addr] = st
+		}
+		return st
+	}
+	rtts := make(map[string]*rttSum)
+	for s := range c.ssMap {
+		ss := s.(*session)
+		if ss.IsClosed() {
+			continue
+		}
+		addr := ss.RemoteAddr()
+		statusOf(addr).Sessions++
+		if ss.health == nil {
+			continue
+		}
+		if rtt := ss.health.lastRTT(); rtt > 0 {
+			if rtts[addr] == nil {
+				rtts[addr] = &rttSum{}
+			}
+			rtts[addr].sum += rtt
+			rtts[addr].num++
+		}
+	}
+	for addr, n := range c.probeTimeouts {
+		statusOf(addr).ProbeTimeouts = n
+	}
+	breakers := make(map[string]*circuitBreaker, len(c.breakers))
+	for addr, b := range c.breakers {
+		breakers[addr] = b
+	}
+	c.Unlock()
+
+	result := make([]EndpointStatus, 0, len(statuses))
+	for addr, st := range statuses {
+		if r := rtts[addr]; r != nil {
+			st.RTT = r.sum / time.Duration(r.num)
+		}
+		if b := breakers[addr]; b != nil {
+			st.CircuitOpen = b.isOpen()
+		}
+		result = append(result, *st)
+	}
+	sort.Slice(result, func(i, j int) bool { return result[i].Addr < result[j].Addr })
+	return result
+}
@@ -0,0 +1,96 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package getty
+
+import (
+	"testing"
+	"time"
+)
+
+import (
+	"github.com/stretchr/testify/assert"
+)
+
+// pongHandler replies the pings by the pongs if @reply is true.
+type pongHandler struct {
+	*chanMessageHandler
+	reply bool
+}
+
+func (h *pongHandler) OnMessage(session Session, pkg interface{}) {
+	if pkg == "ping" {
+		if h.reply {
+			session.WritePkg("pong", 0)
+		}
+		return
+	}
+	h.chanMessageHandler.OnMessage(session, pkg)
+}
+
+func TestClientHealthCheck(t *testing.T) {
+	newServer := func(reply bool) *server {
+		s := NewTCPServer(WithLocalAddress("127.0.0.1:0")).(*server)
+		s.RunEventLoop(func(session Session) error {
+			session.SetPkgHandler(&stringPkgHandler{})
+			session.SetEventListener(&pongHandler{chanMessageHandler: newChanMessageHandler(), reply: reply})
+			session.SetReadTimeout(50 * time.Millisecond)
+			return nil
+		})
+		return s
+	}
+	s1, s2 := newServer(true), newServer(false)
+	defer s1.Close()
+	defer s2.Close()
+
+	clientHandler := newChanMessageHandler()
+	clt := NewTCPClient(WithResolver(NewStaticResolver(s1.addr, s2.addr)), WithConnectionNumber(2),
+		WithReconnectInterval(1e7), WithHealthCheck(HealthCheckOptions{
+			Interval: time.Hour,
+			Timeout:  time.Hour,
+			Ping:     func(Session) interface{} { return "ping" },
+			IsPong:   func(session Session, pkg interface{}) bool { return pkg == "pong" },
+		})).(*client)
+	defer clt.Close()
+	clt.RunEventLoop(func(session Session) error {
+		session.SetPkgHandler(&stringPkgHandler{})
+		session.SetEventListener(clientHandler)
+		session.SetReadTimeout(50 * time.Millisecond)
+		return nil
+	})
+	statusOf := func(addr string) EndpointStatus {
+		for _, st := range clt.Status() {
+			if st.Addr == addr {
+				return st
+			}
+		}
+		return EndpointStatus{}
+	}
+	assert.Equal(t, 2, len(clt.Status()))
+	assert.Equal(t, EndpointStatus{Addr: s1.addr, Sessions: 1}, statusOf(s1.addr))
+
+	// the sessions are idle for 2 hours
+	clt.checkHealth(time.Now().Add(2 * time.Hour))
+	assert.Eventually(t, func() bool { return statusOf(s1.addr).RTT > 0 }, time.Second, 10*time.Millisecond)
+	assert.Equal(t, 0, len(clientHandler.msgs))
+
+	// the probe to s2 times out
+	clt.checkHealth(time.Now().Add(4 * time.Hour))
+	assert.Equal(t, uint64(0), statusOf(s1.addr).ProbeTimeouts)
+	assert.Equal(t, uint64(1), statusOf(s2.addr).ProbeTimeouts)
+	assert.Equal(t, time.Duration(0), statusOf(s2.addr).RTT)
+}
@@ -0,0 +1,79 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package getty
+
+import (
+	"time"
+)
+
+// WriteFunc writes @pkg to @session, which is Session.WritePkg at the end of the interceptors.
+type WriteFunc func(session Session, pkg interface{}, timeout time.Duration) (totalBytes int, sendBytes int, err error)
+
+// Interceptor is a middleware of all the sessions of an endpoint, eg: for auth, metrics, tracing,
+// panic recovery or rate limiting. Listener wraps the EventListener set by SetEventListener, whose
+// OnOpen, OnMessage, OnClose, OnError and OnCron are invoked by the wrapper, and Write wraps
+// Session.WritePkg. Either of them can be nil. The wrapper can embed @next to only override some
+// callbacks.
+type Interceptor struct {
+	Listener func(next EventListener) EventListener
+	Write    func(next WriteFunc) WriteFunc
+}
+
+// interceptorHolder is implemented by the endpoints which accept interceptors.
+type interceptorHolder interface {
+	sessionInterceptors() []Interceptor
+}
+
+func (s *server) sessionInterceptors() []Interceptor {
+	return s.interceptors
+}
+
+func (c *client) sessionInterceptors() []Interceptor {
+	return c.interceptors
+}
+
+// interceptorsOf returns the interceptors of @endPoint, the first one is the outermost.
+func interceptorsOf(endPoint EndPoint) []Interceptor {
+	if h, ok := endPoint.(interceptorHolder); ok {
+		return h.sessionInterceptors()
+	}
+
+	return nil
+}
+
+// interceptListener wraps @listener by @interceptors.
+func interceptListener(interceptors []Interceptor, listener EventListener) EventListener {
+	for i := len(interceptors) - 1; i >= 0; i-- {
+		if interceptors[i].Listener != nil {
+			listener = interceptors[i].Listener(listener)
+		}
+	}
+
+	return listener
+}
+
+// interceptWrite wraps @write by @interceptors.
+func interceptWrite(interceptors []Interceptor, write WriteFunc) WriteFunc {
+	for i := len(interceptors) - 1; i >= 0; i-- {
+		if interceptors[i].Write != nil {
+			write = interceptors[i].Write(write)
+		}
+	}
+
+	return write
+}
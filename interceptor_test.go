@@ -0,0 +1,206 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package getty
+
+import (
+	"sync"
+	"testing"
+	"time"
+)
+
+import (
+	"github.com/stretchr/testify/assert"
+)
+
+// traceListener records the callbacks in @trace by @name.
+type traceListener struct {
+	EventListener
+	name  string
+	lock  *sync.Mutex
+	trace *[]string
+}
+
+func (l *traceListener) record(event string) {
+	l.lock.Lock()
+	*l.trace = append(*l.trace, l.name+"."+event)
+	l.lock.Unlock()
+}
+
+func (l *traceListener) OnOpen(session Session) error {
+	l.record("open")
+	return l.EventListener.OnOpen(session)
+}
+
+func (l *traceListener) OnMessage(session Session, pkg interface{}) {
+	l.record("message")
+	l.EventListener.OnMessage(session, pkg)
+}
+
+func TestInterceptor(t *testing.T) {
+	var (
+		lock  sync.Mutex
+		trace []string
+	)
+	snapshot := func() []string {
+		lock.Lock()
+		defer lock.Unlock()
+		return append([]string(nil), trace...)
+	}
+	newInterceptor := func(name string) Interceptor {
+		return Interceptor{
+			Listener: func(next EventListener) EventListener {
+				return &traceListener{EventListener: next, name: name, lock: &lock, trace: &trace}
+			},
+			Write: func(next WriteFunc) WriteFunc {
+				return func(session Session, pkg interface{}, timeout time.Duration) (int, int, error) {
+					lock.Lock()
+					trace = append(trace, name+".write")
+					lock.Unlock()
+					return next(session, pkg.(string)+"-"+name, timeout)
+				}
+			},
+		}
+	}
+
+	serverHandler := newChanMessageHandler()
+	s := NewTCPServer(WithLocalAddress("127.0.0.1:0")).(*server)
+	defer s.Close()
+	s.RunEventLoop(func(session Session) error {
+		session.SetPkgHandler(&stringPkgHandler{})
+		session.SetEventListener(serverHandler)
+		session.SetReadTimeout(50 * time.Millisecond)
+		return nil
+	})
+
+	clt := NewTCPClient(WithServerAddress(s.addr), WithConnectionNumber(1), WithReconnectInterval(1e7),
+		WithClientInterceptor(newInterceptor("a")), WithClientInterceptor(newInterceptor("b"), Interceptor{}))
+	defer clt.Close()
+	clientHandler := newChanMessageHandler()
+	clt.RunEventLoop(func(session Session) error {
+		session.SetPkgHandler(&stringPkgHandler{})
+		session.SetEventListener(clientHandler)
+		session.SetReadTimeout(50 * time.Millisecond)
+		return nil
+	})
+	assert.Equal(t, 1, clientHandler.SessionNumber())
+	assert.Equal(t, []string{"a.open", "b.open"}, snapshot())
+
+	// the outer interceptor writes first
+	_, _, err := clientHandler.array[0].WritePkg("ping", 0)
+	assert.Nil(t, err)
+	select {
+	case msg := <-serverHandler.msgs:
+		assert.Equal(t, "ping-a-b", msg)
+	case <-time.After(3 * time.Second):
+		t.Fatal("server did not receive the package")
+	}
+
+	// the replies reach the listener through the interceptors
+	for _, ss := range serverHandler.array {
+		_, _, err = ss.WritePkg("pong", 0)
+		assert.Nil(t, err)
+	}
+	select {
+	case msg := <-clientHandler.msgs:
+		assert.Equal(t, "pong", msg)
+	case <-time.After(3 * time.Second):
+		t.Fatal("client did not receive the package")
+	}
+	assert.Equal(t, []string{"a.open", "b.open", "a.write", "b.write", "a.message", "b.message"}, snapshot())
+
+	// every package of a batch passes the interceptors
+	_, err = clientHandler.array[0].WriteBatchPkg([]interface{}{"x", "y"}, 0)
+	assert.Nil(t, err)
+	for _, want := range []string{"x-a-b", "y-a-b"} {
+		select {
+		case msg := <-serverHandler.msgs:
+			assert.Equal(t, want, msg)
+		case <-time.After(3 * time.Second):
+			t.Fatal("server did not receive the package")
+		}
+	}
+	assert.Equal(t, []string{"a.write", "b.write", "a.write", "b.write"}, snapshot()[6:])
+}
+
+func TestInterceptorWriteResult(t *testing.T) {
+	type result struct {
+		pkg  interface{}
+		sent int
+		err  error
+	}
+	results := make(chan result, 4)
+	interceptor := Interceptor{
+		Write: func(next WriteFunc) WriteFunc {
+			return func(session Session, pkg interface{}, timeout time.Duration) (int, int, error) {
+				total, sent, err := next(session, pkg, timeout)
+				results <- result{pkg: pkg, sent: sent, err: err}
+				return total, sent, err
+			}
+		},
+	}
+
+	serverHandler := newChanMessageHandler()
+	s := NewTCPServer(WithLocalAddress("127.0.0.1:0")).(*server)
+	defer s.Close()
+	s.RunEventLoop(func(session Session) error {
+		session.SetPkgHandler(&stringPkgHandler{})
+		session.SetEventListener(serverHandler)
+		return nil
+	})
+	clt := NewTCPClient(WithServerAddress(s.addr), WithConnectionNumber(1), WithReconnectInterval(1e7),
+		WithClientInterceptor(interceptor))
+	defer clt.Close()
+	clientHandler := newChanMessageHandler()
+	clt.RunEventLoop(func(session Session) error {
+		session.SetPkgHandler(&stringPkgHandler{})
+		session.SetEventListener(clientHandler)
+		return nil
+	})
+	ss := clientHandler.array[0]
+	next := func() result {
+		select {
+		case r := <-results:
+			return r
+		case <-time.After(3 * time.Second):
+			t.Fatal("the interceptor is not invoked")
+			return result{}
+		}
+	}
+
+	// the queued package passes the interceptors
+	written := make(chan error, 1)
+	ss.WritePkgAsync("async", 0, func(err error) { written <- err })
+	assert.Nil(t, <-written)
+	assert.Equal(t, result{pkg: "async"}, next())
+	select {
+	case msg := <-serverHandler.msgs:
+		assert.Equal(t, "async", msg)
+	case <-time.After(3 * time.Second):
+		t.Fatal("server did not receive the package")
+	}
+
+	// the interceptors of a batch see the error of sending it
+	_, err := ss.WriteBatchPkg([]interface{}{"x", "y"}, time.Nanosecond)
+	assert.NotNil(t, err)
+	for _, pkg := range []string{"y", "x"} {
+		r := next()
+		assert.Equal(t, pkg, r.pkg)
+		assert.Equal(t, 0, r.sent)
+		assert.Equal(t, err, r.err)
+	}
+}
@@ -0,0 +1,171 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package getty
+
+import (
+	"net"
+	"strings"
+	"sync"
+)
+
+import (
+	perrors "github.com/pkg/errors"
+)
+
+var (
+	errIPDenied          = perrors.New("ip is denied")
+	errTooManyConnsPerIP = perrors.New("too many connections of the ip")
+)
+
+// parseCIDRs parses @cidrs, a plain ip is taken as a single address network.
+func parseCIDRs(cidrs []string) ([]*net.IPNet, error) {
+	nets := make([]*net.IPNet, 0, len(cidrs))
+	for _, cidr := range cidrs {
+		if !strings.Contains(cidr, "/") {
+			ip := net.ParseIP(cidr)
+			if ip == nil {
+				return nil, perrors.Errorf("illegal ip %q", cidr)
+			}
+			bits := 8 * net.IPv6len
+			if ip4 := ip.To4(); ip4 != nil {
+				ip, bits = ip4, 8*net.IPv4len
+			}
+			nets = append(nets, &net.IPNet{IP: ip, Mask: net.CIDRMask(bits, bits)})
+			continue
+		}
+		_, n, err := net.ParseCIDR(cidr)
+		if err != nil {
+			return nil, perrors.WithStack(err)
+		}
+		nets = append(nets, n)
+	}
+
+	return nets, nil
+}
+
+func containsIP(nets []*net.IPNet, ip net.IP) bool {
+	for _, n := range nets {
+		if n.Contains(ip) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// sessionFilterIPKey is the session attribute of the ip counted by the ip filter.
+var sessionFilterIPKey = "session-filter-ip"
+
+// addrIP returns the ip of the host:port address @addr.
+func addrIP(addr string) net.IP {
+	host, _, err := net.SplitHostPort(addr)
+	if err != nil {
+		host = addr
+	}
+
+	return net.ParseIP(host)
+}
+
+// ipFilter checks the remote ip of the accepted connections by the allow/deny lists,
+// and counts the connections of every ip for the per ip limit.
+type ipFilter struct {
+	lock     sync.Mutex
+	allow    []*net.IPNet
+	deny     []*net.IPNet
+	maxPerIP int
+	conns    map[string]int
+}
+
+func newIPFilter(allow, deny []string, maxPerIP int) (*ipFilter, error) {
+	f := &ipFilter{maxPerIP: maxPerIP, conns: make(map[string]int)}
+	if err := f.setAllowList(allow); err != nil {
+		return nil, err
+	}
+	if err := f.setDenyList(deny); err != nil {
+		return nil, err
+	}
+
+	return f, nil
+}
+
+func (f *ipFilter) setAllowList(cidrs []string) error {
+	nets, err := parseCIDRs(cidrs)
+	if err != nil {
+		return err
+	}
+	f.lock.Lock()
+	f.allow = nets
+	f.lock.Unlock()
+
+	return nil
+}
+
+func (f *ipFilter) setDenyList(cidrs []string) error {
+	nets, err := parseCIDRs(cidrs)
+	if err != nil {
+		return err
+	}
+	f.lock.Lock()
+	f.deny = nets
+	f.lock.Unlock()
+
+	return nil
+}
+
+func (f *ipFilter) setMaxPerIP(n int) {
+	f.lock.Lock()
+	f.maxPerIP = n
+	f.lock.Unlock()
+}
+
+// acquire checks a new connection of @ip, which should be released by release if it succeeds.
+// The connections whose ip is unknown, eg: unix socket, are not checked.
+func (f *ipFilter) acquire(ip net.IP) error {
+	if ip == nil {
+		return nil
+	}
+
+	f.lock.Lock()
+	defer f.lock.Unlock()
+
+	if containsIP(f.deny, ip) || (len(f.allow) != 0 && !containsIP(f.allow, ip)) {
+		return perrors.Wrapf(errIPDenied, "ip %s", ip)
+	}
+	key := ip.String()
+	if f.maxPerIP > 0 && f.conns[key] >= f.maxPerIP {
+		return perrors.Wrapf(errTooManyConnsPerIP, "ip %s, limit %d", ip, f.maxPerIP)
+	}
+	f.conns[key]++
+
+	return nil
+}
+
+func (f *ipFilter) release(ip net.IP) {
+	if ip == nil {
+		return
+	}
+
+	key := ip.String()
+	f.lock.Lock()
+	if f.conns[key] <= 1 {
+		delete(f.conns, key)
+	} else {
+		f.conns[key]--
+	}
+	f.lock.Unlock()
+}
@@ -0,0 +1,210 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package getty
+
+import (
+	"crypto/tls"
+	"io"
+	"net"
+	"testing"
+	"time"
+)
+
+import (
+	perrors "github.com/pkg/errors"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestIPFilter(t *testing.T) {
+	_, err := newIPFilter([]string{"10.0.0.0/33"}, nil, 0)
+	assert.NotNil(t, err)
+	_, err = newIPFilter(nil, []string{"example.com"}, 0)
+	assert.NotNil(t, err)
+
+	f, err := newIPFilter([]string{"10.0.0.0/8", "::1"}, []string{"10.0.0.1"}, 2)
+	assert.Nil(t, err)
+	assert.Equal(t, errIPDenied, perrors.Cause(f.acquire(net.ParseIP("10.0.0.1"))))
+	assert.Equal(t, errIPDenied, perrors.Cause(f.acquire(net.ParseIP("192.168.0.1"))))
+	assert.Nil(t, f.acquire(net.ParseIP("::1")))
+	assert.Nil(t, f.acquire(nil))
+
+	ip := net.ParseIP("10.0.0.2")
+	assert.Nil(t, f.acquire(ip))
+	assert.Nil(t, f.acquire(ip))
+	assert.Equal(t, errTooManyConnsPerIP, perrors.Cause(f.acquire(ip)))
+	f.release(ip)
+	assert.Nil(t, f.acquire(ip))
+	f.setMaxPerIP(0)
+	assert.Nil(t, f.acquire(ip))
+
+	assert.Nil(t, f.setAllowList(nil))
+	assert.Nil(t, f.acquire(net.ParseIP("192.168.0.1")))
+	assert.NotNil(t, f.setDenyList([]string{"bad"}))
+}
+
+func TestServerIPLimit(t *testing.T) {
+	s := NewTCPServer(WithLocalAddress("127.0.0.1:0"), WithMaxConnPerIP(1)).(*server)
+	defer s.Close()
+	serverHandler := newChanMessageHandler()
+	s.RunEventLoop(func(session Session) error {
+		session.SetPkgHandler(&stringPkgHandler{})
+		session.SetEventListener(serverHandler)
+		return nil
+	})
+	closed := func(conn net.Conn) bool {
+		conn.SetReadDeadline(time.Now().Add(200 * time.Millisecond))
+		_, err := conn.Read(make([]byte, 1))
+		return err == io.EOF
+	}
+
+	conn1, err := net.Dial("tcp", s.addr)
+	assert.Nil(t, err)
+	defer conn1.Close()
+	assert.False(t, closed(conn1))
+	conn2, err := net.Dial("tcp", s.addr)
+	assert.Nil(t, err)
+	assert.True(t, closed(conn2))
+	conn2.Close()
+
+	// the ip can connect again after its session is closed
+	conn1.Close()
+	for i := 0; s.SessionCount() != 0 && i < 100; i++ {
+		time.Sleep(10 * time.Millisecond)
+	}
+	conn3, err := net.Dial("tcp", s.addr)
+	assert.Nil(t, err)
+	defer conn3.Close()
+	assert.False(t, closed(conn3))
+
+	assert.Nil(t, s.SetIPDenyList([]string{"127.0.0.0/8"}))
+	s.SetMaxConnPerIP(0)
+	conn4, err := net.Dial("tcp", s.addr)
+	assert.Nil(t, err)
+	assert.True(t, closed(conn4))
+	conn4.Close()
+}
+
+func TestServerIPLimitBeforeHandshake(t *testing.T) {
+	dir := t.TempDir()
+	serverCert := writeTestCertificate(t, dir, "server")
+	clientCert := writeTestCertificate(t, dir, "client")
+	s := NewTCPServer(
+		WithLocalAddress("127.0.0.1:0"),
+		WithMaxConnPerIP(1),
+		WithServerSslEnabled(true),
+		WithServerTlsConfigBuilder(&ServerTlsConfigBuilder{
+			ServerKeyCertChainPath: serverCert.KeyCertChainPath,
+			ServerPrivateKeyPath:   serverCert.PrivateKeyPath,
+		}),
+	).(*server)
+	defer s.Close()
+	s.RunEventLoop(func(session Session) error {
+		session.SetPkgHandler(&stringPkgHandler{})
+		session.SetEventListener(newChanMessageHandler())
+		return nil
+	})
+	closed := func(conn net.Conn) bool {
+		conn.SetReadDeadline(time.Now().Add(200 * time.Millisecond))
+		_, err := conn.Read(make([]byte, 1))
+		return err == io.EOF
+	}
+
+	// the connection waiting for its tls handshake takes the quota of the ip
+	conn1, err := net.Dial("tcp", s.addr)
+	assert.Nil(t, err)
+	assert.False(t, closed(conn1))
+	conn2, err := net.Dial("tcp", s.addr)
+	assert.Nil(t, err)
+	assert.True(t, closed(conn2))
+	conn2.Close()
+
+	// the quota is released if the handshake fails
+	conn1.Close()
+	time.Sleep(50 * time.Millisecond)
+	config, err := (&ClientTlsConfigBuilder{
+		ClientKeyCertChainPath:        clientCert.KeyCertChainPath,
+		ClientPrivateKeyPath:          clientCert.PrivateKeyPath,
+		ClientTrustCertCollectionPath: serverCert.KeyCertChainPath,
+	}).BuildTlsConfig()
+	assert.Nil(t, err)
+	config.ServerName = "server"
+	conn3, err := tls.Dial("tcp", s.addr, config)
+	assert.Nil(t, err)
+	defer conn3.Close()
+	for i := 0; s.SessionCount() != 1 && i < 100; i++ {
+		time.Sleep(10 * time.Millisecond)
+	}
+	assert.Equal(t, 1, s.SessionCount())
+}
+
+func TestServerIPLimitProxySource(t *testing.T) {
+	s := NewTCPServer(
+		WithLocalAddress("127.0.0.1:0"),
+		WithMaxConnPerIP(1),
+		WithIPDenyList([]string{"192.0.2.9"}),
+		WithProxyProtocol(true),
+	).(*server)
+	defer s.Close()
+	s.RunEventLoop(func(session Session) error {
+		session.SetPkgHandler(&stringPkgHandler{})
+		session.SetEventListener(newChanMessageHandler())
+		return nil
+	})
+	dial := func(src string) net.Conn {
+		conn, err := net.Dial("tcp", s.addr)
+		assert.Nil(t, err)
+		_, err = conn.Write([]byte("PROXY TCP4 " + src + " 198.51.100.1 5555 80\r\n"))
+		assert.Nil(t, err)
+		return conn
+	}
+	closed := func(conn net.Conn) bool {
+		conn.SetReadDeadline(time.Now().Add(200 * time.Millisecond))
+		_, err := conn.Read(make([]byte, 1))
+		return err == io.EOF
+	}
+
+	// the clients behind the same proxy are screened by their own addresses
+	conn1 := dial("192.0.2.1")
+	defer conn1.Close()
+	assert.False(t, closed(conn1))
+	conn2 := dial("192.0.2.2")
+	defer conn2.Close()
+	assert.False(t, closed(conn2))
+	for i := 0; s.SessionCount() != 2 && i < 100; i++ {
+		time.Sleep(10 * time.Millisecond)
+	}
+	assert.Equal(t, 2, s.SessionCount())
+
+	conn3 := dial("192.0.2.1")
+	assert.True(t, closed(conn3))
+	conn3.Close()
+	conn4 := dial("192.0.2.9")
+	assert.True(t, closed(conn4))
+	conn4.Close()
+	assert.Equal(t, 2, s.SessionCount())
+
+	// the quota of the source address is released with its session
+	conn1.Close()
+	for i := 0; s.SessionCount() != 1 && i < 100; i++ {
+		time.Sleep(10 * time.Millisecond)
+	}
+	conn5 := dial("192.0.2.1")
+	defer conn5.Close()
+	assert.False(t, closed(conn5))
+}
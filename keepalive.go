@@ -0,0 +1,45 @@
+//go:build go1.23
+
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package getty
+
+import (
+	"net"
+)
+
+import (
+	perrors "github.com/pkg/errors"
+)
+
+// applyKeepAlive enables the keepalive of @tc, and sets the non-zero keepalive options.
+func (o *SocketOptions) applyKeepAlive(tc *net.TCPConn) error {
+	// -1 keeps the current value of the socket
+	config := net.KeepAliveConfig{Enable: true, Idle: -1, Interval: -1, Count: -1}
+	if o.KeepAlivePeriod > 0 {
+		config.Idle = o.KeepAlivePeriod
+	}
+	if o.KeepAliveInterval > 0 {
+		config.Interval = o.KeepAliveInterval
+	}
+	if o.KeepAliveCount > 0 {
+		config.Count = o.KeepAliveCount
+	}
+
+	return perrors.WithStack(tc.SetKeepAliveConfig(config))
+}
@@ -0,0 +1,45 @@
+//go:build !go1.23 && !linux && !darwin && !dragonfly && !freebsd && !netbsd
+
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package getty
+
+import (
+	"net"
+	"runtime"
+)
+
+import (
+	perrors "github.com/pkg/errors"
+)
+
+// applyKeepAlive enables the keepalive of @tc, and sets the keepalive period. The toolchains
+// before go1.23 can not set the keepalive probes on this platform.
+func (o *SocketOptions) applyKeepAlive(tc *net.TCPConn) error {
+	if o.KeepAliveInterval > 0 || o.KeepAliveCount > 0 {
+		return perrors.Errorf("setting the keepalive probes is not supported on %s before go1.23", runtime.GOOS)
+	}
+	if err := tc.SetKeepAlive(true); err != nil {
+		return perrors.WithStack(err)
+	}
+	if o.KeepAlivePeriod > 0 {
+		return perrors.WithStack(tc.SetKeepAlivePeriod(o.KeepAlivePeriod))
+	}
+
+	return nil
+}
@@ -0,0 +1,54 @@
+//go:build !go1.23 && (linux || darwin || dragonfly || freebsd || netbsd)
+
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package getty
+
+import (
+	"net"
+)
+
+import (
+	perrors "github.com/pkg/errors"
+
+	"golang.org/x/sys/unix"
+)
+
+// applyKeepAlive enables the keepalive of @tc, and sets the non-zero keepalive options. The
+// toolchains before go1.23 have no net.KeepAliveConfig, so the probes are set by setsockopt.
+func (o *SocketOptions) applyKeepAlive(tc *net.TCPConn) error {
+	if err := tc.SetKeepAlive(true); err != nil {
+		return perrors.WithStack(err)
+	}
+	// it sets the interval too, which is overwritten by KeepAliveInterval
+	if o.KeepAlivePeriod > 0 {
+		if err := tc.SetKeepAlivePeriod(o.KeepAlivePeriod); err != nil {
+			return perrors.WithStack(err)
+		}
+	}
+	if o.KeepAliveInterval > 0 {
+		if err := setsockoptInt(tc, unix.IPPROTO_TCP, unix.TCP_KEEPINTVL, int(o.KeepAliveInterval.Seconds())); err != nil {
+			return err
+		}
+	}
+	if o.KeepAliveCount > 0 {
+		return setsockoptInt(tc, unix.IPPROTO_TCP, unix.TCP_KEEPCNT, o.KeepAliveCount)
+	}
+
+	return nil
+}
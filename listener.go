@@ -0,0 +1,351 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package getty
+
+import (
+	"net"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+import (
+	perrors "github.com/pkg/errors"
+)
+
+// addrSeparator separates the local addresses of a server, eg: ":8090,10.0.0.2:8091"
+const addrSeparator = ","
+
+// splitAddrs splits @addr into local addresses. An empty @addr means one random port.
+func splitAddrs(addr string) []string {
+	var addrs []string
+	for _, a := range strings.Split(addr, addrSeparator) {
+		if a = strings.TrimSpace(a); a != "" {
+			addrs = append(addrs, a)
+		}
+	}
+	if len(addrs) == 0 {
+		addrs = append(addrs, "")
+	}
+
+	return addrs
+}
+
+func joinAddrs[T any](listeners []T, addrOf func(T) net.Addr) string {
+	addrs := make([]string, 0, len(listeners))
+	for _, l := range listeners {
+		addr := addrOf(l).String()
+		// the listeners opened with SO_REUSEPORT share the same address
+		if len(addrs) == 0 || addrs[len(addrs)-1] != addr {
+			addrs = append(addrs, addr)
+		}
+	}
+
+	return strings.Join(addrs, addrSeparator)
+}
+
+// systemdFdStart is the first file descriptor passed by systemd, see sd_listen_fds(3).
+const systemdFdStart = 3
+
+var (
+	systemdOnce  sync.Once
+	systemdFiles []*os.File
+	systemdErr   error
+)
+
+// systemdListenFiles returns the sockets passed by systemd socket activation whose
+// FileDescriptorName is one of @names. All of the sockets are returned if @names is empty.
+func systemdListenFiles(names []string) ([]*os.File, error) {
+	systemdOnce.Do(func() {
+		pid, err := strconv.Atoi(os.Getenv("LISTEN_PID"))
+		if err != nil || pid != os.Getpid() {
+			systemdErr = perrors.Errorf("LISTEN_PID %q is not the pid of current process", os.Getenv("LISTEN_PID"))
+			return
+		}
+		num, err := strconv.Atoi(os.Getenv("LISTEN_FDS"))
+		if err != nil || num <= 0 {
+			systemdErr = perrors.Errorf("illegal LISTEN_FDS %q", os.Getenv("LISTEN_FDS"))
+			return
+		}
+		fdNames := strings.Split(os.Getenv("LISTEN_FDNAMES"), ":")
+		for i := 0; i < num; i++ {
+			name := "LISTEN_FD_" + strconv.Itoa(systemdFdStart+i)
+			if i < len(fdNames) && fdNames[i] != "" {
+				name = fdNames[i]
+			}
+			systemdFiles = append(systemdFiles, os.NewFile(uintptr(systemdFdStart+i), name))
+		}
+	})
+	if systemdErr != nil {
+		return nil, systemdErr
+	}
+
+	var files []*os.File
+	for _, f := range systemdFiles {
+		if len(names) == 0 {
+			files = append(files, f)
+			continue
+		}
+		for _, name := range names {
+			if f.Name() == name {
+				files = append(files, f)
+				break
+			}
+		}
+	}
+	if len(files) == 0 {
+		return nil, perrors.Errorf("no systemd socket named %v", names)
+	}
+
+	return files, nil
+}
+
+type acceptResult struct {
+	conn net.Conn
+	err  error
+}
+
+// multiListener accepts connections from all of its listeners. The accept goroutines are
+// started by the first Accept, so the listeners can also be served by their own accept loops.
+type multiListener struct {
+	listeners []net.Listener
+	results   chan acceptResult
+	start     sync.Once
+	once      sync.Once
+	done      chan struct{}
+}
+
+func newMultiListener(listeners []net.Listener) *multiListener {
+	return &multiListener{
+		listeners: listeners,
+		results:   make(chan acceptResult),
+		done:      make(chan struct{}),
+	}
+}
+
+func (l *multiListener) accept(ln net.Listener) {
+	for {
+		conn, err := ln.Accept()
+		select {
+		case l.results <- acceptResult{conn: conn, err: err}:
+		case <-l.done:
+			if conn != nil {
+				conn.Close()
+			}
+			return
+		}
+		if err != nil {
+			if netErr, ok := perrors.Cause(err).(net.Error); ok && netErr.Temporary() {
+				continue
+			}
+			return
+		}
+	}
+}
+
+func (l *multiListener) Accept() (net.Conn, error) {
+	l.start.Do(func() {
+		for _, ln := range l.listeners {
+			go l.accept(ln)
+		}
+	})
+
+	select {
+	case r := <-l.results:
+		return r.conn, r.err
+	case <-l.done:
+		return nil, perrors.WithStack(net.ErrClosed)
+	}
+}
+
+func (l *multiListener) Close() error {
+	var err error
+	l.once.Do(func() {
+		close(l.done)
+		for _, ln := range l.listeners {
+			if closeErr := ln.Close(); closeErr != nil && err == nil {
+				err = closeErr
+			}
+		}
+	})
+
+	return perrors.WithStack(err)
+}
+
+// Addr returns the address of the first listener.
+func (l *multiListener) Addr() net.Addr {
+	return l.listeners[0].Addr()
+}
+
+// acceptBackoff returns the delay before retrying a transient accept error, eg: EMFILE, which
+// doubles the last @delay from 5ms up to 1s to let the sessions release their fds.
+func acceptBackoff(delay time.Duration) time.Duration {
+	if delay == 0 {
+		return 5 * time.Millisecond
+	}
+	if delay *= 2; delay > time.Second {
+		delay = time.Second
+	}
+
+	return delay
+}
+
+const (
+	// handshakeBacklog is the number of prepared but not yet accepted connections of a handshakeListener.
+	handshakeBacklog = 128
+	// maxPendingHandshakes is the max number of the ongoing handshakes of a handshakeListener.
+	maxPendingHandshakes = 256
+)
+
+// connAdmitter screens the raw connections of a handshakeListener before their handshakes.
+type connAdmitter interface {
+	// wait blocks until a new connection can be accepted.
+	wait()
+	// admit checks the raw connection @conn, which is closed if it returns an error.
+	admit(conn net.Conn) error
+	// abort undoes admit after the handshake of @conn fails, or the prepared @conn is dropped.
+	abort(conn net.Conn)
+}
+
+// handshakeListener prepares every accepted connection in its own goroutine before returning
+// it by Accept, eg: reads the PROXY header or does the tls handshake, so that a slow peer does
+// not block the accept loop. The connection is closed if handshake fails. It stops accepting
+// while maxPendingHandshakes handshakes are ongoing.
+type handshakeListener struct {
+	net.Listener
+	handshake func(net.Conn) (net.Conn, error)
+	admitter  connAdmitter  // nil if the raw connections are not screened
+	pending   chan struct{} // a token for every ongoing handshake
+	conns     chan net.Conn
+	errs      chan error
+	once      sync.Once
+	done      chan struct{}
+}
+
+func newHandshakeListener(l net.Listener, admitter connAdmitter, handshake func(net.Conn) (net.Conn, error)) *handshakeListener {
+	hl := &handshakeListener{
+		Listener:  l,
+		handshake: handshake,
+		admitter:  admitter,
+		pending:   make(chan struct{}, maxPendingHandshakes),
+		conns:     make(chan net.Conn, handshakeBacklog),
+		errs:      make(chan error, 1),
+		done:      make(chan struct{}),
+	}
+	go hl.accept()
+
+	return hl
+}
+
+// admits tells whether the raw connections of @l are screened by a connAdmitter.
+func admits(l net.Listener) bool {
+	hl, ok := l.(*handshakeListener)
+	return ok && hl.admitter != nil
+}
+
+func (l *handshakeListener) accept() {
+	var delay time.Duration
+	for {
+		if delay != 0 {
+			select {
+			case <-time.After(delay):
+			case <-l.done:
+				return
+			}
+		}
+		select {
+		case l.pending <- struct{}{}:
+		case <-l.done:
+			return
+		}
+		if l.admitter != nil {
+			l.admitter.wait()
+		}
+
+		conn, err := l.Listener.Accept()
+		if err != nil {
+			<-l.pending
+			if netErr, ok := perrors.Cause(err).(net.Error); ok && netErr.Temporary() {
+				delay = acceptBackoff(delay)
+				log.Warnf("listener{%s}.Accept() = transient err {%v}, retry in %s", l.Addr(), err, delay)
+				continue
+			}
+			select {
+			case l.errs <- err:
+			case <-l.done:
+			}
+			return
+		}
+		delay = 0
+		if l.admitter != nil {
+			if err = l.admitter.admit(conn); err != nil {
+				log.Warnf("listener{%s} rejects conn{%s}: %v", l.Addr(), conn.RemoteAddr(), err)
+				conn.Close()
+				<-l.pending
+				continue
+			}
+		}
+		go l.prepare(conn)
+	}
+}
+
+func (l *handshakeListener) prepare(conn net.Conn) {
+	defer func() { <-l.pending }()
+
+	preparedConn, err := l.handshake(conn)
+	if err != nil {
+		log.Warnf("conn{%s} handshake() = error:%+v", conn.RemoteAddr(), err)
+		conn.Close()
+		if l.admitter != nil {
+			l.admitter.abort(conn)
+		}
+		return
+	}
+
+	select {
+	case l.conns <- preparedConn:
+	case <-l.done:
+		preparedConn.Close()
+		if l.admitter != nil {
+			l.admitter.abort(preparedConn)
+		}
+	}
+}
+
+func (l *handshakeListener) Accept() (net.Conn, error) {
+	select {
+	case conn := <-l.conns:
+		return conn, nil
+	case err := <-l.errs:
+		return nil, perrors.WithStack(err)
+	case <-l.done:
+		return nil, perrors.WithStack(net.ErrClosed)
+	}
+}
+
+func (l *handshakeListener) Close() error {
+	var err error
+	l.once.Do(func() {
+		close(l.done)
+		err = l.Listener.Close()
+	})
+
+	return perrors.WithStack(err)
+}
@@ -0,0 +1,144 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package getty
+
+import (
+	"net"
+	"strings"
+	"testing"
+	"time"
+)
+
+import (
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSplitAddrs(t *testing.T) {
+	assert.Equal(t, []string{""}, splitAddrs(""))
+	assert.Equal(t, []string{":8090"}, splitAddrs(":8090"))
+	assert.Equal(t, []string{":8090", "10.0.0.2:8091"}, splitAddrs(":8090, 10.0.0.2:8091,"))
+}
+
+func TestMultiAddressServer(t *testing.T) {
+	s := NewTCPServer(WithLocalAddress("127.0.0.1:0,127.0.0.1:0"), WithLocalAddress("127.0.0.1:0")).(*server)
+	defer s.Close()
+
+	serverHandler := newChanMessageHandler()
+	s.RunEventLoop(func(session Session) error {
+		session.SetPkgHandler(&stringPkgHandler{})
+		session.SetEventListener(serverHandler)
+		return nil
+	})
+	addrs := strings.Split(s.addr, addrSeparator)
+	assert.Equal(t, 3, len(addrs))
+
+	for _, addr := range addrs {
+		clt := NewTCPClient(WithServerAddress(addr), WithConnectionNumber(1))
+		clientHandler := newChanMessageHandler()
+		clt.RunEventLoop(func(session Session) error {
+			session.SetPkgHandler(&stringPkgHandler{})
+			session.SetEventListener(clientHandler)
+			return nil
+		})
+		assert.Equal(t, 1, clientHandler.SessionNumber())
+		_, _, err := clientHandler.array[0].WritePkg(addr, 0)
+		assert.Nil(t, err)
+
+		select {
+		case msg := <-serverHandler.msgs:
+			assert.Equal(t, addr, msg)
+		case <-time.After(3 * time.Second):
+			t.Fatalf("server did not receive the package from %s", addr)
+		}
+		clt.Close()
+	}
+}
+
+func TestServerWithListener(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	assert.Nil(t, err)
+
+	s := NewTCPServer(WithLocalAddress("127.0.0.1:1"), WithListener(ln)).(*server)
+	defer s.Close()
+
+	serverHandler := newChanMessageHandler()
+	s.RunEventLoop(func(session Session) error {
+		session.SetPkgHandler(&stringPkgHandler{})
+		session.SetEventListener(serverHandler)
+		return nil
+	})
+	assert.Equal(t, ln.Addr().String(), s.addr)
+
+	clt := NewTCPClient(WithServerAddress(s.addr), WithConnectionNumber(1))
+	defer clt.Close()
+	clientHandler := newChanMessageHandler()
+	clt.RunEventLoop(func(session Session) error {
+		session.SetPkgHandler(&stringPkgHandler{})
+		session.SetEventListener(clientHandler)
+		return nil
+	})
+	assert.Equal(t, 1, clientHandler.SessionNumber())
+	_, _, err = clientHandler.array[0].WritePkg("hello", 0)
+	assert.Nil(t, err)
+
+	select {
+	case msg := <-serverHandler.msgs:
+		assert.Equal(t, "hello", msg)
+	case <-time.After(3 * time.Second):
+		t.Fatal("server did not receive the package")
+	}
+
+	_, err = NewTransportServer(KCP_SERVER, &tcpTransport{}, WithListener(ln)).(*server).inheritedListeners()
+	assert.NotNil(t, err)
+}
+
+func TestServerWithReusePort(t *testing.T) {
+	s := NewTCPServer(WithLocalAddress("127.0.0.1:0"), WithReusePort(4)).(*server)
+	defer s.Close()
+
+	serverHandler := newChanMessageHandler()
+	s.RunEventLoop(func(session Session) error {
+		session.SetPkgHandler(&stringPkgHandler{})
+		session.SetEventListener(serverHandler)
+		return nil
+	})
+	assert.Equal(t, 4, len(s.streamListeners))
+	assert.False(t, strings.Contains(s.addr, addrSeparator))
+
+	clt := NewTCPClient(WithServerAddress(s.addr), WithConnectionNumber(4), WithReconnectInterval(1e7))
+	defer clt.Close()
+	clientHandler := newChanMessageHandler()
+	clt.RunEventLoop(func(session Session) error {
+		session.SetPkgHandler(&stringPkgHandler{})
+		session.SetEventListener(clientHandler)
+		return nil
+	})
+	assert.Equal(t, 4, clientHandler.SessionNumber())
+	for _, ss := range clientHandler.array {
+		_, _, err := ss.WritePkg("hello", 0)
+		assert.Nil(t, err)
+	}
+	for i := 0; i < 4; i++ {
+		select {
+		case msg := <-serverHandler.msgs:
+			assert.Equal(t, "hello", msg)
+		case <-time.After(3 * time.Second):
+			t.Fatal("server did not receive the package")
+		}
+	}
+}
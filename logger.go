@@ -59,8 +59,12 @@ const (
 )
 
 var (
-	log       Logger
-	zapLogger *zap.Logger
+	// the logger of SetLogger, which writes the logs of all the modules
+	rawLog Logger
+	// the loggers writing by rawLog which skip the frames of the module loggers in the callers, see
+	// setRawLog
+	callerSkipLogs [3]Logger
+	zapLogger      *zap.Logger
 
 	zapLoggerConfig        = zap.NewDevelopmentConfig()
 	zapLoggerEncoderConfig = zapcore.EncoderConfig{
@@ -80,7 +84,7 @@ var (
 func init() {
 	zapLoggerConfig.EncoderConfig = zapLoggerEncoderConfig
 	zapLogger, _ = zapLoggerConfig.Build()
-	log = zapLogger.Sugar()
+	setRawLog(zapLogger.Sugar())
 
 	// todo: flushes buffer when redirect log to file.
 	// var exitSignal = make(chan os.Signal)
@@ -99,12 +103,25 @@ func init() {
 
 // SetLogger customize yourself logger.
 func SetLogger(logger Logger) {
-	log = logger
+	setRawLog(logger)
+}
+
+// setRawLog sets rawLog to @logger, and callerSkipLogs[n] to the one skipping n frames in the
+// callers if @logger is a zap logger, eg: the frame of moduleLogger. So the callers of the logs of
+// the modules are reported as the ones of the logs of GetLogger.
+func setRawLog(logger Logger) {
+	rawLog = logger
+	for skip := range callerSkipLogs {
+		callerSkipLogs[skip] = logger
+		if sugar, ok := logger.(*zap.SugaredLogger); ok && skip > 0 {
+			callerSkipLogs[skip] = sugar.Desugar().WithOptions(zap.AddCallerSkip(skip)).Sugar()
+		}
+	}
 }
 
 // GetLogger get getty logger
 func GetLogger() Logger {
-	return log
+	return rawLog
 }
 
 // SetLoggerLevel set logger level
@@ -115,7 +132,7 @@ func SetLoggerLevel(level LoggerLevel) error {
 	if err != nil {
 		return err
 	}
-	log = zapLogger.Sugar()
+	setRawLog(zapLogger.Sugar())
 	return nil
 }
 
@@ -129,6 +146,6 @@ func SetLoggerCallerDisable() error {
 	if err != nil {
 		return err
 	}
-	log = zapLogger.Sugar()
+	setRawLog(zapLogger.Sugar())
 	return nil
 }
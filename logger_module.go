@@ -0,0 +1,211 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package getty
+
+import (
+	uatomic "go.uber.org/atomic"
+)
+
+// LogModule is a module of the logs of getty, whose level can be changed at runtime.
+type LogModule int
+
+const (
+	// LogModuleCore is the logs not in the other modules, eg: of the accepting and the sessions.
+	LogModuleCore LogModule = iota
+	// LogModuleTransport is the logs of the socket reads and writes.
+	LogModuleTransport
+	// LogModuleCodec is the logs of encoding and decoding the packages.
+	LogModuleCodec
+	// LogModuleCron is the logs of the heartbeats.
+	LogModuleCron
+	// LogModulePool is the logs of dialing, reconnecting and probing of the client pools.
+	LogModulePool
+	logModuleNum
+)
+
+var (
+	// the levels of the modules, which filter the logs before the Logger of SetLogger and the
+	// StructuredLoggers
+	logModuleLevels [logModuleNum]uatomic.Int32
+
+	log          = moduleLogger(LogModuleCore)
+	transportLog = moduleLogger(LogModuleTransport)
+	codecLog     = moduleLogger(LogModuleCodec)
+	cronLog      = moduleLogger(LogModuleCron)
+	poolLog      = moduleLogger(LogModulePool)
+)
+
+func init() {
+	SetLogLevel(LoggerLevelDebug)
+}
+
+// SetLogLevel sets the level of all the modules at runtime, eg: to get the verbose logs without a
+// restart. The logs are still filtered by the level of the Logger, eg: SetLoggerLevel.
+func SetLogLevel(level LoggerLevel) {
+	for module := LogModule(0); module < logModuleNum; module++ {
+		SetModuleLogLevel(module, level)
+	}
+}
+
+// SetModuleLogLevel sets the level of @module at runtime, eg: LoggerLevelDebug for the socket reads
+// and writes of LogModuleTransport.
+func SetModuleLogLevel(module LogModule, level LoggerLevel) {
+	logModuleLevels[module].Store(int32(level))
+}
+
+// ModuleLogLevel returns the level of @module.
+func ModuleLogLevel(module LogModule) LoggerLevel {
+	return LoggerLevel(logModuleLevels[module].Load())
+}
+
+// moduleLogger writes the logs of a module not below its level by the Logger of SetLogger.
+type moduleLogger LogModule
+
+func (m moduleLogger) enabled(level LoggerLevel) bool {
+	return level >= ModuleLogLevel(LogModule(m))
+}
+
+func (m moduleLogger) Info(args ...interface{}) {
+	if m.enabled(LoggerLevelInfo) {
+		callerSkipLogs[1].Info(args...)
+	}
+}
+
+func (m moduleLogger) Warn(args ...interface{}) {
+	if m.enabled(LoggerLevelWarn) {
+		callerSkipLogs[1].Warn(args...)
+	}
+}
+
+func (m moduleLogger) Error(args ...interface{}) {
+	if m.enabled(LoggerLevelError) {
+		callerSkipLogs[1].Error(args...)
+	}
+}
+
+func (m moduleLogger) Debug(args ...interface{}) {
+	if m.enabled(LoggerLevelDebug) {
+		callerSkipLogs[1].Debug(args...)
+	}
+}
+
+func (m moduleLogger) Infof(fmt string, args ...interface{}) {
+	if m.enabled(LoggerLevelInfo) {
+		callerSkipLogs[1].Infof(fmt, args...)
+	}
+}
+
+func (m moduleLogger) Warnf(fmt string, args ...interface{}) {
+	if m.enabled(LoggerLevelWarn) {
+		callerSkipLogs[1].Warnf(fmt, args...)
+	}
+}
+
+func (m moduleLogger) Errorf(fmt string, args ...interface{}) {
+	if m.enabled(LoggerLevelError) {
+		callerSkipLogs[1].Errorf(fmt, args...)
+	}
+}
+
+func (m moduleLogger) Debugf(fmt string, args ...interface{}) {
+	if m.enabled(LoggerLevelDebug) {
+		callerSkipLogs[1].Debugf(fmt, args...)
+	}
+}
+
+// with returns the StructuredLogger which writes the logs of the module by @logger, filtered by the
+// level of the module, or the level of the endpoint if @logger is the one of an endpoint or a session.
+func (m moduleLogger) with(logger StructuredLogger) StructuredLogger {
+	if l, ok := logger.(moduleStructuredLogger); ok {
+		l.module = m
+		return l
+	}
+
+	return newModuleStructuredLogger(m, nil, logger)
+}
+
+// logLevelOf returns the level of the logs of @endPoint by WithServerLogLevel or WithClientLogLevel,
+// and whether it is set.
+func logLevelOf(endPoint EndPoint) (LoggerLevel, bool) {
+	switch ep := endPoint.(type) {
+	case *server:
+		return ep.logLevel, ep.logLevelSet
+	case *client:
+		return ep.logLevel, ep.logLevelSet
+	}
+
+	return 0, false
+}
+
+type moduleStructuredLogger struct {
+	module   moduleLogger
+	level    LoggerLevel
+	levelSet bool
+	l        StructuredLogger
+}
+
+// newModuleStructuredLogger returns the StructuredLogger which writes the logs of @module by @logger,
+// filtered by the level of @endPoint if it is set, otherwise the level of @module.
+func newModuleStructuredLogger(module moduleLogger, endPoint EndPoint, logger StructuredLogger) StructuredLogger {
+	l := moduleStructuredLogger{module: module, l: logger}
+	l.level, l.levelSet = logLevelOf(endPoint)
+	// the default logger skips the frame of moduleStructuredLogger in the callers
+	if f, ok := logger.(formattedLogger); ok {
+		f.callerSkip = 1
+		l.l = f
+	}
+
+	return l
+}
+
+func (l moduleStructuredLogger) enabled(level LoggerLevel) bool {
+	if l.levelSet {
+		return level >= l.level
+	}
+
+	return l.module.enabled(level)
+}
+
+func (l moduleStructuredLogger) Debugw(msg string, keysAndValues ...interface{}) {
+	if l.enabled(LoggerLevelDebug) {
+		l.l.Debugw(msg, keysAndValues...)
+	}
+}
+
+func (l moduleStructuredLogger) Infow(msg string, keysAndValues ...interface{}) {
+	if l.enabled(LoggerLevelInfo) {
+		l.l.Infow(msg, keysAndValues...)
+	}
+}
+
+func (l moduleStructuredLogger) Warnw(msg string, keysAndValues ...interface{}) {
+	if l.enabled(LoggerLevelWarn) {
+		l.l.Warnw(msg, keysAndValues...)
+	}
+}
+
+func (l moduleStructuredLogger) Errorw(msg string, keysAndValues ...interface{}) {
+	if l.enabled(LoggerLevelError) {
+		l.l.Errorw(msg, keysAndValues...)
+	}
+}
+
+func (l moduleStructuredLogger) With(keysAndValues ...interface{}) StructuredLogger {
+	l.l = l.l.With(keysAndValues...)
+	return l
+}
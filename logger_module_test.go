@@ -0,0 +1,124 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package getty
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+	"testing"
+)
+
+import (
+	"github.com/stretchr/testify/assert"
+
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+	"go.uber.org/zap/zaptest/observer"
+)
+
+// fmtLogger records the formatted debug and warning logs.
+type fmtLogger struct {
+	Logger
+	lock sync.Mutex
+	logs []string
+}
+
+func (l *fmtLogger) Debugf(format string, args ...interface{}) {
+	l.lock.Lock()
+	l.logs = append(l.logs, "debug "+fmt.Sprintf(format, args...))
+	l.lock.Unlock()
+}
+
+func (l *fmtLogger) Warnf(format string, args ...interface{}) {
+	l.lock.Lock()
+	l.logs = append(l.logs, "warn "+fmt.Sprintf(format, args...))
+	l.lock.Unlock()
+}
+
+func TestModuleLogLevel(t *testing.T) {
+	logger := &fmtLogger{Logger: GetLogger()}
+	SetLogger(logger)
+	defer SetLogger(logger.Logger)
+	defer SetLogLevel(LoggerLevelDebug)
+
+	SetLogLevel(LoggerLevelWarn)
+	SetModuleLogLevel(LogModuleTransport, LoggerLevelDebug)
+	assert.Equal(t, LoggerLevelWarn, ModuleLogLevel(LogModuleCodec))
+	transportLog.Debugf("read %d", 1)
+	codecLog.Debugf("decode %d", 2)
+	codecLog.Warnf("decode %d", 3)
+	log.Debugf("core %d", 4)
+
+	logger.lock.Lock()
+	defer logger.lock.Unlock()
+	// the goroutines of the other tests may log too
+	assert.Contains(t, logger.logs, "debug read 1")
+	assert.Contains(t, logger.logs, "warn decode 3")
+	assert.NotContains(t, logger.logs, "debug decode 2")
+	assert.NotContains(t, logger.logs, "debug core 4")
+}
+
+func TestLoggerCaller(t *testing.T) {
+	core, logs := observer.New(zapcore.DebugLevel)
+	defer SetLogger(GetLogger())
+	SetLogger(zap.New(core, zap.AddCaller()).Sugar())
+	defer SetStructuredLogger(GetStructuredLogger())
+	SetStructuredLogger(formattedLogger{})
+	ss, _ := newPipeSessions(t)
+
+	GetLogger().Infof("caller direct")
+	log.Infof("caller module")
+	GetStructuredLogger().Infow("caller structured")
+	ss.Logger().Infow("caller session")
+	ss.Logger().With("k", "v").Infow("caller session with")
+	endPointLogger(log, NewTCPServer()).Infow("caller endpoint")
+
+	var callers []string
+	for _, entry := range logs.All() {
+		if strings.HasPrefix(entry.Message, "caller ") {
+			callers = append(callers, entry.Caller.File)
+		}
+	}
+	assert.Len(t, callers, 6)
+	for _, caller := range callers {
+		assert.True(t, strings.HasSuffix(caller, "/logger_module_test.go"), caller)
+	}
+}
+
+func TestEndPointLogLevel(t *testing.T) {
+	logger := newRecordLogger()
+	defer SetLogLevel(LoggerLevelDebug)
+	SetLogLevel(LoggerLevelError)
+
+	s := NewTCPServer(WithServerLogger(logger), WithServerLogLevel(LoggerLevelDebug))
+	endPointLogger(transportLog, s).Debugw("read")
+	codecLog.with(endPointLogger(transportLog, s)).Debugw("decode")
+	c := NewTCPClient(WithServerAddress("127.0.0.1:0"), WithConnectionNumber(1),
+		WithClientLogger(logger), WithClientLogLevel(LoggerLevelWarn))
+	defer c.Close()
+	endPointLogger(poolLog, c).Infow("dial")
+	endPointLogger(poolLog, c).Warnw("eject")
+	endPointLogger(transportLog, NewTCPServer(WithServerLogger(logger))).Debugw("dropped")
+
+	assert.Equal(t, []string{
+		"debug read endpoint=" + EndPointName(s),
+		"debug decode endpoint=" + EndPointName(s),
+		"warn eject endpoint=" + EndPointName(c),
+	}, logger.records())
+}
@@ -0,0 +1,174 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package getty
+
+import (
+	"fmt"
+	"log/slog"
+	"strings"
+	"sync/atomic"
+)
+
+import (
+	"go.uber.org/zap"
+)
+
+// StructuredLogger is a leveled logger of the messages with the key-value pairs. NewSlogLogger and
+// NewZapLogger adapt the log/slog and zap loggers, github.com/apache/dubbo-getty/contrib/logrus adapts
+// the logrus ones, and the others can be adapted by implementing it. The logs of the sessions, the
+// servers and the clients are written by it, filtered by the levels of their modules.
+type StructuredLogger interface {
+	Debugw(msg string, keysAndValues ...interface{})
+	Infow(msg string, keysAndValues ...interface{})
+	Warnw(msg string, keysAndValues ...interface{})
+	Errorw(msg string, keysAndValues ...interface{})
+	// With returns the logger which adds @keysAndValues to every message.
+	With(keysAndValues ...interface{}) StructuredLogger
+}
+
+var structuredLog atomic.Pointer[StructuredLogger]
+
+func init() {
+	SetStructuredLogger(formattedLogger{})
+}
+
+// SetStructuredLogger sets the StructuredLogger of the endpoints without WithServerLogger or
+// WithClientLogger, which writes the messages in the form of "msg k=v ..." by the Logger of
+// SetLogger in default.
+func SetStructuredLogger(logger StructuredLogger) {
+	structuredLog.Store(&logger)
+}
+
+// GetStructuredLogger returns the logger set by SetStructuredLogger.
+func GetStructuredLogger() StructuredLogger {
+	return *structuredLog.Load()
+}
+
+// loggerOf returns the StructuredLogger of @endPoint.
+func loggerOf(endPoint EndPoint) StructuredLogger {
+	var logger StructuredLogger
+	switch ep := endPoint.(type) {
+	case *server:
+		logger = ep.logger
+	case *client:
+		logger = ep.logger
+	}
+	if logger == nil {
+		return GetStructuredLogger()
+	}
+
+	return logger
+}
+
+// endPointLogger returns the StructuredLogger of @endPoint with its name by EndPointName, which
+// writes the logs of @module filtered by the level of @endPoint or @module.
+func endPointLogger(module moduleLogger, endPoint EndPoint) StructuredLogger {
+	return newModuleStructuredLogger(module, endPoint, loggerOf(endPoint).With("endpoint", EndPointName(endPoint)))
+}
+
+// formattedLogger is the default StructuredLogger, see SetStructuredLogger.
+type formattedLogger struct {
+	kvs []interface{}
+	// the frames of the wrappers in the callers, eg: moduleStructuredLogger
+	callerSkip int
+}
+
+func (l formattedLogger) Debugw(msg string, keysAndValues ...interface{}) {
+	callerSkipLogs[1+l.callerSkip].Debugf("%s", formattedMessage{msg: msg, kvs: l.kvs, keysAndValues: keysAndValues})
+}
+
+func (l formattedLogger) Infow(msg string, keysAndValues ...interface{}) {
+	callerSkipLogs[1+l.callerSkip].Infof("%s", formattedMessage{msg: msg, kvs: l.kvs, keysAndValues: keysAndValues})
+}
+
+func (l formattedLogger) Warnw(msg string, keysAndValues ...interface{}) {
+	callerSkipLogs[1+l.callerSkip].Warnf("%s", formattedMessage{msg: msg, kvs: l.kvs, keysAndValues: keysAndValues})
+}
+
+func (l formattedLogger) Errorw(msg string, keysAndValues ...interface{}) {
+	callerSkipLogs[1+l.callerSkip].Errorf("%s", formattedMessage{msg: msg, kvs: l.kvs, keysAndValues: keysAndValues})
+}
+
+func (l formattedLogger) With(keysAndValues ...interface{}) StructuredLogger {
+	return formattedLogger{kvs: append(l.kvs[:len(l.kvs):len(l.kvs)], keysAndValues...), callerSkip: l.callerSkip}
+}
+
+// formattedMessage is formatted by the Logger only if it is written.
+type formattedMessage struct {
+	msg           string
+	kvs           []interface{}
+	keysAndValues []interface{}
+}
+
+func (m formattedMessage) String() string {
+	var b strings.Builder
+	b.WriteString(m.msg)
+	for _, kvs := range [][]interface{}{m.kvs, m.keysAndValues} {
+		for i := 0; i < len(kvs); i += 2 {
+			if i+1 == len(kvs) {
+				fmt.Fprintf(&b, " !BADKEY=%v", kvs[i])
+				break
+			}
+			fmt.Fprintf(&b, " %v=%v", kvs[i], kvs[i+1])
+		}
+	}
+
+	return b.String()
+}
+
+type slogLogger struct {
+	l *slog.Logger
+}
+
+// NewSlogLogger adapts @logger to StructuredLogger.
+func NewSlogLogger(logger *slog.Logger) StructuredLogger {
+	return slogLogger{l: logger}
+}
+
+func (l slogLogger) Debugw(msg string, keysAndValues ...interface{}) {
+	l.l.Debug(msg, keysAndValues...)
+}
+
+func (l slogLogger) Infow(msg string, keysAndValues ...interface{}) {
+	l.l.Info(msg, keysAndValues...)
+}
+
+func (l slogLogger) Warnw(msg string, keysAndValues ...interface{}) {
+	l.l.Warn(msg, keysAndValues...)
+}
+
+func (l slogLogger) Errorw(msg string, keysAndValues ...interface{}) {
+	l.l.Error(msg, keysAndValues...)
+}
+
+func (l slogLogger) With(keysAndValues ...interface{}) StructuredLogger {
+	return slogLogger{l: l.l.With(keysAndValues...)}
+}
+
+type zapSugarLogger struct {
+	*zap.SugaredLogger
+}
+
+// NewZapLogger adapts @logger to StructuredLogger.
+func NewZapLogger(logger *zap.Logger) StructuredLogger {
+	return zapSugarLogger{SugaredLogger: logger.Sugar()}
+}
+
+func (l zapSugarLogger) With(keysAndValues ...interface{}) StructuredLogger {
+	return zapSugarLogger{SugaredLogger: l.SugaredLogger.With(keysAndValues...)}
+}
@@ -0,0 +1,139 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package getty
+
+import (
+	"bytes"
+	"fmt"
+	"log/slog"
+	"strings"
+	"sync"
+	"testing"
+)
+
+import (
+	"github.com/stretchr/testify/assert"
+
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+)
+
+// recordLogger records the messages in the form of "level msg k=v ...".
+type recordLogger struct {
+	lock *sync.Mutex
+	logs *[]string
+	kvs  []interface{}
+}
+
+func newRecordLogger() *recordLogger {
+	return &recordLogger{lock: &sync.Mutex{}, logs: &[]string{}}
+}
+
+func (l *recordLogger) record(level, msg string, keysAndValues []interface{}) {
+	var b strings.Builder
+	b.WriteString(level + " " + msg)
+	kvs := append(append([]interface{}{}, l.kvs...), keysAndValues...)
+	for i := 0; i+1 < len(kvs); i += 2 {
+		fmt.Fprintf(&b, " %v=%v", kvs[i], kvs[i+1])
+	}
+	l.lock.Lock()
+	*l.logs = append(*l.logs, b.String())
+	l.lock.Unlock()
+}
+
+func (l *recordLogger) Debugw(msg string, kvs ...interface{}) { l.record("debug", msg, kvs) }
+func (l *recordLogger) Infow(msg string, kvs ...interface{})  { l.record("info", msg, kvs) }
+func (l *recordLogger) Warnw(msg string, kvs ...interface{})  { l.record("warn", msg, kvs) }
+func (l *recordLogger) Errorw(msg string, kvs ...interface{}) { l.record("error", msg, kvs) }
+
+func (l *recordLogger) With(kvs ...interface{}) StructuredLogger {
+	return &recordLogger{lock: l.lock, logs: l.logs, kvs: append(append([]interface{}{}, l.kvs...), kvs...)}
+}
+
+func (l *recordLogger) records() []string {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+	return append([]string(nil), *l.logs...)
+}
+
+func TestSlogLogger(t *testing.T) {
+	var buf bytes.Buffer
+	logger := NewSlogLogger(slog.New(slog.NewTextHandler(&buf, &slog.HandlerOptions{Level: slog.LevelWarn})))
+	logger.Infow("dropped")
+	logger.With("session_id", 1).Warnw("slow write", "size", 10)
+	assert.NotContains(t, buf.String(), "dropped")
+	assert.Contains(t, buf.String(), `level=WARN msg="slow write" session_id=1 size=10`)
+}
+
+func TestSessionLogger(t *testing.T) {
+	ss, _ := newPipeSessions(t)
+	logger := newRecordLogger()
+	defer SetStructuredLogger(GetStructuredLogger())
+	SetStructuredLogger(logger)
+
+	ss.SetLabel("tenant", "t1")
+	ss.Logger().Infow("hello", "k", "v")
+	assert.Equal(t, []string{fmt.Sprintf("info hello session_id=%d local=pipe peer=pipe labels=tenant=t1 k=v", ss.ID())},
+		logger.records())
+
+	s := NewTCPServer(WithServerLogger(NewZapLogger(zapLogger)))
+	assert.IsType(t, zapSugarLogger{}, loggerOf(s))
+	assert.Equal(t, logger, loggerOf(nil))
+}
+
+func TestFormattedLogger(t *testing.T) {
+	var buf bytes.Buffer
+	defer SetLogger(GetLogger())
+	encoder := zapcore.NewConsoleEncoder(zapcore.EncoderConfig{MessageKey: "msg"})
+	SetLogger(zap.New(zapcore.NewCore(encoder, zapcore.AddSync(&buf), zapcore.InfoLevel)).Sugar())
+
+	logger := formattedLogger{}.With("session_id", 1)
+	logger.Debugw("dropped")
+	logger.Warnw("slow write", "size", 10, "dangling")
+	assert.NotContains(t, buf.String(), "dropped")
+	assert.Contains(t, buf.String(), "slow write session_id=1 size=10 !BADKEY=dangling\n")
+}
+
+func TestInternalLogger(t *testing.T) {
+	logger := newRecordLogger()
+	defer SetStructuredLogger(GetStructuredLogger())
+	SetStructuredLogger(logger)
+	defer SetModuleLogLevel(LogModuleCore, ModuleLogLevel(LogModuleCore))
+
+	closed := func(ss Session) string {
+		return fmt.Sprintf("info closed session_id=%d local=pipe peer=pipe gr_num=", ss.ID())
+	}
+	hasLog := func(prefix string) bool {
+		for _, record := range logger.records() {
+			if strings.HasPrefix(record, prefix) {
+				return true
+			}
+		}
+		return false
+	}
+
+	SetModuleLogLevel(LogModuleCore, LoggerLevelWarn)
+	ss, _ := newPipeSessions(t)
+	ss.Close()
+	assert.False(t, hasLog(closed(ss)))
+
+	SetModuleLogLevel(LogModuleCore, LoggerLevelInfo)
+	ss, _ = newPipeSessions(t)
+	ss.Close()
+	assert.True(t, hasLog(closed(ss)))
+}
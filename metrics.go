@@ -0,0 +1,308 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package getty
+
+import (
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+)
+
+import (
+	uatomic "go.uber.org/atomic"
+)
+
+var (
+	// DefaultLatencyBuckets are the upper bounds in seconds of the handler latency histogram.
+	DefaultLatencyBuckets = []float64{.001, .005, .01, .025, .05, .1, .25, .5, 1, 2.5, 5, 10}
+	// DefaultSizeBuckets are the upper bounds in bytes of the package size histogram.
+	DefaultSizeBuckets = []float64{64, 256, 1 << 10, 4 << 10, 16 << 10, 64 << 10, 256 << 10, 1 << 20}
+)
+
+// HistogramSnapshot is a cumulative histogram in the form of the prometheus ones, eg: for
+// prometheus.MustNewConstHistogram.
+type HistogramSnapshot struct {
+	Buckets []float64 // the upper bounds
+	Counts  []uint64  // the number of the observations <= the bound of every bucket
+	Count   uint64
+	Sum     float64
+}
+
+type histogram struct {
+	buckets []float64
+	counts  []uatomic.Uint64
+	count   uatomic.Uint64
+	sum     uatomic.Float64
+}
+
+func newHistogram(buckets []float64) *histogram {
+	buckets = append([]float64(nil), buckets...)
+	sort.Float64s(buckets)
+	return &histogram{buckets: buckets, counts: make([]uatomic.Uint64, len(buckets))}
+}
+
+func (h *histogram) observe(v float64) {
+	if i := sort.SearchFloat64s(h.buckets, v); i < len(h.buckets) {
+		h.counts[i].Inc()
+	}
+	h.count.Inc()
+	h.sum.Add(v)
+}
+
+func (h *histogram) snapshot() HistogramSnapshot {
+	s := HistogramSnapshot{
+		Buckets: append([]float64(nil), h.buckets...),
+		Counts:  make([]uint64, len(h.buckets)),
+		Count:   h.count.Load(),
+		Sum:     h.sum.Load(),
+	}
+	var cumulative uint64
+	for i := range h.counts {
+		cumulative += h.counts[i].Load()
+		s.Counts[i] = cumulative
+	}
+
+	return s
+}
+
+// EndPointMetrics is the metrics of the sessions of an endpoint, see MetricsSnapshot.
+type EndPointMetrics struct {
+	EndPoint        string // the name of the endpoint by EndPointName
+	Sessions        int
+	Opened          uint64
+	Reconnects      uint64
+	ReadBytes       uint64
+	WriteBytes      uint64
+	WriteQueueDepth int
+	TaskQueueLength int
+}
+
+// EndPointName returns the name of @endPoint in the metrics, which is its type and the local
+// address of a server or the server address of a client, eg: TCP_SERVER:127.0.0.1:8080.
+func EndPointName(endPoint EndPoint) string {
+	var addr string
+	switch ep := endPoint.(type) {
+	case nil:
+		return ""
+	case *server:
+		addr = ep.addr
+	case *client:
+		addr = ep.addr
+	}
+	if addr == "" {
+		return fmt.Sprintf("%s:%d", endPoint.EndPointType(), endPoint.ID())
+	}
+
+	return endPoint.EndPointType().String() + ":" + addr
+}
+
+// MetricsSnapshot is the metrics of the sessions of the endpoints sharing a Metrics.
+type MetricsSnapshot struct {
+	Sessions   int    // the live sessions
+	Opened     uint64 // the sessions accepted by the servers or connected by the clients
+	Reconnects uint64 // the sessions reconnected by the client pools
+	ReadBytes  uint64
+	WriteBytes uint64
+	// the packages queued by WritePkgAsync but not written yet
+	WriteQueueDepth int
+	// the packages being handled by OnMessage or queued in the task pools
+	TaskQueueLength int
+	// the encoded size in bytes of the packages written by WritePkg
+	WritePkgSize HistogramSnapshot
+	// the seconds of OnMessage
+	HandlerLatency HistogramSnapshot
+	// the metrics above except the histograms by endpoint, sorted by EndPoint
+	EndPoints []EndPointMetrics
+}
+
+// MetricsObserver receives the observations of the histograms of Metrics when they are made, eg: the
+// adapter which records them by the histograms of the opentelemetry metrics API, whose observable
+// counters and gauges read the others from Snapshot, see github.com/apache/dubbo-getty/contrib/otel.
+type MetricsObserver interface {
+	ObserveHandlerLatency(session Session, latency time.Duration)
+	ObserveWritePkgSize(session Session, size int)
+}
+
+// Metrics collects the metrics of the sessions by its Interceptor. The exporter reads them by
+// Snapshot, eg: the prometheus.Collector of github.com/apache/dubbo-getty/contrib/prometheus,
+// which converts the snapshot to the const metrics on every scrape. Accepts/sec is the rate of Opened.
+type Metrics struct {
+	// Observer is notified of every observation if it is not nil, which should be set before the
+	// Interceptor is used.
+	Observer MetricsObserver
+
+	pkgSize *histogram
+	latency *histogram
+
+	lock      sync.Mutex
+	sessions  map[Session]*endPointCounters
+	endPoints map[string]*endPointCounters
+}
+
+// endPointCounters is the counters of the sessions of an endpoint.
+type endPointCounters struct {
+	opened     uint64
+	reconnects uint64
+	// the bytes of the closed sessions
+	closedReadBytes  uint64
+	closedWriteBytes uint64
+}
+
+// NewMetrics returns a Metrics with DefaultLatencyBuckets and DefaultSizeBuckets.
+func NewMetrics() *Metrics {
+	return NewMetricsWithBuckets(DefaultLatencyBuckets, DefaultSizeBuckets)
+}
+
+// NewMetricsWithBuckets returns a Metrics whose handler latency histogram is bucketed by
+// @latencyBuckets in seconds and package size histogram by @sizeBuckets in bytes.
+func NewMetricsWithBuckets(latencyBuckets, sizeBuckets []float64) *Metrics {
+	return &Metrics{
+		pkgSize:   newHistogram(sizeBuckets),
+		latency:   newHistogram(latencyBuckets),
+		sessions:  make(map[Session]*endPointCounters),
+		endPoints: make(map[string]*endPointCounters),
+	}
+}
+
+// Interceptor returns the Interceptor which collects the metrics.
+func (m *Metrics) Interceptor() Interceptor {
+	return Interceptor{
+		Listener: func(next EventListener) EventListener {
+			return &metricsListener{EventListener: next, metrics: m}
+		},
+		Write: func(next WriteFunc) WriteFunc {
+			return func(session Session, pkg interface{}, timeout time.Duration) (int, int, error) {
+				total, sent, err := next(session, pkg, timeout)
+				if err == nil {
+					m.pkgSize.observe(float64(total))
+					if m.Observer != nil {
+						m.Observer.ObserveWritePkgSize(session, total)
+					}
+				}
+				return total, sent, err
+			}
+		},
+	}
+}
+
+// Snapshot returns the current metrics.
+func (m *Metrics) Snapshot() MetricsSnapshot {
+	s := MetricsSnapshot{
+		WritePkgSize:   m.pkgSize.snapshot(),
+		HandlerLatency: m.latency.snapshot(),
+	}
+
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	endPoints := make(map[*endPointCounters]*EndPointMetrics, len(m.endPoints))
+	for name, c := range m.endPoints {
+		endPoints[c] = &EndPointMetrics{
+			EndPoint:   name,
+			Opened:     c.opened,
+			Reconnects: c.reconnects,
+			ReadBytes:  c.closedReadBytes,
+			WriteBytes: c.closedWriteBytes,
+		}
+	}
+	for ss, c := range m.sessions {
+		e := endPoints[c]
+		stats := ss.Stats()
+		e.Sessions++
+		e.ReadBytes += stats.ReadBytes
+		e.WriteBytes += stats.WriteBytes
+		e.WriteQueueDepth += stats.PendingWrites
+		if impl, ok := ss.(*session); ok {
+			e.TaskQueueLength += int(impl.handling.Load())
+		}
+	}
+	s.EndPoints = make([]EndPointMetrics, 0, len(endPoints))
+	for _, e := range endPoints {
+		s.Sessions += e.Sessions
+		s.Opened += e.Opened
+		s.Reconnects += e.Reconnects
+		s.ReadBytes += e.ReadBytes
+		s.WriteBytes += e.WriteBytes
+		s.WriteQueueDepth += e.WriteQueueDepth
+		s.TaskQueueLength += e.TaskQueueLength
+		s.EndPoints = append(s.EndPoints, *e)
+	}
+	sort.Slice(s.EndPoints, func(i, j int) bool { return s.EndPoints[i].EndPoint < s.EndPoints[j].EndPoint })
+
+	return s
+}
+
+func (m *Metrics) open(ss Session) {
+	name := EndPointName(ss.EndPoint())
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	c, ok := m.endPoints[name]
+	if !ok {
+		c = &endPointCounters{}
+		m.endPoints[name] = c
+	}
+	c.opened++
+	// the session is connected by the reconnection of the client pool after a session is closed
+	if impl, ok := ss.(*session); ok && impl.reconnected {
+		c.reconnects++
+	}
+	m.sessions[ss] = c
+}
+
+func (m *Metrics) close(session Session) {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	c, ok := m.sessions[session]
+	if !ok {
+		return
+	}
+	delete(m.sessions, session)
+	stats := session.Stats()
+	c.closedReadBytes += stats.ReadBytes
+	c.closedWriteBytes += stats.WriteBytes
+}
+
+type metricsListener struct {
+	EventListener
+	metrics *Metrics
+}
+
+func (l *metricsListener) OnOpen(session Session) error {
+	if err := l.EventListener.OnOpen(session); err != nil {
+		return err
+	}
+	l.metrics.open(session)
+	return nil
+}
+
+func (l *metricsListener) OnClose(session Session) {
+	l.metrics.close(session)
+	l.EventListener.OnClose(session)
+}
+
+func (l *metricsListener) OnMessage(session Session, pkg interface{}) {
+	start := time.Now()
+	defer func() {
+		latency := time.Since(start)
+		l.metrics.latency.observe(latency.Seconds())
+		if l.metrics.Observer != nil {
+			l.metrics.Observer.ObserveHandlerLatency(session, latency)
+		}
+	}()
+	l.EventListener.OnMessage(session, pkg)
+}
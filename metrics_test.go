@@ -0,0 +1,135 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package getty
+
+import (
+	"testing"
+	"time"
+)
+
+import (
+	"github.com/stretchr/testify/assert"
+)
+
+func TestHistogram(t *testing.T) {
+	h := newHistogram([]float64{10, 1, 100})
+	for _, v := range []float64{0.5, 1, 5, 50, 500} {
+		h.observe(v)
+	}
+	assert.Equal(t, HistogramSnapshot{
+		Buckets: []float64{1, 10, 100},
+		Counts:  []uint64{2, 3, 4},
+		Count:   5,
+		Sum:     556.5,
+	}, h.snapshot())
+}
+
+// sizeObserver records the sizes of the written packages.
+type sizeObserver struct {
+	latencies int
+	sizes     []int
+}
+
+func (o *sizeObserver) ObserveHandlerLatency(Session, time.Duration) {
+	o.latencies++
+}
+
+func (o *sizeObserver) ObserveWritePkgSize(_ Session, size int) {
+	o.sizes = append(o.sizes, size)
+}
+
+func TestMetrics(t *testing.T) {
+	ss, _ := newPipeSessions(t)
+	m := NewMetrics()
+	observer := &sizeObserver{}
+	m.Observer = observer
+	handler := newChanMessageHandler()
+	listener := m.Interceptor().Listener(handler)
+
+	assert.Nil(t, listener.OnOpen(ss))
+	listener.OnMessage(ss, "hello")
+	assert.Equal(t, "hello", <-handler.msgs)
+	write := m.Interceptor().Write(func(session Session, pkg interface{}, timeout time.Duration) (int, int, error) {
+		return session.WritePkg(pkg, timeout)
+	})
+	_, _, err := write(ss, "hello", 0)
+	assert.Nil(t, err)
+
+	snapshot := m.Snapshot()
+	assert.Equal(t, 1, snapshot.Sessions)
+	assert.Equal(t, uint64(1), snapshot.Opened)
+	assert.Equal(t, uint64(1), snapshot.HandlerLatency.Count)
+	assert.Equal(t, uint64(1), snapshot.WritePkgSize.Count)
+	// the 4 bytes length and "hello"
+	assert.Equal(t, float64(9), snapshot.WritePkgSize.Sum)
+	assert.Equal(t, uint64(9), snapshot.WriteBytes)
+	assert.Equal(t, 1, observer.latencies)
+	assert.Equal(t, []int{9}, observer.sizes)
+
+	// the bytes of the closed sessions are kept
+	listener.OnClose(ss)
+	snapshot = m.Snapshot()
+	assert.Equal(t, 0, snapshot.Sessions)
+	assert.Equal(t, uint64(9), snapshot.WriteBytes)
+}
+
+func TestMetricsEndPoints(t *testing.T) {
+	m := NewMetrics()
+	s := NewTCPServer(WithLocalAddress("127.0.0.1:0"), WithServerInterceptor(m.Interceptor())).(*server)
+	defer s.Close()
+	s.RunEventLoop(func(session Session) error {
+		session.SetPkgHandler(&stringPkgHandler{})
+		session.SetEventListener(newChanMessageHandler())
+		return nil
+	})
+	clt := NewTCPClient(WithServerAddress(s.addr), WithConnectionNumber(1), WithMaxConnNum(3),
+		WithReconnectInterval(1e7), WithClientInterceptor(m.Interceptor())).(*client)
+	defer clt.Close()
+	clt.RunEventLoop(func(session Session) error {
+		session.SetPkgHandler(&stringPkgHandler{})
+		session.SetEventListener(newChanMessageHandler())
+		return nil
+	})
+	endPoint := func(name string) EndPointMetrics {
+		for _, e := range m.Snapshot().EndPoints {
+			if e.EndPoint == name {
+				return e
+			}
+		}
+		return EndPointMetrics{}
+	}
+	clientName, serverName := "TCP_CLIENT:"+s.addr, "TCP_SERVER:"+s.addr
+	assert.Equal(t, clientName, EndPointName(clt))
+	assert.Eventually(t, func() bool { return endPoint(serverName).Sessions == 1 }, time.Second, 10*time.Millisecond)
+	assert.Equal(t, uint64(1), endPoint(clientName).Opened)
+
+	// growing the pool does not count as reconnects
+	clt.ResizePool(2)
+	assert.Eventually(t, func() bool { return endPoint(clientName).Sessions == 2 }, time.Second, 10*time.Millisecond)
+	assert.Equal(t, uint64(0), m.Snapshot().Reconnects)
+
+	// the session replacing a closed one does
+	ss, err := clt.PickSession("")
+	assert.Nil(t, err)
+	ss.Close()
+	assert.Eventually(t, func() bool { return endPoint(clientName).Reconnects == 1 }, time.Second, 10*time.Millisecond)
+	snapshot := m.Snapshot()
+	assert.Equal(t, uint64(1), snapshot.Reconnects)
+	assert.Equal(t, uint64(0), endPoint(serverName).Reconnects)
+	assert.Equal(t, 2, len(snapshot.EndPoints))
+}
@@ -0,0 +1,83 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package getty
+
+import (
+	"net"
+)
+
+import (
+	perrors "github.com/pkg/errors"
+
+	"golang.org/x/net/ipv4"
+	"golang.org/x/net/ipv6"
+)
+
+// multicastGroup is a multicast group joined by the udp endpoint on the network interface iface.
+type multicastGroup struct {
+	group string
+	iface string
+}
+
+// join joins the group on @conn. The system chooses the interface if iface is empty.
+func (g multicastGroup) join(conn net.PacketConn) error {
+	host := g.group
+	if h, _, err := net.SplitHostPort(g.group); err == nil {
+		host = h
+	}
+	ip := net.ParseIP(host)
+	if ip == nil || !ip.IsMulticast() {
+		return perrors.Errorf("illegal multicast group %q", g.group)
+	}
+
+	var ifi *net.Interface
+	if g.iface != "" {
+		var err error
+		if ifi, err = net.InterfaceByName(g.iface); err != nil {
+			return perrors.Wrapf(err, "net.InterfaceByName(%s)", g.iface)
+		}
+	}
+
+	var err error
+	if ip.To4() != nil {
+		err = ipv4.NewPacketConn(conn).JoinGroup(ifi, &net.UDPAddr{IP: ip})
+	} else {
+		err = ipv6.NewPacketConn(conn).JoinGroup(ifi, &net.UDPAddr{IP: ip})
+	}
+	if err != nil {
+		return perrors.Wrapf(err, "JoinGroup(group:%s, iface:%s)", g.group, g.iface)
+	}
+
+	return nil
+}
+
+// setupUDPConn joins the multicast groups and enables SO_BROADCAST on @conn as configured.
+func setupUDPConn(conn net.PacketConn, groups []multicastGroup, broadcast bool) error {
+	for _, g := range groups {
+		if err := g.join(conn); err != nil {
+			return perrors.WithStack(err)
+		}
+	}
+	if broadcast {
+		if err := setBroadcast(conn, true); err != nil {
+			return perrors.WithStack(err)
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,72 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package getty
+
+import (
+	"net"
+	"testing"
+	"time"
+)
+
+import (
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMulticastGroupJoin(t *testing.T) {
+	conn, err := net.ListenPacket("udp4", "0.0.0.0:0")
+	assert.Nil(t, err)
+	defer conn.Close()
+
+	assert.NotNil(t, multicastGroup{group: "10.0.0.1"}.join(conn))
+	assert.NotNil(t, multicastGroup{group: "239.255.0.1", iface: "no-such-iface"}.join(conn))
+	assert.Nil(t, setBroadcast(conn, true))
+}
+
+func TestUDPMulticastServer(t *testing.T) {
+	group := "239.255.10.1"
+	s := NewUDPEndPoint(WithLocalAddress("0.0.0.0:0"), WithMulticastGroup(group, ""), WithServerBroadcast(true)).(*server)
+	if err := s.listen(); err != nil {
+		t.Skipf("multicast is not available: %v", err)
+	}
+	defer s.Close()
+
+	serverHandler := newChanMessageHandler()
+	s.runUDPEventLoop(func(session Session) error {
+		session.SetPkgHandler(&stringPkgHandler{})
+		session.SetEventListener(serverHandler)
+		return nil
+	})
+
+	_, port, _ := net.SplitHostPort(s.addr)
+	conn, err := net.Dial("udp4", net.JoinHostPort(group, port))
+	assert.Nil(t, err)
+	defer conn.Close()
+	pkg, _ := (&stringPkgHandler{}).Write(nil, "hello")
+	if _, err = conn.Write(pkg); err != nil {
+		t.Skipf("multicast is not routable: %v", err)
+	}
+
+	select {
+	case msg := <-serverHandler.msgs:
+		ctx := msg.(UDPContext)
+		assert.Equal(t, "hello", ctx.Pkg)
+		assert.Equal(t, conn.LocalAddr().(*net.UDPAddr).Port, ctx.PeerAddr.Port)
+	case <-time.After(time.Second):
+		t.Skip("multicast packet is not looped back")
+	}
+}
@@ -0,0 +1,115 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package mux
+
+import (
+	"encoding/binary"
+)
+
+import (
+	perrors "github.com/pkg/errors"
+)
+
+import (
+	getty "github.com/apache/dubbo-getty"
+)
+
+// FrameType is the type of the frames of the streams.
+type FrameType byte
+
+const (
+	// FrameOpen opens the stream.
+	FrameOpen FrameType = iota
+	// FrameData carries a message of the stream.
+	FrameData
+	// FrameWindow grants the peer the bytes in Length to send more messages.
+	FrameWindow
+	// FrameFin closes the writing side of the stream.
+	FrameFin
+	// FrameReset aborts the stream.
+	FrameReset
+)
+
+// frameHeaderLen is the length of the type, the stream id and the length.
+const frameHeaderLen = 9
+
+// Frame is the package of the sessions of Mux.
+type Frame struct {
+	Type     FrameType
+	StreamID uint32
+	// Length is the bytes of Data, or the window increment of FrameWindow.
+	Length uint32
+	Data   []byte
+}
+
+// Codec is the getty ReadWriter of the Frames.
+type Codec struct {
+	// MaxDataLen is the max bytes of the Data of a frame, 0 means no limit.
+	MaxDataLen int
+}
+
+var _ getty.ReadWriter = (*Codec)(nil)
+
+func (c *Codec) Read(ss getty.Session, data []byte) (interface{}, int, error) {
+	if len(data) < frameHeaderLen {
+		return nil, 0, nil
+	}
+
+	f := &Frame{
+		Type:     FrameType(data[0]),
+		StreamID: binary.BigEndian.Uint32(data[1:]),
+		Length:   binary.BigEndian.Uint32(data[5:]),
+	}
+	if f.Type > FrameReset {
+		return nil, 0, perrors.Errorf("illegal mux frame type %d", f.Type)
+	}
+	if f.Type != FrameData {
+		return f, frameHeaderLen, nil
+	}
+	if c.MaxDataLen > 0 && int64(f.Length) > int64(c.MaxDataLen) {
+		return nil, 0, perrors.Errorf("mux frame length %d > max data length %d", f.Length, c.MaxDataLen)
+	}
+	frameLen := frameHeaderLen + int(f.Length)
+	if len(data) < frameLen {
+		return nil, frameLen, nil
+	}
+	f.Data = append([]byte{}, data[frameHeaderLen:frameLen]...)
+
+	return f, frameLen, nil
+}
+
+func (c *Codec) Write(ss getty.Session, pkg interface{}) ([]byte, error) {
+	f, ok := pkg.(*Frame)
+	if !ok {
+		return nil, perrors.Errorf("illegal @pkg{%#v} type", pkg)
+	}
+
+	length := f.Length
+	if f.Type == FrameData {
+		length = uint32(len(f.Data))
+	}
+	buf := make([]byte, frameHeaderLen, frameHeaderLen+len(f.Data))
+	buf[0] = byte(f.Type)
+	binary.BigEndian.PutUint32(buf[1:], f.StreamID)
+	binary.BigEndian.PutUint32(buf[5:], length)
+	if f.Type == FrameData {
+		buf = append(buf, f.Data...)
+	}
+
+	return buf, nil
+}
@@ -0,0 +1,231 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+// Package mux multiplexes the logical streams over one getty session, each with its own flow
+// control, so the concurrent requests need not a connection each.
+package mux
+
+import (
+	"context"
+	"sync"
+)
+
+import (
+	perrors "github.com/pkg/errors"
+)
+
+import (
+	getty "github.com/apache/dubbo-getty"
+)
+
+const (
+	DefaultWindow        = 256 * 1024
+	DefaultAcceptBacklog = 256
+)
+
+var (
+	ErrStreamReset  = perrors.New("mux stream reset")
+	ErrStreamClosed = perrors.New("mux stream closed")
+	ErrMessageLarge = perrors.New("mux message larger than the stream window")
+)
+
+type Option func(*Mux)
+
+// WithWindow sets the bytes of the messages a stream can receive before they are read.
+func WithWindow(window int) Option {
+	return func(m *Mux) {
+		m.window = window
+	}
+}
+
+// WithAcceptBacklog sets the max number of the streams opened by the peer but not accepted,
+// the later ones are reset.
+func WithAcceptBacklog(backlog int) Option {
+	return func(m *Mux) {
+		m.backlog = backlog
+	}
+}
+
+// Mux is the EventListener of a session whose package handler is Codec, eg:
+//
+//	m := mux.New(true)
+//	session.SetPkgHandler(&mux.Codec{})
+//	session.SetEventListener(m)
+//
+// The client and the server side of the session must be told apart by New, so that their
+// streams have the different ids.
+type Mux struct {
+	window  int
+	backlog int
+
+	lock    sync.Mutex
+	session getty.Session
+	nextID  uint32
+	streams map[uint32]*Stream
+	accept  chan *Stream
+	done    chan struct{}
+	err     error
+}
+
+// New returns the Mux of the client side of the session if @client is true, else the server side.
+func New(client bool, opts ...Option) *Mux {
+	m := &Mux{
+		window:  DefaultWindow,
+		backlog: DefaultAcceptBacklog,
+		nextID:  2,
+		streams: make(map[uint32]*Stream),
+		done:    make(chan struct{}),
+	}
+	if client {
+		m.nextID = 1
+	}
+	for _, opt := range opts {
+		opt(m)
+	}
+	m.accept = make(chan *Stream, m.backlog)
+	return m
+}
+
+func (m *Mux) OnOpen(session getty.Session) error {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+
+	if m.session != nil {
+		return perrors.New("mux serves only one session")
+	}
+	m.session = session
+	return nil
+}
+
+func (m *Mux) OnClose(session getty.Session) {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+
+	if m.err != nil {
+		return
+	}
+	m.err = getty.ErrSessionClosed
+	close(m.done)
+	for id, s := range m.streams {
+		delete(m.streams, id)
+		s.abort(getty.ErrSessionClosed)
+	}
+}
+
+func (m *Mux) OnError(getty.Session, error) {}
+
+func (m *Mux) OnCron(getty.Session) {}
+
+func (m *Mux) OnMessage(session getty.Session, pkg interface{}) {
+	f, ok := pkg.(*Frame)
+	if !ok {
+		return
+	}
+
+	m.lock.Lock()
+	s := m.streams[f.StreamID]
+	if f.Type == FrameOpen && s == nil && m.err == nil {
+		s = newStream(m, f.StreamID)
+		select {
+		case m.accept <- s:
+			m.streams[f.StreamID] = s
+		default:
+			s = nil
+			m.lock.Unlock()
+			m.write(&Frame{Type: FrameReset, StreamID: f.StreamID})
+			return
+		}
+	}
+	m.lock.Unlock()
+	if s == nil {
+		return
+	}
+
+	switch f.Type {
+	case FrameData:
+		if !s.receive(f.Data) {
+			// the peer does not respect the window
+			m.remove(s.id)
+			s.abort(ErrStreamReset)
+			m.write(&Frame{Type: FrameReset, StreamID: s.id})
+		}
+	case FrameWindow:
+		s.grant(int(f.Length))
+	case FrameFin:
+		s.finish()
+	case FrameReset:
+		m.remove(s.id)
+		s.abort(ErrStreamReset)
+	}
+}
+
+// Open opens a stream to the peer.
+func (m *Mux) Open() (*Stream, error) {
+	m.lock.Lock()
+	if m.err != nil || m.session == nil {
+		m.lock.Unlock()
+		return nil, getty.ErrSessionClosed
+	}
+	s := newStream(m, m.nextID)
+	m.nextID += 2
+	m.streams[s.id] = s
+	m.lock.Unlock()
+
+	if err := m.write(&Frame{Type: FrameOpen, StreamID: s.id}); err != nil {
+		m.remove(s.id)
+		return nil, err
+	}
+	return s, nil
+}
+
+// Accept returns the next stream opened by the peer.
+func (m *Mux) Accept(ctx context.Context) (*Stream, error) {
+	select {
+	case s := <-m.accept:
+		return s, nil
+	case <-m.done:
+		return nil, getty.ErrSessionClosed
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
+
+// NumStreams returns the number of the open streams.
+func (m *Mux) NumStreams() int {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+
+	return len(m.streams)
+}
+
+func (m *Mux) remove(id uint32) {
+	m.lock.Lock()
+	delete(m.streams, id)
+	m.lock.Unlock()
+}
+
+func (m *Mux) write(f *Frame) error {
+	m.lock.Lock()
+	session := m.session
+	m.lock.Unlock()
+	if session == nil {
+		return getty.ErrSessionClosed
+	}
+
+	_, _, err := session.WritePkg(f, 0)
+	return err
+}
@@ -0,0 +1,136 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package mux
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"sync"
+	"testing"
+	"time"
+)
+
+import (
+	"github.com/stretchr/testify/assert"
+)
+
+import (
+	getty "github.com/apache/dubbo-getty"
+)
+
+func newMuxPair(t *testing.T, opts ...Option) (client *Mux, server *Mux) {
+	clientEndPoint, serverEndPoint := getty.NewPipeEndpoint()
+	t.Cleanup(func() {
+		clientEndPoint.Close()
+		serverEndPoint.Close()
+	})
+	client, server = New(true, opts...), New(false, opts...)
+	for _, c := range []struct {
+		endPoint getty.EndPoint
+		m        *Mux
+	}{{serverEndPoint, server}, {clientEndPoint, client}} {
+		m := c.m
+		c.endPoint.RunEventLoop(func(session getty.Session) error {
+			session.SetReadTimeout(50 * time.Millisecond)
+			session.SetPkgHandler(&Codec{})
+			session.SetEventListener(m)
+			return nil
+		})
+	}
+	return client, server
+}
+
+func TestMux(t *testing.T) {
+	client, server := newMuxPair(t)
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	// the server echoes the messages of every stream
+	go func() {
+		for {
+			s, err := server.Accept(ctx)
+			if err != nil {
+				return
+			}
+			go func() {
+				for {
+					msg, err := s.Read(ctx)
+					if err != nil {
+						s.Close()
+						return
+					}
+					s.Write(ctx, msg)
+				}
+			}()
+		}
+	}()
+
+	var wg sync.WaitGroup
+	for i := 0; i < 50; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			s, err := client.Open()
+			assert.Nil(t, err)
+			for j := 0; j < 3; j++ {
+				msg := fmt.Sprintf("stream %d message %d", s.ID(), j)
+				assert.Nil(t, s.Write(ctx, []byte(msg)))
+				rsp, err := s.Read(ctx)
+				assert.Nil(t, err)
+				assert.Equal(t, msg, string(rsp))
+			}
+			assert.Nil(t, s.Close())
+			_, err = s.Read(ctx)
+			assert.Equal(t, io.EOF, err)
+		}(i)
+	}
+	wg.Wait()
+	assert.Equal(t, 0, client.NumStreams())
+}
+
+func TestMuxFlowControl(t *testing.T) {
+	client, server := newMuxPair(t, WithWindow(8))
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	s, err := client.Open()
+	assert.Nil(t, err)
+	assert.Equal(t, ErrMessageLarge, s.Write(ctx, make([]byte, 9)))
+	assert.Nil(t, s.Write(ctx, []byte("1234")))
+	assert.Nil(t, s.Write(ctx, []byte("5678")))
+
+	// the window is used up until the peer reads a message
+	short, cancelShort := context.WithTimeout(ctx, 50*time.Millisecond)
+	defer cancelShort()
+	assert.Equal(t, context.DeadlineExceeded, s.Write(short, []byte("9")))
+	peer, err := server.Accept(ctx)
+	assert.Nil(t, err)
+	msg, err := peer.Read(ctx)
+	assert.Nil(t, err)
+	assert.Equal(t, "1234", string(msg))
+	assert.Nil(t, s.Write(ctx, []byte("9")))
+
+	// the reset aborts the stream on both sides
+	assert.Nil(t, peer.Reset())
+	_, err = s.Read(ctx)
+	assert.Equal(t, ErrStreamReset, err)
+	assert.Equal(t, ErrStreamReset, s.Write(ctx, []byte("0")))
+	_, err = peer.Read(ctx)
+	assert.Equal(t, ErrStreamReset, err)
+}
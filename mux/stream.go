@@ -0,0 +1,193 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package mux
+
+import (
+	"context"
+	"io"
+	"sync"
+)
+
+// Stream is a logical stream of messages over the session of Mux.
+type Stream struct {
+	m  *Mux
+	id uint32
+
+	lock     sync.Mutex
+	notify   chan struct{} // closed and replaced when the state below changes
+	msgs     [][]byte
+	buffered int // the bytes of @msgs
+	window   int // the bytes the peer grants to send
+	finished bool
+	closed   bool
+	err      error
+}
+
+func newStream(m *Mux, id uint32) *Stream {
+	return &Stream{m: m, id: id, notify: make(chan struct{}), window: m.window}
+}
+
+func (s *Stream) ID() uint32 {
+	return s.id
+}
+
+// broadcast wakes up the waiters, it is called with @s.lock held.
+func (s *Stream) broadcast() {
+	close(s.notify)
+	s.notify = make(chan struct{})
+}
+
+// wait waits for @ready which is called with @s.lock held, and returns with @s.lock held.
+func (s *Stream) wait(ctx context.Context, ready func() bool) error {
+	for !ready() {
+		notify := s.notify
+		s.lock.Unlock()
+		select {
+		case <-notify:
+			s.lock.Lock()
+		case <-ctx.Done():
+			s.lock.Lock()
+			return ctx.Err()
+		}
+	}
+	return nil
+}
+
+// Write writes the message @msg, which waits while the window granted by the peer is less than
+// len(@msg). So @msg can not be larger than the window.
+func (s *Stream) Write(ctx context.Context, msg []byte) error {
+	if len(msg) > s.m.window {
+		return ErrMessageLarge
+	}
+
+	s.lock.Lock()
+	err := s.wait(ctx, func() bool { return s.err != nil || s.closed || s.window >= len(msg) })
+	if err == nil {
+		err = s.err
+	}
+	if err == nil && s.closed {
+		err = ErrStreamClosed
+	}
+	if err != nil {
+		s.lock.Unlock()
+		return err
+	}
+	s.window -= len(msg)
+	s.lock.Unlock()
+
+	return s.m.write(&Frame{Type: FrameData, StreamID: s.id, Data: msg})
+}
+
+// Read returns the next message, or io.EOF after the peer closes the stream.
+func (s *Stream) Read(ctx context.Context) ([]byte, error) {
+	s.lock.Lock()
+	err := s.wait(ctx, func() bool { return len(s.msgs) > 0 || s.finished || s.err != nil })
+	if err == nil && len(s.msgs) == 0 {
+		if err = s.err; err == nil {
+			err = io.EOF
+		}
+	}
+	if err != nil {
+		s.lock.Unlock()
+		return nil, err
+	}
+	msg := s.msgs[0]
+	s.msgs[0] = nil
+	s.msgs = s.msgs[1:]
+	s.buffered -= len(msg)
+	s.lock.Unlock()
+
+	// grant the peer the window of the read message
+	if len(msg) > 0 {
+		s.m.write(&Frame{Type: FrameWindow, StreamID: s.id, Length: uint32(len(msg))})
+	}
+	return msg, nil
+}
+
+// Close closes the writing side of the stream, and the stream is removed after the peer closes too.
+func (s *Stream) Close() error {
+	s.lock.Lock()
+	if s.closed || s.err != nil {
+		s.lock.Unlock()
+		return nil
+	}
+	s.closed = true
+	finished := s.finished
+	s.broadcast()
+	s.lock.Unlock()
+
+	if finished {
+		s.m.remove(s.id)
+	}
+	return s.m.write(&Frame{Type: FrameFin, StreamID: s.id})
+}
+
+// Reset aborts the stream.
+func (s *Stream) Reset() error {
+	s.m.remove(s.id)
+	s.abort(ErrStreamReset)
+	return s.m.write(&Frame{Type: FrameReset, StreamID: s.id})
+}
+
+// receive queues the message @msg, and returns false if it exceeds the window.
+func (s *Stream) receive(msg []byte) bool {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	if s.buffered+len(msg) > s.m.window {
+		return false
+	}
+	if s.finished || s.err != nil {
+		return true
+	}
+	s.msgs = append(s.msgs, msg)
+	s.buffered += len(msg)
+	s.broadcast()
+	return true
+}
+
+func (s *Stream) grant(n int) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	s.window += n
+	s.broadcast()
+}
+
+func (s *Stream) finish() {
+	s.lock.Lock()
+	s.finished = true
+	closed := s.closed
+	s.broadcast()
+	s.lock.Unlock()
+
+	if closed {
+		s.m.remove(s.id)
+	}
+}
+
+func (s *Stream) abort(err error) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	if s.err == nil {
+		s.err = err
+		s.msgs, s.buffered = nil, 0
+		s.broadcast()
+	}
+}
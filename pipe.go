@@ -0,0 +1,123 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package getty
+
+import (
+	"net"
+	"sync"
+)
+
+import (
+	gxsync "github.com/dubbogo/gost/sync"
+
+	uatomic "go.uber.org/atomic"
+)
+
+var pipeID uatomic.Int32
+
+type pipeEndPoint struct {
+	ServerOptions
+
+	endPointID   EndPointID
+	endPointType EndPointType
+
+	lock sync.Mutex
+	conn net.Conn
+	ss   Session
+
+	sync.Once
+	done chan struct{}
+}
+
+// NewPipeEndpoint builds a pair of endpoints whose connections are the two ends of a net.Pipe.
+// Each endpoint serves exactly one session which is opened synchronously in its RunEventLoop,
+// so pkg handlers and event listeners can be unit tested without binding ports or sleeping
+// for connects.
+func NewPipeEndpoint(opts ...ServerOption) (client EndPoint, server EndPoint) {
+	clientConn, serverConn := net.Pipe()
+
+	return newPipeEndPoint(PIPE_CLIENT, clientConn, opts...), newPipeEndPoint(PIPE_SERVER, serverConn, opts...)
+}
+
+func newPipeEndPoint(t EndPointType, conn net.Conn, opts ...ServerOption) *pipeEndPoint {
+	p := &pipeEndPoint{
+		endPointID:   pipeID.Add(1),
+		endPointType: t,
+		conn:         conn,
+		done:         make(chan struct{}),
+	}
+	for _, opt := range opts {
+		opt(&(p.ServerOptions))
+	}
+
+	return p
+}
+
+func (p *pipeEndPoint) ID() EndPointID {
+	return p.endPointID
+}
+
+func (p *pipeEndPoint) EndPointType() EndPointType {
+	return p.endPointType
+}
+
+// RunEventLoop invokes @newSession and runs the session of the pipe end.
+func (p *pipeEndPoint) RunEventLoop(newSession NewSessionCallback) {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+
+	if p.IsClosed() || p.ss != nil {
+		return
+	}
+
+	ss := newTCPSession(p.conn, p)
+	ss.SetName(defaultPipeSessionName)
+	if err := newSession(ss); err != nil {
+		log.with(ss.Logger()).Warnw("newSession failed", "err", err)
+		p.conn.Close()
+		return
+	}
+	p.ss = ss
+	ss.(*session).run()
+}
+
+func (p *pipeEndPoint) IsClosed() bool {
+	select {
+	case <-p.done:
+		return true
+	default:
+		return false
+	}
+}
+
+// Close closes the session and its pipe end. The peer session will get an EOF.
+func (p *pipeEndPoint) Close() {
+	p.Once.Do(func() {
+		close(p.done)
+		p.lock.Lock()
+		if p.ss != nil {
+			p.ss.Close()
+		}
+		p.conn.Close()
+		p.lock.Unlock()
+	})
+}
+
+func (p *pipeEndPoint) GetTaskPool() gxsync.GenericTaskPool {
+	return p.tPool
+}
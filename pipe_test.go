@@ -0,0 +1,123 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package getty
+
+import (
+	"encoding/binary"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+)
+
+import (
+	"github.com/stretchr/testify/assert"
+)
+
+// stringPkgHandler frames a string package with a 4 bytes length header
+type stringPkgHandler struct{}
+
+func (h *stringPkgHandler) Read(ss Session, data []byte) (interface{}, int, error) {
+	if len(data) < 4 {
+		return nil, 0, nil
+	}
+	pkgLen := int(binary.BigEndian.Uint32(data))
+	if len(data) < 4+pkgLen {
+		return nil, 4 + pkgLen, nil
+	}
+
+	return string(data[4 : 4+pkgLen]), 4 + pkgLen, nil
+}
+
+func (h *stringPkgHandler) Write(ss Session, pkg interface{}) ([]byte, error) {
+	s, ok := pkg.(string)
+	if !ok {
+		return nil, errors.New("illegal pkg")
+	}
+	buf := make([]byte, 4+len(s))
+	binary.BigEndian.PutUint32(buf, uint32(len(s)))
+	copy(buf[4:], s)
+
+	return buf, nil
+}
+
+type chanMessageHandler struct {
+	MessageHandler
+	msgs   chan interface{}
+	once   sync.Once
+	closed chan struct{} // closed when the first session is closed
+}
+
+func newChanMessageHandler() *chanMessageHandler {
+	return &chanMessageHandler{msgs: make(chan interface{}, 16), closed: make(chan struct{})}
+}
+
+func (h *chanMessageHandler) OnMessage(session Session, pkg interface{}) {
+	h.msgs <- pkg
+}
+
+func (h *chanMessageHandler) OnClose(session Session) {
+	h.once.Do(func() { close(h.closed) })
+}
+
+func TestPipeEndpoint(t *testing.T) {
+	clientEndPoint, serverEndPoint := NewPipeEndpoint()
+	assert.Equal(t, PIPE_CLIENT, clientEndPoint.EndPointType())
+	assert.Equal(t, PIPE_SERVER, serverEndPoint.EndPointType())
+
+	newCallback := func(handler *chanMessageHandler) NewSessionCallback {
+		return func(session Session) error {
+			session.SetPkgHandler(&stringPkgHandler{})
+			session.SetEventListener(handler)
+			return nil
+		}
+	}
+	clientHandler := newChanMessageHandler()
+	serverHandler := newChanMessageHandler()
+	clientEndPoint.RunEventLoop(newCallback(clientHandler))
+	serverEndPoint.RunEventLoop(newCallback(serverHandler))
+	assert.Equal(t, 1, clientHandler.SessionNumber())
+	assert.Equal(t, 1, serverHandler.SessionNumber())
+
+	_, _, err := clientHandler.array[0].WritePkg("hello", 0)
+	assert.Nil(t, err)
+	select {
+	case msg := <-serverHandler.msgs:
+		assert.Equal(t, "hello", msg)
+	case <-time.After(3 * time.Second):
+		t.Fatal("server did not receive the package")
+	}
+
+	_, _, err = serverHandler.array[0].WritePkg("world", 0)
+	assert.Nil(t, err)
+	select {
+	case msg := <-clientHandler.msgs:
+		assert.Equal(t, "world", msg)
+	case <-time.After(3 * time.Second):
+		t.Fatal("client did not receive the package")
+	}
+
+	clientEndPoint.Close()
+	assert.True(t, clientEndPoint.IsClosed())
+	select {
+	case <-serverHandler.closed:
+	case <-time.After(3 * time.Second):
+		t.Fatal("server session is not closed")
+	}
+	serverEndPoint.Close()
+}
@@ -0,0 +1,232 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package getty
+
+import (
+	"sort"
+	"time"
+)
+
+const (
+	poolScaleInterval            = 1e9 // 1s
+	defaultPoolScalePendingBytes = 32 * 1024
+	defaultPoolScaleRTT          = 200 * time.Millisecond
+)
+
+// poolSize returns the current target size of the pool.
+func (c *client) poolSize() int {
+	c.Lock()
+	defer c.Unlock()
+	return c.number
+}
+
+// ResizePool sets the number of the sessions of the pool to @n, which is bounded by
+// WithMinConnNum and WithMaxConnNum. The new sessions are connected in the background,
+// and the excess sessions of the least pending writes are closed.
+func (c *client) ResizePool(n int) {
+	if n < c.minConnNum {
+		n = c.minConnNum
+	}
+	if c.maxConnNum > 0 && n > c.maxConnNum {
+		n = c.maxConnNum
+	}
+	if n < 1 {
+		n = 1
+	}
+
+	c.Lock()
+	if c.ssMap == nil {
+		c.Unlock()
+		return
+	}
+	grow := n > c.number
+	c.number = n
+	var closing []Session
+	if !grow {
+		live := make([]Session, 0, len(c.ssMap))
+		for s := range c.ssMap {
+			if !s.IsClosed() {
+				live = append(live, s)
+			}
+		}
+		if len(live) > n {
+			sort.Slice(live, func(i, j int) bool {
+				return live[i].Stats().PendingWriteBytes < live[j].Stats().PendingWriteBytes
+			})
+			closing = live[:len(live)-n]
+			for _, s := range closing {
+				delete(c.ssMap, s)
+			}
+		}
+	}
+	running := c.newSession != nil
+	c.Unlock()
+
+	for _, s := range closing {
+		// closing the session should not reconnect it
+		s.RemoveAttribute(sessionClientKey)
+		s.Close()
+	}
+	if grow && running {
+		c.wg.Add(1)
+		go func() {
+			defer c.wg.Done()
+			c.reConnect(false)
+		}()
+	}
+}
+
+// scalePool grows the pool by one session when the average pending write bytes or rtt of
+// the sessions exceeds the thresholds of WithPoolScaleThreshold, and shrinks it by one when
+// both of them fall below a quarter of the thresholds.
+func (c *client) scalePool() {
+	c.Lock()
+	number := c.number
+	sessions := make([]Session, 0, len(c.ssMap))
+	for s := range c.ssMap {
+		if !s.IsClosed() {
+			sessions = append(sessions, s)
+		}
+	}
+	c.Unlock()
+	if len(sessions) == 0 || len(sessions) < number {
+		// the pool is still connecting
+		return
+	}
+
+	var (
+		pending, rttNum int
+		rtt             time.Duration
+	)
+	for _, s := range sessions {
+		pending += s.Stats().PendingWriteBytes
+		if conn := tcpConnOf(s.Conn()); conn != nil {
+			if d, err := tcpRTT(conn); err == nil {
+				rtt += d
+				rttNum++
+			}
+		}
+	}
+	pending /= len(sessions)
+	if rttNum > 0 {
+		rtt /= time.Duration(rttNum)
+	}
+
+	pendingThreshold, rttThreshold := c.poolScalePendingBytes, c.poolScaleRTT
+	if pendingThreshold <= 0 {
+		pendingThreshold = defaultPoolScalePendingBytes
+	}
+	if rttThreshold <= 0 {
+		rttThreshold = defaultPoolScaleRTT
+	}
+	switch {
+	case pending > pendingThreshold || rtt > rttThreshold:
+		c.ResizePool(number + 1)
+	case pending < pendingThreshold/4 && rtt < rttThreshold/4:
+		c.ResizePool(number - 1)
+	}
+}
+
+// runPoolScaler scales the pool every poolScaleInterval.
+func (c *client) runPoolScaler() {
+	defer c.wg.Done()
+
+	ticker := time.NewTicker(poolScaleInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-c.done:
+			return
+		case <-ticker.C:
+			c.scalePool()
+		}
+	}
+}
+
+// reapIdle closes the sessions idle for longer than WithMaxConnIdleTime, the most idle ones
+// first, while keeping WithMinConnNum sessions or one at least. The pool shrinks with them.
+func (c *client) reapIdle(now time.Time) {
+	floor := c.minConnNum
+	if floor < 1 {
+		floor = 1
+	}
+
+	c.Lock()
+	if c.ssMap == nil {
+		c.Unlock()
+		return
+	}
+	var (
+		live    int
+		idle    []Session
+		idleFor = make(map[Session]time.Duration)
+	)
+	for s := range c.ssMap {
+		if s.IsClosed() {
+			continue
+		}
+		live++
+		stats := s.Stats()
+		last := stats.ConnectTime
+		if stats.LastReadTime.After(last) {
+			last = stats.LastReadTime
+		}
+		if stats.LastWriteTime.After(last) {
+			last = stats.LastWriteTime
+		}
+		if d := now.Sub(last); d > c.maxConnIdleTime {
+			idle = append(idle, s)
+			idleFor[s] = d
+		}
+	}
+	if n := live - floor; len(idle) > n {
+		sort.Slice(idle, func(i, j int) bool { return idleFor[idle[i]] > idleFor[idle[j]] })
+		idle = idle[:max(n, 0)]
+	}
+	for _, s := range idle {
+		delete(c.ssMap, s)
+	}
+	c.number -= len(idle)
+	if c.number < floor {
+		c.number = floor
+	}
+	c.Unlock()
+
+	for _, s := range idle {
+		poolLog.with(s.Logger()).Infow("close the idle session", "idle", idleFor[s])
+		// closing the session should not reconnect it
+		s.RemoveAttribute(sessionClientKey)
+		s.Close()
+	}
+}
+
+// runIdleReaper reaps the idle sessions every half of WithMaxConnIdleTime.
+func (c *client) runIdleReaper() {
+	defer c.wg.Done()
+
+	ticker := time.NewTicker(c.maxConnIdleTime / 2)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-c.done:
+			return
+		case now := <-ticker.C:
+			c.reapIdle(now)
+		}
+	}
+}
@@ -0,0 +1,123 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package getty
+
+import (
+	"runtime"
+	"testing"
+	"time"
+)
+
+import (
+	"github.com/stretchr/testify/assert"
+)
+
+func TestClientResizePool(t *testing.T) {
+	s := NewTCPServer(WithLocalAddress("127.0.0.1:0")).(*server)
+	defer s.Close()
+	s.RunEventLoop(func(session Session) error {
+		session.SetPkgHandler(&stringPkgHandler{})
+		session.SetEventListener(newChanMessageHandler())
+		session.SetReadTimeout(50 * time.Millisecond)
+		return nil
+	})
+
+	clt := NewTCPClient(WithServerAddress(s.addr), WithConnectionNumber(1), WithMaxConnNum(3),
+		WithReconnectInterval(1e7)).(*client)
+	defer clt.Close()
+	clt.RunEventLoop(func(session Session) error {
+		session.SetPkgHandler(&stringPkgHandler{})
+		session.SetEventListener(newChanMessageHandler())
+		session.SetReadTimeout(50 * time.Millisecond)
+		return nil
+	})
+	assert.Equal(t, 1, clt.sessionNum())
+
+	// bounded by WithMaxConnNum
+	clt.ResizePool(5)
+	assert.Equal(t, 3, clt.poolSize())
+	assert.Eventually(t, func() bool { return clt.sessionNum() == 3 }, time.Second, 10*time.Millisecond)
+	clt.Lock()
+	sessions := make([]Session, 0, 3)
+	for ss := range clt.ssMap {
+		sessions = append(sessions, ss)
+	}
+	clt.Unlock()
+
+	// the closed sessions are not reconnected
+	clt.ResizePool(0)
+	assert.Equal(t, 1, clt.poolSize())
+	assert.Equal(t, 1, clt.sessionNum())
+	closed := 0
+	for _, ss := range sessions {
+		if ss.IsClosed() {
+			closed++
+		}
+	}
+	assert.Equal(t, 2, closed)
+	time.Sleep(50 * time.Millisecond)
+	assert.Equal(t, 1, clt.sessionNum())
+
+	if runtime.GOOS == "linux" {
+		// the rtt of any session exceeds 1ns
+		clt.poolScaleRTT = time.Nanosecond
+		clt.scalePool()
+		assert.Equal(t, 2, clt.poolSize())
+	}
+}
+
+func TestClientPoolBounds(t *testing.T) {
+	clt := newClient(TCP_CLIENT, WithServerAddress("127.0.0.1:1"), WithMinConnNum(2), WithMaxConnNum(4))
+	assert.Equal(t, 2, clt.number)
+	clt = newClient(TCP_CLIENT, WithServerAddress("127.0.0.1:1"), WithConnectionNumber(8), WithMaxConnNum(4))
+	assert.Equal(t, 4, clt.number)
+	assert.Panics(t, func() {
+		newClient(TCP_CLIENT, WithServerAddress("127.0.0.1:1"), WithMinConnNum(5), WithMaxConnNum(4))
+	})
+}
+
+func TestClientReapIdle(t *testing.T) {
+	s := NewTCPServer(WithLocalAddress("127.0.0.1:0")).(*server)
+	defer s.Close()
+	s.RunEventLoop(func(session Session) error {
+		session.SetPkgHandler(&stringPkgHandler{})
+		session.SetEventListener(newChanMessageHandler())
+		return nil
+	})
+
+	clt := NewTCPClient(WithServerAddress(s.addr), WithConnectionNumber(3), WithMinConnNum(2),
+		WithMaxConnIdleTime(time.Hour), WithReconnectInterval(1e7)).(*client)
+	defer clt.Close()
+	clt.RunEventLoop(func(session Session) error {
+		session.SetPkgHandler(&stringPkgHandler{})
+		session.SetEventListener(newChanMessageHandler())
+		session.SetReadTimeout(50 * time.Millisecond)
+		return nil
+	})
+	assert.Equal(t, 3, clt.sessionNum())
+
+	clt.reapIdle(time.Now())
+	assert.Equal(t, 3, clt.sessionNum())
+
+	// keep WithMinConnNum sessions
+	clt.reapIdle(time.Now().Add(2 * time.Hour))
+	assert.Equal(t, 2, clt.poolSize())
+	assert.Equal(t, 2, clt.sessionNum())
+	time.Sleep(50 * time.Millisecond)
+	assert.Equal(t, 2, clt.sessionNum())
+}
@@ -0,0 +1,90 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package getty
+
+import (
+	"sync"
+)
+
+import (
+	gxbytes "github.com/dubbogo/gost/bytes"
+
+	uatomic "go.uber.org/atomic"
+)
+
+var pooledBufferPool = sync.Pool{New: func() interface{} { return new(PooledBuffer) }}
+
+// PooledBuffer is a package whose bytes alias the read buffer of the session instead of a copy,
+// eg: the payload of a large frame. The Reader returns it by NewPooledBuffer and the handler must
+// call Release once it is done with the bytes, which may be reused by the session after that.
+type PooledBuffer struct {
+	b     []byte
+	chunk *readChunk
+}
+
+// NewPooledBuffer returns the package of @data which is a part of the data passed to Reader.Read.
+func NewPooledBuffer(data []byte) *PooledBuffer {
+	b := pooledBufferPool.Get().(*PooledBuffer)
+	b.b = data
+	return b
+}
+
+// Bytes returns the bytes, which must not be used after Release.
+func (b *PooledBuffer) Bytes() []byte {
+	return b.b
+}
+
+func (b *PooledBuffer) Len() int {
+	return len(b.b)
+}
+
+// Release hands the bytes back to the session. The PooledBuffer must not be used after that.
+func (b *PooledBuffer) Release() {
+	if b.chunk != nil {
+		b.chunk.release()
+	}
+	b.b, b.chunk = nil, nil
+	pooledBufferPool.Put(b)
+}
+
+// readChunk is a read buffer of the session handed off to the PooledBuffers aliasing it. @bufp
+// is released to the bytes pool when all the PooledBuffers are done with it.
+type readChunk struct {
+	bufp *[]byte
+	refs uatomic.Int32
+}
+
+func newReadChunk(bufp *[]byte) *readChunk {
+	return &readChunk{bufp: bufp}
+}
+
+// retain makes @pkg hold a reference of the chunk if it is a PooledBuffer.
+func (c *readChunk) retain(pkg interface{}) bool {
+	b, ok := pkg.(*PooledBuffer)
+	if ok {
+		c.refs.Inc()
+		b.chunk = c
+	}
+	return ok
+}
+
+func (c *readChunk) release() {
+	if c.refs.Dec() == 0 && c.bufp != nil {
+		gxbytes.ReleaseBytes(c.bufp)
+	}
+}
@@ -0,0 +1,71 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package getty
+
+import (
+	"encoding/binary"
+	"fmt"
+	"testing"
+	"time"
+)
+
+import (
+	"github.com/stretchr/testify/assert"
+)
+
+// pooledPkgHandler reads the frames of stringPkgHandler as the PooledBuffers.
+type pooledPkgHandler struct {
+	stringPkgHandler
+}
+
+func (h *pooledPkgHandler) Read(ss Session, data []byte) (interface{}, int, error) {
+	if len(data) < 4 {
+		return nil, 0, nil
+	}
+	pkgLen := int(binary.BigEndian.Uint32(data))
+	if len(data) < 4+pkgLen {
+		return nil, 4 + pkgLen, nil
+	}
+
+	return NewPooledBuffer(data[4 : 4+pkgLen]), 4 + pkgLen, nil
+}
+
+func TestSessionPooledBuffer(t *testing.T) {
+	ss, handler := newPipeSessions(t)
+	handler.array[0].SetPkgHandler(&pooledPkgHandler{})
+
+	const num = 10
+	for i := 0; i < num; i++ {
+		_, _, err := ss.WritePkg(fmt.Sprintf("message-%d", i), 0)
+		assert.Nil(t, err)
+	}
+	bufs := make([]*PooledBuffer, 0, num)
+	for len(bufs) < num {
+		select {
+		case pkg := <-handler.msgs:
+			bufs = append(bufs, pkg.(*PooledBuffer))
+		case <-time.After(3 * time.Second):
+			t.Fatal("the messages are not received")
+		}
+	}
+	// the held bytes are not overwritten by the following reads
+	for i, buf := range bufs {
+		assert.Equal(t, fmt.Sprintf("message-%d", i), string(buf.Bytes()))
+		buf.Release()
+	}
+}
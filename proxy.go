@@ -0,0 +1,159 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package getty
+
+import (
+	"bufio"
+	"crypto/tls"
+	"encoding/base64"
+	"net"
+	"net/http"
+	"net/url"
+	"time"
+)
+
+import (
+	perrors "github.com/pkg/errors"
+
+	"golang.org/x/net/proxy"
+)
+
+// proxyDialer dials the server through a http, https or socks5 proxy.
+type proxyDialer struct {
+	proxyURL *url.URL
+	timeout  time.Duration
+}
+
+func newProxyDialer(rawURL string, timeout time.Duration) (*proxyDialer, error) {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return nil, perrors.WithStack(err)
+	}
+	switch u.Scheme {
+	case "http", "https", "socks5", "socks5h":
+	default:
+		return nil, perrors.Errorf("unsupported proxy scheme %q", u.Scheme)
+	}
+	if u.Host == "" {
+		return nil, perrors.Errorf("proxy url %q has no host", rawURL)
+	}
+
+	return &proxyDialer{proxyURL: u, timeout: timeout}, nil
+}
+
+// proxyAddr returns the address of the proxy with the default port of its scheme.
+func (d *proxyDialer) proxyAddr() string {
+	if d.proxyURL.Port() != "" {
+		return d.proxyURL.Host
+	}
+	port := "80"
+	switch d.proxyURL.Scheme {
+	case "https":
+		port = "443"
+	case "socks5", "socks5h":
+		port = "1080"
+	}
+
+	return net.JoinHostPort(d.proxyURL.Hostname(), port)
+}
+
+// Dial connects @addr through the proxy.
+func (d *proxyDialer) Dial(network, addr string) (net.Conn, error) {
+	switch d.proxyURL.Scheme {
+	case "socks5", "socks5h":
+		var auth *proxy.Auth
+		if d.proxyURL.User != nil {
+			password, _ := d.proxyURL.User.Password()
+			auth = &proxy.Auth{User: d.proxyURL.User.Username(), Password: password}
+		}
+		dialer, err := proxy.SOCKS5("tcp", d.proxyAddr(), auth, &net.Dialer{Timeout: d.timeout})
+		if err != nil {
+			return nil, perrors.WithStack(err)
+		}
+		conn, err := dialer.Dial(network, addr)
+		if err != nil {
+			return nil, perrors.Wrapf(err, "socks5 proxy{%s}.Dial(addr:%s)", d.proxyAddr(), addr)
+		}
+		return conn, nil
+	}
+
+	return d.dialConnect(addr)
+}
+
+// dialConnect opens a tunnel to @addr by the http CONNECT method.
+func (d *proxyDialer) dialConnect(addr string) (net.Conn, error) {
+	conn, err := net.DialTimeout("tcp", d.proxyAddr(), d.timeout)
+	if err != nil {
+		return nil, perrors.Wrapf(err, "net.DialTimeout(proxy:%s)", d.proxyAddr())
+	}
+	conn.SetDeadline(time.Now().Add(d.timeout))
+	if d.proxyURL.Scheme == "https" {
+		tlsConn := tls.Client(conn, &tls.Config{ServerName: d.proxyURL.Hostname()})
+		if err = tlsConn.Handshake(); err != nil {
+			conn.Close()
+			return nil, perrors.Wrapf(err, "tls handshake with proxy %s", d.proxyAddr())
+		}
+		conn = tlsConn
+	}
+
+	req := &http.Request{
+		Method: http.MethodConnect,
+		URL:    &url.URL{Opaque: addr},
+		Host:   addr,
+		Header: make(http.Header),
+	}
+	if d.proxyURL.User != nil {
+		password, _ := d.proxyURL.User.Password()
+		credential := base64.StdEncoding.EncodeToString([]byte(d.proxyURL.User.Username() + ":" + password))
+		req.Header.Set("Proxy-Authorization", "Basic "+credential)
+	}
+	if err = req.Write(conn); err != nil {
+		conn.Close()
+		return nil, perrors.WithStack(err)
+	}
+
+	reader := bufio.NewReader(conn)
+	resp, err := http.ReadResponse(reader, req)
+	if err != nil {
+		conn.Close()
+		return nil, perrors.WithStack(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		conn.Close()
+		return nil, perrors.Errorf("proxy %s CONNECT %s: %s", d.proxyAddr(), addr, resp.Status)
+	}
+	conn.SetDeadline(time.Time{})
+
+	if reader.Buffered() > 0 {
+		// the server has sent some data after the response
+		return &bufferedConn{Conn: conn, reader: reader}, nil
+	}
+
+	return conn, nil
+}
+
+// bufferedConn reads the data buffered by its reader first.
+type bufferedConn struct {
+	net.Conn
+	reader *bufio.Reader
+}
+
+func (c *bufferedConn) Read(p []byte) (int, error) {
+	return c.reader.Read(p)
+}
@@ -0,0 +1,116 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package getty
+
+import (
+	"bufio"
+	"io"
+	"net"
+	"net/http"
+	"testing"
+	"time"
+)
+
+import (
+	"github.com/stretchr/testify/assert"
+)
+
+// connectProxy is a http CONNECT proxy which requires the basic auth "getty:secret".
+func connectProxy(t *testing.T) (addr string, tunnels chan string) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	assert.Nil(t, err)
+	t.Cleanup(func() { ln.Close() })
+
+	tunnels = make(chan string, 4)
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			go func(conn net.Conn) {
+				defer conn.Close()
+				req, err := http.ReadRequest(bufio.NewReader(conn))
+				if err != nil {
+					return
+				}
+				// "Z2V0dHk6c2VjcmV0" is base64("getty:secret")
+				if req.Method != http.MethodConnect || req.Header.Get("Proxy-Authorization") != "Basic Z2V0dHk6c2VjcmV0" {
+					io.WriteString(conn, "HTTP/1.1 407 Proxy Authentication Required\r\n\r\n")
+					return
+				}
+				upstream, err := net.Dial("tcp", req.Host)
+				if err != nil {
+					io.WriteString(conn, "HTTP/1.1 502 Bad Gateway\r\n\r\n")
+					return
+				}
+				defer upstream.Close()
+				tunnels <- req.Host
+				io.WriteString(conn, "HTTP/1.1 200 Connection established\r\n\r\n")
+				go io.Copy(upstream, conn)
+				io.Copy(conn, upstream)
+			}(conn)
+		}
+	}()
+
+	return ln.Addr().String(), tunnels
+}
+
+func TestClientWithProxy(t *testing.T) {
+	s := NewTCPServer(WithLocalAddress("127.0.0.1:0")).(*server)
+	defer s.Close()
+	serverHandler := newChanMessageHandler()
+	s.RunEventLoop(func(session Session) error {
+		session.SetPkgHandler(&stringPkgHandler{})
+		session.SetEventListener(serverHandler)
+		return nil
+	})
+
+	proxyAddr, tunnels := connectProxy(t)
+	clt := NewTCPClient(
+		WithServerAddress(s.addr),
+		WithConnectionNumber(1),
+		WithProxy("http://getty:secret@"+proxyAddr),
+	)
+	defer clt.Close()
+	clientHandler := newChanMessageHandler()
+	clt.RunEventLoop(func(session Session) error {
+		session.SetPkgHandler(&stringPkgHandler{})
+		session.SetEventListener(clientHandler)
+		return nil
+	})
+	assert.Equal(t, 1, clientHandler.SessionNumber())
+	assert.Equal(t, s.addr, <-tunnels)
+
+	_, _, err := clientHandler.array[0].WritePkg("hello", 0)
+	assert.Nil(t, err)
+	select {
+	case msg := <-serverHandler.msgs:
+		assert.Equal(t, "hello", msg)
+	case <-time.After(3 * time.Second):
+		t.Fatal("server did not receive the package")
+	}
+
+	dialer, err := newProxyDialer("http://"+proxyAddr, time.Second)
+	assert.Nil(t, err)
+	_, err = dialer.Dial("tcp", s.addr)
+	assert.NotNil(t, err)
+
+	_, err = newProxyDialer("ftp://"+proxyAddr, time.Second)
+	assert.NotNil(t, err)
+}
@@ -0,0 +1,251 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package getty
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"net"
+	"strconv"
+	"strings"
+	"time"
+)
+
+import (
+	perrors "github.com/pkg/errors"
+)
+
+// the versions of PROXY protocol, pls see https://www.haproxy.org/download/2.0/doc/proxy-protocol.txt
+const (
+	ProxyProtocolV1 = 1
+	ProxyProtocolV2 = 2
+)
+
+const (
+	// proxyV1MaxLen is the max length of a v1 header including the CRLF
+	proxyV1MaxLen = 107
+	// proxyV2HeaderLen is the length of the fixed part of a v2 header
+	proxyV2HeaderLen = 16
+	// proxyHeaderTimeout is the max time to wait for the PROXY header of an accepted connection
+	proxyHeaderTimeout = 5 * time.Second
+)
+
+var (
+	proxyV1Prefix    = []byte("PROXY ")
+	proxyV2Signature = []byte("\r\n\r\n\x00\r\nQUIT\n")
+)
+
+// readProxyHeader reads a v1 or v2 PROXY header from @r. The returned addresses are
+// nil if the header does not carry the addresses, eg: "PROXY UNKNOWN" or a LOCAL command.
+func readProxyHeader(r *bufio.Reader) (src net.Addr, dst net.Addr, err error) {
+	// peek the v1 prefix first, so that a short v1 header such as "PROXY UNKNOWN\r\n"
+	// never waits for the bytes of the v2 signature
+	prefix, err := r.Peek(len(proxyV1Prefix))
+	if err != nil {
+		return nil, nil, perrors.WithStack(err)
+	}
+	if bytes.Equal(prefix, proxyV1Prefix) {
+		return readProxyV1Header(r)
+	}
+	sig, err := r.Peek(len(proxyV2Signature))
+	if err != nil {
+		return nil, nil, perrors.WithStack(err)
+	}
+	if bytes.Equal(sig, proxyV2Signature) {
+		return readProxyV2Header(r)
+	}
+
+	return nil, nil, perrors.Errorf("illegal PROXY header %q", sig)
+}
+
+func readProxyV1Header(r *bufio.Reader) (net.Addr, net.Addr, error) {
+	var line []byte
+	for len(line) < proxyV1MaxLen {
+		b, err := r.ReadByte()
+		if err != nil {
+			return nil, nil, perrors.WithStack(err)
+		}
+		line = append(line, b)
+		if b == '\n' {
+			break
+		}
+	}
+	if !bytes.HasSuffix(line, []byte("\r\n")) {
+		return nil, nil, perrors.Errorf("PROXY v1 header is too long or not ended with CRLF")
+	}
+
+	fields := strings.Fields(string(line[:len(line)-2]))
+	if len(fields) >= 2 && fields[1] == "UNKNOWN" {
+		return nil, nil, nil
+	}
+	if len(fields) != 6 || (fields[1] != "TCP4" && fields[1] != "TCP6") {
+		return nil, nil, perrors.Errorf("illegal PROXY v1 header %q", line)
+	}
+	srcIP, dstIP := net.ParseIP(fields[2]), net.ParseIP(fields[3])
+	srcPort, srcErr := strconv.ParseUint(fields[4], 10, 16)
+	dstPort, dstErr := strconv.ParseUint(fields[5], 10, 16)
+	if srcIP == nil || dstIP == nil || srcErr != nil || dstErr != nil {
+		return nil, nil, perrors.Errorf("illegal PROXY v1 header %q", line)
+	}
+
+	return &net.TCPAddr{IP: srcIP, Port: int(srcPort)}, &net.TCPAddr{IP: dstIP, Port: int(dstPort)}, nil
+}
+
+func readProxyV2Header(r *bufio.Reader) (net.Addr, net.Addr, error) {
+	header := make([]byte, proxyV2HeaderLen)
+	if _, err := io.ReadFull(r, header); err != nil {
+		return nil, nil, perrors.WithStack(err)
+	}
+	if header[12]>>4 != 2 {
+		return nil, nil, perrors.Errorf("illegal PROXY v2 version %d", header[12]>>4)
+	}
+	payload := make([]byte, binary.BigEndian.Uint16(header[14:]))
+	if _, err := io.ReadFull(r, payload); err != nil {
+		return nil, nil, perrors.WithStack(err)
+	}
+
+	// LOCAL command, eg: the health check of the proxy
+	if header[12]&0x0F == 0 {
+		return nil, nil, nil
+	}
+	switch header[13] {
+	case 0x11: // TCP over IPv4
+		if len(payload) < 12 {
+			return nil, nil, perrors.Errorf("illegal PROXY v2 ipv4 address length %d", len(payload))
+		}
+		return &net.TCPAddr{IP: net.IP(payload[0:4]), Port: int(binary.BigEndian.Uint16(payload[8:]))},
+			&net.TCPAddr{IP: net.IP(payload[4:8]), Port: int(binary.BigEndian.Uint16(payload[10:]))}, nil
+	case 0x21: // TCP over IPv6
+		if len(payload) < 36 {
+			return nil, nil, perrors.Errorf("illegal PROXY v2 ipv6 address length %d", len(payload))
+		}
+		return &net.TCPAddr{IP: net.IP(payload[0:16]), Port: int(binary.BigEndian.Uint16(payload[32:]))},
+			&net.TCPAddr{IP: net.IP(payload[16:32]), Port: int(binary.BigEndian.Uint16(payload[34:]))}, nil
+	}
+
+	// the other families are not used by getty, so the addresses are ignored
+	return nil, nil, nil
+}
+
+// writeProxyHeader writes a PROXY header of @version which says that the connection is from @src to @dst.
+func writeProxyHeader(w io.Writer, version int, src, dst net.Addr) error {
+	var header []byte
+
+	srcAddr, srcOK := src.(*net.TCPAddr)
+	dstAddr, dstOK := dst.(*net.TCPAddr)
+	ipv4 := srcOK && dstOK && srcAddr.IP.To4() != nil && dstAddr.IP.To4() != nil
+	switch version {
+	case ProxyProtocolV1:
+		switch {
+		case !srcOK || !dstOK:
+			header = []byte("PROXY UNKNOWN\r\n")
+		case ipv4:
+			header = []byte(fmt.Sprintf("PROXY TCP4 %s %s %d %d\r\n", srcAddr.IP, dstAddr.IP, srcAddr.Port, dstAddr.Port))
+		default:
+			header = []byte(fmt.Sprintf("PROXY TCP6 %s %s %d %d\r\n", srcAddr.IP, dstAddr.IP, srcAddr.Port, dstAddr.Port))
+		}
+
+	case ProxyProtocolV2:
+		header = append(header, proxyV2Signature...)
+		switch {
+		case !srcOK || !dstOK:
+			// LOCAL command without addresses
+			header = append(header, 0x20, 0x00, 0x00, 0x00)
+		case ipv4:
+			header = append(header, 0x21, 0x11, 0x00, 12)
+			header = append(header, srcAddr.IP.To4()...)
+			header = append(header, dstAddr.IP.To4()...)
+			header = binary.BigEndian.AppendUint16(header, uint16(srcAddr.Port))
+			header = binary.BigEndian.AppendUint16(header, uint16(dstAddr.Port))
+		default:
+			header = append(header, 0x21, 0x21, 0x00, 36)
+			header = append(header, srcAddr.IP.To16()...)
+			header = append(header, dstAddr.IP.To16()...)
+			header = binary.BigEndian.AppendUint16(header, uint16(srcAddr.Port))
+			header = binary.BigEndian.AppendUint16(header, uint16(dstAddr.Port))
+		}
+
+	default:
+		return perrors.Errorf("illegal PROXY protocol version %d", version)
+	}
+
+	_, err := w.Write(header)
+	return perrors.WithStack(err)
+}
+
+// proxyConn is an accepted connection whose addresses are given by its PROXY header.
+type proxyConn struct {
+	net.Conn
+	reader *bufio.Reader
+	local  net.Addr
+	remote net.Addr
+}
+
+// NetConn returns the underlying connection.
+func (c *proxyConn) NetConn() net.Conn {
+	return c.Conn
+}
+
+func (c *proxyConn) Read(p []byte) (int, error) {
+	return c.reader.Read(p)
+}
+
+func (c *proxyConn) LocalAddr() net.Addr {
+	if c.local != nil {
+		return c.local
+	}
+	return c.Conn.LocalAddr()
+}
+
+func (c *proxyConn) RemoteAddr() net.Addr {
+	if c.remote != nil {
+		return c.remote
+	}
+	return c.Conn.RemoteAddr()
+}
+
+// readProxyConn reads the PROXY header of the accepted @conn.
+func readProxyConn(conn net.Conn) (net.Conn, error) {
+	reader := bufio.NewReader(conn)
+	conn.SetReadDeadline(time.Now().Add(proxyHeaderTimeout))
+	src, dst, err := readProxyHeader(reader)
+	conn.SetReadDeadline(time.Time{})
+	if err != nil {
+		return nil, perrors.WithStack(err)
+	}
+
+	return &proxyConn{Conn: conn, reader: reader, local: dst, remote: src}, nil
+}
+
+// filterIP returns the ip of @conn counted by the ip filter, which is the source address in the
+// PROXY header of a PROXY connection, or the address of the raw connection otherwise.
+func filterIP(conn net.Conn) net.IP {
+	for {
+		if pc, ok := conn.(*proxyConn); ok {
+			return addrIP(pc.RemoteAddr().String())
+		}
+		wrapper, ok := conn.(interface{ NetConn() net.Conn })
+		if !ok {
+			return addrIP(conn.RemoteAddr().String())
+		}
+		conn = wrapper.NetConn()
+	}
+}
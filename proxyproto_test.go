@@ -0,0 +1,126 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package getty
+
+import (
+	"bufio"
+	"bytes"
+	"net"
+	"testing"
+	"time"
+)
+
+import (
+	"github.com/stretchr/testify/assert"
+)
+
+func TestProxyHeader(t *testing.T) {
+	cases := []struct {
+		src, dst net.Addr
+	}{
+		{&net.TCPAddr{IP: net.ParseIP("192.0.2.1"), Port: 5555}, &net.TCPAddr{IP: net.ParseIP("198.51.100.1"), Port: 80}},
+		{&net.TCPAddr{IP: net.ParseIP("2001:db8::1"), Port: 5555}, &net.TCPAddr{IP: net.ParseIP("2001:db8::2"), Port: 443}},
+	}
+	for _, version := range []int{ProxyProtocolV1, ProxyProtocolV2} {
+		for _, c := range cases {
+			var buf bytes.Buffer
+			assert.Nil(t, writeProxyHeader(&buf, version, c.src, c.dst))
+			buf.WriteString("payload")
+
+			r := bufio.NewReader(&buf)
+			src, dst, err := readProxyHeader(r)
+			assert.Nil(t, err)
+			assert.Equal(t, c.src.String(), src.String())
+			assert.Equal(t, c.dst.String(), dst.String())
+			rest, _ := r.ReadString(0)
+			assert.Equal(t, "payload", rest)
+		}
+
+		// LOCAL or UNKNOWN header keeps the addresses of the connection
+		var buf bytes.Buffer
+		assert.Nil(t, writeProxyHeader(&buf, version, nil, nil))
+		src, dst, err := readProxyHeader(bufio.NewReader(&buf))
+		assert.Nil(t, err)
+		assert.Nil(t, src)
+		assert.Nil(t, dst)
+	}
+
+	// a short v1 header is read without waiting for more bytes of the connection
+	local, remote := net.Pipe()
+	defer local.Close()
+	defer remote.Close()
+	go remote.Write([]byte("PROXY UNKNOWN\r\n"))
+	local.SetReadDeadline(time.Now().Add(time.Second))
+	src, dst, err := readProxyHeader(bufio.NewReader(local))
+	assert.Nil(t, err)
+	assert.Nil(t, src)
+	assert.Nil(t, dst)
+
+	_, _, err = readProxyHeader(bufio.NewReader(bytes.NewBufferString("GET / HTTP/1.1\r\n\r\n")))
+	assert.NotNil(t, err)
+}
+
+func TestProxyProtocolServer(t *testing.T) {
+	s := NewTCPServer(WithLocalAddress("127.0.0.1:0"), WithProxyProtocol(true)).(*server)
+	defer s.Close()
+	sessions := make(chan Session, 2)
+	serverHandler := newChanMessageHandler()
+	s.RunEventLoop(func(session Session) error {
+		session.SetPkgHandler(&stringPkgHandler{})
+		session.SetEventListener(serverHandler)
+		sessions <- session
+		return nil
+	})
+
+	conn, err := net.Dial("tcp", s.addr)
+	assert.Nil(t, err)
+	defer conn.Close()
+	_, err = conn.Write([]byte("PROXY TCP4 192.0.2.1 198.51.100.1 5555 80\r\n"))
+	assert.Nil(t, err)
+	select {
+	case ss := <-sessions:
+		assert.Equal(t, "192.0.2.1:5555", ss.RemoteAddr())
+		assert.Equal(t, "198.51.100.1:80", ss.LocalAddr())
+	case <-time.After(3 * time.Second):
+		t.Fatal("server did not accept the connection")
+	}
+
+	clt := NewTCPClient(WithServerAddress(s.addr), WithConnectionNumber(1), WithClientProxyProtocol(ProxyProtocolV2))
+	defer clt.Close()
+	clientHandler := newChanMessageHandler()
+	clt.RunEventLoop(func(session Session) error {
+		session.SetPkgHandler(&stringPkgHandler{})
+		session.SetEventListener(clientHandler)
+		return nil
+	})
+	assert.Equal(t, 1, clientHandler.SessionNumber())
+	_, _, err = clientHandler.array[0].WritePkg("hello", 0)
+	assert.Nil(t, err)
+	select {
+	case ss := <-sessions:
+		assert.Equal(t, clientHandler.array[0].LocalAddr(), ss.RemoteAddr())
+	case <-time.After(3 * time.Second):
+		t.Fatal("server did not accept the connection")
+	}
+	select {
+	case msg := <-serverHandler.msgs:
+		assert.Equal(t, "hello", msg)
+	case <-time.After(3 * time.Second):
+		t.Fatal("server did not receive the package")
+	}
+}
@@ -0,0 +1,96 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package getty
+
+import (
+	"runtime"
+)
+
+import (
+	uatomic "go.uber.org/atomic"
+)
+
+// PanicPolicy is what Recovery does with the session whose callback panics.
+type PanicPolicy int
+
+const (
+	// PanicContinue keeps the session, and the package whose OnMessage panics is dropped.
+	PanicContinue PanicPolicy = iota
+	// PanicClose closes the session.
+	PanicClose
+)
+
+// Recovery recovers the panics of OnMessage and OnCron by its Interceptor, which would otherwise
+// crash the process or kill the worker of the task pool. The stack of a panic is logged, and then
+// the session is kept or closed by Policy. Sharing one Recovery among the endpoints sums up their
+// panics.
+type Recovery struct {
+	Policy PanicPolicy
+	// OnPanic is invoked with the recovered value if it is not nil, eg: to report the panic.
+	OnPanic func(session Session, r interface{})
+	panics  uatomic.Uint64
+}
+
+// Panics returns the number of the recovered panics.
+func (r *Recovery) Panics() uint64 {
+	return r.panics.Load()
+}
+
+// Interceptor returns the Interceptor which recovers the panics of the wrapped EventListener. Being
+// the first interceptor, it covers the panics of the other ones too.
+func (r *Recovery) Interceptor() Interceptor {
+	return Interceptor{
+		Listener: func(next EventListener) EventListener {
+			return &recoveryListener{EventListener: next, recovery: r}
+		},
+	}
+}
+
+func (r *Recovery) recover(session Session, callback string) {
+	p := recover()
+	if p == nil {
+		return
+	}
+
+	const size = 64 << 10
+	buf := make([]byte, size)
+	buf = buf[:runtime.Stack(buf, false)]
+	session.Logger().Errorw("panic recovered", "callback", callback, "panic", p, "stack", string(buf))
+	r.panics.Inc()
+	if r.OnPanic != nil {
+		r.OnPanic(session, p)
+	}
+	if r.Policy == PanicClose {
+		session.Close()
+	}
+}
+
+type recoveryListener struct {
+	EventListener
+	recovery *Recovery
+}
+
+func (l *recoveryListener) OnMessage(session Session, pkg interface{}) {
+	defer l.recovery.recover(session, "OnMessage")
+	l.EventListener.OnMessage(session, pkg)
+}
+
+func (l *recoveryListener) OnCron(session Session) {
+	defer l.recovery.recover(session, "OnCron")
+	l.EventListener.OnCron(session)
+}
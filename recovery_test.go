@@ -0,0 +1,63 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package getty
+
+import (
+	"testing"
+)
+
+import (
+	"github.com/stretchr/testify/assert"
+)
+
+// panicHandler panics on the package "boom" and the cron.
+type panicHandler struct {
+	*chanMessageHandler
+}
+
+func (h *panicHandler) OnMessage(session Session, pkg interface{}) {
+	if pkg == "boom" {
+		panic("boom")
+	}
+	h.chanMessageHandler.OnMessage(session, pkg)
+}
+
+func (h *panicHandler) OnCron(session Session) {
+	panic("cron")
+}
+
+func TestRecovery(t *testing.T) {
+	ss, _ := newPipeSessions(t)
+	handler := &panicHandler{chanMessageHandler: newChanMessageHandler()}
+	var recovered []interface{}
+	r := &Recovery{OnPanic: func(_ Session, p interface{}) { recovered = append(recovered, p) }}
+	listener := r.Interceptor().Listener(handler)
+
+	listener.OnMessage(ss, "boom")
+	listener.OnCron(ss)
+	listener.OnMessage(ss, "hello")
+	assert.Equal(t, "hello", <-handler.msgs)
+	assert.Equal(t, uint64(2), r.Panics())
+	assert.Equal(t, []interface{}{"boom", "cron"}, recovered)
+	assert.False(t, ss.IsClosed())
+
+	r.Policy = PanicClose
+	listener.OnMessage(ss, "boom")
+	assert.Equal(t, uint64(3), r.Panics())
+	assert.True(t, ss.IsClosed())
+}
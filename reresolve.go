@@ -0,0 +1,132 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package getty
+
+import (
+	"net"
+	"net/url"
+	"slices"
+)
+
+// serverHostPort returns the host:port of the server address, the default port of ws/wss is 80/443.
+func (c *client) serverHostPort() string {
+	if c.endPointType != WS_CLIENT && c.endPointType != WSS_CLIENT {
+		return c.addr
+	}
+
+	u, err := url.Parse(c.addr)
+	if err != nil {
+		return c.addr
+	}
+	if u.Port() != "" {
+		return u.Host
+	}
+	if u.Scheme == "wss" {
+		return net.JoinHostPort(u.Hostname(), "443")
+	}
+	return net.JoinHostPort(u.Hostname(), "80")
+}
+
+// pickAddr returns the resolved address which has the fewest sessions, or @addr itself
+// if the server address is not re-resolved.
+func (c *client) pickAddr(addr string) string {
+	c.Lock()
+	defer c.Unlock()
+
+	if len(c.resolvedAddrs) == 0 {
+		return addr
+	}
+	load := make(map[string]int, len(c.resolvedAddrs))
+	for s := range c.ssMap {
+		if !s.IsClosed() {
+			load[s.RemoteAddr()]++
+		}
+	}
+	picked := c.resolvedAddrs[0]
+	for _, a := range c.resolvedAddrs[1:] {
+		if load[a] < load[picked] {
+			picked = a
+		}
+	}
+
+	return picked
+}
+
+// watchResolver applies the endpoints from @r. The sessions connected to the removed addresses
+// are closed, and so are the sessions above the fair share of an address when new addresses
+// show up, then the reconnecting sessions are spread over the current addresses by pickAddr.
+func (c *client) watchResolver(r Resolver) {
+	defer c.wg.Done()
+
+	for {
+		var (
+			endpoints []Endpoint
+			ok        bool
+		)
+		select {
+		case <-c.done:
+			return
+		case endpoints, ok = <-r.Watch():
+			if !ok {
+				return
+			}
+		}
+
+		addrs := endpointAddrs(endpoints)
+		if len(addrs) == 0 {
+			// keep the former addresses
+			endPointLogger(poolLog, c).Warnw("resolver returns no endpoint")
+			continue
+		}
+		for _, s := range c.updateResolvedAddrs(addrs) {
+			poolLog.with(s.Logger()).Infow("close the session to rebalance", "addrs", addrs)
+			s.Close()
+		}
+	}
+}
+
+// updateResolvedAddrs stores @addrs and returns the sessions to be closed.
+func (c *client) updateResolvedAddrs(addrs []string) []Session {
+	c.Lock()
+	defer c.Unlock()
+
+	if c.ssMap == nil || slices.Equal(c.resolvedAddrs, addrs) {
+		return nil
+	}
+	c.resolvedAddrs = addrs
+
+	quota := (c.number + len(addrs) - 1) / len(addrs)
+	current := make(map[string][]Session, len(addrs))
+	for _, a := range addrs {
+		current[a] = nil
+	}
+	var closing []Session
+	for s := range c.ssMap {
+		if s.IsClosed() {
+			continue
+		}
+		ss, ok := current[s.RemoteAddr()]
+		if !ok || len(ss) >= quota {
+			closing = append(closing, s)
+			continue
+		}
+		current[s.RemoteAddr()] = append(ss, s)
+	}
+
+	return closing
+}
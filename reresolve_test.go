@@ -0,0 +1,71 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package getty
+
+import (
+	"net"
+	"testing"
+	"time"
+)
+
+import (
+	"github.com/stretchr/testify/assert"
+)
+
+func TestServerHostPort(t *testing.T) {
+	assert.Equal(t, "example.com:80", newClient(WS_CLIENT, WithServerAddress("ws://example.com/echo"), WithConnectionNumber(1)).serverHostPort())
+	assert.Equal(t, "example.com:443", newClient(WSS_CLIENT, WithServerAddress("wss://example.com/echo"), WithConnectionNumber(1)).serverHostPort())
+	assert.Equal(t, "example.com:8080", newClient(WS_CLIENT, WithServerAddress("ws://example.com:8080/echo"), WithConnectionNumber(1)).serverHostPort())
+	assert.Equal(t, "example.com:8080", newClient(TCP_CLIENT, WithServerAddress("example.com:8080"), WithConnectionNumber(1)).serverHostPort())
+}
+
+func TestClientReResolve(t *testing.T) {
+	s := NewTCPServer(WithLocalAddress("127.0.0.1:0")).(*server)
+	defer s.Close()
+	s.RunEventLoop(func(session Session) error {
+		session.SetPkgHandler(&stringPkgHandler{})
+		session.SetEventListener(newChanMessageHandler())
+		return nil
+	})
+	_, port, err := net.SplitHostPort(s.addr)
+	assert.Nil(t, err)
+
+	clt := NewTCPClient(WithServerAddress(net.JoinHostPort("localhost", port)), WithConnectionNumber(2),
+		WithReconnectInterval(1e7), WithReResolveInterval(time.Hour)).(*client)
+	defer clt.Close()
+	clientHandler := newChanMessageHandler()
+	clt.RunEventLoop(func(session Session) error {
+		session.SetPkgHandler(&stringPkgHandler{})
+		session.SetEventListener(clientHandler)
+		return nil
+	})
+	assert.Equal(t, 2, clt.sessionNum())
+	clt.Lock()
+	addrs := clt.resolvedAddrs
+	clt.Unlock()
+	assert.Contains(t, addrs, "127.0.0.1:"+port)
+	for _, ss := range clientHandler.array {
+		assert.Contains(t, addrs, ss.RemoteAddr())
+	}
+
+	// all of the sessions to the removed address should be closed
+	assert.Equal(t, 2, len(clt.updateResolvedAddrs([]string{"127.0.0.2:1"})))
+	// one session is above the fair share when a new address shows up
+	assert.Equal(t, 1, len(clt.updateResolvedAddrs(append([]string{"127.0.0.2:1"}, addrs...))))
+	assert.Nil(t, clt.updateResolvedAddrs(append([]string{"127.0.0.2:1"}, addrs...)))
+}
@@ -0,0 +1,223 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package getty
+
+import (
+	"context"
+	"net"
+	"slices"
+	"sort"
+	"sync"
+	"time"
+)
+
+import (
+	perrors "github.com/pkg/errors"
+)
+
+// Endpoint is a server address found by a Resolver.
+type Endpoint struct {
+	Addr string
+	// Metadata carries the attributes from the registry, eg: the zone or the weight.
+	Metadata map[string]string
+}
+
+// Resolver feeds the server addresses to a client, eg: from nacos, zookeeper or the k8s
+// endpoints, see WithResolver.
+type Resolver interface {
+	// Resolve returns the current endpoints.
+	Resolve() []Endpoint
+	// Watch returns the channel which receives the endpoints whenever they change, and it is
+	// closed when the resolver stops. The channel is consumed by a single client.
+	Watch() <-chan []Endpoint
+}
+
+// endpointAddrs returns the sorted addresses of @endpoints.
+func endpointAddrs(endpoints []Endpoint) []string {
+	addrs := make([]string, 0, len(endpoints))
+	for _, ep := range endpoints {
+		addrs = append(addrs, ep.Addr)
+	}
+	sort.Strings(addrs)
+	return slices.Compact(addrs)
+}
+
+// notifyEndpoints replaces the unconsumed endpoints of @ch with @endpoints, so the watcher
+// always gets the latest ones without blocking the resolver.
+func notifyEndpoints(ch chan []Endpoint, endpoints []Endpoint) {
+	for {
+		select {
+		case ch <- endpoints:
+			return
+		default:
+		}
+		select {
+		case <-ch:
+		default:
+		}
+	}
+}
+
+// StaticResolver is the Resolver of a fixed address list, which can be replaced by Update.
+type StaticResolver struct {
+	lock      sync.Mutex
+	endpoints []Endpoint
+	ch        chan []Endpoint
+}
+
+// NewStaticResolver returns the StaticResolver of @addrs.
+func NewStaticResolver(addrs ...string) *StaticResolver {
+	r := &StaticResolver{ch: make(chan []Endpoint, 1)}
+	r.endpoints = staticEndpoints(addrs)
+	return r
+}
+
+func staticEndpoints(addrs []string) []Endpoint {
+	endpoints := make([]Endpoint, 0, len(addrs))
+	for _, addr := range addrs {
+		endpoints = append(endpoints, Endpoint{Addr: addr})
+	}
+	return endpoints
+}
+
+func (r *StaticResolver) Resolve() []Endpoint {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	return r.endpoints
+}
+
+func (r *StaticResolver) Watch() <-chan []Endpoint {
+	return r.ch
+}
+
+// Update replaces the addresses and notifies the watcher.
+func (r *StaticResolver) Update(addrs ...string) {
+	endpoints := staticEndpoints(addrs)
+	r.lock.Lock()
+	r.endpoints = endpoints
+	r.lock.Unlock()
+	notifyEndpoints(r.ch, endpoints)
+}
+
+// DNSResolver is the Resolver which looks up the hostname of a host:port periodically, eg: a k8s
+// headless service.
+type DNSResolver struct {
+	hostPort string
+	interval time.Duration
+
+	lock      sync.Mutex
+	endpoints []Endpoint
+
+	once      sync.Once
+	closeOnce sync.Once
+	ch        chan []Endpoint
+	done      chan struct{}
+}
+
+// NewDNSResolver returns the DNSResolver of @hostPort which looks it up every @interval.
+func NewDNSResolver(hostPort string, interval time.Duration) *DNSResolver {
+	return &DNSResolver{
+		hostPort: hostPort,
+		interval: interval,
+		ch:       make(chan []Endpoint, 1),
+		done:     make(chan struct{}),
+	}
+}
+
+// Resolve looks up the host at the first call, and returns the latest endpoints then.
+func (r *DNSResolver) Resolve() []Endpoint {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	if r.endpoints == nil {
+		addrs, err := lookupHostPort(r.hostPort)
+		if err != nil {
+			log.Warnf("DNSResolver{%s} lookupHostPort() = error:%+v", r.hostPort, err)
+			return nil
+		}
+		r.endpoints = staticEndpoints(addrs)
+	}
+	return r.endpoints
+}
+
+// Watch starts looking up the host every interval, the former endpoints are kept if the
+// lookup fails.
+func (r *DNSResolver) Watch() <-chan []Endpoint {
+	r.once.Do(func() {
+		go r.run()
+	})
+	return r.ch
+}
+
+func (r *DNSResolver) run() {
+	defer close(r.ch)
+
+	ticker := time.NewTicker(r.interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-r.done:
+			return
+		case <-ticker.C:
+		}
+
+		addrs, err := lookupHostPort(r.hostPort)
+		if err != nil || len(addrs) == 0 {
+			log.Warnf("DNSResolver{%s} lookupHostPort() = error:%+v", r.hostPort, err)
+			continue
+		}
+		r.lock.Lock()
+		changed := !slices.Equal(endpointAddrs(r.endpoints), addrs)
+		if changed {
+			r.endpoints = staticEndpoints(addrs)
+		}
+		r.lock.Unlock()
+		if changed {
+			notifyEndpoints(r.ch, staticEndpoints(addrs))
+		}
+	}
+}
+
+// Close stops the periodic lookup.
+func (r *DNSResolver) Close() {
+	r.closeOnce.Do(func() {
+		close(r.done)
+	})
+}
+
+// lookupHostPort resolves the host of @hostPort into the sorted ip:port list.
+func lookupHostPort(hostPort string) ([]string, error) {
+	host, port, err := net.SplitHostPort(hostPort)
+	if err != nil {
+		return nil, perrors.WithStack(err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), connectTimeout)
+	defer cancel()
+	ips, err := net.DefaultResolver.LookupIPAddr(ctx, host)
+	if err != nil {
+		return nil, perrors.WithStack(err)
+	}
+	addrs := make([]string, 0, len(ips))
+	for _, ip := range ips {
+		addrs = append(addrs, net.JoinHostPort(ip.String(), port))
+	}
+	sort.Strings(addrs)
+
+	return addrs, nil
+}
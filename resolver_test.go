@@ -0,0 +1,88 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package getty
+
+import (
+	"testing"
+	"time"
+)
+
+import (
+	"github.com/stretchr/testify/assert"
+)
+
+func TestStaticResolver(t *testing.T) {
+	r := NewStaticResolver("b:1", "a:1")
+	assert.Equal(t, []string{"a:1", "b:1"}, endpointAddrs(r.Resolve()))
+
+	// the watcher gets the latest endpoints only
+	r.Update("c:1")
+	r.Update("d:1", "d:1")
+	assert.Equal(t, []string{"d:1"}, endpointAddrs(<-r.Watch()))
+	assert.Equal(t, []string{"d:1"}, endpointAddrs(r.Resolve()))
+	select {
+	case <-r.Watch():
+		t.Fatal("unexpected endpoints")
+	default:
+	}
+}
+
+func TestDNSResolver(t *testing.T) {
+	r := NewDNSResolver("localhost:80", 10*time.Millisecond)
+	assert.Contains(t, endpointAddrs(r.Resolve()), "127.0.0.1:80")
+	ch := r.Watch()
+	r.Close()
+	r.Close()
+	for range ch {
+	}
+}
+
+func TestClientResolver(t *testing.T) {
+	newServer := func() *server {
+		s := NewTCPServer(WithLocalAddress("127.0.0.1:0")).(*server)
+		s.RunEventLoop(func(session Session) error {
+			session.SetPkgHandler(&stringPkgHandler{})
+			session.SetEventListener(newChanMessageHandler())
+			return nil
+		})
+		return s
+	}
+	s1, s2 := newServer(), newServer()
+	defer s1.Close()
+	defer s2.Close()
+
+	r := NewStaticResolver(s1.addr)
+	clt := NewTCPClient(WithResolver(r), WithConnectionNumber(1), WithReconnectInterval(1e7)).(*client)
+	defer clt.Close()
+	clt.RunEventLoop(func(session Session) error {
+		session.SetPkgHandler(&stringPkgHandler{})
+		session.SetEventListener(newChanMessageHandler())
+		session.SetReadTimeout(50 * time.Millisecond)
+		return nil
+	})
+	ss, err := clt.PickSession("")
+	assert.Nil(t, err)
+	assert.Equal(t, s1.addr, ss.RemoteAddr())
+
+	// the session to the removed address is closed, and the client reconnects the new one
+	r.Update(s2.addr)
+	assert.Eventually(t, func() bool {
+		ss, err := clt.PickSession("")
+		return err == nil && ss.RemoteAddr() == s2.addr
+	}, 2*time.Second, 10*time.Millisecond)
+}
@@ -21,12 +21,15 @@ import (
 	"context"
 	"crypto/tls"
 	"crypto/x509"
+	"errors"
 	"fmt"
 	"io/ioutil"
 	"net"
 	"net/http"
+	"os"
 	"strings"
 	"sync"
+	"syscall"
 	"time"
 )
 
@@ -45,6 +48,9 @@ import (
 var (
 	errSelfConnect        = perrors.New("connect self!")
 	serverFastFailTimeout = time.Second * 1
+	drainCheckInterval    = 100 * time.Millisecond
+	tlsHandshakeTimeout   = 10 * time.Second
+	handshakeTimeout      = 5 * time.Second
 
 	serverID uatomic.Int32
 )
@@ -52,6 +58,22 @@ var (
 // Server interface
 type Server interface {
 	EndPoint
+	// GracefulClose stops accepting, notifies the sessions whose listener is a DrainListener,
+	// and closes every session once its in-flight packages are handled and the packages queued
+	// by WritePkgAsync are written. The sessions left at the deadline of @ctx are closed at once,
+	// and the error of @ctx is returned.
+	GracefulClose(ctx context.Context) error
+	// Sessions returns the snapshot of the active sessions of the server.
+	Sessions() []Session
+	// SessionCount returns the number of the active sessions.
+	SessionCount() int
+	// ForEachSession calls @fn for every active session, eg: to broadcast or to close some of them.
+	// @fn is called without any lock held, so it can close the session.
+	ForEachSession(fn func(Session))
+	// DumpStats returns the aggregate statistics, see also PublishStats.
+	DumpStats() ServerStats
+	// SetTrafficTap replaces the TrafficTap of WithTrafficTap, nil disables the tapping.
+	SetTrafficTap(tap TrafficTap)
 }
 
 // StreamServer is like tcp/websocket/wss server
@@ -59,6 +81,24 @@ type StreamServer interface {
 	Server
 	// Listener get the network listener
 	Listener() net.Listener
+	// SetIPAllowList replaces the ip allow list of WithIPAllowList, nil means allowing all.
+	SetIPAllowList(cidrs []string) error
+	// SetIPDenyList replaces the ip deny list of WithIPDenyList.
+	SetIPDenyList(cidrs []string) error
+	// SetMaxConnPerIP changes the limit of WithMaxConnPerIP. The established sessions are kept.
+	SetMaxConnPerIP(n int)
+	// SessionLimitHits returns how many times the new connections are blocked or rejected by
+	// WithMaxSessions or WithMaxSessionsPerListener.
+	SessionLimitHits() uint64
+}
+
+// WSServer is websocket server, which can also be mounted on an existing http server
+type WSServer interface {
+	StreamServer
+	// WSHandler returns the websocket upgrade handler which serves the sessions by @newSession,
+	// so that it can share the port, middleware and tls termination of an existing http server.
+	// RunEventLoop is not needed if the server is used only by its WSHandler.
+	WSHandler(newSession NewSessionCallback) http.Handler
 }
 
 // PacketServer is like udp listen endpoint
@@ -75,11 +115,25 @@ type server struct {
 	endPointID EndPointID
 
 	// net
-	pktListener    net.PacketConn
-	streamListener net.Listener
-	lock           sync.Mutex // for server
-	endPointType   EndPointType
-	server         *http.Server // for ws or wss server
+	pktListener     net.PacketConn   // the first one of pktListeners
+	pktListeners    []net.PacketConn // one for every local address of udp endpoint
+	streamListener  net.Listener     // accepts on all of streamListeners
+	streamListeners []net.Listener   // one accept loop for each of them
+	rawListeners    []net.Listener   // streamListeners without tls
+	bindAddr        string           // the configured local addresses
+	lock            sync.Mutex       // for server
+	endPointType    EndPointType
+	transport       Transport    // for the servers of NewTransportServer
+	server          *http.Server // for ws or wss server
+	ssLock          sync.Mutex
+	ssMap           map[Session]struct{} // the active sessions
+	ipFilter        *ipFilter
+	limitHits       uatomic.Uint64
+	pending         uatomic.Int32 // the admitted connections which are not served yet
+	accepted        uatomic.Uint64
+	listenerLimits  []*listenerLimit // one for every accept loop
+	acceptLimiter   *tokenBucket     // nil if no accept rate limit
+	trafficTapper
 	sync.Once
 	done chan struct{}
 	wg   sync.WaitGroup
@@ -99,6 +153,15 @@ func newServer(t EndPointType, opts ...ServerOption) *server {
 	}
 
 	s.init(opts...)
+	s.SetTrafficTap(s.trafficTap)
+	ipFilter, err := newIPFilter(s.ipAllowList, s.ipDenyList, s.maxConnPerIP)
+	if err != nil {
+		panic(fmt.Sprintf("illegal ip list: %+v", err))
+	}
+	s.ipFilter = ipFilter
+	if s.acceptRate > 0 {
+		s.acceptLimiter = newTokenBucket(s.acceptRate, s.acceptBurst)
+	}
 
 	return s
 }
@@ -115,12 +178,12 @@ func NewUDPEndPoint(opts ...ServerOption) Server {
 
 // NewWSServer builds a websocket server.
 func NewWSServer(opts ...ServerOption) Server {
-	return newServer(WS_SERVER, opts...)
+	return newServer(WS_SERVER, append([]ServerOption{WithServerWSCompression(DefaultWSCompressionOptions)}, opts...)...)
 }
 
 // NewWSSServer builds a secure websocket server.
 func NewWSSServer(opts ...ServerOption) Server {
-	s := newServer(WSS_SERVER, opts...)
+	s := newServer(WSS_SERVER, append([]ServerOption{WithServerWSCompression(DefaultWSCompressionOptions)}, opts...)...)
 
 	if s.addr == "" || s.cert == "" || s.privateKey == "" {
 		panic(fmt.Sprintf("@addr:%s, @cert:%s, @privateKey:%s, @caCert:%s",
@@ -130,6 +193,30 @@ func NewWSSServer(opts ...ServerOption) Server {
 	return s
 }
 
+// NewUDSServer builds a unix domain socket server which listens on @path.
+func NewUDSServer(path string, opts ...ServerOption) Server {
+	s := newServer(UDS_SERVER, opts...)
+	s.addr = path
+
+	if s.addr == "" {
+		panic("@path is empty")
+	}
+
+	return s
+}
+
+// NewTransportServer builds a server of type @t which accepts the connections of @transport,
+// eg: the quic, kcp and sctp servers of the modules under contrib/.
+func NewTransportServer(t EndPointType, transport Transport, opts ...ServerOption) Server {
+	if transport == nil {
+		panic(fmt.Sprintf("server type:%s, @transport is nil", t))
+	}
+	s := newServer(t, opts...)
+	s.transport = transport
+
+	return s
+}
+
 func (s *server) ID() int32 {
 	return s.endPointID
 }
@@ -149,9 +236,9 @@ func (s *server) stop() {
 			if s.server != nil {
 				ctx, cancel := context.WithTimeout(context.Background(), serverFastFailTimeout)
 				if err := s.server.Shutdown(ctx); err != nil {
-					// if the log output is "shutdown ctx: context deadline exceeded"， it means that
+					// if the error is "context deadline exceeded", it means that
 					// there are still some active connections.
-					log.Errorf("server shutdown ctx:%s error:%v", ctx, err)
+					endPointLogger(log, s).Errorw("shutdown failed", "err", err)
 				}
 				cancel()
 			}
@@ -162,10 +249,11 @@ func (s *server) stop() {
 				s.streamListener.Close()
 				s.streamListener = nil
 			}
-			if s.pktListener != nil {
-				s.pktListener.Close()
-				s.pktListener = nil
+			for _, pktListener := range s.pktListeners {
+				pktListener.Close()
 			}
+			s.pktListener = nil
+			s.pktListeners = nil
 		})
 	}
 }
@@ -183,158 +271,780 @@ func (s *server) IsClosed() bool {
 	}
 }
 
+func (s *server) addSession(ss Session) {
+	s.ssLock.Lock()
+	if s.ssMap == nil {
+		s.ssMap = make(map[Session]struct{})
+	}
+	s.ssMap[ss] = struct{}{}
+	s.ssLock.Unlock()
+	s.accepted.Inc()
+}
+
+func (s *server) removeSession(ss Session) {
+	s.ssLock.Lock()
+	_, ok := s.ssMap[ss]
+	delete(s.ssMap, ss)
+	s.ssLock.Unlock()
+	if ok {
+		s.releaseSession(ss)
+	}
+}
+
+// releaseSession releases the quotas of the ip filter and the session limits taken by @ss.
+func (s *server) releaseSession(ss Session) {
+	ip, ok := ss.GetAttribute(sessionFilterIPKey).(net.IP)
+	if !ok {
+		ip = addrIP(ss.RemoteAddr())
+	}
+	s.ipFilter.release(ip)
+	if limit, ok := ss.GetAttribute(sessionListenerKey).(*listenerLimit); ok {
+		limit.num.Dec()
+	}
+}
+
+func (s *server) SetIPAllowList(cidrs []string) error {
+	return s.ipFilter.setAllowList(cidrs)
+}
+
+func (s *server) SetIPDenyList(cidrs []string) error {
+	return s.ipFilter.setDenyList(cidrs)
+}
+
+func (s *server) SetMaxConnPerIP(n int) {
+	s.ipFilter.setMaxPerIP(n)
+}
+
+func (s *server) SessionCount() int {
+	s.ssLock.Lock()
+	defer s.ssLock.Unlock()
+
+	return len(s.ssMap)
+}
+
+func (s *server) Sessions() []Session {
+	s.ssLock.Lock()
+	defer s.ssLock.Unlock()
+
+	sessions := make([]Session, 0, len(s.ssMap))
+	for ss := range s.ssMap {
+		sessions = append(sessions, ss)
+	}
+
+	return sessions
+}
+
+func (s *server) ForEachSession(fn func(Session)) {
+	for _, ss := range s.Sessions() {
+		fn(ss)
+	}
+}
+
+// stopAccepting closes the listeners of the server. The accepted stream sessions keep working.
+func (s *server) stopAccepting() {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	if s.streamListener != nil {
+		s.streamListener.Close()
+	}
+	for _, pktListener := range s.pktListeners {
+		pktListener.Close()
+	}
+}
+
+// drain waits for the sessions to be closed until @timeout, and then closes the rest of them.
+func (s *server) drain(timeout time.Duration) {
+	deadline := time.Now().Add(timeout)
+	for s.SessionCount() > 0 && time.Now().Before(deadline) {
+		<-gxtime.After(drainCheckInterval)
+	}
+
+	for _, ss := range s.Sessions() {
+		ss.CloseWithReason(CloseReasonDrain)
+	}
+}
+
+func (s *server) GracefulClose(ctx context.Context) error {
+	s.stopAccepting()
+	publishEvent(LifecycleEvent{Type: DrainStarted, EndPoint: s})
+	for _, ss := range s.Sessions() {
+		if l, ok := ss.(*session).listener.(DrainListener); ok {
+			l.OnDrain(ss)
+		}
+	}
+
+	var err error
+	for {
+		busy := 0
+		for _, ss := range s.Sessions() {
+			if ss.IsClosed() {
+				continue
+			}
+			if (ss.(*session).handling.Load() > 0 || ss.(*session).writeQueue.pendingBytes() > 0) && err == nil {
+				busy++
+				continue
+			}
+			ss.CloseWithReason(CloseReasonDrain)
+		}
+		if busy == 0 {
+			break
+		}
+		select {
+		case <-ctx.Done():
+			err = ctx.Err()
+			endPointLogger(log, s).Warnw("close the busy sessions at the deadline", "busy", busy)
+		case <-gxtime.After(drainCheckInterval):
+		}
+	}
+	s.Close()
+
+	return err
+}
+
 // net.ipv4.tcp_max_syn_backlog
 // net.ipv4.tcp_timestamps
 // net.ipv4.tcp_tw_recycle
-func (s *server) listenTCP() error {
+func (s *server) listenTCP(addr string) (net.Listener, error) {
 	var (
 		err            error
 		streamListener net.Listener
 	)
 
-	if len(s.addr) == 0 || !strings.Contains(s.addr, ":") {
-		streamListener, err = gxnet.ListenOnTCPRandomPort(s.addr)
+	if s.reusePort > 0 || s.tcpFastOpen {
+		if !strings.Contains(addr, ":") {
+			addr = net.JoinHostPort(addr, "0")
+		}
+		lc := net.ListenConfig{Control: s.listenTCPControl}
+		streamListener, err = lc.Listen(context.Background(), "tcp", addr)
 		if err != nil {
-			return perrors.Wrapf(err, "gxnet.ListenOnTCPRandomPort(addr:%s)", s.addr)
+			return nil, perrors.Wrapf(err, "net.Listen(tcp, addr:%s, reuse port:%t, fast open:%t)",
+				addr, s.reusePort > 0, s.tcpFastOpen)
 		}
-	} else {
-		if s.sslEnabled {
-			if sslConfig, buildTlsConfErr := s.tlsConfigBuilder.BuildTlsConfig(); buildTlsConfErr == nil && sslConfig != nil {
-				streamListener, err = tls.Listen("tcp", s.addr, sslConfig)
-			}
-		} else {
-			streamListener, err = net.Listen("tcp", s.addr)
+	} else if len(addr) == 0 || !strings.Contains(addr, ":") {
+		streamListener, err = gxnet.ListenOnTCPRandomPort(addr)
+		if err != nil {
+			return nil, perrors.Wrapf(err, "gxnet.ListenOnTCPRandomPort(addr:%s)", addr)
 		}
+	} else {
+		streamListener, err = net.Listen("tcp", addr)
 		if err != nil {
-			return perrors.Wrapf(err, "net.Listen(tcp, addr:%s)", s.addr)
+			return nil, perrors.Wrapf(err, "net.Listen(tcp, addr:%s)", addr)
 		}
 	}
 
-	s.streamListener = streamListener
-	s.addr = s.streamListener.Addr().String()
+	return streamListener, nil
+}
+
+// listenTCPControl sets SO_REUSEPORT and TCP_FASTOPEN of the tcp listener before it is bound.
+func (s *server) listenTCPControl(network, address string, c syscall.RawConn) error {
+	if s.reusePort > 0 {
+		if err := reusePortControl(network, address, c); err != nil {
+			return err
+		}
+	}
+	if s.tcpFastOpen {
+		return fastOpenListenControl(network, address, c)
+	}
 
 	return nil
 }
 
-func (s *server) listenUDP() error {
+func (s *server) listenUDP(addr string) (net.PacketConn, error) {
 	var (
 		err         error
 		localAddr   *net.UDPAddr
 		pktListener *net.UDPConn
 	)
 
-	if len(s.addr) == 0 || !strings.Contains(s.addr, ":") {
-		pktListener, err = gxnet.ListenOnUDPRandomPort(s.addr)
+	if s.reusePort > 0 {
+		if !strings.Contains(addr, ":") {
+			addr = net.JoinHostPort(addr, "0")
+		}
+		lc := net.ListenConfig{Control: reusePortControl}
+		pktConn, err := lc.ListenPacket(context.Background(), "udp", addr)
 		if err != nil {
-			return perrors.Wrapf(err, "gxnet.ListenOnUDPRandomPort(addr:%s)", s.addr)
+			return nil, perrors.Wrapf(err, "net.ListenPacket(udp, addr:%s, SO_REUSEPORT)", addr)
+		}
+		return pktConn, nil
+	}
+	if len(addr) == 0 || !strings.Contains(addr, ":") {
+		pktListener, err = gxnet.ListenOnUDPRandomPort(addr)
+		if err != nil {
+			return nil, perrors.Wrapf(err, "gxnet.ListenOnUDPRandomPort(addr:%s)", addr)
 		}
 	} else {
-		localAddr, err = net.ResolveUDPAddr("udp", s.addr)
+		localAddr, err = net.ResolveUDPAddr("udp", addr)
 		if err != nil {
-			return perrors.Wrapf(err, "net.ResolveUDPAddr(udp, addr:%s)", s.addr)
+			return nil, perrors.Wrapf(err, "net.ResolveUDPAddr(udp, addr:%s)", addr)
 		}
 		pktListener, err = net.ListenUDP("udp", localAddr)
 		if err != nil {
-			return perrors.Wrapf(err, "net.ListenUDP((udp, localAddr:%#v)", localAddr)
+			return nil, perrors.Wrapf(err, "net.ListenUDP((udp, localAddr:%#v)", localAddr)
 		}
 	}
 
-	s.pktListener = pktListener
-	s.addr = s.pktListener.LocalAddr().String()
+	return pktListener, nil
+}
 
-	return nil
+func (s *server) listenUDS(path string) (net.Listener, error) {
+	var (
+		err            error
+		streamListener net.Listener
+	)
+
+	// remove the socket file left by the last process
+	if fi, statErr := os.Stat(path); statErr == nil && fi.Mode()&os.ModeSocket != 0 {
+		if err = os.Remove(path); err != nil {
+			return nil, perrors.Wrapf(err, "os.Remove(path:%s)", path)
+		}
+	}
+	streamListener, err = net.Listen("unix", path)
+	if err != nil {
+		return nil, perrors.Wrapf(err, "net.Listen(unix, path:%s)", path)
+	}
+
+	return streamListener, nil
 }
 
-// Listen announces on the local network address.
-func (s *server) listen() error {
+// listenTransport returns a listener of the connections of s.transport, whose connect pings
+// are read in their own goroutines, so that a silent peer does not block the accepting.
+func (s *server) listenTransport(addr string) (net.Listener, error) {
+	var (
+		err       error
+		sslConfig *tls.Config
+		listener  net.Listener
+	)
+
+	if s.tlsConfigBuilder != nil {
+		if sslConfig, err = s.buildTlsConfig(); err != nil {
+			return nil, perrors.WithStack(err)
+		}
+	}
+	listener, err = s.transport.Listen(addr, sslConfig)
+	if err != nil {
+		return nil, perrors.Wrapf(err, "%s listen(addr:%s)", s.endPointType, addr)
+	}
+
+	return newHandshakeListener(listener, nil, readConnectPing), nil
+}
+
+func (s *server) listenStream(addr string) (net.Listener, error) {
+	if s.transport != nil {
+		return s.listenTransport(addr)
+	}
 	switch s.endPointType {
 	case TCP_SERVER, WS_SERVER, WSS_SERVER:
-		return perrors.WithStack(s.listenTCP())
-	case UDP_ENDPOINT:
-		return perrors.WithStack(s.listenUDP())
+		return s.listenTCP(addr)
+	case UDS_SERVER:
+		return s.listenUDS(addr)
 	}
 
-	return nil
+	return nil, perrors.Errorf("illegal stream server type %s", s.endPointType)
 }
 
-func (s *server) accept(newSession NewSessionCallback) (Session, error) {
-	conn, err := s.streamListener.Accept()
+// reusePortNum returns the number of listeners opened for every local address.
+func (s *server) reusePortNum() int {
+	switch s.endPointType {
+	case TCP_SERVER, WS_SERVER, WSS_SERVER, UDP_ENDPOINT:
+		if s.reusePort > 1 {
+			return s.reusePort
+		}
+	}
+
+	return 1
+}
+
+// upgradeKey identifies the listeners of the server on upgrade.
+func (s *server) upgradeKey() string {
+	return s.endPointType.String() + "|" + s.bindAddr
+}
+
+// inheritedListeners returns the listeners handed over by the old process on upgrade,
+// or the listeners given by WithListener and WithSystemdListeners.
+func (s *server) inheritedListeners() ([]net.Listener, error) {
+	var (
+		err   error
+		files []*os.File
+	)
+
+	listeners := append([]net.Listener{}, s.listeners...)
+	if files = upgradeListenFiles(s.upgradeKey()); len(files) != 0 {
+		listeners = listeners[:0]
+	} else if s.systemdFds {
+		if files, err = systemdListenFiles(s.systemdFdNames); err != nil {
+			return nil, perrors.WithStack(err)
+		}
+	}
+	if len(files) != 0 {
+		for _, f := range files {
+			l, err := net.FileListener(f)
+			if err != nil {
+				return nil, perrors.Wrapf(err, "net.FileListener(%s)", f.Name())
+			}
+			listeners = append(listeners, l)
+		}
+	}
+	if len(listeners) == 0 {
+		return nil, nil
+	}
+
+	switch s.endPointType {
+	case TCP_SERVER, WS_SERVER, WSS_SERVER, UDS_SERVER:
+	default:
+		return nil, perrors.Errorf("%s can not accept on inherited listeners", s.endPointType)
+	}
+
+	return listeners, nil
+}
+
+// proxyListeners wraps @listeners to read the PROXY headers if the PROXY protocol is enabled.
+func (s *server) proxyListeners(listeners []net.Listener) []net.Listener {
+	switch s.endPointType {
+	case TCP_SERVER, WS_SERVER, WSS_SERVER, UDS_SERVER:
+		if !s.proxyProtocol {
+			return listeners
+		}
+	default:
+		return listeners
+	}
+
+	proxyListeners := make([]net.Listener, 0, len(listeners))
+	for i, l := range listeners {
+		admitter := s.admitterOf(l, i, true)
+		handshake := readProxyConn
+		if admitter != nil {
+			handshake = s.readProxyConn
+		}
+		proxyListeners = append(proxyListeners, newHandshakeListener(l, admitter, handshake))
+	}
+
+	return proxyListeners
+}
+
+// tlsListeners wraps @listeners with tls if ssl is enabled.
+func (s *server) tlsListeners(listeners []net.Listener) ([]net.Listener, error) {
+	switch s.endPointType {
+	case TCP_SERVER, WS_SERVER, WSS_SERVER, UDS_SERVER:
+		if !s.sslEnabled {
+			return listeners, nil
+		}
+	default:
+		return listeners, nil
+	}
+
+	sslConfig, err := s.buildTlsConfig()
 	if err != nil {
 		return nil, perrors.WithStack(err)
 	}
+	handshake := func(conn net.Conn) (net.Conn, error) {
+		tlsConn := tls.Server(conn, sslConfig)
+		ctx, cancel := context.WithTimeout(context.Background(), tlsHandshakeTimeout)
+		defer cancel()
+		if err := tlsConn.HandshakeContext(ctx); err != nil {
+			if ctx.Err() != nil {
+				return nil, perrors.Wrapf(ErrHandshakeTimeout, "tls handshake: %v", err)
+			}
+			return nil, perrors.WithStack(err)
+		}
+		return tlsConn, nil
+	}
+	tlsListeners := make([]net.Listener, 0, len(listeners))
+	for i, l := range listeners {
+		// the handshake is done before accepting, so that the tls state is available in NewSessionCallback
+		tlsListeners = append(tlsListeners, newHandshakeListener(l, s.admitterOf(l, i, false), handshake))
+	}
+
+	return tlsListeners, nil
+}
+
+// buildTlsConfig builds the tls config by the TlsConfigBuilder, and applies WithClientAuth.
+func (s *server) buildTlsConfig() (*tls.Config, error) {
+	sslConfig, err := s.tlsConfigBuilder.BuildTlsConfig()
+	if err != nil {
+		return nil, perrors.Wrapf(err, "BuildTlsConfig()")
+	}
+	if s.clientAuthSet {
+		sslConfig.ClientAuth = s.clientAuth
+	}
+
+	return sslConfig, nil
+}
+
+// inheritedPacketConns returns the udp sockets handed over by the old process on upgrade,
+// or the udp sockets given by WithSystemdListeners.
+func (s *server) inheritedPacketConns() ([]net.PacketConn, error) {
+	var (
+		err   error
+		files []*os.File
+	)
+
+	if len(s.listeners) != 0 {
+		return nil, perrors.Errorf("%s can not accept on inherited listeners", s.endPointType)
+	}
+	if files = upgradeListenFiles(s.upgradeKey()); len(files) == 0 {
+		if !s.systemdFds {
+			return nil, nil
+		}
+		if files, err = systemdListenFiles(s.systemdFdNames); err != nil {
+			return nil, perrors.WithStack(err)
+		}
+	}
+	pktConns := make([]net.PacketConn, 0, len(files))
+	for _, f := range files {
+		pktConn, err := net.FilePacketConn(f)
+		if err != nil {
+			return nil, perrors.Wrapf(err, "net.FilePacketConn(%s)", f.Name())
+		}
+		pktConns = append(pktConns, pktConn)
+	}
+
+	return pktConns, nil
+}
+
+// Listen announces on all of the local network addresses, or uses the inherited listeners.
+func (s *server) listen() error {
+	s.bindAddr = s.addr
+	addrs := splitAddrs(s.addr)
+
+	if s.endPointType == UDP_ENDPOINT {
+		pktConns, err := s.inheritedPacketConns()
+		if err != nil {
+			return perrors.WithStack(err)
+		}
+		if len(pktConns) != 0 {
+			addrs = nil
+			s.pktListeners = pktConns
+		}
+		for _, addr := range addrs {
+			for i := 0; i < s.reusePortNum(); i++ {
+				pktListener, err := s.listenUDP(addr)
+				if err != nil {
+					for _, l := range s.pktListeners {
+						l.Close()
+					}
+					s.pktListeners = nil
+					return perrors.WithStack(err)
+				}
+				s.pktListeners = append(s.pktListeners, pktListener)
+				// the other sockets bind the same port as the first one
+				addr = pktListener.LocalAddr().String()
+			}
+		}
+		for _, pktListener := range s.pktListeners {
+			if err = setupUDPConn(pktListener, s.multicastGroups, s.broadcast); err != nil {
+				for _, l := range s.pktListeners {
+					l.Close()
+				}
+				s.pktListeners = nil
+				return perrors.WithStack(err)
+			}
+		}
+		s.pktListener = s.pktListeners[0]
+		s.addr = joinAddrs(s.pktListeners, func(l net.PacketConn) net.Addr { return l.LocalAddr() })
+
+		return nil
+	}
+
+	listeners, err := s.inheritedListeners()
+	if err != nil {
+		return perrors.WithStack(err)
+	}
+	if len(listeners) != 0 {
+		addrs = nil
+	}
+	for _, addr := range addrs {
+		for i := 0; i < s.reusePortNum(); i++ {
+			streamListener, err := s.listenStream(addr)
+			if err != nil {
+				for _, l := range listeners {
+					l.Close()
+				}
+				return perrors.WithStack(err)
+			}
+			listeners = append(listeners, streamListener)
+			// the other listeners bind the same port as the first one
+			addr = streamListener.Addr().String()
+		}
+	}
+	s.rawListeners = listeners
+	s.lock.Lock()
+	for _, l := range listeners {
+		s.listenerLimits = append(s.listenerLimits, &listenerLimit{addr: l.Addr().String()})
+	}
+	s.lock.Unlock()
+	listeners = s.proxyListeners(listeners)
+	if listeners, err = s.tlsListeners(listeners); err != nil {
+		for _, l := range s.rawListeners {
+			l.Close()
+		}
+		return perrors.WithStack(err)
+	}
+	s.streamListeners = listeners
+	if len(listeners) == 1 {
+		s.streamListener = listeners[0]
+	} else {
+		s.streamListener = newMultiListener(listeners)
+	}
+	s.addr = joinAddrs(listeners, func(l net.Listener) net.Addr { return l.Addr() })
+
+	return nil
+}
+
+// admit checks the raw connection @conn of the listener of @limit by the session limits and
+// the ip filter, and takes a quota of the ip filter which is released by releaseSession. The
+// admitted connection counts as a session until open serves it or unadmit undoes admit.
+func (s *server) admit(conn net.Conn, limit *listenerLimit, ip net.IP) error {
 	if gxnet.IsSameAddr(conn.RemoteAddr(), conn.LocalAddr()) {
-		log.Warnf("conn.localAddr{%s} == conn.RemoteAddr", conn.LocalAddr().String(), conn.RemoteAddr().String())
-		return nil, perrors.WithStack(errSelfConnect)
+		endPointLogger(log, s).Warnw("reject the self connection", "addr", conn.LocalAddr())
+		return perrors.WithStack(errSelfConnect)
+	}
+	if s.rejectForLimit(limit) {
+		return perrors.Errorf("session number reaches the limit, reject client{%s}", conn.RemoteAddr())
+	}
+	if err := s.ipFilter.acquire(ip); err != nil {
+		return perrors.WithStack(err)
+	}
+	s.pending.Inc()
+	if limit != nil {
+		limit.pending.Inc()
+	}
+
+	return nil
+}
+
+// unadmit undoes admit of a connection from @ip of the listener of @limit, which is not served.
+func (s *server) unadmit(limit *listenerLimit, ip net.IP) {
+	s.ipFilter.release(ip)
+	s.pending.Dec()
+	if limit != nil {
+		limit.pending.Dec()
 	}
+}
 
-	ss := newTCPSession(conn, s)
+// admitterOf returns the connAdmitter of the raw listener @l, whose index in s.rawListeners is @i.
+// It returns nil if @l screens its connections already, or the server is a websocket server,
+// which screens the upgrade requests instead. The ip filter of a @proxied listener is left to
+// readProxyConn, which knows the source address of the PROXY header.
+func (s *server) admitterOf(l net.Listener, i int, proxied bool) connAdmitter {
+	if admits(l) {
+		return nil
+	}
+	switch s.endPointType {
+	case WS_SERVER, WSS_SERVER:
+		return nil
+	}
+
+	return &listenerAdmitter{server: s, limit: s.listenerLimits[i], proxied: proxied}
+}
+
+// readProxyConn reads the PROXY header of @conn, and then takes a quota of the ip filter for
+// the source address in the header instead of the address of the proxy.
+func (s *server) readProxyConn(conn net.Conn) (net.Conn, error) {
+	proxied, err := readProxyConn(conn)
+	if err != nil {
+		return nil, err
+	}
+	if err = s.ipFilter.acquire(filterIP(proxied)); err != nil {
+		return nil, perrors.WithStack(err)
+	}
+
+	return proxied, nil
+}
+
+// listenerAdmitter screens the raw connections of a handshakeListener as runTCPEventLoop does
+// for the plain listeners, so that the limits are enforced before the handshakes.
+type listenerAdmitter struct {
+	server  *server
+	limit   *listenerLimit
+	proxied bool // the ip filter is applied by readProxyConn
+}
+
+func (a *listenerAdmitter) wait() {
+	a.server.waitForLimit(a.limit)
+	if a.server.acceptLimiter != nil {
+		a.server.acceptLimiter.wait(a.server.done)
+	}
+}
+
+func (a *listenerAdmitter) admit(conn net.Conn) error {
+	return a.server.admit(conn, a.limit, a.ipOf(conn))
+}
+
+func (a *listenerAdmitter) abort(conn net.Conn) {
+	a.server.unadmit(a.limit, a.ipOf(conn))
+}
+
+// ipOf returns the ip of @conn which takes a quota of the ip filter, or nil if the PROXY
+// header of @conn has not been read.
+func (a *listenerAdmitter) ipOf(conn net.Conn) net.IP {
+	if _, ok := conn.(*proxyConn); a.proxied && !ok {
+		return nil
+	}
+
+	return filterIP(conn)
+}
+
+// accept accepts a new session from @listener. The connection has been checked by admit
+// before its handshake if @listener admits its connections.
+func (s *server) accept(listener net.Listener, limit *listenerLimit, newSession NewSessionCallback) (Session, error) {
+	conn, err := listener.Accept()
+	if err != nil {
+		return nil, perrors.WithStack(err)
+	}
+	ip := filterIP(conn)
+	if !admits(listener) {
+		if err = s.admit(conn, limit, ip); err != nil {
+			conn.Close()
+			return nil, err
+		}
+	}
+
+	ss := newTCPSession(s.chaos.Wrap(conn), s)
+	switch {
+	case s.transport != nil:
+		ss.SetName(transportSessionName(s.endPointType))
+	case s.endPointType == UDS_SERVER:
+		ss.SetName(defaultUDSSessionName)
+	}
 	err = newSession(ss)
 	if err != nil {
 		conn.Close()
+		s.unadmit(limit, ip)
 		return nil, perrors.WithStack(err)
 	}
+	ss.SetAttribute(sessionFilterIPKey, ip)
+	if limit != nil {
+		limit.pending.Dec()
+		limit.num.Inc()
+		limit.accepted.Inc()
+		ss.SetAttribute(sessionListenerKey, limit)
+	}
 
 	return ss, nil
 }
 
-func (s *server) runTCPEventLoop(newSession NewSessionCallback) {
-	s.wg.Add(1)
+// open starts serving the accepted session @ss. The handshake of WithHandshake runs in its own
+// goroutine to not block the accepting, and @ss is served only if the handshake succeeds. @ss
+// does not count as an admitted connection after it is served or its handshake fails.
+func (s *server) open(ss Session) {
+	if s.lingerSet {
+		ss.(*session).setLinger(s.linger)
+	}
+	if err := s.socketOptions.apply(ss.Conn()); err != nil {
+		log.with(ss.Logger()).Warnw("set socket options failed", "err", err)
+	}
+	if s.handshake == nil {
+		s.addSession(ss)
+		s.pending.Dec()
+		ss.(*session).run()
+		return
+	}
+
 	go func() {
-		defer s.wg.Done()
-		var (
-			err    error
-			client Session
-			delay  time.Duration
-		)
-		for {
-			if s.IsClosed() {
-				log.Infof("server{%s} stop accepting client connect request.", s.addr)
-				return
-			}
-			if delay != 0 {
-				<-gxtime.After(delay)
-			}
-			client, err = s.accept(newSession)
-			log.Info("accept")
-			if err != nil {
-				if netErr, ok := perrors.Cause(err).(net.Error); ok && netErr.Temporary() {
-					if delay == 0 {
-						delay = 5 * time.Millisecond
-					} else {
-						delay *= 2
+		timeout := s.handshakeTimeout
+		if timeout <= 0 {
+			timeout = handshakeTimeout
+		}
+		conn := ss.Conn()
+		conn.SetDeadline(time.Now().Add(timeout))
+		err := s.handshake(ss)
+		conn.SetDeadline(time.Time{})
+		var netErr net.Error
+		if errors.As(err, &netErr) && netErr.Timeout() {
+			err = perrors.Wrapf(ErrHandshakeTimeout, "%v", err)
+		}
+		if err == nil && s.IsClosed() {
+			err = perrors.New("server is closed")
+		}
+		if err != nil {
+			log.with(ss.Logger()).Warnw("handshake failed", "err", err)
+			s.releaseSession(ss)
+			s.pending.Dec()
+			ss.Close()
+			ss.(*session).gc()
+			return
+		}
+		s.addSession(ss)
+		s.pending.Dec()
+		ss.(*session).run()
+	}()
+}
+
+// runTCPEventLoop runs an accept loop for every stream listener.
+func (s *server) runTCPEventLoop(newSession NewSessionCallback) {
+	for i, listener := range s.streamListeners {
+		s.lock.Lock()
+		limit := s.listenerLimits[i]
+		s.lock.Unlock()
+		s.wg.Add(1)
+		go func(listener net.Listener) {
+			defer s.wg.Done()
+			var (
+				err    error
+				client Session
+				delay  time.Duration
+			)
+			for {
+				if s.IsClosed() {
+					endPointLogger(log, s).Infow("stop accepting")
+					return
+				}
+				if delay != 0 {
+					<-gxtime.After(delay)
+				}
+				// the handshakeListener which admits its connections waits for the limits itself
+				if !admits(listener) {
+					s.waitForLimit(limit)
+					if s.acceptLimiter != nil {
+						s.acceptLimiter.wait(s.done)
 					}
-					if max := 1 * time.Second; delay > max {
-						delay = max
+				}
+				client, err = s.accept(listener, limit, newSession)
+				endPointLogger(log, s).Infow("accept")
+				if err != nil {
+					if netErr, ok := perrors.Cause(err).(net.Error); ok && netErr.Temporary() {
+						delay = acceptBackoff(delay)
+						endPointLogger(log, s).Warnw("accept failed transiently", "err", err, "retry_in", delay)
+						continue
+					}
+					if errors.Is(err, net.ErrClosed) {
+						endPointLogger(log, s).Infow("listener is closed", "listener", listener.Addr())
+						return
 					}
+					endPointLogger(log, s).Warnw("accept failed", "err", err)
 					continue
 				}
-				log.Warnf("server{%s}.Accept() = err {%+v}", s.addr, perrors.WithStack(err))
-				continue
+				delay = 0
+				s.open(client)
 			}
-			delay = 0
-			client.(*session).run()
-		}
-	}()
+		}(listener)
+	}
 }
 
 func (s *server) runUDPEventLoop(newSession NewSessionCallback) {
-	s.wg.Add(1)
-	go func() {
-		defer s.wg.Done()
-		var (
-			err  error
-			conn *net.UDPConn
-			ss   Session
-		)
-
-		conn = s.pktListener.(*net.UDPConn)
-		ss = newUDPSession(conn, s)
-		if err = newSession(ss); err != nil {
-			conn.Close()
-			panic(err.Error())
+	for _, pktListener := range s.pktListeners {
+		s.wg.Add(1)
+		if s.udpPeerSession {
+			go func(conn *net.UDPConn) {
+				defer s.wg.Done()
+				newUDPDemux(s, conn, newSession, s.udpPeerIdleTimeout).run()
+			}(pktListener.(*net.UDPConn))
+			continue
 		}
-		ss.(*session).run()
-	}()
+
+		go func(conn *net.UDPConn) {
+			defer s.wg.Done()
+
+			ss := newUDPSession(conn, s)
+			if err := newSession(ss); err != nil {
+				conn.Close()
+				panic(err.Error())
+			}
+			s.addSession(ss)
+			ss.(*session).run()
+		}(pktListener.(*net.UDPConn))
+	}
 }
 
 type wsHandler struct {
@@ -352,7 +1062,8 @@ func newWSHandler(server *server, newSession NewSessionCallback) *wsHandler {
 			// in default, ReadBufferSize & WriteBufferSize is 4k
 			// HandshakeTimeout: server.HTTPTimeout,
 			CheckOrigin:       func(_ *http.Request) bool { return true }, // allow connections from any origin
-			EnableCompression: true,
+			EnableCompression: server.wsCompression.Enabled,
+			Subprotocols:      server.wsSubprotocols,
 		},
 	}
 }
@@ -366,31 +1077,55 @@ func (s *wsHandler) serveWSRequest(w http.ResponseWriter, r *http.Request) {
 
 	if s.server.IsClosed() {
 		http.Error(w, "HTTP server is closed(code:500-11).", 500)
-		log.Warnf("server{%s} stop acceptting client connect request.", s.server.addr)
+		endPointLogger(log, s.server).Warnw("reject the connection of the closed server", "peer", r.RemoteAddr)
 		return
 	}
 
-	conn, err := s.upgrader.Upgrade(w, r, nil)
+	if s.server.reachLimit(nil) {
+		s.server.onLimit()
+		http.Error(w, "Too many sessions", http.StatusServiceUnavailable)
+		return
+	}
+	ip := addrIP(r.RemoteAddr)
+	if err := s.server.ipFilter.acquire(ip); err != nil {
+		http.Error(w, "Forbidden", http.StatusForbidden)
+		endPointLogger(log, s.server).Warnw("reject the connection", "peer", r.RemoteAddr, "err", err)
+		return
+	}
+	s.server.pending.Inc()
+	conn, err := s.upgrader.Upgrade(w, r, s.server.wsHeader)
 	if err != nil {
-		log.Warnf("upgrader.Upgrader(http.Request{%#v}) = error:%+v", r, err)
+		s.server.unadmit(nil, ip)
+		endPointLogger(log, s.server).Warnw("websocket upgrade failed", "peer", r.RemoteAddr, "err", err)
 		return
 	}
 	if conn.RemoteAddr().String() == conn.LocalAddr().String() {
-		log.Warnf("conn.localAddr{%s} == conn.RemoteAddr", conn.LocalAddr().String(), conn.RemoteAddr().String())
+		s.server.unadmit(nil, ip)
+		endPointLogger(log, s.server).Warnw("reject the self connection", "addr", conn.LocalAddr())
 		return
 	}
 	// conn.SetReadLimit(int64(handler.maxMsgLen))
 	ss := newWSSession(conn, s.server)
+	s.server.wsCompression.apply(conn)
 	err = s.newSession(ss)
 	if err != nil {
 		conn.Close()
-		log.Warnf("server{%s}.newSession(ss{%#v}) = err {%s}", s.server.addr, ss, err)
+		s.server.unadmit(nil, ip)
+		log.with(ss.Logger()).Warnw("newSession failed", "err", err)
 		return
 	}
 	if ss.(*session).maxMsgLen > 0 {
 		conn.SetReadLimit(int64(ss.(*session).maxMsgLen))
 	}
-	ss.(*session).run()
+	s.server.open(ss)
+}
+
+func (s *server) WSHandler(newSession NewSessionCallback) http.Handler {
+	if s.endPointType != WS_SERVER && s.endPointType != WSS_SERVER {
+		panic(fmt.Sprintf("illegal websocket server type %s", s.endPointType.String()))
+	}
+
+	return http.HandlerFunc(newWSHandler(s, newSession).serveWSRequest)
 }
 
 // runWSEventLoop serve websocket client request
@@ -406,6 +1141,9 @@ func (s *server) runWSEventLoop(newSession NewSessionCallback) {
 		)
 		handler = newWSHandler(s, newSession)
 		handler.HandleFunc(s.path, handler.serveWSRequest)
+		for _, route := range s.wsRoutes {
+			handler.HandleFunc(route.path, newWSHandler(s, route.newSession).serveWSRequest)
+		}
 		server = &http.Server{
 			Addr:    s.addr,
 			Handler: handler,
@@ -417,7 +1155,7 @@ func (s *server) runWSEventLoop(newSession NewSessionCallback) {
 		s.lock.Unlock()
 		err = server.Serve(s.streamListener)
 		if err != nil {
-			log.Errorf("http.server.Serve(addr{%s}) = err:%+v", s.addr, perrors.WithStack(err))
+			endPointLogger(log, s).Errorw("http server Serve failed", "err", err)
 		}
 	}()
 }
@@ -462,9 +1200,15 @@ func (s *server) runWSSEventLoop(newSession NewSessionCallback) {
 			config.ClientAuth = tls.RequireAndVerifyClientCert
 			config.InsecureSkipVerify = false
 		}
+		if s.clientAuthSet {
+			config.ClientAuth = s.clientAuth
+		}
 
 		handler = newWSHandler(s, newSession)
 		handler.HandleFunc(s.path, handler.serveWSRequest)
+		for _, route := range s.wsRoutes {
+			handler.HandleFunc(route.path, newWSHandler(s, route.newSession).serveWSRequest)
+		}
 		server = &http.Server{
 			Addr:    s.addr,
 			Handler: handler,
@@ -477,7 +1221,7 @@ func (s *server) runWSSEventLoop(newSession NewSessionCallback) {
 		s.lock.Unlock()
 		err = server.Serve(tls.NewListener(s.streamListener, config))
 		if err != nil {
-			log.Errorf("http.server.Serve(addr{%s}) = err:%+v", s.addr, perrors.WithStack(err))
+			endPointLogger(log, s).Errorw("http server Serve failed", "err", err)
 			panic(err)
 		}
 	}()
@@ -490,8 +1234,12 @@ func (s *server) RunEventLoop(newSession NewSessionCallback) {
 		panic(fmt.Errorf("server.listen() = error:%+v", perrors.WithStack(err)))
 	}
 
+	if s.transport != nil {
+		s.runTCPEventLoop(newSession)
+		return
+	}
 	switch s.endPointType {
-	case TCP_SERVER:
+	case TCP_SERVER, UDS_SERVER:
 		s.runTCPEventLoop(newSession)
 	case UDP_ENDPOINT:
 		s.runUDPEventLoop(newSession)
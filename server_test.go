@@ -18,7 +18,15 @@
 package getty
 
 import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"errors"
+	"io"
+	"net"
+	"os"
 	"path/filepath"
+	"strings"
 	"testing"
 	"time"
 )
@@ -143,6 +151,51 @@ func testTCPTlsServer(t *testing.T, address string) {
 	assert.True(t, server.IsClosed())
 }
 
+func testUDSServer(t *testing.T, path string) {
+	var (
+		server           *server
+		serverMsgHandler MessageHandler
+	)
+
+	func() {
+		server = newServer(UDS_SERVER, WithLocalAddress(path))
+		newServerSession := func(session Session) error {
+			return newSessionCallback(session, &serverMsgHandler)
+		}
+		server.RunEventLoop(newServerSession)
+		assert.True(t, server.EndPointType() == UDS_SERVER)
+		assert.NotNil(t, server.streamListener)
+	}()
+	time.Sleep(500e6)
+
+	clt := NewUDSClient(
+		WithUnixAddress(path),
+		WithReconnectInterval(5e8),
+		WithConnectionNumber(1),
+	).(*client)
+	assert.Equal(t, clt.endPointType, UDS_CLIENT)
+
+	var msgHandler MessageHandler
+	cb := func(session Session) error {
+		return newSessionCallback(session, &msgHandler)
+	}
+
+	clt.RunEventLoop(cb)
+	time.Sleep(1e9)
+
+	assert.Equal(t, 1, msgHandler.SessionNumber())
+	assert.Equal(t, 1, serverMsgHandler.SessionNumber())
+	l, err := msgHandler.array[0].WriteBytes([]byte("hello"))
+	assert.Nil(t, err)
+	assert.Equal(t, 5, l)
+
+	clt.Close()
+	assert.True(t, clt.IsClosed())
+
+	server.Close()
+	assert.True(t, server.IsClosed())
+}
+
 func testUDPServer(t *testing.T, address string) {
 	var (
 		server           *server
@@ -182,4 +235,240 @@ func TestServer(t *testing.T) {
 	testUDPServer(t, addr)
 	addr = "127.0.0.9999"
 	testTCPTlsServer(t, addr)
+
+	testUDSServer(t, filepath.Join(t.TempDir(), "getty.sock"))
+}
+
+func TestServerHandshake(t *testing.T) {
+	s := NewTCPServer(
+		WithLocalAddress("127.0.0.1:0"),
+		WithHandshake(func(session Session) error {
+			token := make([]byte, 4)
+			if _, err := io.ReadFull(session.Conn(), token); err != nil {
+				return err
+			}
+			if !bytes.Equal(token, []byte("good")) {
+				return errors.New("bad token")
+			}
+			_, err := session.Conn().Write([]byte("ok"))
+			return err
+		}),
+		WithHandshakeTimeout(100*time.Millisecond),
+	).(*server)
+	defer s.Close()
+	serverHandler := newChanMessageHandler()
+	s.RunEventLoop(func(session Session) error {
+		session.SetPkgHandler(&stringPkgHandler{})
+		session.SetEventListener(serverHandler)
+		return nil
+	})
+
+	dial := func(token string) net.Conn {
+		conn, err := net.Dial("tcp", s.addr)
+		assert.Nil(t, err)
+		if token != "" {
+			_, err = conn.Write([]byte(token))
+			assert.Nil(t, err)
+		}
+		return conn
+	}
+	// the server closes the connections which fail the handshake or time out
+	for _, token := range []string{"evil", ""} {
+		conn := dial(token)
+		conn.SetReadDeadline(time.Now().Add(3 * time.Second))
+		_, err := conn.Read(make([]byte, 1))
+		assert.Equal(t, io.EOF, err)
+		conn.Close()
+	}
+
+	conn := dial("good")
+	defer conn.Close()
+	reply := make([]byte, 2)
+	_, err := io.ReadFull(conn, reply)
+	assert.Nil(t, err)
+	assert.Equal(t, "ok", string(reply))
+	pkg := make([]byte, 4, 9)
+	binary.BigEndian.PutUint32(pkg, 5)
+	_, err = conn.Write(append(pkg, "hello"...))
+	assert.Nil(t, err)
+	select {
+	case msg := <-serverHandler.msgs:
+		assert.Equal(t, "hello", msg)
+	case <-time.After(3 * time.Second):
+		t.Fatal("server did not receive the package")
+	}
+	assert.Equal(t, 1, serverHandler.SessionNumber())
+}
+
+type drainMessageHandler struct {
+	*chanMessageHandler
+	drained chan Session
+	release chan struct{}
+}
+
+func (h *drainMessageHandler) OnMessage(session Session, pkg interface{}) {
+	<-h.release
+	h.chanMessageHandler.OnMessage(session, pkg)
+}
+
+func (h *drainMessageHandler) OnDrain(session Session) {
+	h.drained <- session
+}
+
+func TestServerGracefulClose(t *testing.T) {
+	for _, timeout := range []time.Duration{3 * time.Second, 100 * time.Millisecond} {
+		s := NewTCPServer(WithLocalAddress("127.0.0.1:0")).(*server)
+		handler := &drainMessageHandler{
+			chanMessageHandler: newChanMessageHandler(),
+			drained:            make(chan Session, 1),
+			release:            make(chan struct{}),
+		}
+		s.RunEventLoop(func(session Session) error {
+			session.SetPkgHandler(&stringPkgHandler{})
+			session.SetEventListener(handler)
+			return nil
+		})
+
+		conn, err := net.Dial("tcp", s.addr)
+		assert.Nil(t, err)
+		pkg := make([]byte, 4, 9)
+		binary.BigEndian.PutUint32(pkg, 5)
+		_, err = conn.Write(append(pkg, "hello"...))
+		assert.Nil(t, err)
+		for i := 0; s.SessionCount() == 0 && i < 100; i++ {
+			time.Sleep(10 * time.Millisecond)
+		}
+		for i := 0; s.Sessions()[0].(*session).handling.Load() == 0 && i < 100; i++ {
+			time.Sleep(10 * time.Millisecond)
+		}
+
+		ctx, cancel := context.WithTimeout(context.Background(), timeout)
+		closed := make(chan error, 1)
+		go func() {
+			closed <- s.GracefulClose(ctx)
+		}()
+		select {
+		case <-handler.drained:
+		case <-time.After(3 * time.Second):
+			t.Fatal("session is not notified")
+		}
+		_, err = net.Dial("tcp", s.addr)
+		assert.NotNil(t, err)
+
+		if timeout > time.Second {
+			// the session is closed after its package is handled
+			close(handler.release)
+			assert.Equal(t, "hello", <-handler.msgs)
+			assert.Nil(t, <-closed)
+		} else {
+			assert.Equal(t, context.DeadlineExceeded, <-closed)
+			close(handler.release)
+		}
+		conn.SetReadDeadline(time.Now().Add(3 * time.Second))
+		_, err = conn.Read(make([]byte, 1))
+		assert.Equal(t, io.EOF, err)
+		conn.Close()
+		cancel()
+	}
+}
+
+func TestServerGracefulCloseFlushes(t *testing.T) {
+	s := NewTCPServer(WithLocalAddress("127.0.0.1:0")).(*server)
+	sessions := make(chan Session, 1)
+	s.RunEventLoop(func(session Session) error {
+		session.SetPkgHandler(&stringPkgHandler{})
+		session.SetEventListener(newChanMessageHandler())
+		sessions <- session
+		return nil
+	})
+
+	conn, err := net.Dial("tcp", s.addr)
+	assert.Nil(t, err)
+	defer conn.Close()
+	var ss Session
+	select {
+	case ss = <-sessions:
+	case <-time.After(3 * time.Second):
+		t.Fatal("server did not accept the connection")
+	}
+	// more than the socket buffers, so that the packages stay queued until the peer reads
+	pkg := strings.Repeat("x", 64*1024)
+	const num = 128
+	failed := make(chan error, num)
+	for i := 0; i < num; i++ {
+		ss.WritePkgAsync(pkg, 0, func(err error) {
+			if err != nil {
+				failed <- err
+			}
+		})
+	}
+	assert.True(t, ss.(*session).writeQueue.pendingBytes() > 0)
+
+	closed := make(chan error, 1)
+	go func() {
+		closed <- s.GracefulClose(context.Background())
+	}()
+	time.Sleep(20 * time.Millisecond)
+	n, err := io.Copy(io.Discard, conn)
+	assert.Nil(t, err)
+	assert.Equal(t, int64(num*(4+len(pkg))), n)
+	assert.Nil(t, <-closed)
+	assert.Equal(t, 0, len(failed))
+}
+
+func TestServerSessions(t *testing.T) {
+	s := NewTCPServer(WithLocalAddress("127.0.0.1:0"))
+	defer s.Close()
+	s.RunEventLoop(func(session Session) error {
+		session.SetPkgHandler(&stringPkgHandler{})
+		session.SetEventListener(newChanMessageHandler())
+		return nil
+	})
+
+	for i := 0; i < 2; i++ {
+		conn, err := net.Dial("tcp", s.(*server).addr)
+		assert.Nil(t, err)
+		defer conn.Close()
+	}
+	assert.Eventually(t, func() bool { return s.SessionCount() == 2 }, 3*time.Second, 10*time.Millisecond)
+	assert.Equal(t, 2, len(s.Sessions()))
+
+	s.ForEachSession(func(session Session) {
+		session.Close()
+	})
+	assert.Eventually(t, func() bool { return s.SessionCount() == 0 }, 3*time.Second, 10*time.Millisecond)
+	assert.Equal(t, 0, len(s.Sessions()))
+}
+
+func TestServerLinger(t *testing.T) {
+	// the PROXY connection wraps the tcp conn
+	for _, proxy := range []bool{false, true} {
+		s := NewTCPServer(WithLocalAddress("127.0.0.1:0"), WithServerLinger(0), WithProxyProtocol(proxy)).(*server)
+		serverHandler := newChanMessageHandler()
+		s.RunEventLoop(func(session Session) error {
+			session.SetPkgHandler(&stringPkgHandler{})
+			session.SetEventListener(serverHandler)
+			// Close takes effect once the reading times out
+			session.SetReadTimeout(50 * time.Millisecond)
+			return nil
+		})
+
+		conn, err := net.Dial("tcp", s.addr)
+		assert.Nil(t, err)
+		if proxy {
+			_, err = conn.Write([]byte("PROXY UNKNOWN\r\n"))
+			assert.Nil(t, err)
+		}
+		assert.Eventually(t, func() bool { return serverHandler.SessionNumber() == 1 }, 3*time.Second, 10*time.Millisecond)
+
+		// the zero linger resets the connection rather than sending FIN
+		serverHandler.array[0].Close()
+		conn.SetReadDeadline(time.Now().Add(3 * time.Second))
+		_, err = conn.Read(make([]byte, 1))
+		assert.NotNil(t, err)
+		assert.NotEqual(t, io.EOF, err)
+		assert.False(t, errors.Is(err, os.ErrDeadlineExceeded))
+		conn.Close()
+		s.Close()
+	}
 }
@@ -20,11 +20,15 @@ package getty
 import (
 	"bytes"
 	"context"
+	"crypto/tls"
 	"fmt"
 	"io"
 	"net"
 	"runtime"
+	"sort"
+	"strings"
 	"sync"
+	"syscall"
 	"time"
 )
 
@@ -49,12 +53,17 @@ const (
 	MaxWheelTimeSpan = 900e9
 	maxPacketLen     = 16 * 1024
 
-	defaultSessionName    = "session"
-	defaultTCPSessionName = "tcp-session"
-	defaultUDPSessionName = "udp-session"
-	defaultWSSessionName  = "ws-session"
-	defaultWSSSessionName = "wss-session"
-	outputFormat          = "session %s, Read Bytes: %d, Write Bytes: %d, Read Pkgs: %d, Write Pkgs: %d"
+	defaultSessionName     = "session"
+	defaultTCPSessionName  = "tcp-session"
+	defaultUDPSessionName  = "udp-session"
+	defaultWSSessionName   = "ws-session"
+	defaultWSSSessionName  = "wss-session"
+	defaultQUICSessionName = "quic-session"
+	defaultUDSSessionName  = "uds-session"
+	defaultKCPSessionName  = "kcp-session"
+	defaultPipeSessionName = "pipe-session"
+	defaultSCTPSessionName = "sctp-session"
+	outputFormat           = "session %s, Read Bytes: %d, Write Bytes: %d, Read Pkgs: %d, Write Pkgs: %d"
 )
 
 var defaultTimerWheel *gxtime.TimerWheel
@@ -64,38 +73,135 @@ func init() {
 	defaultTimerWheel = gxtime.GetDefaultTimerWheel()
 }
 
+// SessionStats is the traffic and timing statistics of a session.
+type SessionStats struct {
+	ReadBytes         uint64
+	WriteBytes        uint64
+	ReadPkgs          uint64 // the packages handled by OnMessage
+	WritePkgs         uint64
+	LastReadTime      time.Time // zero if nothing has been read
+	LastWriteTime     time.Time // zero if nothing has been written
+	ConnectTime       time.Time
+	PendingWrites     int // the packages queued by WritePkgAsync but not written yet
+	PendingWriteBytes int
+	Labels            map[string]string // a copy of the labels of the session
+}
+
 // Session wrap connection between the server and the client
 type Session interface {
 	Connection
 	Reset()
 	Conn() net.Conn
+	// SyscallConn returns the raw socket under the tcp, tls, udp or websocket connection, eg: to set
+	// SO_MARK, IP_TRANSPARENT or a BPF filter which the options do not cover. It returns an error
+	// for the sessions without a socket, eg: the pipe and the quic sessions.
+	SyscallConn() (syscall.RawConn, error)
 	Stat() string
+	// Stats returns the traffic and timing statistics, eg: for the idle eviction and the per-tenant accounting.
+	Stats() SessionStats
 	IsClosed() bool
 	// EndPoint get endpoint type
 	EndPoint() EndPoint
+	// SetMaxMsgLen, SetPkgHandler, SetReader, SetWriter, SetCronPeriod and the read/write timeouts
+	// can be changed while the session is active, eg: after the protocol negotiation upgrades to a
+	// larger frame size, and take effect on the next read, write or cron.
 	SetMaxMsgLen(int)
 	SetName(string)
 	SetEventListener(EventListener)
 	SetPkgHandler(ReadWriter)
+	// SetPkgPipeline sets the package handler composed of @stages, see Pipeline.
+	SetPkgPipeline(stages ...ReadWriter)
 	SetReader(Reader)
 	SetWriter(Writer)
 	SetCronPeriod(int)
+	// SetReadIdleTimeout sets the timeout after which OnIdle of the IdleListener is invoked with
+	// ReadIdle if nothing has been read. 0 @timeout, the default, disables it.
+	SetReadIdleTimeout(timeout time.Duration)
+	// SetWriteIdleTimeout sets the timeout after which OnIdle of the IdleListener is invoked with
+	// WriteIdle if nothing has been written. 0 @timeout, the default, disables it.
+	SetWriteIdleTimeout(timeout time.Duration)
 	SetWaitTime(time.Duration)
 	GetAttribute(interface{}) interface{}
 	SetAttribute(interface{}, interface{})
 	RemoveAttribute(interface{})
+	// SetLabel sets the label @key to @value, or removes it if @value is empty, eg: the tenant,
+	// the protocol version or the region. The labels are in the logs of the session and its Stats.
+	SetLabel(key, value string)
+	// Label returns the value of the label @key.
+	Label(key string) string
+	// Context returns the context of the session, which is cancelled when the session is closed.
+	Context() context.Context
+	// Logger returns the StructuredLogger of the endpoint with the id, the addresses and the
+	// labels of the session, whose logs are filtered by the level of LogModuleCore, or the one of
+	// WithServerLogLevel or WithClientLogLevel.
+	Logger() StructuredLogger
+	// SetContext replaces the context of the session by the one derived from @ctx, eg: to carry the
+	// trace id or the auth principal in OnOpen. The former context is cancelled.
+	SetContext(ctx context.Context)
+	// Subprotocol returns the negotiated websocket subprotocol, it is empty for the other sessions.
+	Subprotocol() string
+	// TLSConnectionState returns the state of the tls connection, including the negotiated version,
+	// cipher suite, ALPN protocol and the verified certificate chains of the peer, eg: to authorize
+	// the peer by its SPIFFE ID or CN in OnOpen. The bool is false for the non-tls sessions.
+	TLSConnectionState() (*tls.ConnectionState, bool)
 
 	// WritePkg the Writer will invoke this function. Pls attention that if timeout is less than 0, WritePkg will send @pkg asap.
 	// for udp session, the first parameter should be UDPContext.
 	// totalBytesLength: @pkg stream bytes length after encoding @pkg.
 	// sendBytesLength: stream bytes length that sent out successfully.
 	// err: maybe it has illegal data, encoding error, or write out system error.
-	WritePkg(pkg interface{}, timeout time.Duration) (totalBytesLength int, sendBytesLength int, err error)
+	// @opts can set the deadline after which @pkg is dropped with ErrWriteExpired.
+	WritePkg(pkg interface{}, timeout time.Duration, opts ...WriteOption) (totalBytesLength int, sendBytesLength int, err error)
+	// WriteBatchPkg writes @pkgs by one flush, eg: to fan out the packages to a peer, and returns
+	// the number of the written bytes.
+	WriteBatchPkg(pkgs []interface{}, timeout time.Duration) (int, error)
+	// WritePkgAsync writes @pkg like WritePkg but does not wait. @callback, which can be nil, is
+	// invoked in the writing goroutine when @pkg is written or fails, so it should return asap.
+	// It is invoked before WritePkgAsync returns if @pkg can not be encoded. @opts can set the
+	// priority of @pkg in the queue, and the deadline after which it is dropped with ErrWriteExpired.
+	WritePkgAsync(pkg interface{}, timeout time.Duration, callback func(err error), opts ...WriteOption)
+	// PendingWriteBytes returns the bytes of the packages queued by WritePkgAsync but not written yet.
+	PendingWriteBytes() int
+	// IsWritable returns false once PendingWriteBytes exceeds the high watermark, until it drops
+	// to the low watermark, when OnWritable of the WritableListener is invoked. The producers
+	// should hold off WritePkgAsync while the session is not writable.
+	IsWritable() bool
+	// SetWriteOverflowPolicy sets the policy when PendingWriteBytes would exceed @limit, eg: for
+	// a slow consumer. 0 @limit means no limit, which is the default.
+	SetWriteOverflowPolicy(policy WriteOverflowPolicy, limit int)
+	// WriteOverflowStats returns the counts of the actions of the write overflow policy.
+	WriteOverflowStats() WriteOverflowStats
+	// SetWriteWatermark sets the low and high watermarks of PendingWriteBytes, 32KB and 64KB in default.
+	SetWriteWatermark(low, high int)
 	WriteBytes([]byte) (int, error)
 	WriteBytesArray(...[]byte) (int, error)
+	// PauseRead stops reading from the connection, and the tcp flow control pushes back on the peer,
+	// eg: while the upstream of a proxy is slower than the client. The packages which have been read
+	// are still handled, and the reading in progress takes effect after it returns.
+	PauseRead()
+	// ResumeRead resumes the reading paused by PauseRead.
+	ResumeRead()
+	// CloseWrite shuts down the writing side of the tcp connection, which sends FIN to the peer.
+	// It returns ErrHalfClose for the udp and websocket sessions.
+	CloseWrite() error
+	// CloseRead shuts down the reading side of the tcp connection, and the session stops reading
+	// but keeps open for writing until Close. It returns ErrHalfClose for the udp and websocket sessions.
+	CloseRead() error
+	// CloseWithTimeout flushes the packages queued by WritePkgAsync for up to @timeout before closing
+	// the session, which avoids truncating the final responses. It returns false if the flush times out.
+	CloseWithTimeout(timeout time.Duration) bool
+	// CloseWithReason closes the session by @reason, eg: CloseReasonIdleTimeout by OnIdle.
+	CloseWithReason(reason CloseReason)
+	// CloseReason returns why the session is closed, or CloseReasonUnknown if it is open.
+	CloseReason() CloseReason
 	Close()
 }
 
+// sessionTracker is implemented by the endpoints which keep their active sessions.
+type sessionTracker interface {
+	removeSession(Session)
+}
+
 // getty base session
 type session struct {
 	name     string
@@ -105,6 +211,8 @@ type session struct {
 	Connection
 
 	listener EventListener
+	// @listener wrapped by the interceptors of the endpoint
+	intercepted EventListener
 
 	// codec
 	reader Reader // @reader should be nil when @conn is a gettyWSConn object.
@@ -114,18 +222,52 @@ type session struct {
 	maxMsgLen int32
 
 	// heartbeat
-	period time.Duration
+	period    time.Duration
+	cron      *gxtime.Timer
+	clockCron ClockTimer // the cron on the Clock of the endpoint instead of defaultTimerWheel
 
 	// done
 	wait time.Duration
 	once *sync.Once
 	done chan struct{}
 
+	// SO_LINGER in seconds, which is applied on close if lingerSet
+	linger    int
+	lingerSet bool
+
 	// attribute
 	attrs *gxcontext.ValuesContext
 
+	// labels, and their sorted "k=v,..." form in the logs
+	labels   map[string]string
+	labelStr uatomic.String
+
+	// the packages of WritePkgAsync
+	writeQueue writeQueue
+
+	// the circuit breaker of the server address and the write limiter of a client session, or nil
+	breaker *circuitBreaker
+	limiter *writeLimiter
+	// the probe state of a client session, or nil
+	health *sessionHealth
+	// the client session is connected to replace a closed one of the pool
+	reconnected bool
+
+	// idle events
+	readIdle  idleTimer
+	writeIdle idleTimer
+
+	// context, cancelled when the session is closed
+	ctx    context.Context
+	cancel context.CancelFunc
+
 	// goroutines sync
 	grNum      uatomic.Int32
+	handling   uatomic.Int32 // the packages being handled or queued in the task pool
+	readClosed uatomic.Bool  // CloseRead is called
+	reason     uatomic.Int32 // the CloseReason, which is set only once
+	pauseLock  sync.Mutex
+	resume     chan struct{} // closed by ResumeRead, nil if the reading is not paused
 	lock       sync.RWMutex
 	packetLock sync.RWMutex
 }
@@ -146,6 +288,9 @@ func newSession(endPoint EndPoint, conn Connection) *session {
 		wait:  pendingDuration,
 		attrs: gxcontext.NewValuesContext(context.Background()),
 	}
+	ss.ctx, ss.cancel = context.WithCancel(context.Background())
+	ss.writeQueue.init(ss)
+	ss.writeIdle.kind = WriteIdle
 
 	ss.Connection.setSession(ss)
 	ss.SetWriteTimeout(netIOTimeout)
@@ -162,7 +307,7 @@ func newTCPSession(conn net.Conn, endPoint EndPoint) Session {
 	return session
 }
 
-func newUDPSession(conn *net.UDPConn, endPoint EndPoint) Session {
+func newUDPSession(conn udpConn, endPoint EndPoint) Session {
 	c := newGettyUDPConn(conn)
 	session := newSession(endPoint, c)
 	session.name = defaultUDPSessionName
@@ -187,6 +332,9 @@ func (s *session) Reset() {
 		wait:   pendingDuration,
 		attrs:  gxcontext.NewValuesContext(context.Background()),
 	}
+	s.ctx, s.cancel = context.WithCancel(context.Background())
+	s.writeQueue.init(s)
+	s.writeIdle.kind = WriteIdle
 }
 
 func (s *session) Conn() net.Conn {
@@ -205,6 +353,38 @@ func (s *session) Conn() net.Conn {
 	return nil
 }
 
+func (s *session) SyscallConn() (syscall.RawConn, error) {
+	conn := s.Conn()
+	sc := syscallConnOf(conn)
+	if sc == nil {
+		return nil, perrors.Errorf("%T has no raw socket", conn)
+	}
+	raw, err := sc.SyscallConn()
+
+	return raw, perrors.WithStack(err)
+}
+
+func (s *session) Subprotocol() string {
+	s.lock.RLock()
+	defer s.lock.RUnlock()
+
+	if wc, ok := s.Connection.(*gettyWSConn); ok {
+		return wc.conn.Subprotocol()
+	}
+
+	return ""
+}
+
+func (s *session) TLSConnectionState() (*tls.ConnectionState, bool) {
+	// *tls.Conn of the tls and wss sessions, and quicStreamConn
+	if tc, ok := s.Conn().(interface{ ConnectionState() tls.ConnectionState }); ok {
+		state := tc.ConnectionState()
+		return &state, true
+	}
+
+	return nil, false
+}
+
 func (s *session) EndPoint() EndPoint {
 	return s.endPoint
 }
@@ -241,6 +421,37 @@ func (s *session) Stat() string {
 	)
 }
 
+func (s *session) Stats() SessionStats {
+	var stats SessionStats
+	stats.PendingWrites, stats.PendingWriteBytes = s.writeQueue.depth()
+
+	s.lock.RLock()
+	defer s.lock.RUnlock()
+	if len(s.labels) > 0 {
+		stats.Labels = make(map[string]string, len(s.labels))
+		for k, v := range s.labels {
+			stats.Labels[k] = v
+		}
+	}
+	conn := s.gettyConn()
+	if conn == nil {
+		return stats
+	}
+	stats.ReadBytes = uint64(conn.readBytes.Load())
+	stats.WriteBytes = uint64(conn.writeBytes.Load())
+	stats.ReadPkgs = uint64(conn.readPkgNum.Load())
+	stats.WritePkgs = uint64(conn.writePkgNum.Load())
+	if t := conn.lastRead.Load(); t != 0 {
+		stats.LastReadTime = launchTime.Add(time.Duration(t))
+	}
+	if t := conn.lastWrite.Load(); t != 0 {
+		stats.LastWriteTime = launchTime.Add(time.Duration(t))
+	}
+	stats.ConnectTime = conn.connected
+
+	return stats
+}
+
 // IsClosed check whether the session has been closed.
 func (s *session) IsClosed() bool {
 	select {
@@ -274,6 +485,7 @@ func (s *session) SetEventListener(listener EventListener) {
 	defer s.lock.Unlock()
 
 	s.listener = listener
+	s.intercepted = interceptListener(interceptorsOf(s.endPoint), withReasons(listener))
 }
 
 // SetPkgHandler set package handler
@@ -285,6 +497,11 @@ func (s *session) SetPkgHandler(handler ReadWriter) {
 	s.writer = handler
 }
 
+// SetPkgPipeline set package handler composed of @stages
+func (s *session) SetPkgPipeline(stages ...ReadWriter) {
+	s.SetPkgHandler(Pipeline(stages...))
+}
+
 func (s *session) SetReader(reader Reader) {
 	s.lock.Lock()
 	defer s.lock.Unlock()
@@ -308,6 +525,41 @@ func (s *session) SetCronPeriod(period int) {
 	s.lock.Lock()
 	defer s.lock.Unlock()
 	s.period = time.Duration(period) * time.Millisecond
+	if s.cron != nil {
+		s.cron.Reset(s.period)
+	}
+	if s.clockCron != nil {
+		s.clockCron.Stop()
+		s.armClockCron(clockOf(s.endPoint))
+	}
+}
+
+// armClockCron schedules the next heartbeat on @clock, s.lock should be held.
+func (s *session) armClockCron(clock Clock) {
+	s.clockCron = clock.AfterFunc(s.period, func() {
+		if heartbeat(0, clock.Now(), s) != nil {
+			return
+		}
+		s.lock.Lock()
+		s.armClockCron(clock)
+		s.lock.Unlock()
+	})
+}
+
+// codec returns the reader and the writer, which can be replaced while the session is active,
+// eg: by SetPkgHandler after the protocol negotiation.
+func (s *session) codec() (Reader, Writer) {
+	s.lock.RLock()
+	defer s.lock.RUnlock()
+
+	return s.reader, s.writer
+}
+
+func (s *session) maxMsgLength() int32 {
+	s.lock.RLock()
+	defer s.lock.RUnlock()
+
+	return s.maxMsgLen
 }
 
 // SetWaitTime set maximum wait time when session got error or got exit signal
@@ -347,6 +599,91 @@ func (s *session) SetAttribute(key interface{}, value interface{}) {
 	s.lock.Unlock()
 }
 
+func (s *session) Context() context.Context {
+	s.lock.RLock()
+	defer s.lock.RUnlock()
+
+	return s.ctx
+}
+
+func (s *session) SetContext(ctx context.Context) {
+	ctx, cancel := context.WithCancel(ctx)
+
+	s.lock.Lock()
+	s.cancel()
+	s.ctx, s.cancel = ctx, cancel
+	s.lock.Unlock()
+	if s.IsClosed() {
+		cancel()
+	}
+}
+
+func (s *session) SetLabel(key, value string) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	if value == "" {
+		delete(s.labels, key)
+	} else {
+		if s.labels == nil {
+			s.labels = make(map[string]string)
+		}
+		s.labels[key] = value
+	}
+
+	keys := make([]string, 0, len(s.labels))
+	for k := range s.labels {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	pairs := make([]string, 0, len(keys))
+	for _, k := range keys {
+		pairs = append(pairs, k+"="+s.labels[k])
+	}
+	s.labelStr.Store(strings.Join(pairs, ","))
+}
+
+func (s *session) Logger() StructuredLogger {
+	logger := loggerOf(s.EndPoint())
+	if s.Connection != nil {
+		keysAndValues := []interface{}{"session_id", s.ID(), "local", s.LocalAddr(), "peer", s.RemoteAddr()}
+		if labels := s.labelStr.Load(); labels != "" {
+			keysAndValues = append(keysAndValues, "labels", labels)
+		}
+		logger = logger.With(keysAndValues...)
+	}
+
+	return newModuleStructuredLogger(log, s.EndPoint(), logger)
+}
+
+// logger returns the Logger of the session which writes the logs of @module.
+func (s *session) logger(module moduleLogger) StructuredLogger {
+	return module.with(s.Logger())
+}
+
+func (s *session) Label(key string) string {
+	s.lock.RLock()
+	defer s.lock.RUnlock()
+
+	return s.labels[key]
+}
+
+// loadOrStoreAttribute returns the attribute of @key if it exists, otherwise sets it to @value.
+func (s *session) loadOrStoreAttribute(key interface{}, value interface{}) (interface{}, bool) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	if s.attrs == nil {
+		return value, false
+	}
+	if ret, ok := s.attrs.Get(key); ok {
+		return ret, true
+	}
+	s.attrs.Set(key, value)
+
+	return value, false
+}
+
 // RemoveAttribute remove attribute of key @session:key
 func (s *session) RemoveAttribute(key interface{}) {
 	s.lock.Lock()
@@ -361,31 +698,70 @@ func (s *session) sessionToken() string {
 		return "session-closed"
 	}
 
+	if labels := s.labelStr.Load(); labels != "" {
+		return fmt.Sprintf("{%s:%s:%d:%s<->%s}[%s]",
+			s.name, s.EndPoint().EndPointType(), s.ID(), s.LocalAddr(), s.RemoteAddr(), labels)
+	}
+
 	return fmt.Sprintf("{%s:%s:%d:%s<->%s}",
 		s.name, s.EndPoint().EndPointType(), s.ID(), s.LocalAddr(), s.RemoteAddr())
 }
 
-func (s *session) WritePkg(pkg interface{}, timeout time.Duration) (int, int, error) {
+func (s *session) WritePkg(pkg interface{}, timeout time.Duration, opts ...WriteOption) (int, int, error) {
+	interceptors := interceptorsOf(s.EndPoint())
+	if len(interceptors) == 0 {
+		return s.writePkg(pkg, timeout, opts)
+	}
+	write := func(_ Session, pkg interface{}, timeout time.Duration) (int, int, error) {
+		return s.writePkg(pkg, timeout, opts)
+	}
+	return interceptWrite(interceptors, write)(s, pkg, timeout)
+}
+
+func (s *session) writePkg(pkg interface{}, timeout time.Duration, opts []WriteOption) (int, int, error) {
 	if pkg == nil {
 		return 0, 0, fmt.Errorf("@pkg is nil")
 	}
 	if s.IsClosed() {
 		return 0, 0, ErrSessionClosed
 	}
+	o := newWriteOptions(opts)
+	if o.expired() {
+		return 0, 0, ErrWriteExpired
+	}
 
 	defer func() {
 		if r := recover(); r != nil {
 			const size = 64 << 10
 			rBuf := make([]byte, size)
 			rBuf = rBuf[:runtime.Stack(rBuf, false)]
-			log.Errorf("[session.WritePkg] panic session %s: err=%s\n%s", s.sessionToken(), r, rBuf)
+			s.logger(log).Errorw("WritePkg panic", "panic", r, "stack", string(rBuf))
 		}
 	}()
 
-	pkgBytes, err := s.writer.Write(s, pkg)
+	data, size, err := s.encodePkg(pkg)
+	if err != nil {
+		return size, 0, err
+	}
+	done, err := s.admitWrite(1)
+	if err != nil {
+		return size, 0, err
+	}
+	succssCount, err := s.sendPkg(data, o.writeTimeout(timeout))
+	if done != nil {
+		done(err)
+	}
+	return size, succssCount, err
+}
+
+// encodePkg encodes @pkg by the writer, the result is the bytes of @pkg, or the UDPContext
+// whose Pkg is the bytes, and its length.
+func (s *session) encodePkg(pkg interface{}) (interface{}, int, error) {
+	_, writer := s.codec()
+	pkgBytes, err := writer.Write(s, pkg)
 	if err != nil {
-		log.Warnf("%s, [session.WritePkg] session.writer.Write(@pkg:%#v) = error:%+v", s.Stat(), pkg, err)
-		return len(pkgBytes), 0, perrors.WithStack(err)
+		s.logger(codecLog).Warnw("encode package failed", "pkg", fmt.Sprintf("%#v", pkg), "err", err)
+		return nil, len(pkgBytes), perrors.WithStack(err)
 	}
 	var udpCtxPtr *UDPContext
 	if udpCtx, ok := pkg.(UDPContext); ok {
@@ -395,22 +771,211 @@ func (s *session) WritePkg(pkg interface{}, timeout time.Duration) (int, int, er
 	}
 	if udpCtxPtr != nil {
 		udpCtxPtr.Pkg = pkgBytes
-		pkg = *udpCtxPtr
-	} else {
-		pkg = pkgBytes
+		return *udpCtxPtr, len(pkgBytes), nil
 	}
+
+	return pkgBytes, len(pkgBytes), nil
+}
+
+// sendPkg writes the encoded package @data.
+func (s *session) sendPkg(data interface{}, timeout time.Duration) (int, error) {
 	s.packetLock.RLock()
 	defer s.packetLock.RUnlock()
 	if 0 < timeout {
 		s.Connection.SetWriteTimeout(timeout)
 	}
-	var succssCount int
-	succssCount, err = s.Connection.send(pkg)
+	start := time.Now()
+	succssCount, err := s.Connection.send(data)
+	if _, writeThreshold := slowThresholdsOf(s.EndPoint()); writeThreshold > 0 {
+		s.logSlow("write", succssCount, start, writeThreshold)
+	}
 	if err != nil {
-		log.Warnf("%s, [session.WritePkg] @s.Connection.Write(pkg:%#v) = err:%+v", s.Stat(), pkg, err)
-		return len(pkgBytes), succssCount, perrors.WithStack(err)
+		s.logger(transportLog).Warnw("write package failed", "err", err)
+		return succssCount, perrors.WithStack(err)
 	}
-	return len(pkgBytes), succssCount, nil
+	return succssCount, nil
+}
+
+// WriteBatchPkg encodes @pkgs and writes them by one writev syscall. The packages of the udp and
+// websocket sessions are written one by one as every one of them is a datagram or a message.
+// Every package passes the Write interceptors, and the packages are sent after all of them pass,
+// so the interceptors of every package see the result of sending the whole batch.
+func (s *session) WriteBatchPkg(pkgs []interface{}, timeout time.Duration) (int, error) {
+	if s.IsClosed() {
+		return 0, ErrSessionClosed
+	}
+	if _, ok := s.Connection.(*gettyTCPConn); !ok {
+		var total int
+		for _, pkg := range pkgs {
+			_, n, err := s.WritePkg(pkg, timeout)
+			total += n
+			if err != nil {
+				return total, err
+			}
+		}
+		return total, nil
+	}
+
+	_, writer := s.codec()
+	interceptors := interceptorsOf(s.EndPoint())
+	buffers := make([][]byte, 0, len(pkgs))
+	var (
+		sent    bool
+		sendNum int
+		sendErr error
+	)
+	// the next package is written by the innermost WriteFunc of the previous one, and the
+	// batch is sent by the one of the last package
+	var writeFrom func(i int) error
+	writeFrom = func(i int) error {
+		if i == len(pkgs) {
+			sent = true
+			sendNum, sendErr = s.sendBatch(buffers, timeout)
+			return sendErr
+		}
+
+		passed := false
+		var write WriteFunc = func(_ Session, pkg interface{}, _ time.Duration) (int, int, error) {
+			passed = true
+			if pkg == nil {
+				return 0, 0, perrors.New("@pkg is nil")
+			}
+			pkgBytes, err := writer.Write(s, pkg)
+			if err != nil {
+				s.logger(codecLog).Warnw("encode package failed", "pkg", fmt.Sprintf("%#v", pkg), "err", err)
+				return 0, 0, perrors.WithStack(err)
+			}
+			buffers = append(buffers, pkgBytes)
+			if err = writeFrom(i + 1); err != nil {
+				return len(pkgBytes), 0, err
+			}
+			return len(pkgBytes), len(pkgBytes), nil
+		}
+		if len(interceptors) != 0 {
+			write = interceptWrite(interceptors, write)
+		}
+		if _, _, err := write(s, pkgs[i], timeout); err != nil {
+			return err
+		}
+		if !passed {
+			// the interceptors drop the package
+			return writeFrom(i + 1)
+		}
+		return nil
+	}
+	err := writeFrom(0)
+	if sent {
+		return sendNum, sendErr
+	}
+
+	return 0, err
+}
+
+// sendBatch sends the encoded packages @buffers of WriteBatchPkg by one writev syscall.
+func (s *session) sendBatch(buffers [][]byte, timeout time.Duration) (int, error) {
+	if len(buffers) == 0 {
+		// the interceptors drop all of the packages
+		return 0, nil
+	}
+	done, err := s.admitWrite(len(buffers))
+	if err != nil {
+		return 0, err
+	}
+	s.packetLock.RLock()
+	defer s.packetLock.RUnlock()
+	if 0 < timeout {
+		s.Connection.SetWriteTimeout(timeout)
+	}
+	start := time.Now()
+	n, err := s.Connection.send(buffers)
+	if _, writeThreshold := slowThresholdsOf(s.EndPoint()); writeThreshold > 0 {
+		s.logSlow("write", n, start, writeThreshold)
+	}
+	if done != nil {
+		done(err)
+	}
+	if err != nil {
+		s.logger(transportLog).Warnw("write packages failed", "num", len(buffers), "err", err)
+		return n, perrors.WithStack(err)
+	}
+
+	return n, nil
+}
+
+// WritePkgAsync encodes @pkg and queues it to be written in another goroutine, and @callback is
+// invoked with the result once @pkg has been written to the socket or failed. The packages of a
+// session are written in the order of their priorities and then their WritePkgAsync. @pkg passes
+// the Write interceptors, which see it queued with 0 sent bytes.
+func (s *session) WritePkgAsync(pkg interface{}, timeout time.Duration, callback func(err error), opts ...WriteOption) {
+	queued := false
+	var write WriteFunc = func(_ Session, pkg interface{}, timeout time.Duration) (int, int, error) {
+		size, err := s.pushPkg(pkg, timeout, callback, opts)
+		queued = err == nil
+		return size, 0, err
+	}
+	if interceptors := interceptorsOf(s.EndPoint()); len(interceptors) != 0 {
+		write = interceptWrite(interceptors, write)
+	}
+	_, _, err := write(s, pkg, timeout)
+	if !queued && callback != nil {
+		callback(err)
+	}
+}
+
+// pushPkg encodes @pkg and queues it to the write queue, it returns the bytes of @pkg.
+func (s *session) pushPkg(pkg interface{}, timeout time.Duration, callback func(err error), opts []WriteOption) (int, error) {
+	if pkg == nil {
+		return 0, fmt.Errorf("@pkg is nil")
+	}
+	if s.IsClosed() {
+		return 0, ErrSessionClosed
+	}
+	data, size, err := s.encodePkg(pkg)
+	if err != nil {
+		return size, err
+	}
+	done, err := s.admitWrite(1)
+	if err != nil {
+		return size, err
+	}
+	if done != nil {
+		cb := callback
+		callback = func(err error) {
+			done(err)
+			if cb != nil {
+				cb(err)
+			}
+		}
+	}
+	w := &asyncWrite{data: data, size: size, timeout: timeout, opts: newWriteOptions(opts), callback: callback}
+	if err = s.writeQueue.push(w); err != nil && done != nil {
+		done(err)
+	}
+
+	return size, err
+}
+
+func (s *session) PendingWriteBytes() int {
+	return s.writeQueue.pendingBytes()
+}
+
+func (s *session) IsWritable() bool {
+	return s.writeQueue.writable()
+}
+
+func (s *session) SetWriteOverflowPolicy(policy WriteOverflowPolicy, limit int) {
+	s.writeQueue.setOverflowPolicy(policy, limit)
+}
+
+func (s *session) WriteOverflowStats() WriteOverflowStats {
+	return s.writeQueue.overflowStats()
+}
+
+func (s *session) SetWriteWatermark(low, high int) {
+	if low < 0 || high < low {
+		panic(fmt.Sprintf("illegal write watermark low:%d, high:%d", low, high))
+	}
+	s.writeQueue.setWatermark(low, high)
 }
 
 // WriteBytes for codecs
@@ -512,17 +1077,21 @@ func heartbeat(_ gxtime.TimerID, _ time.Time, arg interface{}) error {
 	if ss == nil || ss.IsClosed() {
 		return ErrSessionClosed
 	}
+	if ss.EndPoint() == nil || ss.intercepted == nil {
+		// the session has been reset
+		return ErrSessionClosed
+	}
 
 	f := func() {
 		wsConn, wsFlag := ss.Connection.(*gettyWSConn)
 		if wsFlag {
 			err := wsConn.writePing()
 			if err != nil {
-				log.Warnf("wsConn.writePing() = error:%+v", perrors.WithStack(err))
+				ss.logger(cronLog).Warnw("write websocket ping failed", "err", err)
 			}
 		}
 
-		ss.listener.OnCron(ss)
+		ss.intercepted.OnCron(ss)
 	}
 
 	// if enable task pool, run @f asynchronously.
@@ -539,19 +1108,35 @@ func (s *session) run() {
 	if s.Connection == nil || s.listener == nil || s.writer == nil {
 		errStr := fmt.Sprintf("session{name:%s, conn:%#v, listener:%#v, writer:%#v}",
 			s.name, s.Connection, s.listener, s.writer)
-		log.Error(errStr)
+		s.logger(log).Errorw(errStr)
 		panic(errStr)
 	}
 
 	// call session opened
 	s.UpdateActive()
-	if err := s.listener.OnOpen(s); err != nil {
-		log.Errorf("[OnOpen] session %s, error: %#v", s.Stat(), err)
+	if err := s.intercepted.OnOpen(s); err != nil {
+		s.logger(log).Errorw("OnOpen failed", "err", err)
 		s.Close()
+		if tracker, ok := s.EndPoint().(sessionTracker); ok {
+			tracker.removeSession(s)
+		}
 		return
 	}
+	publishEvent(LifecycleEvent{Type: SessionOpened, EndPoint: s.EndPoint(), Session: s})
 
-	if _, err := defaultTimerWheel.AddTimer(heartbeat, gxtime.TimerLoop, s.period, s); err != nil {
+	s.lock.Lock()
+	var (
+		cron *gxtime.Timer
+		err  error
+	)
+	if clock := clockOf(s.endPoint); clock != nil {
+		s.armClockCron(clock)
+	} else {
+		cron, err = defaultTimerWheel.AddTimer(heartbeat, gxtime.TimerLoop, s.period, s)
+		s.cron = cron
+	}
+	s.lock.Unlock()
+	if err != nil {
 		panic(fmt.Sprintf("failed to add session %s to defaultTimerWheel err:%v", s.Stat(), err))
 	}
 
@@ -560,9 +1145,20 @@ func (s *session) run() {
 	go s.handlePackage()
 }
 
-func (s *session) addTask(pkg interface{}) {
+func (s *session) addTask(pkg interface{}, size int) {
+	if _, ok := pkg.(fragment); ok {
+		return
+	}
+	if s.health != nil && s.health.onMessage(s, pkg) {
+		return
+	}
+	s.handling.Inc()
 	f := func() {
-		s.listener.OnMessage(s, pkg)
+		defer s.handling.Dec()
+		if handlerThreshold, _ := slowThresholdsOf(s.EndPoint()); handlerThreshold > 0 {
+			defer s.logSlow("OnMessage", size, time.Now(), handlerThreshold)
+		}
+		s.intercepted.OnMessage(s, pkg)
 		s.incReadPkgNum()
 	}
 	if taskPool := s.EndPoint().GetTaskPool(); taskPool != nil {
@@ -574,32 +1170,39 @@ func (s *session) addTask(pkg interface{}) {
 
 func (s *session) handlePackage() {
 	var err error
-	log.Infof("async handel package")
 	defer func() {
 		if r := recover(); r != nil {
 			const size = 64 << 10
 			rBuf := make([]byte, size)
 			rBuf = rBuf[:runtime.Stack(rBuf, false)]
-			log.Errorf("[session.handlePackage] panic session %s: err=%s\n%s", s.sessionToken(), r, rBuf)
+			s.logger(log).Errorw("handlePackage panic", "panic", r, "stack", string(rBuf))
 		}
 		grNum := s.grNum.Add(-1)
-		log.Infof("%s, [session.handlePackage] gr will exit now, left gr num %d", s.sessionToken(), grNum)
+		s.logger(log).Infow("handlePackage exits", "gr_num", grNum)
+		if err != nil {
+			s.setCloseReason(closeReasonOf(err))
+		}
+		s.setCloseReason(CloseReasonLocal)
 		s.stop()
 		if err != nil {
-			log.Errorf("%s, [session.handlePackage] error:%+v", s.sessionToken(), perrors.WithStack(err))
+			s.logger(log).Errorw("handlePackage failed", "err", err)
 			if s != nil || s.listener != nil {
-				s.listener.OnError(s, err)
+				s.intercepted.OnError(s, err)
 			}
 		}
 
-		s.listener.OnClose(s)
+		s.intercepted.OnClose(s)
+		publishEvent(LifecycleEvent{Type: SessionClosed, EndPoint: s.EndPoint(), Session: s, Reason: err})
+		if tracker, ok := s.EndPoint().(sessionTracker); ok {
+			tracker.removeSession(s)
+		}
 		s.gc()
 	}()
 
 	if _, ok := s.Connection.(*gettyTCPConn); ok {
 		if s.reader == nil {
 			errStr := fmt.Sprintf("session{name:%s, conn:%#v, reader:%#v}", s.name, s.Connection, s.reader)
-			log.Error(errStr)
+			s.logger(log).Errorw(errStr)
 			panic(errStr)
 		}
 
@@ -626,12 +1229,22 @@ func (s *session) handleTCPPackage() error {
 		buf      []byte
 		pktBuf   *gxbytes.Buffer
 		pkg      interface{}
+		eof      bool
+		reader   Reader
+		maxLen   int32
+		aliased  bool
 	)
 
-	pktBuf = gxbytes.NewBuffer(nil)
-
 	conn = s.Connection.(*gettyTCPConn)
+	if reader, _ = s.codec(); reader != nil {
+		if _, ok = reader.(StreamReader); ok {
+			return s.handleTCPStream(conn)
+		}
+	}
+
+	pktBuf = gxbytes.NewBuffer(nil)
 	for {
+		s.waitReadResumed()
 		if s.IsClosed() {
 			err = nil
 			// do not handle the left stream in pktBuf and exit asap.
@@ -650,38 +1263,42 @@ func (s *session) handleTCPPackage() error {
 					break
 				}
 				if perrors.Cause(err) == io.EOF {
-					log.Infof("%s, session.conn read EOF, client send over, session exit", s.sessionToken())
+					s.logger(transportLog).Infow("read EOF, the session exits")
 					err = nil
 					exit = true
+					eof = true
 					if bufLen != 0 {
 						// as https://github.com/apache/dubbo-getty/issues/77#issuecomment-939652203
 						// this branch is impossible. Even if it happens, the bufLen will be zero and the error
 						// is io.EOF when getty continues to read the socket.
 						exit = false
-						log.Infof("%s, session.conn read EOF, while the bufLen(%d) is non-zero.", s.sessionToken())
+						eof = false
+						s.logger(transportLog).Infow("read EOF with the non-zero bufLen", "bufLen", bufLen)
 					}
 					break
 				}
-				log.Errorf("%s, [session.conn.read] = error:%+v", s.sessionToken(), perrors.WithStack(err))
+				s.logger(transportLog).Errorw("read failed", "err", err)
 				exit = true
 			}
 			break
 		}
 		if 0 != bufLen {
+			// the codec and the max message length set while the session is active take effect here
+			reader, _ = s.codec()
+			maxLen = s.maxMsgLength()
 			pktBuf.WriteNextEnd(bufLen)
 			for {
 				if pktBuf.Len() <= 0 {
 					break
 				}
-				pkg, pkgLen, err = s.reader.Read(s, pktBuf.Bytes())
+				pkg, pkgLen, err = reader.Read(s, pktBuf.Bytes())
 				// for case 3/case 4
-				if err == nil && s.maxMsgLen > 0 && pkgLen > int(s.maxMsgLen) {
-					err = perrors.Errorf("pkgLen %d > session max message len %d", pkgLen, s.maxMsgLen)
+				if err == nil && maxLen > 0 && pkgLen > int(maxLen) {
+					err = perrors.Wrapf(ErrMaxMsgLenExceeded, "pkgLen %d > session max message len %d", pkgLen, maxLen)
 				}
 				// handle case 1
 				if err != nil {
-					log.Warnf("%s, [session.handleTCPPackage] = len{%d}, error:%+v",
-						s.sessionToken(), pkgLen, perrors.WithStack(err))
+					s.logger(codecLog).Warnw("decode package failed", "len", pkgLen, "err", err)
 					exit = true
 					break
 				}
@@ -690,20 +1307,107 @@ func (s *session) handleTCPPackage() error {
 					break
 				}
 				// handle case 4
+				if _, ok = pkg.(*PooledBuffer); ok {
+					aliased = true
+				}
 				s.UpdateActive()
-				s.addTask(pkg)
+				s.addTask(pkg, pkgLen)
 				pktBuf.Next(pkgLen)
 				// continue to handle case 5
 			}
+			if aliased {
+				// the PooledBuffers alias pktBuf, so the left stream is moved to a new buffer
+				pktBuf = gxbytes.NewBuffer(bytes.Clone(pktBuf.Bytes()))
+				aliased = false
+			}
 		}
 		if exit {
 			break
 		}
 	}
+	if eof {
+		// the reason is decided once the session exits by the EOF
+		s.setCloseReason(CloseReasonPeerClosed)
+		s.waitHalfClose()
+	}
 
 	return perrors.WithStack(err)
 }
 
+func (s *session) PauseRead() {
+	s.pauseLock.Lock()
+	defer s.pauseLock.Unlock()
+
+	if s.resume == nil {
+		s.resume = make(chan struct{})
+	}
+}
+
+func (s *session) ResumeRead() {
+	s.pauseLock.Lock()
+	defer s.pauseLock.Unlock()
+
+	if s.resume != nil {
+		close(s.resume)
+		s.resume = nil
+	}
+}
+
+// waitReadResumed blocks the reading goroutine while the reading is paused and the session is open.
+func (s *session) waitReadResumed() {
+	s.pauseLock.Lock()
+	resume := s.resume
+	s.pauseLock.Unlock()
+
+	if resume != nil {
+		select {
+		case <-resume:
+		case <-s.done:
+		}
+	}
+}
+
+// waitHalfClose keeps the session open for writing, until it is closed, after its reading side
+// is shut down by CloseRead or the peer which is handled by the HalfCloseListener.
+func (s *session) waitHalfClose() {
+	if !s.readClosed.Load() {
+		l, ok := s.listener.(HalfCloseListener)
+		if !ok {
+			return
+		}
+		s.logger(log).Infow("half-closed by the peer")
+		l.OnHalfClose(s)
+	}
+	<-s.done
+}
+
+func (s *session) CloseWrite() error {
+	if s.IsClosed() {
+		return ErrSessionClosed
+	}
+	conn, ok := s.Conn().(interface{ CloseWrite() error })
+	if _, tcp := s.Connection.(*gettyTCPConn); !tcp || !ok {
+		return ErrHalfClose
+	}
+
+	s.packetLock.Lock()
+	defer s.packetLock.Unlock()
+	return perrors.WithStack(conn.CloseWrite())
+}
+
+func (s *session) CloseRead() error {
+	if s.IsClosed() {
+		return ErrSessionClosed
+	}
+	conn, ok := s.Conn().(interface{ CloseRead() error })
+	if _, tcp := s.Connection.(*gettyTCPConn); !tcp || !ok {
+		return ErrHalfClose
+	}
+
+	s.readClosed.Store(true)
+	return perrors.WithStack(conn.CloseRead())
+}
+
 // get package from udp packet
 func (s *session) handleUDPPackage() error {
 	var (
@@ -718,60 +1422,83 @@ func (s *session) handleUDPPackage() error {
 		addr      *net.UDPAddr
 		pkgLen    int
 		pkg       interface{}
+		reader    Reader
+		maxLen    int32
+		bufMaxLen int32
 	)
 
 	conn = s.Connection.(*gettyUDPConn)
-	maxBufLen = int(s.maxMsgLen + maxReadBufLen)
-	if int(s.maxMsgLen<<1) < bufLen {
-		maxBufLen = int(s.maxMsgLen << 1)
-	}
-	bufp = gxbytes.AcquireBytes(maxBufLen)
-	defer gxbytes.ReleaseBytes(bufp)
-	buf = *bufp
+	defer func() {
+		if bufp != nil {
+			gxbytes.ReleaseBytes(bufp)
+		}
+	}()
 	for {
+		s.waitReadResumed()
 		if s.IsClosed() {
 			break
 		}
 
+		// the codec and the max message length set while the session is active take effect here
+		reader, _ = s.codec()
+		if maxLen = s.maxMsgLength(); bufp == nil || maxLen != bufMaxLen {
+			if bufp != nil {
+				gxbytes.ReleaseBytes(bufp)
+			}
+			maxBufLen = int(maxLen + maxReadBufLen)
+			if int(maxLen<<1) < bufLen {
+				maxBufLen = int(maxLen << 1)
+			}
+			bufp = gxbytes.AcquireBytes(maxBufLen)
+			buf = *bufp
+			bufMaxLen = maxLen
+		}
+
 		bufLen, addr, err = conn.recv(buf)
-		log.Debugf("conn.read() = bufLen:%d, addr:%#v, err:%+v", bufLen, addr, perrors.WithStack(err))
+		s.logger(transportLog).Debugw("read", "bufLen", bufLen, "addr", addr, "err", err)
 		if netError, ok = perrors.Cause(err).(net.Error); ok && netError.Timeout() {
 			continue
 		}
 		if err != nil {
-			log.Errorf("%s, [session.handleUDPPackage] = len:%d, error:%+v",
-				s.sessionToken(), bufLen, perrors.WithStack(err))
+			s.logger(transportLog).Errorw("read failed", "bufLen", bufLen, "err", err)
 			err = perrors.Wrapf(err, "conn.read()")
 			break
 		}
 
 		if bufLen == 0 {
-			log.Errorf("conn.read() = bufLen:%d, addr:%s, err:%+v", bufLen, addr, perrors.WithStack(err))
+			s.logger(transportLog).Errorw("read empty package", "addr", addr)
 			continue
 		}
 
 		if bufLen == len(connectPingPackage) && bytes.Equal(connectPingPackage, buf[:bufLen]) {
-			log.Infof("got %s connectPingPackage", addr)
+			s.logger(transportLog).Infow("got connectPingPackage", "addr", addr)
 			continue
 		}
 
-		pkg, pkgLen, err = s.reader.Read(s, buf[:bufLen])
-		log.Debugf("s.reader.Read() = pkg:%#v, pkgLen:%d, err:%+v", pkg, pkgLen, perrors.WithStack(err))
-		if err == nil && s.maxMsgLen > 0 && bufLen > int(s.maxMsgLen) {
-			err = perrors.Errorf("Message Too Long, bufLen %d, session max message len %d", bufLen, s.maxMsgLen)
+		pkg, pkgLen, err = reader.Read(s, buf[:bufLen])
+		s.logger(codecLog).Debugw("decode", "pkgLen", pkgLen, "err", err)
+		if err == nil && maxLen > 0 && bufLen > int(maxLen) {
+			err = perrors.Wrapf(ErrMaxMsgLenExceeded, "Message Too Long, bufLen %d, session max message len %d", bufLen, maxLen)
 		}
 		if err != nil {
-			log.Warnf("%s, [session.handleUDPPackage] = len:%d, error:%+v",
-				s.sessionToken(), pkgLen, perrors.WithStack(err))
+			s.logger(codecLog).Warnw("decode package failed", "len", pkgLen, "err", err)
 			continue
 		}
 		if pkgLen == 0 {
-			log.Errorf("s.reader.Read() = pkg:%#v, pkgLen:%d, err:%+v", pkg, pkgLen, perrors.WithStack(err))
+			s.logger(codecLog).Errorw("decode package of zero length", "pkg", fmt.Sprintf("%#v", pkg))
 			continue
 		}
 
+		if newReadChunk(bufp).retain(pkg) {
+			// the PooledBuffer aliases buf, so the next packet is read into a new buffer
+			bufp = nil
+		}
+
 		s.UpdateActive()
-		s.addTask(UDPContext{Pkg: pkg, PeerAddr: addr})
+		if _, ok = pkg.(fragment); ok {
+			continue
+		}
+		s.addTask(UDPContext{Pkg: pkg, PeerAddr: addr}, pkgLen)
 	}
 
 	return perrors.WithStack(err)
@@ -787,10 +1514,13 @@ func (s *session) handleWSPackage() error {
 		conn         *gettyWSConn
 		pkg          []byte
 		unmarshalPkg interface{}
+		reader       Reader
+		maxLen       int32
 	)
 
 	conn = s.Connection.(*gettyWSConn)
 	for {
+		s.waitReadResumed()
 		if s.IsClosed() {
 			break
 		}
@@ -799,25 +1529,25 @@ func (s *session) handleWSPackage() error {
 			continue
 		}
 		if err != nil {
-			log.Warnf("%s, [session.handleWSPackage] = error:%+v",
-				s.sessionToken(), perrors.WithStack(err))
+			s.logger(transportLog).Warnw("read failed", "err", err)
 			return perrors.WithStack(err)
 		}
 		s.UpdateActive()
-		if s.reader != nil {
-			unmarshalPkg, length, err = s.reader.Read(s, pkg)
-			if err == nil && s.maxMsgLen > 0 && length > int(s.maxMsgLen) {
-				err = perrors.Errorf("Message Too Long, length %d, session max message len %d", length, s.maxMsgLen)
+		// the codec and the max message length set while the session is active take effect here
+		if reader, _ = s.codec(); reader != nil {
+			maxLen = s.maxMsgLength()
+			unmarshalPkg, length, err = reader.Read(s, pkg)
+			if err == nil && maxLen > 0 && length > int(maxLen) {
+				err = perrors.Wrapf(ErrMaxMsgLenExceeded, "Message Too Long, length %d, session max message len %d", length, maxLen)
 			}
 			if err != nil {
-				log.Warnf("%s, [session.handleWSPackage] = len:%d, error:%+v",
-					s.sessionToken(), length, perrors.WithStack(err))
+				s.logger(codecLog).Warnw("decode package failed", "len", length, "err", err)
 				continue
 			}
 
-			s.addTask(unmarshalPkg)
+			s.addTask(unmarshalPkg, len(pkg))
 		} else {
-			s.addTask(pkg)
+			s.addTask(pkg, len(pkg))
 		}
 	}
 
@@ -838,9 +1568,16 @@ func (s *session) stop() {
 				conn.SetWriteDeadline(now.Add(s.writeTimeout()))
 			}
 			close(s.done)
+			s.lock.RLock()
+			s.cancel()
+			s.lock.RUnlock()
+			s.writeQueue.wakeup()
+			s.readIdle.stop()
+			s.writeIdle.stop()
 			c := s.GetAttribute(sessionClientKey)
 			if clt, ok := c.(*client); ok {
-				clt.reConnect()
+				publishEvent(LifecycleEvent{Type: ReconnectStarted, EndPoint: clt, Session: s})
+				clt.reConnect(true)
 			}
 		})
 	}
@@ -857,18 +1594,52 @@ func (s *session) gc() {
 	}
 	s.lock.Unlock()
 
+	linger := int(s.wait)
+	if s.lingerSet {
+		linger = s.linger
+	}
 	go func() {
 		if conn != nil {
-			conn.close(int(s.wait))
+			conn.close(linger)
 		}
 	}()
 }
 
+func (s *session) setLinger(sec int) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	s.linger, s.lingerSet = sec, true
+}
+
+// CloseWithTimeout waits up to @timeout for the packages queued by WritePkgAsync to be written,
+// and then closes the session. It returns false if some packages are not written.
+func (s *session) CloseWithTimeout(timeout time.Duration) bool {
+	flushed := s.writeQueue.flush(timeout)
+	s.Close()
+
+	return flushed
+}
+
 // Close will be invoked by NewSessionCallback(if return error is not nil)
 // or (session)handleLoop automatically. It's thread safe.
 func (s *session) Close() {
+	s.CloseWithReason(CloseReasonLocal)
+}
+
+func (s *session) CloseWithReason(reason CloseReason) {
+	s.setCloseReason(reason)
 	s.stop()
-	log.Infof("%s closed now. its current gr num is %d", s.sessionToken(), s.grNum.Load())
+	s.logger(log).Infow("closed", "gr_num", s.grNum.Load())
+}
+
+func (s *session) CloseReason() CloseReason {
+	return CloseReason(s.reason.Load())
+}
+
+// setCloseReason sets the reason if the session has none.
+func (s *session) setCloseReason(reason CloseReason) {
+	s.reason.CAS(int32(CloseReasonUnknown), int32(reason))
 }
 
 // GetActive return connection's time
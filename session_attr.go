@@ -0,0 +1,71 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package getty
+
+// SessionAttr is a typed key of the session attributes, which saves the type assertions of
+// GetAttribute. Every SessionAttr is a distinct key even if their names are the same.
+//
+// All of its methods are safe to be called concurrently, as they are serialized by the lock of
+// the session. But the value itself is shared by the callers, so a mutable value, eg: a map,
+// should be replaced by Set instead of being modified in place, or be guarded by its own lock.
+// The attributes are dropped when the session is closed, then Get returns false and Set does nothing.
+type SessionAttr[T any] struct {
+	name string
+}
+
+// NewSessionAttr builds a session attribute key, @name is only used for debugging.
+func NewSessionAttr[T any](name string) *SessionAttr[T] {
+	return &SessionAttr[T]{name: name}
+}
+
+func (a *SessionAttr[T]) String() string {
+	return a.name
+}
+
+// Get returns the value of the attribute of @ss, false if it is not set.
+func (a *SessionAttr[T]) Get(ss Session) (T, bool) {
+	v, ok := ss.GetAttribute(a).(T)
+	return v, ok
+}
+
+// Set sets the value of the attribute of @ss.
+func (a *SessionAttr[T]) Set(ss Session, v T) {
+	ss.SetAttribute(a, v)
+}
+
+// Delete removes the attribute from @ss.
+func (a *SessionAttr[T]) Delete(ss Session) {
+	ss.RemoveAttribute(a)
+}
+
+// LoadOrStore returns the value of the attribute of @ss if it is set, otherwise it sets the
+// attribute to @v and returns it. The loaded result is true if the value was set before.
+func (a *SessionAttr[T]) LoadOrStore(ss Session, v T) (actual T, loaded bool) {
+	if s, ok := ss.(*session); ok {
+		raw, loaded := s.loadOrStoreAttribute(a, v)
+		actual, _ = raw.(T)
+		return actual, loaded
+	}
+
+	// the other implementations of Session, eg: a mock, are not atomic
+	if actual, loaded = a.Get(ss); loaded {
+		return actual, true
+	}
+	a.Set(ss, v)
+	return v, false
+}
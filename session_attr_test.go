@@ -0,0 +1,73 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package getty
+
+import (
+	"sync"
+	"testing"
+)
+
+import (
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSessionAttr(t *testing.T) {
+	ss, _ := newPipeSessions(t)
+	userID := NewSessionAttr[int64]("user-id")
+	other := NewSessionAttr[int64]("user-id")
+	assert.Equal(t, "user-id", userID.String())
+
+	_, ok := userID.Get(ss)
+	assert.False(t, ok)
+	userID.Set(ss, 42)
+	id, ok := userID.Get(ss)
+	assert.True(t, ok)
+	assert.Equal(t, int64(42), id)
+	// the keys of the same name are distinct
+	_, ok = other.Get(ss)
+	assert.False(t, ok)
+	// the attribute of another type is not mistaken
+	ss.SetAttribute("plain", "value")
+	_, ok = NewSessionAttr[int64]("plain").Get(ss)
+	assert.False(t, ok)
+
+	userID.Delete(ss)
+	_, ok = userID.Get(ss)
+	assert.False(t, ok)
+
+	counter := NewSessionAttr[*sync.Mutex]("lock")
+	var (
+		wg     sync.WaitGroup
+		lock   sync.Mutex
+		values = make(map[*sync.Mutex]struct{})
+	)
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			v, _ := counter.LoadOrStore(ss, &sync.Mutex{})
+			lock.Lock()
+			values[v] = struct{}{}
+			lock.Unlock()
+		}()
+	}
+	wg.Wait()
+	assert.Equal(t, 1, len(values))
+	_, loaded := counter.LoadOrStore(ss, &sync.Mutex{})
+	assert.True(t, loaded)
+}
@@ -0,0 +1,120 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package getty
+
+import (
+	"sync"
+	"time"
+)
+
+// SessionGroup is a concurrent safe set of sessions, eg: the members of a chat room.
+type SessionGroup struct {
+	lock     sync.RWMutex
+	sessions map[Session]struct{}
+}
+
+// NewSessionGroup builds an empty session group.
+func NewSessionGroup() *SessionGroup {
+	return &SessionGroup{sessions: make(map[Session]struct{})}
+}
+
+// Add adds @ss into the group, it returns false if @ss is closed or is in the group already.
+func (g *SessionGroup) Add(ss Session) bool {
+	if ss.IsClosed() {
+		return false
+	}
+
+	g.lock.Lock()
+	defer g.lock.Unlock()
+
+	if _, ok := g.sessions[ss]; ok {
+		return false
+	}
+	g.sessions[ss] = struct{}{}
+
+	return true
+}
+
+// Remove removes @ss from the group, it returns false if @ss is not in the group.
+func (g *SessionGroup) Remove(ss Session) bool {
+	g.lock.Lock()
+	defer g.lock.Unlock()
+
+	if _, ok := g.sessions[ss]; !ok {
+		return false
+	}
+	delete(g.sessions, ss)
+
+	return true
+}
+
+// Contains checks whether @ss is in the group.
+func (g *SessionGroup) Contains(ss Session) bool {
+	g.lock.RLock()
+	defer g.lock.RUnlock()
+
+	_, ok := g.sessions[ss]
+	return ok
+}
+
+// Len returns the number of the sessions in the group.
+func (g *SessionGroup) Len() int {
+	g.lock.RLock()
+	defer g.lock.RUnlock()
+
+	return len(g.sessions)
+}
+
+// Sessions returns the snapshot of the sessions in the group.
+func (g *SessionGroup) Sessions() []Session {
+	g.lock.RLock()
+	defer g.lock.RUnlock()
+
+	sessions := make([]Session, 0, len(g.sessions))
+	for ss := range g.sessions {
+		sessions = append(sessions, ss)
+	}
+
+	return sessions
+}
+
+// Broadcast writes @pkg to every session of the group by WritePkg with @timeout, and returns
+// the errors of the failed sessions, nil if all of them succeed. The closed sessions are
+// removed from the group. The membership can be changed while broadcasting, the sessions
+// added meanwhile may not receive @pkg.
+func (g *SessionGroup) Broadcast(pkg interface{}, timeout time.Duration) map[Session]error {
+	var errs map[Session]error
+	for _, ss := range g.Sessions() {
+		err := ErrSessionClosed
+		if !ss.IsClosed() {
+			_, _, err = ss.WritePkg(pkg, timeout)
+		}
+		if err == nil {
+			continue
+		}
+		if ss.IsClosed() {
+			g.Remove(ss)
+		}
+		if errs == nil {
+			errs = make(map[Session]error)
+		}
+		errs[ss] = err
+	}
+
+	return errs
+}
@@ -0,0 +1,74 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package getty
+
+import (
+	"testing"
+	"time"
+)
+
+import (
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSessionGroup(t *testing.T) {
+	group := NewSessionGroup()
+	clientHandlers := make([]*chanMessageHandler, 0, 2)
+	for i := 0; i < 2; i++ {
+		clientEndPoint, serverEndPoint := NewPipeEndpoint()
+		defer clientEndPoint.Close()
+		defer serverEndPoint.Close()
+		clientHandler := newChanMessageHandler()
+		clientHandlers = append(clientHandlers, clientHandler)
+		clientEndPoint.RunEventLoop(func(session Session) error {
+			session.SetPkgHandler(&stringPkgHandler{})
+			session.SetEventListener(clientHandler)
+			return nil
+		})
+		serverEndPoint.RunEventLoop(func(session Session) error {
+			session.SetPkgHandler(&stringPkgHandler{})
+			session.SetEventListener(newChanMessageHandler())
+			assert.True(t, group.Add(session))
+			assert.False(t, group.Add(session))
+			return nil
+		})
+	}
+	assert.Equal(t, 2, group.Len())
+
+	assert.Nil(t, group.Broadcast("hello", 0))
+	for _, h := range clientHandlers {
+		select {
+		case msg := <-h.msgs:
+			assert.Equal(t, "hello", msg)
+		case <-time.After(3 * time.Second):
+			t.Fatal("client did not receive the package")
+		}
+	}
+
+	closed := group.Sessions()[0]
+	closed.Close()
+	errs := group.Broadcast("world", 0)
+	assert.Equal(t, 1, len(errs))
+	assert.Equal(t, ErrSessionClosed, errs[closed])
+	assert.False(t, group.Contains(closed))
+	assert.Equal(t, 1, group.Len())
+
+	assert.True(t, group.Remove(group.Sessions()[0]))
+	assert.Equal(t, 0, group.Len())
+	assert.Nil(t, group.Broadcast("again", 0))
+}
@@ -0,0 +1,140 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package getty
+
+import (
+	"sync"
+	"time"
+)
+
+// IdleKind is the kind of the idle event of a session.
+type IdleKind int
+
+const (
+	// ReadIdle means nothing has been read for the read idle timeout, eg: the peer is dead.
+	ReadIdle IdleKind = iota
+	// WriteIdle means nothing has been written for the write idle timeout, eg: to send a heartbeat.
+	WriteIdle
+)
+
+func (k IdleKind) String() string {
+	switch k {
+	case ReadIdle:
+		return "ReadIdle"
+	case WriteIdle:
+		return "WriteIdle"
+	}
+
+	return "UnknownIdle"
+}
+
+// IdleListener is implemented by the EventListener which wants to know when the session is idle,
+// see Session.SetReadIdleTimeout and Session.SetWriteIdleTimeout.
+type IdleListener interface {
+	// OnIdle invoked every idle timeout while the session keeps idle.
+	OnIdle(Session, IdleKind)
+}
+
+// idleTimer fires OnIdle when the session has not read or written for its timeout. It is rearmed
+// by the time of the last read or write, so the busy session costs no timer operations per package.
+type idleTimer struct {
+	kind    IdleKind
+	lock    sync.Mutex
+	timeout time.Duration
+	timer   ClockTimer
+	gen     int // increased by every set, to ignore the stale timers
+}
+
+func (t *idleTimer) set(ss *session, timeout time.Duration) {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+
+	t.stopLocked()
+	t.timeout = timeout
+	if timeout > 0 && !ss.IsClosed() {
+		t.arm(ss, timeout)
+	}
+}
+
+func (t *idleTimer) arm(ss *session, d time.Duration) {
+	gen := t.gen
+	t.timer = clockOrSystem(clockOf(ss.endPoint)).AfterFunc(d, func() { t.check(ss, gen) })
+}
+
+func (t *idleTimer) check(ss *session, gen int) {
+	t.lock.Lock()
+	if gen != t.gen || ss.IsClosed() {
+		t.lock.Unlock()
+		return
+	}
+	idle := clockOrSystem(clockOf(ss.endPoint)).Now().Sub(ss.lastActive(t.kind))
+	if idle < t.timeout {
+		t.arm(ss, t.timeout-idle)
+		t.lock.Unlock()
+		return
+	}
+	t.arm(ss, t.timeout)
+	t.lock.Unlock()
+
+	if l, ok := ss.listener.(IdleListener); ok {
+		l.OnIdle(ss, t.kind)
+	}
+}
+
+func (t *idleTimer) stop() {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+
+	t.stopLocked()
+}
+
+func (t *idleTimer) stopLocked() {
+	t.gen++
+	if t.timer != nil {
+		t.timer.Stop()
+		t.timer = nil
+	}
+}
+
+// lastActive returns the time of the last read or write, or the connect time if there is none.
+func (s *session) lastActive(kind IdleKind) time.Time {
+	s.lock.RLock()
+	defer s.lock.RUnlock()
+
+	conn := s.gettyConn()
+	if conn == nil {
+		return clockOrSystem(clockOf(s.endPoint)).Now()
+	}
+	last := conn.lastRead.Load()
+	if kind == WriteIdle {
+		last = conn.lastWrite.Load()
+	}
+	if last == 0 {
+		return conn.connected
+	}
+
+	return launchTime.Add(time.Duration(last))
+}
+
+func (s *session) SetReadIdleTimeout(timeout time.Duration) {
+	s.readIdle.set(s, timeout)
+}
+
+func (s *session) SetWriteIdleTimeout(timeout time.Duration) {
+	s.writeIdle.set(s, timeout)
+}
@@ -0,0 +1,113 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package getty
+
+import (
+	"time"
+)
+
+import (
+	uatomic "go.uber.org/atomic"
+)
+
+// MaxSessionsPolicy is what the server does to the new connections when its sessions reach the
+// limit of WithMaxSessions or WithMaxSessionsPerListener.
+type MaxSessionsPolicy int
+
+const (
+	// MaxSessionsBlock stops accepting until a session is closed, so the new connections wait
+	// in the backlog of the listener. The connections in their tls handshakes, PROXY headers or
+	// the handshakes of WithHandshake count as sessions.
+	MaxSessionsBlock MaxSessionsPolicy = iota
+	// MaxSessionsReject accepts the new connections and closes them at once.
+	MaxSessionsReject
+)
+
+const (
+	// sessionLimitCheckInterval is the interval to check the session number when accepting is blocked
+	sessionLimitCheckInterval = 10 * time.Millisecond
+)
+
+var sessionListenerKey = "session-listener-limit"
+
+// listenerLimit counts the sessions accepted by a listener.
+type listenerLimit struct {
+	addr     string
+	num      uatomic.Int32 // the active sessions
+	pending  uatomic.Int32 // the admitted connections which are not accepted as sessions yet
+	accepted uatomic.Uint64
+}
+
+// reachLimit checks whether the sessions of the server or the listener of @limit reach their limits.
+// The admitted connections which are not served yet count as sessions.
+func (s *server) reachLimit(limit *listenerLimit) bool {
+	if s.maxSessions > 0 && s.SessionCount()+int(s.pending.Load()) >= s.maxSessions {
+		return true
+	}
+
+	return limit != nil && s.maxSessionsPerListener > 0 &&
+		int(limit.num.Load()+limit.pending.Load()) >= s.maxSessionsPerListener
+}
+
+// onLimit records that the limits are reached and invokes the callback of WithMaxSessionsCallback.
+func (s *server) onLimit() {
+	s.limitHits.Inc()
+	num := s.SessionCount()
+	endPointLogger(log, s).Warnw("sessions reach the limit", "sessions", num)
+	publishEvent(LifecycleEvent{Type: LimitHit, EndPoint: s, Sessions: num})
+	if s.maxSessionsCallback != nil {
+		s.maxSessionsCallback(num)
+	}
+}
+
+// waitForLimit blocks the accept loop of the listener of @limit until the sessions are below the limits.
+func (s *server) waitForLimit(limit *listenerLimit) {
+	if s.maxSessionsPolicy != MaxSessionsBlock || !s.reachLimit(limit) {
+		return
+	}
+
+	s.onLimit()
+	ticker := time.NewTicker(sessionLimitCheckInterval)
+	defer ticker.Stop()
+	for s.reachLimit(limit) {
+		select {
+		case <-s.done:
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// rejectForLimit checks whether a new connection of the listener of @limit should be closed.
+func (s *server) rejectForLimit(limit *listenerLimit) bool {
+	if s.maxSessionsPolicy == MaxSessionsBlock && limit != nil {
+		// the accept loop has been blocked by waitForLimit
+		return false
+	}
+	if !s.reachLimit(limit) {
+		return false
+	}
+
+	s.onLimit()
+	return true
+}
+
+// SessionLimitHits returns how many times the new connections are blocked or rejected by the limits.
+func (s *server) SessionLimitHits() uint64 {
+	return s.limitHits.Load()
+}
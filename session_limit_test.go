@@ -0,0 +1,138 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package getty
+
+import (
+	"io"
+	"net"
+	"testing"
+	"time"
+)
+
+import (
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMaxSessionsReject(t *testing.T) {
+	limited := make(chan int, 1)
+	s := NewTCPServer(
+		WithLocalAddress("127.0.0.1:0"),
+		WithMaxSessions(1),
+		WithMaxSessionsPolicy(MaxSessionsReject),
+		WithMaxSessionsCallback(func(sessionNum int) { limited <- sessionNum }),
+	).(*server)
+	defer s.Close()
+	s.RunEventLoop(func(session Session) error {
+		session.SetPkgHandler(&stringPkgHandler{})
+		session.SetEventListener(newChanMessageHandler())
+		return nil
+	})
+
+	conn1, err := net.Dial("tcp", s.addr)
+	assert.Nil(t, err)
+	defer conn1.Close()
+	for i := 0; s.SessionCount() == 0 && i < 100; i++ {
+		time.Sleep(10 * time.Millisecond)
+	}
+	conn2, err := net.Dial("tcp", s.addr)
+	assert.Nil(t, err)
+	defer conn2.Close()
+	conn2.SetReadDeadline(time.Now().Add(3 * time.Second))
+	_, err = conn2.Read(make([]byte, 1))
+	assert.Equal(t, io.EOF, err)
+	assert.Equal(t, 1, <-limited)
+	assert.Equal(t, uint64(1), s.SessionLimitHits())
+	assert.Equal(t, 1, s.SessionCount())
+}
+
+func TestMaxSessionsPendingHandshake(t *testing.T) {
+	started, release := make(chan struct{}, 2), make(chan struct{})
+	s := NewTCPServer(
+		WithLocalAddress("127.0.0.1:0"),
+		WithMaxSessions(1),
+		WithMaxSessionsPolicy(MaxSessionsReject),
+		WithHandshake(func(Session) error {
+			started <- struct{}{}
+			<-release
+			return nil
+		}),
+	).(*server)
+	defer s.Close()
+	s.RunEventLoop(func(session Session) error {
+		session.SetPkgHandler(&stringPkgHandler{})
+		session.SetEventListener(newChanMessageHandler())
+		return nil
+	})
+
+	// the connection in its handshake counts as a session
+	conn1, err := net.Dial("tcp", s.addr)
+	assert.Nil(t, err)
+	defer conn1.Close()
+	select {
+	case <-started:
+	case <-time.After(3 * time.Second):
+		t.Fatal("server did not start the handshake")
+	}
+	conn2, err := net.Dial("tcp", s.addr)
+	assert.Nil(t, err)
+	defer conn2.Close()
+	conn2.SetReadDeadline(time.Now().Add(3 * time.Second))
+	_, err = conn2.Read(make([]byte, 1))
+	assert.Equal(t, io.EOF, err)
+	assert.Equal(t, 0, len(started))
+
+	close(release)
+	for i := 0; s.SessionCount() == 0 && i < 100; i++ {
+		time.Sleep(10 * time.Millisecond)
+	}
+	assert.Equal(t, 1, s.SessionCount())
+	assert.Equal(t, int32(0), s.pending.Load())
+}
+
+func TestMaxSessionsBlock(t *testing.T) {
+	s := NewTCPServer(WithLocalAddress("127.0.0.1:0"), WithMaxSessionsPerListener(1)).(*server)
+	defer s.Close()
+	accepted := make(chan Session, 2)
+	s.RunEventLoop(func(session Session) error {
+		session.SetPkgHandler(&stringPkgHandler{})
+		session.SetEventListener(newChanMessageHandler())
+		accepted <- session
+		return nil
+	})
+	waitAccepted := func() {
+		select {
+		case <-accepted:
+		case <-time.After(3 * time.Second):
+			t.Fatal("server did not accept the connection")
+		}
+	}
+
+	conn1, err := net.Dial("tcp", s.addr)
+	assert.Nil(t, err)
+	waitAccepted()
+	// the second connection waits in the backlog
+	conn2, err := net.Dial("tcp", s.addr)
+	assert.Nil(t, err)
+	defer conn2.Close()
+	time.Sleep(50 * time.Millisecond)
+	assert.Equal(t, 0, len(accepted))
+	assert.Equal(t, uint64(1), s.SessionLimitHits())
+
+	conn1.Close()
+	waitAccepted()
+}
@@ -0,0 +1,128 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package getty
+
+import (
+	"bufio"
+	"io"
+	"net"
+)
+
+import (
+	perrors "github.com/pkg/errors"
+)
+
+// tcpStream is the io.Reader of the tcp stream for the StreamReader, which retries on the read
+// timeout until the session is closed.
+type tcpStream struct {
+	s    *session
+	conn *gettyTCPConn
+}
+
+func (r *tcpStream) Read(p []byte) (int, error) {
+	for {
+		n, err := r.conn.recv(p)
+		if n > 0 || err == nil {
+			return n, nil
+		}
+		if netError, ok := perrors.Cause(err).(net.Error); ok && netError.Timeout() {
+			if r.s.IsClosed() {
+				return 0, ErrSessionClosed
+			}
+			continue
+		}
+		if perrors.Cause(err) == io.EOF {
+			return 0, io.EOF
+		}
+		return 0, err
+	}
+}
+
+// limitedStream fails the Decode which reads more than @limit bytes, 0 @limit means no limit.
+type limitedStream struct {
+	r     io.Reader
+	n     int64
+	limit int64
+}
+
+func (l *limitedStream) Read(p []byte) (int, error) {
+	if l.limit > 0 {
+		if l.n >= l.limit {
+			return 0, perrors.Wrapf(ErrMaxMsgLenExceeded, "pkgLen > session max message len %d", l.limit)
+		}
+		if int64(len(p)) > l.limit-l.n {
+			p = p[:l.limit-l.n]
+		}
+	}
+	n, err := l.r.Read(p)
+	l.n += int64(n)
+	return n, err
+}
+
+// get package from tcp stream by StreamReader
+func (s *session) handleTCPStream(conn *gettyTCPConn) error {
+	var (
+		err    error
+		eof    bool
+		pkg    interface{}
+		reader Reader
+	)
+
+	stream := &limitedStream{r: bufio.NewReaderSize(&tcpStream{s: s, conn: conn}, maxReadBufLen)}
+	for {
+		s.waitReadResumed()
+		if s.IsClosed() {
+			err = nil
+			break
+		}
+
+		// the codec and the max message length set while the session is active take effect here
+		reader, _ = s.codec()
+		decoder, ok := reader.(StreamReader)
+		if !ok {
+			err = perrors.Errorf("reader %T is not a StreamReader", reader)
+			break
+		}
+		stream.n, stream.limit = 0, int64(s.maxMsgLength())
+		pkg, err = decoder.Decode(stream)
+		if err != nil {
+			if perrors.Cause(err) == ErrSessionClosed {
+				err = nil
+				break
+			}
+			if perrors.Cause(err) == io.EOF {
+				s.logger(transportLog).Infow("read EOF, the session exits")
+				err = nil
+				eof = true
+				break
+			}
+			s.logger(transportLog).Warnw("decode stream failed", "err", err)
+			break
+		}
+		if pkg != nil {
+			s.UpdateActive()
+			s.addTask(pkg, int(stream.n))
+		}
+	}
+	if eof {
+		s.setCloseReason(CloseReasonPeerClosed)
+		s.waitHalfClose()
+	}
+
+	return perrors.WithStack(err)
+}
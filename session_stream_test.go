@@ -0,0 +1,104 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package getty
+
+import (
+	"crypto/sha256"
+	"encoding/binary"
+	"errors"
+	"io"
+	"strings"
+	"testing"
+	"time"
+)
+
+import (
+	"github.com/stretchr/testify/assert"
+)
+
+// digestStreamHandler decodes the frames of stringPkgHandler into their sha256 digest without
+// buffering the whole frame.
+type digestStreamHandler struct {
+	stringPkgHandler
+}
+
+func (h *digestStreamHandler) Decode(r io.Reader) (interface{}, error) {
+	var header [4]byte
+	if _, err := io.ReadFull(r, header[:]); err != nil {
+		return nil, err
+	}
+	digest := sha256.New()
+	if _, err := io.CopyN(digest, r, int64(binary.BigEndian.Uint32(header[:]))); err != nil {
+		return nil, err
+	}
+	return string(digest.Sum(nil)), nil
+}
+
+func TestSessionStreamReader(t *testing.T) {
+	clientEndPoint, serverEndPoint := NewPipeEndpoint()
+	t.Cleanup(func() {
+		clientEndPoint.Close()
+		serverEndPoint.Close()
+	})
+	clientHandler, serverHandler := newChanMessageHandler(), newChanMessageHandler()
+	clientEndPoint.RunEventLoop(func(session Session) error {
+		session.SetPkgHandler(&stringPkgHandler{})
+		session.SetEventListener(clientHandler)
+		return nil
+	})
+	serverEndPoint.RunEventLoop(func(session Session) error {
+		session.SetMaxMsgLen(1 << 20)
+		session.SetPkgHandler(&digestStreamHandler{})
+		session.SetEventListener(serverHandler)
+		return nil
+	})
+	ss := clientHandler.array[0]
+
+	// the message is much larger than the read buffer
+	msg := strings.Repeat("getty", 64*1024)
+	for _, m := range []string{msg, "ping"} {
+		_, _, err := ss.WritePkg(m, 0)
+		assert.Nil(t, err)
+		sum := sha256.Sum256([]byte(m))
+		select {
+		case pkg := <-serverHandler.msgs:
+			assert.Equal(t, string(sum[:]), pkg)
+		case <-time.After(3 * time.Second):
+			t.Fatal("the message is not decoded")
+		}
+	}
+
+	// the message over the max message length fails the session
+	_, _, err := ss.WritePkg(strings.Repeat(msg, 4), 0)
+	assert.Nil(t, err)
+	select {
+	case <-serverHandler.closed:
+	case <-time.After(3 * time.Second):
+		t.Fatal("the session is not closed")
+	}
+}
+
+func TestLimitedStream(t *testing.T) {
+	r := &limitedStream{r: strings.NewReader("hello"), limit: 4}
+	buf := make([]byte, 8)
+	n, err := r.Read(buf)
+	assert.Nil(t, err)
+	assert.Equal(t, 4, n)
+	_, err = r.Read(buf)
+	assert.True(t, errors.Is(err, ErrMaxMsgLenExceeded))
+}
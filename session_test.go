@@ -0,0 +1,586 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package getty
+
+import (
+	"context"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"net"
+	"strings"
+	"testing"
+	"time"
+)
+
+import (
+	"github.com/stretchr/testify/assert"
+
+	uatomic "go.uber.org/atomic"
+)
+
+// newPipeSessions returns the client session of a pipe endpoint and the handler of its server session.
+func newPipeSessions(t *testing.T) (Session, *chanMessageHandler) {
+	clientEndPoint, serverEndPoint := NewPipeEndpoint()
+	t.Cleanup(func() {
+		clientEndPoint.Close()
+		serverEndPoint.Close()
+	})
+	clientHandler, serverHandler := newChanMessageHandler(), newChanMessageHandler()
+	clientEndPoint.RunEventLoop(func(session Session) error {
+		session.SetPkgHandler(&stringPkgHandler{})
+		session.SetEventListener(clientHandler)
+		return nil
+	})
+	serverEndPoint.RunEventLoop(func(session Session) error {
+		session.SetPkgHandler(&stringPkgHandler{})
+		session.SetEventListener(serverHandler)
+		return nil
+	})
+
+	return clientHandler.array[0], serverHandler
+}
+
+type traceIDKey struct{}
+
+func TestSessionContext(t *testing.T) {
+	ss, _ := newPipeSessions(t)
+	ctx := ss.Context()
+	assert.Nil(t, ctx.Err())
+
+	ss.SetContext(context.WithValue(context.Background(), traceIDKey{}, "trace-1"))
+	// the former context is cancelled
+	assert.Equal(t, context.Canceled, ctx.Err())
+	ctx = ss.Context()
+	assert.Equal(t, "trace-1", ctx.Value(traceIDKey{}))
+	assert.Nil(t, ctx.Err())
+
+	ss.Close()
+	select {
+	case <-ctx.Done():
+	case <-time.After(3 * time.Second):
+		t.Fatal("session context is not cancelled")
+	}
+	ss.SetContext(context.Background())
+	assert.NotNil(t, ss.Context().Err())
+}
+
+func TestSessionWritePkgAsync(t *testing.T) {
+	ss, serverHandler := newPipeSessions(t)
+
+	const num = 8
+	results := make(chan error, num)
+	for i := 0; i < num; i++ {
+		ss.WritePkgAsync(fmt.Sprintf("pkg-%d", i), 0, func(err error) {
+			results <- err
+		})
+	}
+	for i := 0; i < num; i++ {
+		select {
+		case msg := <-serverHandler.msgs:
+			assert.Equal(t, fmt.Sprintf("pkg-%d", i), msg)
+		case <-time.After(3 * time.Second):
+			t.Fatal("server did not receive the package")
+		}
+		assert.Nil(t, <-results)
+	}
+
+	ss.Close()
+	ss.WritePkgAsync("closed", 0, func(err error) {
+		results <- err
+	})
+	select {
+	case err := <-results:
+		assert.Equal(t, ErrSessionClosed, err)
+	case <-time.After(3 * time.Second):
+		t.Fatal("callback is not invoked")
+	}
+}
+
+func TestSessionWriteBatchPkg(t *testing.T) {
+	ss, serverHandler := newPipeSessions(t)
+
+	_, err := ss.WriteBatchPkg([]interface{}{"a", nil}, 0)
+	assert.NotNil(t, err)
+	n, err := ss.WriteBatchPkg([]interface{}{"hello", "getty", "batch"}, 0)
+	assert.Nil(t, err)
+	assert.Equal(t, 3*(4+5), n)
+	for _, want := range []string{"hello", "getty", "batch"} {
+		select {
+		case msg := <-serverHandler.msgs:
+			assert.Equal(t, want, msg)
+		case <-time.After(3 * time.Second):
+			t.Fatal("server did not receive the package")
+		}
+	}
+}
+
+type writableMessageHandler struct {
+	*chanMessageHandler
+	writable chan struct{}
+}
+
+func (h *writableMessageHandler) OnWritable(session Session) {
+	h.writable <- struct{}{}
+}
+
+func TestSessionWriteWatermark(t *testing.T) {
+	clientEndPoint, serverEndPoint := NewPipeEndpoint()
+	defer clientEndPoint.Close()
+	defer serverEndPoint.Close()
+	clientHandler := &writableMessageHandler{chanMessageHandler: newChanMessageHandler(), writable: make(chan struct{}, 1)}
+	serverHandler := newChanMessageHandler()
+	clientEndPoint.RunEventLoop(func(session Session) error {
+		session.SetPkgHandler(&stringPkgHandler{})
+		session.SetEventListener(clientHandler)
+		return nil
+	})
+	serverEndPoint.RunEventLoop(func(session Session) error {
+		session.SetPkgHandler(&stringPkgHandler{})
+		session.SetEventListener(serverHandler)
+		return nil
+	})
+	ss := clientHandler.array[0]
+	assert.Panics(t, func() { ss.SetWriteWatermark(20, 10) })
+	ss.SetWriteWatermark(100, 200)
+
+	// the server handler blocks when its channel is full, so do the writes on the pipe
+	const num = 64
+	for i := 0; i < num; i++ {
+		ss.WritePkgAsync("hello", 0, nil)
+	}
+	assert.False(t, ss.IsWritable())
+	assert.True(t, ss.PendingWriteBytes() > 200)
+
+	for i := 0; i < num; i++ {
+		select {
+		case <-serverHandler.msgs:
+		case <-time.After(3 * time.Second):
+			t.Fatal("server did not receive the package")
+		}
+	}
+	select {
+	case <-clientHandler.writable:
+	case <-time.After(3 * time.Second):
+		t.Fatal("OnWritable is not invoked")
+	}
+	assert.True(t, ss.IsWritable())
+	assert.Eventually(t, func() bool { return ss.PendingWriteBytes() == 0 }, 3*time.Second, 10*time.Millisecond)
+}
+
+func TestSessionWriteOverflowPolicy(t *testing.T) {
+	const num = 64
+	drain := func(server *chanMessageHandler, n int) {
+		for i := 0; i < n; i++ {
+			select {
+			case <-server.msgs:
+			case <-time.After(3 * time.Second):
+				t.Fatal("server did not receive the package")
+			}
+		}
+	}
+
+	// every "hello" package costs 9 bytes, so the queue holds up to 10 packages.
+	// the server handler blocks when its channel is full, so do the writes on the pipe
+	ss, server := newPipeSessions(t)
+	ss.SetWriteOverflowPolicy(WriteOverflowDropNewest, 90)
+	var dropped uatomic.Int32
+	for i := 0; i < num; i++ {
+		ss.WritePkgAsync("hello", 0, func(err error) {
+			if err == ErrWriteOverflow {
+				dropped.Add(1)
+			}
+		})
+	}
+	stats := ss.WriteOverflowStats()
+	assert.True(t, stats.DroppedNewest > 0)
+	assert.Equal(t, int32(stats.DroppedNewest), dropped.Load())
+	drain(server, num-int(stats.DroppedNewest))
+
+	ss, server = newPipeSessions(t)
+	ss.SetWriteOverflowPolicy(WriteOverflowDropOldest, 90)
+	dropped.Store(0)
+	for i := 0; i < num; i++ {
+		ss.WritePkgAsync("hello", 0, func(err error) {
+			if err == ErrWriteOverflow {
+				dropped.Add(1)
+			}
+		})
+	}
+	stats = ss.WriteOverflowStats()
+	assert.True(t, stats.DroppedOldest > 0)
+	assert.Equal(t, int32(stats.DroppedOldest), dropped.Load())
+	drain(server, num-int(stats.DroppedOldest))
+
+	ss, server = newPipeSessions(t)
+	ss.SetWriteOverflowPolicy(WriteOverflowBlock, 90)
+	written := make(chan struct{})
+	go func() {
+		for i := 0; i < num; i++ {
+			ss.WritePkgAsync("hello", 0, nil)
+		}
+		close(written)
+	}()
+	assert.Eventually(t, func() bool { return ss.WriteOverflowStats().Blocked > 0 }, 3*time.Second, 10*time.Millisecond)
+	drain(server, num)
+	<-written
+	assert.True(t, ss.PendingWriteBytes() <= 90)
+
+	ss, _ = newPipeSessions(t)
+	ss.SetWriteOverflowPolicy(WriteOverflowClose, 90)
+	for i := 0; i < num && !ss.IsClosed(); i++ {
+		ss.WritePkgAsync("hello", 0, nil)
+	}
+	assert.True(t, ss.IsClosed())
+	assert.Equal(t, uint64(1), ss.WriteOverflowStats().Closed)
+}
+
+func TestWriteQueueDropOldestWritable(t *testing.T) {
+	handler := &writableMessageHandler{writable: make(chan struct{}, 1)}
+	ss := &session{listener: handler}
+	q := &ss.writeQueue
+	q.init(ss)
+	// keep the queued packages from being written
+	q.running = true
+	q.setWatermark(60, 80)
+	q.setOverflowPolicy(WriteOverflowDropOldest, 100)
+
+	assert.Nil(t, q.push(&asyncWrite{size: 90}))
+	assert.False(t, q.writable())
+	assert.Nil(t, q.push(&asyncWrite{size: 5}))
+	assert.False(t, q.writable())
+
+	// dropping the 90 bytes package releases the pending bytes below the low watermark
+	assert.Nil(t, q.push(&asyncWrite{size: 10}))
+	assert.Equal(t, uint64(1), q.stats.DroppedOldest)
+	assert.Equal(t, 15, q.pendingBytes())
+	assert.True(t, q.writable())
+	select {
+	case <-handler.writable:
+	default:
+		t.Fatal("OnWritable is not invoked")
+	}
+}
+
+func TestSessionWriteOptions(t *testing.T) {
+	ss, server := newPipeSessions(t)
+	_, _, err := ss.WritePkg("stale", 0, WithDeadline(time.Now().Add(-time.Second)))
+	assert.Equal(t, ErrWriteExpired, err)
+	assert.Panics(t, func() { ss.WritePkgAsync("hello", 0, nil, WithPriority(writePriorityNum)) })
+
+	// the server handler blocks when its channel is full, so the bulk packages are queued
+	const num = 64
+	for i := 0; i < num; i++ {
+		ss.WritePkgAsync("bulk", 0, nil, WithPriority(WritePriorityLow))
+	}
+	expired := make(chan error, 1)
+	ss.WritePkgAsync("stale", 0, func(err error) { expired <- err },
+		WithPriority(WritePriorityLow), WithDeadline(time.Now().Add(10*time.Millisecond)))
+	ss.WritePkgAsync("urgent", 0, nil, WithPriority(WritePriorityHigh))
+	time.Sleep(20 * time.Millisecond)
+
+	urgent := -1
+	for i := 0; i < num+1; i++ {
+		select {
+		case msg := <-server.msgs:
+			assert.NotEqual(t, "stale", msg)
+			if msg == "urgent" {
+				urgent = i
+			}
+		case <-time.After(3 * time.Second):
+			t.Fatal("server did not receive the package")
+		}
+	}
+	// the urgent package jumps ahead of the queued bulk packages
+	assert.True(t, urgent >= 0 && urgent < num)
+	assert.Equal(t, ErrWriteExpired, <-expired)
+}
+
+type halfCloseMessageHandler struct {
+	*chanMessageHandler
+	halfClosed chan Session
+}
+
+func (h *halfCloseMessageHandler) OnHalfClose(session Session) {
+	h.halfClosed <- session
+}
+
+func TestSessionHalfClose(t *testing.T) {
+	ss, _ := newPipeSessions(t)
+	assert.Equal(t, ErrHalfClose, ss.CloseWrite())
+	assert.Equal(t, ErrHalfClose, ss.CloseRead())
+
+	s := NewTCPServer(WithLocalAddress("127.0.0.1:0")).(*server)
+	defer s.Close()
+	serverHandler := &halfCloseMessageHandler{chanMessageHandler: newChanMessageHandler(), halfClosed: make(chan Session, 1)}
+	s.RunEventLoop(func(session Session) error {
+		session.SetPkgHandler(&stringPkgHandler{})
+		session.SetEventListener(serverHandler)
+		return nil
+	})
+
+	conn, err := net.Dial("tcp", s.addr)
+	assert.Nil(t, err)
+	defer conn.Close()
+	pkg := make([]byte, 4, 9)
+	binary.BigEndian.PutUint32(pkg, 5)
+	_, err = conn.Write(append(pkg, "hello"...))
+	assert.Nil(t, err)
+	assert.Nil(t, conn.(*net.TCPConn).CloseWrite())
+
+	// the server session keeps writing after the peer half-closes
+	select {
+	case ss = <-serverHandler.halfClosed:
+	case <-time.After(3 * time.Second):
+		t.Fatal("OnHalfClose is not invoked")
+	}
+	assert.Equal(t, "hello", <-serverHandler.msgs)
+	assert.False(t, ss.IsClosed())
+	_, _, err = ss.WritePkg("reply", 0)
+	assert.Nil(t, err)
+	assert.Nil(t, ss.CloseWrite())
+
+	conn.SetReadDeadline(time.Now().Add(3 * time.Second))
+	reply, err := io.ReadAll(conn)
+	assert.Nil(t, err)
+	assert.Equal(t, "reply", string(reply[4:]))
+
+	ss.Close()
+	select {
+	case <-serverHandler.closed:
+	case <-time.After(3 * time.Second):
+		t.Fatal("OnClose is not invoked")
+	}
+}
+
+func TestSessionPauseRead(t *testing.T) {
+	ss, serverHandler := newPipeSessions(t)
+	server := serverHandler.array[0]
+
+	// the reading in progress may take one package before the pause takes effect
+	server.PauseRead()
+	server.PauseRead()
+	const num = 3
+	for i := 0; i < num; i++ {
+		ss.WritePkgAsync(fmt.Sprintf("pkg-%d", i), 0, nil)
+	}
+	time.Sleep(100 * time.Millisecond)
+	assert.True(t, len(serverHandler.msgs) <= 1)
+	assert.True(t, ss.PendingWriteBytes() > 0)
+
+	server.ResumeRead()
+	server.ResumeRead()
+	for i := 0; i < num; i++ {
+		select {
+		case msg := <-serverHandler.msgs:
+			assert.Equal(t, fmt.Sprintf("pkg-%d", i), msg)
+		case <-time.After(3 * time.Second):
+			t.Fatal("server did not receive the package")
+		}
+	}
+}
+
+func TestSessionStats(t *testing.T) {
+	begin := time.Now()
+	ss, serverHandler := newPipeSessions(t)
+	stats := ss.Stats()
+	assert.False(t, stats.ConnectTime.Before(begin))
+	assert.True(t, stats.LastReadTime.IsZero())
+	assert.True(t, stats.LastWriteTime.IsZero())
+
+	_, _, err := ss.WritePkg("hello", 0)
+	assert.Nil(t, err)
+	assert.Equal(t, "hello", <-serverHandler.msgs)
+	stats = ss.Stats()
+	assert.Equal(t, uint64(4+5), stats.WriteBytes)
+	assert.Equal(t, uint64(1), stats.WritePkgs)
+	assert.False(t, stats.LastWriteTime.Before(stats.ConnectTime))
+	assert.Equal(t, 0, stats.PendingWrites)
+
+	server := serverHandler.array[0]
+	assert.Eventually(t, func() bool { return server.Stats().ReadPkgs == 1 }, 3*time.Second, 10*time.Millisecond)
+	stats = server.Stats()
+	assert.Equal(t, uint64(4+5), stats.ReadBytes)
+	assert.False(t, stats.LastReadTime.IsZero())
+}
+
+type idleMessageHandler struct {
+	*chanMessageHandler
+	idle chan IdleKind
+}
+
+func (h *idleMessageHandler) OnIdle(session Session, kind IdleKind) {
+	select {
+	case h.idle <- kind:
+	default:
+	}
+}
+
+func TestSessionIdleTimeout(t *testing.T) {
+	clientEndPoint, serverEndPoint := NewPipeEndpoint()
+	defer clientEndPoint.Close()
+	defer serverEndPoint.Close()
+	clientHandler := &idleMessageHandler{chanMessageHandler: newChanMessageHandler(), idle: make(chan IdleKind, 8)}
+	serverHandler := newChanMessageHandler()
+	clientEndPoint.RunEventLoop(func(session Session) error {
+		session.SetPkgHandler(&stringPkgHandler{})
+		session.SetEventListener(clientHandler)
+		return nil
+	})
+	serverEndPoint.RunEventLoop(func(session Session) error {
+		session.SetPkgHandler(&stringPkgHandler{})
+		session.SetEventListener(serverHandler)
+		return nil
+	})
+	ss := clientHandler.array[0]
+
+	// the client writes every 10ms but never reads
+	ss.SetReadIdleTimeout(50 * time.Millisecond)
+	ss.SetWriteIdleTimeout(50 * time.Millisecond)
+	for i := 0; i < 10; i++ {
+		_, _, err := ss.WritePkg("ping", 0)
+		assert.Nil(t, err)
+		<-serverHandler.msgs
+		time.Sleep(10 * time.Millisecond)
+	}
+	select {
+	case kind := <-clientHandler.idle:
+		assert.Equal(t, ReadIdle, kind)
+	case <-time.After(3 * time.Second):
+		t.Fatal("OnIdle is not invoked")
+	}
+	for len(clientHandler.idle) > 0 {
+		assert.Equal(t, ReadIdle, <-clientHandler.idle)
+	}
+
+	ss.SetReadIdleTimeout(0)
+	select {
+	case kind := <-clientHandler.idle:
+		assert.Equal(t, WriteIdle, kind)
+	case <-time.After(3 * time.Second):
+		t.Fatal("OnIdle is not invoked")
+	}
+	assert.Equal(t, "WriteIdle", WriteIdle.String())
+}
+
+// upperPkgHandler decodes the string packages to upper case
+type upperPkgHandler struct {
+	stringPkgHandler
+}
+
+func (h *upperPkgHandler) Read(ss Session, data []byte) (interface{}, int, error) {
+	pkg, pkgLen, err := h.stringPkgHandler.Read(ss, data)
+	if str, ok := pkg.(string); ok {
+		pkg = strings.ToUpper(str)
+	}
+	return pkg, pkgLen, err
+}
+
+type cronMessageHandler struct {
+	*chanMessageHandler
+	crons chan struct{}
+}
+
+func (h *cronMessageHandler) OnCron(session Session) {
+	select {
+	case h.crons <- struct{}{}:
+	default:
+	}
+}
+
+func TestSessionReconfigure(t *testing.T) {
+	clientEndPoint, serverEndPoint := NewPipeEndpoint()
+	defer clientEndPoint.Close()
+	defer serverEndPoint.Close()
+	clientHandler := &cronMessageHandler{chanMessageHandler: newChanMessageHandler(), crons: make(chan struct{}, 1)}
+	serverHandler := newChanMessageHandler()
+	clientEndPoint.RunEventLoop(func(session Session) error {
+		session.SetPkgHandler(&stringPkgHandler{})
+		session.SetEventListener(clientHandler)
+		return nil
+	})
+	serverEndPoint.RunEventLoop(func(session Session) error {
+		session.SetPkgHandler(&stringPkgHandler{})
+		session.SetEventListener(serverHandler)
+		return nil
+	})
+	ss, server := clientHandler.array[0], serverHandler.array[0]
+
+	// the default cron period is too long to fire in the test
+	ss.SetCronPeriod(10)
+	select {
+	case <-clientHandler.crons:
+	case <-time.After(3 * time.Second):
+		t.Fatal("OnCron is not invoked by the new period")
+	}
+
+	write := func(pkg string) {
+		_, _, err := ss.WritePkg(pkg, 0)
+		assert.Nil(t, err)
+	}
+	write("hello")
+	assert.Equal(t, "hello", <-serverHandler.msgs)
+	server.SetPkgHandler(&upperPkgHandler{})
+	write("hello")
+	assert.Equal(t, "HELLO", <-serverHandler.msgs)
+
+	server.SetMaxMsgLen(4)
+	write("hello")
+	select {
+	case <-serverHandler.closed:
+	case <-time.After(3 * time.Second):
+		t.Fatal("the package longer than the new max message length is accepted")
+	}
+}
+
+func TestSessionLabels(t *testing.T) {
+	ss, _ := newPipeSessions(t)
+	assert.Nil(t, ss.Stats().Labels)
+
+	ss.SetLabel("tenant", "t1")
+	ss.SetLabel("region", "us")
+	ss.SetLabel("version", "v2")
+	ss.SetLabel("version", "")
+	assert.Equal(t, "t1", ss.Label("tenant"))
+	assert.Equal(t, "", ss.Label("version"))
+	assert.Equal(t, map[string]string{"tenant": "t1", "region": "us"}, ss.Stats().Labels)
+	assert.Contains(t, ss.Stat(), "[region=us,tenant=t1]")
+}
+
+func TestSessionCloseWithTimeout(t *testing.T) {
+	// the server handler blocks when its channel is full, so the flush times out
+	ss, _ := newPipeSessions(t)
+	const num = 64
+	for i := 0; i < num; i++ {
+		ss.WritePkgAsync("hello", 0, nil)
+	}
+	assert.False(t, ss.CloseWithTimeout(50*time.Millisecond))
+	assert.True(t, ss.IsClosed())
+
+	ss, serverHandler := newPipeSessions(t)
+	for i := 0; i < num; i++ {
+		ss.WritePkgAsync("hello", 0, nil)
+	}
+	go func() {
+		for i := 0; i < num; i++ {
+			<-serverHandler.msgs
+		}
+	}()
+	assert.True(t, ss.CloseWithTimeout(3*time.Second))
+	assert.Equal(t, 0, ss.PendingWriteBytes())
+}
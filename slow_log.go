@@ -0,0 +1,53 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package getty
+
+import (
+	"time"
+)
+
+// slowLogger is implemented by the endpoints which log the slow OnMessage and socket writes.
+type slowLogger interface {
+	slowThresholds() (handler time.Duration, write time.Duration)
+}
+
+func (s *server) slowThresholds() (time.Duration, time.Duration) {
+	return s.slowHandlerThreshold, s.slowWriteThreshold
+}
+
+func (c *client) slowThresholds() (time.Duration, time.Duration) {
+	return c.slowHandlerThreshold, c.slowWriteThreshold
+}
+
+// slowThresholdsOf returns the thresholds of the slow OnMessage and socket writes of @endPoint,
+// 0 means no logging.
+func slowThresholdsOf(endPoint EndPoint) (time.Duration, time.Duration) {
+	if l, ok := endPoint.(slowLogger); ok {
+		return l.slowThresholds()
+	}
+
+	return 0, 0
+}
+
+// logSlow logs the @op of the package of @size bytes started at @start if it takes more than
+// @threshold.
+func (s *session) logSlow(op string, size int, start time.Time, threshold time.Duration) {
+	if elapsed := time.Since(start); elapsed > threshold {
+		s.Logger().Warnw("slow "+op, "size", size, "elapsed", elapsed, "threshold", threshold)
+	}
+}
@@ -0,0 +1,52 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package getty
+
+import (
+	"log/slog"
+	"testing"
+	"time"
+)
+
+import (
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSlowLog(t *testing.T) {
+	s := NewTCPServer(WithSlowHandlerThreshold(time.Second), WithSlowWriteThreshold(time.Millisecond)).(*server)
+	handler, write := slowThresholdsOf(s)
+	assert.Equal(t, time.Second, handler)
+	assert.Equal(t, time.Millisecond, write)
+	clt := NewTCPClient(WithServerAddress("127.0.0.1:1"), WithConnectionNumber(1),
+		WithClientSlowHandlerThreshold(time.Minute)).(*client)
+	handler, write = slowThresholdsOf(clt)
+	assert.Equal(t, time.Minute, handler)
+	assert.Equal(t, time.Duration(0), write)
+
+	ss, _ := newPipeSessions(t)
+	logger := newRecordLogger()
+	SetStructuredLogger(logger)
+	defer SetStructuredLogger(NewSlogLogger(slog.Default()))
+	ss.(*session).logSlow("write", 10, time.Now(), time.Hour)
+	ss.(*session).logSlow("OnMessage", 20, time.Now().Add(-time.Second), time.Millisecond)
+	logs := logger.records()
+	assert.Equal(t, 1, len(logs))
+	assert.Contains(t, logs[0], "warn slow OnMessage session_id=")
+	assert.Contains(t, logs[0], "size=20 elapsed=1")
+	assert.Contains(t, logs[0], "threshold=1ms")
+}
@@ -0,0 +1,131 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package getty
+
+import (
+	"net"
+	"syscall"
+	"time"
+)
+
+import (
+	perrors "github.com/pkg/errors"
+)
+
+// SocketOptions tunes the tcp connections of the sessions before OnOpen. The zero value of every
+// field keeps the default of Go or the system.
+type SocketOptions struct {
+	// DisableNoDelay enables the Nagle's algorithm, as Go sets TCP_NODELAY by default.
+	DisableNoDelay bool
+	// KeepAlivePeriod is the idle time before the first keepalive probe, and a negative one
+	// disables the keepalive which Go enables with a 15s period by default.
+	KeepAlivePeriod time.Duration
+	// KeepAliveInterval is the time between the keepalive probes, and KeepAliveCount is the number
+	// of the unacknowledged probes before the connection is closed. Together with a short
+	// KeepAlivePeriod, they detect the dead peers behind NAT in seconds rather than minutes.
+	KeepAliveInterval time.Duration
+	KeepAliveCount    int
+	// ReadBuffer & WriteBuffer are SO_RCVBUF & SO_SNDBUF in bytes.
+	ReadBuffer  int
+	WriteBuffer int
+	// UserTimeout is TCP_USER_TIMEOUT, the max time the written data may stay unacknowledged
+	// before the connection is closed, which is only supported on linux. It also bounds the
+	// keepalive probes on linux.
+	UserTimeout time.Duration
+	// TOS is IP_TOS of ipv4 or IPV6_TCLASS of ipv6, eg: 0xb8 for the DSCP EF class.
+	TOS int
+}
+
+// apply sets the options of the tcp connection under @conn, and does nothing for the other ones.
+func (o *SocketOptions) apply(conn net.Conn) error {
+	tc := tcpConnOf(conn)
+	if tc == nil {
+		return nil
+	}
+
+	if o.DisableNoDelay {
+		if err := tc.SetNoDelay(false); err != nil {
+			return perrors.WithStack(err)
+		}
+	}
+	if o.KeepAlivePeriod < 0 {
+		if err := tc.SetKeepAlive(false); err != nil {
+			return perrors.WithStack(err)
+		}
+	} else if o.KeepAlivePeriod > 0 || o.KeepAliveInterval > 0 || o.KeepAliveCount > 0 {
+		if err := o.applyKeepAlive(tc); err != nil {
+			return err
+		}
+	}
+	if o.ReadBuffer > 0 {
+		if err := tc.SetReadBuffer(o.ReadBuffer); err != nil {
+			return perrors.WithStack(err)
+		}
+	}
+	if o.WriteBuffer > 0 {
+		if err := tc.SetWriteBuffer(o.WriteBuffer); err != nil {
+			return perrors.WithStack(err)
+		}
+	}
+	if o.UserTimeout > 0 {
+		if err := setTCPUserTimeout(tc, o.UserTimeout); err != nil {
+			return err
+		}
+	}
+	if o.TOS > 0 {
+		if err := setTOS(tc, o.TOS); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// syscallConnOf returns the socket under the tls or PROXY protocol connection @conn, or nil if
+// @conn has no socket, eg: a pipe or a quic stream.
+func syscallConnOf(conn net.Conn) syscall.Conn {
+	for conn != nil {
+		switch c := conn.(type) {
+		case syscall.Conn:
+			return c
+		case interface{ NetConn() net.Conn }:
+			conn = c.NetConn()
+		default:
+			return nil
+		}
+	}
+
+	return nil
+}
+
+// tcpConnOf returns the tcp connection under the tls or PROXY protocol connection @conn,
+// or nil if @conn is not a tcp one.
+func tcpConnOf(conn net.Conn) *net.TCPConn {
+	for conn != nil {
+		switch c := conn.(type) {
+		case *net.TCPConn:
+			return c
+		case interface{ NetConn() net.Conn }:
+			conn = c.NetConn()
+		default:
+			return nil
+		}
+	}
+
+	return nil
+}
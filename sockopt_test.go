@@ -0,0 +1,114 @@
+//go:build linux
+
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package getty
+
+import (
+	"crypto/tls"
+	"net"
+	"syscall"
+	"testing"
+	"time"
+)
+
+import (
+	"github.com/stretchr/testify/assert"
+
+	"golang.org/x/sys/unix"
+)
+
+func getsockoptInt(t *testing.T, conn syscall.Conn, level, opt int) int {
+	raw, err := conn.SyscallConn()
+	assert.Nil(t, err)
+	var value int
+	assert.Nil(t, raw.Control(func(fd uintptr) {
+		value, err = unix.GetsockoptInt(int(fd), level, opt)
+	}))
+	assert.Nil(t, err)
+	return value
+}
+
+func TestSocketOptions(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	assert.Nil(t, err)
+	defer ln.Close()
+	conn, err := net.Dial("tcp", ln.Addr().String())
+	assert.Nil(t, err)
+	defer conn.Close()
+	tc := conn.(*net.TCPConn)
+
+	assert.Nil(t, tcpConnOf(&net.UDPConn{}))
+	// the tcp connection under the tls and the PROXY protocol connections
+	assert.Equal(t, tc, tcpConnOf(tls.Client(&proxyConn{Conn: conn}, &tls.Config{})))
+
+	opts := SocketOptions{
+		DisableNoDelay:    true,
+		KeepAlivePeriod:   30 * time.Second,
+		KeepAliveInterval: 3 * time.Second,
+		KeepAliveCount:    4,
+		WriteBuffer:       64 << 10,
+		UserTimeout:       5 * time.Second,
+		TOS:               0x10,
+	}
+	assert.Nil(t, opts.apply(tls.Client(conn, &tls.Config{})))
+	assert.Equal(t, 0, getsockoptInt(t, tc, unix.IPPROTO_TCP, unix.TCP_NODELAY))
+	assert.Equal(t, 1, getsockoptInt(t, tc, unix.SOL_SOCKET, unix.SO_KEEPALIVE))
+	assert.Equal(t, 30, getsockoptInt(t, tc, unix.IPPROTO_TCP, unix.TCP_KEEPIDLE))
+	assert.Equal(t, 3, getsockoptInt(t, tc, unix.IPPROTO_TCP, unix.TCP_KEEPINTVL))
+	assert.Equal(t, 4, getsockoptInt(t, tc, unix.IPPROTO_TCP, unix.TCP_KEEPCNT))
+	// linux doubles the buffer size for its bookkeeping
+	assert.Equal(t, 2*opts.WriteBuffer, getsockoptInt(t, tc, unix.SOL_SOCKET, unix.SO_SNDBUF))
+	assert.Equal(t, 5000, getsockoptInt(t, tc, unix.IPPROTO_TCP, unix.TCP_USER_TIMEOUT))
+	assert.Equal(t, 0x10, getsockoptInt(t, tc, unix.IPPROTO_IP, unix.IP_TOS))
+
+	// the count is kept when only the interval is changed
+	assert.Nil(t, (&SocketOptions{KeepAliveInterval: 5 * time.Second}).apply(conn))
+	assert.Equal(t, 5, getsockoptInt(t, tc, unix.IPPROTO_TCP, unix.TCP_KEEPINTVL))
+	assert.Equal(t, 4, getsockoptInt(t, tc, unix.IPPROTO_TCP, unix.TCP_KEEPCNT))
+
+	assert.Nil(t, (&SocketOptions{KeepAlivePeriod: -1}).apply(conn))
+	assert.Equal(t, 0, getsockoptInt(t, tc, unix.SOL_SOCKET, unix.SO_KEEPALIVE))
+}
+
+func TestSessionSyscallConn(t *testing.T) {
+	ss, _ := newPipeSessions(t)
+	_, err := ss.SyscallConn()
+	assert.NotNil(t, err)
+
+	s := NewTCPServer(WithLocalAddress("127.0.0.1:0")).(*server)
+	defer s.Close()
+	s.RunEventLoop(func(session Session) error {
+		session.SetPkgHandler(&stringPkgHandler{})
+		session.SetEventListener(newChanMessageHandler())
+		return nil
+	})
+	conn, err := net.Dial("tcp", s.addr)
+	assert.Nil(t, err)
+	defer conn.Close()
+	assert.Eventually(t, func() bool { return s.SessionCount() == 1 }, 3*time.Second, 10*time.Millisecond)
+
+	raw, err := s.Sessions()[0].SyscallConn()
+	assert.Nil(t, err)
+	var sockType int
+	assert.Nil(t, raw.Control(func(fd uintptr) {
+		sockType, err = unix.GetsockoptInt(int(fd), unix.SOL_SOCKET, unix.SO_TYPE)
+	}))
+	assert.Nil(t, err)
+	assert.Equal(t, unix.SOCK_STREAM, sockType)
+}
@@ -0,0 +1,77 @@
+//go:build linux || darwin || dragonfly || freebsd || netbsd || openbsd
+
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package getty
+
+import (
+	"net"
+	"syscall"
+)
+
+import (
+	perrors "github.com/pkg/errors"
+
+	"golang.org/x/sys/unix"
+)
+
+// setsockoptInt sets the socket option @opt of @level of @conn to @value.
+func setsockoptInt(conn interface{}, level, opt, value int) error {
+	return controlSocket(conn, func(fd int) error {
+		return unix.SetsockoptInt(fd, level, opt, value)
+	})
+}
+
+// controlSocket calls @f with the raw socket of @conn.
+func controlSocket(conn interface{}, f func(fd int) error) error {
+	sc, ok := conn.(syscall.Conn)
+	if !ok {
+		return perrors.Errorf("%T has no raw socket", conn)
+	}
+	raw, err := sc.SyscallConn()
+	if err != nil {
+		return perrors.WithStack(err)
+	}
+
+	if ctrlErr := raw.Control(func(fd uintptr) {
+		err = f(int(fd))
+	}); ctrlErr != nil {
+		return perrors.WithStack(ctrlErr)
+	}
+
+	return perrors.WithStack(err)
+}
+
+// setBroadcast sets SO_BROADCAST of the udp socket @conn.
+func setBroadcast(conn net.PacketConn, enable bool) error {
+	value := 0
+	if enable {
+		value = 1
+	}
+
+	return setsockoptInt(conn, unix.SOL_SOCKET, unix.SO_BROADCAST, value)
+}
+
+// setTOS sets IP_TOS or IPV6_TCLASS of the tcp socket @conn by its address family.
+func setTOS(conn *net.TCPConn, tos int) error {
+	if addr, ok := conn.LocalAddr().(*net.TCPAddr); ok && addr.IP.To4() == nil {
+		return setsockoptInt(conn, unix.IPPROTO_IPV6, unix.IPV6_TCLASS, tos)
+	}
+
+	return setsockoptInt(conn, unix.IPPROTO_IP, unix.IP_TOS, tos)
+}
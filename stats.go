@@ -0,0 +1,82 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package getty
+
+import (
+	"expvar"
+)
+
+// ListenerStats is the statistics of a listener of a stream server.
+type ListenerStats struct {
+	Addr     string
+	Accepted uint64 // the sessions accepted by the listener
+	Sessions int    // the active sessions of the listener
+}
+
+// ServerStats is the aggregate statistics of a server.
+type ServerStats struct {
+	EndPointType     string
+	Addr             string
+	Sessions         int    // the active sessions
+	Accepted         uint64 // the sessions served since the start
+	SessionLimitHits uint64
+	Listeners        []ListenerStats
+	// the packages being handled by OnMessage or queued in the task pool
+	PendingTasks int
+	// the traffic of the active sessions
+	ReadBytes  uint64
+	WriteBytes uint64
+}
+
+func (s *server) DumpStats() ServerStats {
+	stats := ServerStats{
+		EndPointType:     s.endPointType.String(),
+		Addr:             s.addr,
+		Accepted:         s.accepted.Load(),
+		SessionLimitHits: s.limitHits.Load(),
+	}
+	s.lock.Lock()
+	for _, limit := range s.listenerLimits {
+		stats.Listeners = append(stats.Listeners, ListenerStats{
+			Addr:     limit.addr,
+			Accepted: limit.accepted.Load(),
+			Sessions: int(limit.num.Load()),
+		})
+	}
+	s.lock.Unlock()
+
+	for _, ss := range s.Sessions() {
+		stats.Sessions++
+		st := ss.Stats()
+		stats.ReadBytes += st.ReadBytes
+		stats.WriteBytes += st.WriteBytes
+		if impl, ok := ss.(*session); ok {
+			stats.PendingTasks += int(impl.handling.Load())
+		}
+	}
+
+	return stats
+}
+
+// PublishStats publishes the DumpStats of @server as the expvar @name, so that it is served in
+// the json of /debug/vars. Like expvar.Publish, it panics if @name has been published.
+func PublishStats(name string, server Server) {
+	expvar.Publish(name, expvar.Func(func() interface{} {
+		return server.DumpStats()
+	}))
+}
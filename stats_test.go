@@ -0,0 +1,61 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package getty
+
+import (
+	"encoding/json"
+	"expvar"
+	"testing"
+	"time"
+)
+
+import (
+	"github.com/stretchr/testify/assert"
+)
+
+func TestServerDumpStats(t *testing.T) {
+	s := NewTCPServer(WithLocalAddress("127.0.0.1:0")).(*server)
+	defer s.Close()
+	s.RunEventLoop(func(session Session) error {
+		session.SetPkgHandler(&stringPkgHandler{})
+		session.SetEventListener(newChanMessageHandler())
+		session.SetReadTimeout(50 * time.Millisecond)
+		return nil
+	})
+
+	clt := NewTCPClient(WithServerAddress(s.addr), WithConnectionNumber(2), WithReconnectInterval(1e7))
+	defer clt.Close()
+	clt.RunEventLoop(func(session Session) error {
+		session.SetPkgHandler(&stringPkgHandler{})
+		session.SetEventListener(newChanMessageHandler())
+		session.SetReadTimeout(50 * time.Millisecond)
+		return nil
+	})
+	assert.Eventually(t, func() bool { return s.SessionCount() == 2 }, time.Second, 10*time.Millisecond)
+
+	stats := s.DumpStats()
+	assert.Equal(t, "TCP_SERVER", stats.EndPointType)
+	assert.Equal(t, 2, stats.Sessions)
+	assert.Equal(t, uint64(2), stats.Accepted)
+	assert.Equal(t, []ListenerStats{{Addr: s.addr, Accepted: 2, Sessions: 2}}, stats.Listeners)
+
+	PublishStats("getty-test-server", s)
+	var published ServerStats
+	assert.Nil(t, json.Unmarshal([]byte(expvar.Get("getty-test-server").String()), &published))
+	assert.Equal(t, uint64(2), published.Accepted)
+}
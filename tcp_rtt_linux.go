@@ -0,0 +1,52 @@
+//go:build linux
+
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package getty
+
+import (
+	"net"
+	"time"
+)
+
+import (
+	perrors "github.com/pkg/errors"
+
+	"golang.org/x/sys/unix"
+)
+
+// tcpRTT returns the smoothed round trip time of the tcp socket @conn by TCP_INFO.
+func tcpRTT(conn *net.TCPConn) (time.Duration, error) {
+	raw, err := conn.SyscallConn()
+	if err != nil {
+		return 0, perrors.WithStack(err)
+	}
+	var (
+		info    *unix.TCPInfo
+		infoErr error
+	)
+	if err = raw.Control(func(fd uintptr) {
+		info, infoErr = unix.GetsockoptTCPInfo(int(fd), unix.IPPROTO_TCP, unix.TCP_INFO)
+	}); err != nil {
+		return 0, perrors.WithStack(err)
+	}
+	if infoErr != nil {
+		return 0, perrors.WithStack(infoErr)
+	}
+	return time.Duration(info.Rtt) * time.Microsecond, nil
+}
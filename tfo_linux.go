@@ -0,0 +1,56 @@
+//go:build linux
+
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package getty
+
+import (
+	"syscall"
+)
+
+import (
+	"golang.org/x/sys/unix"
+)
+
+// tcpFastOpenQueueLen is the max number of the pending TFO requests of a listener.
+const tcpFastOpenQueueLen = 256
+
+// fastOpenListenControl enables TCP_FASTOPEN on the listener socket before it is bound.
+func fastOpenListenControl(network, address string, c syscall.RawConn) error {
+	var err error
+	if ctrlErr := c.Control(func(fd uintptr) {
+		err = unix.SetsockoptInt(int(fd), unix.IPPROTO_TCP, unix.TCP_FASTOPEN, tcpFastOpenQueueLen)
+	}); ctrlErr != nil {
+		return ctrlErr
+	}
+
+	return err
+}
+
+// fastOpenDialControl enables TCP_FASTOPEN_CONNECT on the socket before it connects, so the
+// SYN is deferred to carry the first written data, eg: the PROXY header or the tls ClientHello.
+func fastOpenDialControl(network, address string, c syscall.RawConn) error {
+	var err error
+	if ctrlErr := c.Control(func(fd uintptr) {
+		err = unix.SetsockoptInt(int(fd), unix.IPPROTO_TCP, unix.TCP_FASTOPEN_CONNECT, 1)
+	}); ctrlErr != nil {
+		return ctrlErr
+	}
+
+	return err
+}
@@ -0,0 +1,71 @@
+//go:build linux
+
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package getty
+
+import (
+	"syscall"
+	"testing"
+	"time"
+)
+
+import (
+	"github.com/stretchr/testify/assert"
+
+	"golang.org/x/sys/unix"
+)
+
+func TestTCPFastOpen(t *testing.T) {
+	s := NewTCPServer(WithLocalAddress("127.0.0.1"), WithServerTCPFastOpen(true)).(*server)
+	defer s.Close()
+	serverHandler := newChanMessageHandler()
+	s.RunEventLoop(func(session Session) error {
+		session.SetPkgHandler(&stringPkgHandler{})
+		session.SetEventListener(serverHandler)
+		return nil
+	})
+	assert.Equal(t, tcpFastOpenQueueLen,
+		getsockoptInt(t, s.rawListeners[0].(syscall.Conn), unix.IPPROTO_TCP, unix.TCP_FASTOPEN))
+
+	// the client falls back to the normal handshake if the kernel disables TFO
+	clt := NewTCPClient(
+		WithServerAddress(s.addr),
+		WithConnectionNumber(1),
+		WithReconnectInterval(1e7),
+		WithClientTCPFastOpen(true),
+	)
+	defer clt.Close()
+	clientHandler := newChanMessageHandler()
+	clt.RunEventLoop(func(session Session) error {
+		session.SetPkgHandler(&stringPkgHandler{})
+		session.SetEventListener(clientHandler)
+		return nil
+	})
+	assert.Equal(t, 1, clientHandler.SessionNumber())
+	ss := clientHandler.array[0]
+	assert.Equal(t, 1, getsockoptInt(t, ss.Conn().(syscall.Conn), unix.IPPROTO_TCP, unix.TCP_FASTOPEN_CONNECT))
+	_, _, err := ss.WritePkg("hello", 0)
+	assert.Nil(t, err)
+	select {
+	case msg := <-serverHandler.msgs:
+		assert.Equal(t, "hello", msg)
+	case <-time.After(3 * time.Second):
+		t.Fatal("server did not receive the package")
+	}
+}
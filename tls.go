@@ -21,7 +21,13 @@ import (
 	"crypto/tls"
 	"crypto/x509"
 	"fmt"
+	"io"
 	"io/ioutil"
+	"os"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
 )
 
 import (
@@ -33,33 +39,216 @@ type TlsConfigBuilder interface {
 	BuildTlsConfig() (*tls.Config, error)
 }
 
+// CertificatePath is the paths of a certificate chain and its private key
+type CertificatePath struct {
+	KeyCertChainPath string
+	PrivateKeyPath   string
+}
+
 // ServerTlsConfigBuilder impl TlsConfigBuilder for server
 type ServerTlsConfigBuilder struct {
 	ServerKeyCertChainPath        string
 	ServerPrivateKeyPath          string
 	ServerKeyPassword             string
 	ServerTrustCertCollectionPath string
+	// SNICertificates maps the server names, eg: "example.com" or "*.example.com", to their
+	// certificates. The above certificate is served if none of them matches the SNI, so it
+	// can be empty if all of the clients send SNI.
+	SNICertificates map[string]CertificatePath
+	// GetCertificate chooses the certificate by the client hello instead of SNICertificates.
+	// The above certificate is served if it returns nil.
+	GetCertificate func(*tls.ClientHelloInfo) (*tls.Certificate, error)
+	// CertificateReloadInterval is the interval to check the modification of the certificate
+	// files, which are reloaded if they have been changed, eg: renewed by certbot. 0 means never.
+	CertificateReloadInterval time.Duration
+	// VerifyPeerCertificate is called after the normal certificate verification of the client,
+	// eg: to authorize the client by its SPIFFE ID or CN, see tls.Config.VerifyPeerCertificate.
+	VerifyPeerCertificate func(rawCerts [][]byte, verifiedChains [][]*x509.Certificate) error
+	// MinVersion and MaxVersion pin the tls versions, eg: tls.VersionTLS12. 0 means the Go defaults.
+	MinVersion uint16
+	MaxVersion uint16
+	// CipherSuites is the enabled tls 1.0-1.2 cipher suites, the tls 1.3 ones are not configurable.
+	// Nil means the Go defaults.
+	CipherSuites []uint16
+	// CurvePreferences is the key exchange mechanisms in order of preference. Nil means the Go defaults.
+	CurvePreferences []tls.CurveID
+	// NextProtos is the ALPN protocols supported by the server in order of preference, eg: to serve
+	// several protocols on one port. The negotiated one is given by Session.TLSConnectionState in
+	// NewSessionCallback, so that the pkg handler of the protocol can be set there.
+	NextProtos []string
+	// KeyLogWriter is the destination of the tls master secrets in NSS key log format, which
+	// can be used by wireshark to decrypt the traffic. It is only for debugging.
+	KeyLogWriter io.Writer
+	// SessionTicketsDisabled disables the tls session resumption by session tickets.
+	SessionTicketsDisabled bool
+	// SessionTicketKeyRotationInterval is the interval to rotate the session ticket keys, the
+	// tickets encrypted by the former keys are accepted for two more intervals. 0 means the Go
+	// default rotation. Leave it 0 if the keys are given by SetSessionTicketKeys.
+	SessionTicketKeyRotationInterval time.Duration
+
+	certs     atomic.Pointer[serverCertificates]
+	watchOnce sync.Once
+	tickets   sessionTicketKeys
+}
+
+// serverCertificates is the certificates loaded by ServerTlsConfigBuilder
+type serverCertificates struct {
+	def      *tls.Certificate
+	sni      sniCertificates
+	modTimes map[string]time.Time
+}
+
+// sniCertificates chooses the certificate by SNI
+type sniCertificates map[string]*tls.Certificate
+
+// get returns the certificate of the exact server name, or else the wildcard one.
+func (c sniCertificates) get(hello *tls.ClientHelloInfo) *tls.Certificate {
+	name := strings.ToLower(strings.TrimSuffix(hello.ServerName, "."))
+	if cert, ok := c[name]; ok {
+		return cert
+	}
+	if i := strings.IndexByte(name, '.'); i > 0 {
+		if cert, ok := c["*"+name[i:]]; ok {
+			return cert
+		}
+	}
+
+	return nil
+}
+
+func (s *ServerTlsConfigBuilder) loadCertificates() (*serverCertificates, error) {
+	certs := &serverCertificates{modTimes: make(map[string]time.Time)}
+	load := func(path CertificatePath) (*tls.Certificate, error) {
+		cert, err := tls.LoadX509KeyPair(path.KeyCertChainPath, path.PrivateKeyPath)
+		if err != nil {
+			return nil, perrors.Wrapf(err, "tls.LoadX509KeyPair(certs{%s}, privateKey{%s})",
+				path.KeyCertChainPath, path.PrivateKeyPath)
+		}
+		for _, file := range []string{path.KeyCertChainPath, path.PrivateKeyPath} {
+			if fi, err := os.Stat(file); err == nil {
+				certs.modTimes[file] = fi.ModTime()
+			}
+		}
+		return &cert, nil
+	}
+
+	var err error
+	if s.ServerKeyCertChainPath != "" || (len(s.SNICertificates) == 0 && s.GetCertificate == nil) {
+		path := CertificatePath{KeyCertChainPath: s.ServerKeyCertChainPath, PrivateKeyPath: s.ServerPrivateKeyPath}
+		if certs.def, err = load(path); err != nil {
+			return nil, err
+		}
+	}
+	if s.GetCertificate == nil && len(s.SNICertificates) != 0 {
+		certs.sni = make(sniCertificates, len(s.SNICertificates))
+		for name, path := range s.SNICertificates {
+			if certs.sni[strings.ToLower(name)], err = load(path); err != nil {
+				return nil, perrors.WithMessagef(err, "server name %s", name)
+			}
+		}
+	}
+
+	return certs, nil
+}
+
+// ReloadCertificate reloads the certificate files, and the new handshakes are served by the new
+// certificates. The established connections are not affected. The former certificates are kept
+// if it fails.
+func (s *ServerTlsConfigBuilder) ReloadCertificate() error {
+	certs, err := s.loadCertificates()
+	if err != nil {
+		return perrors.WithStack(err)
+	}
+	s.certs.Store(certs)
+
+	return nil
+}
+
+// watchCertificates reloads the certificate files every CertificateReloadInterval if they have been changed.
+func (s *ServerTlsConfigBuilder) watchCertificates() {
+	ticker := time.NewTicker(s.CertificateReloadInterval)
+	defer ticker.Stop()
+
+	for range ticker.C {
+		certs := s.certs.Load()
+		changed := false
+		for file, modTime := range certs.modTimes {
+			if fi, err := os.Stat(file); err == nil && !fi.ModTime().Equal(modTime) {
+				changed = true
+				break
+			}
+		}
+		if !changed {
+			continue
+		}
+		if err := s.ReloadCertificate(); err != nil {
+			// the files may be half written, try it again later
+			log.Warnf("ServerTlsConfigBuilder.ReloadCertificate() = error:%+v", err)
+			continue
+		}
+		log.Infof("the server certificates have been reloaded")
+	}
+}
+
+func (s *ServerTlsConfigBuilder) getCertificate(hello *tls.ClientHelloInfo) (*tls.Certificate, error) {
+	certs := s.certs.Load()
+	if s.GetCertificate != nil {
+		cert, err := s.GetCertificate(hello)
+		if cert != nil || err != nil {
+			return cert, err
+		}
+	} else if cert := certs.sni.get(hello); cert != nil {
+		return cert, nil
+	}
+	if certs.def != nil {
+		return certs.def, nil
+	}
+
+	return nil, perrors.Errorf("no certificate for server name %q", hello.ServerName)
 }
 
 // BuildTlsConfig impl TlsConfigBuilder method
 func (s *ServerTlsConfigBuilder) BuildTlsConfig() (*tls.Config, error) {
 	var (
-		err         error
-		certPem     []byte
-		certificate tls.Certificate
-		certPool    *x509.CertPool
-		config      *tls.Config
+		err      error
+		certPem  []byte
+		certPool *x509.CertPool
+		config   *tls.Config
 	)
-	if certificate, err = tls.LoadX509KeyPair(s.ServerKeyCertChainPath, s.ServerPrivateKeyPath); err != nil {
-		log.Error(fmt.Sprintf("tls.LoadX509KeyPair(certs{%s}, privateKey{%s}) = err:%+v",
-			s.ServerKeyCertChainPath, s.ServerPrivateKeyPath, perrors.WithStack(err)))
+	if err = checkTlsVersions(s.MinVersion, s.MaxVersion); err != nil {
 		return nil, err
 	}
+	if err = s.ReloadCertificate(); err != nil {
+		log.Error(fmt.Sprintf("ServerTlsConfigBuilder.ReloadCertificate() = err:%+v", err))
+		return nil, err
+	}
+	if s.CertificateReloadInterval > 0 {
+		s.watchOnce.Do(func() {
+			go s.watchCertificates()
+		})
+	}
 	config = &tls.Config{
 		InsecureSkipVerify: true, // do not verify peer certs
 		ClientAuth:         tls.RequireAnyClientCert,
-		Certificates:       []tls.Certificate{certificate},
+		GetCertificate:     s.getCertificate,
+		MinVersion:         s.MinVersion,
+		MaxVersion:         s.MaxVersion,
+		CipherSuites:       s.CipherSuites,
+		CurvePreferences:   s.CurvePreferences,
+		NextProtos:         s.NextProtos,
+		KeyLogWriter:       s.KeyLogWriter,
 	}
+	if s.SessionTicketsDisabled {
+		config.SessionTicketsDisabled = true
+	} else {
+		s.tickets.add(config)
+		if s.SessionTicketKeyRotationInterval > 0 {
+			s.tickets.rotateOnce.Do(func() {
+				go s.tickets.rotate(s.SessionTicketKeyRotationInterval)
+			})
+		}
+	}
+	config.VerifyPeerCertificate = s.VerifyPeerCertificate
 
 	if s.ServerTrustCertCollectionPath != "" {
 		certPem, err = ioutil.ReadFile(s.ServerTrustCertCollectionPath)
@@ -79,16 +268,57 @@ func (s *ServerTlsConfigBuilder) BuildTlsConfig() (*tls.Config, error) {
 	return config, nil
 }
 
+// SetSessionTicketKeys sets the session ticket keys of the built tls configs and the later ones,
+// eg: to share the keys among the instances behind a load balancer, so that a client can resume
+// its session on any of them. The first key encrypts the new tickets, and all of them decrypt.
+func (s *ServerTlsConfigBuilder) SetSessionTicketKeys(keys [][32]byte) error {
+	return perrors.WithStack(s.tickets.set(keys))
+}
+
 // ClientTlsConfigBuilder impl TlsConfigBuilder for client
 type ClientTlsConfigBuilder struct {
 	ClientKeyCertChainPath        string
 	ClientPrivateKeyPath          string
 	ClientKeyPassword             string
 	ClientTrustCertCollectionPath string
+	// VerifyPeerCertificate is called after the normal certificate verification of the server,
+	// see tls.Config.VerifyPeerCertificate.
+	VerifyPeerCertificate func(rawCerts [][]byte, verifiedChains [][]*x509.Certificate) error
+	// MinVersion and MaxVersion pin the tls versions, eg: tls.VersionTLS12. 0 means the Go defaults.
+	MinVersion uint16
+	MaxVersion uint16
+	// CipherSuites is the enabled tls 1.0-1.2 cipher suites, the tls 1.3 ones are not configurable.
+	// Nil means the Go defaults.
+	CipherSuites []uint16
+	// CurvePreferences is the key exchange mechanisms in order of preference. Nil means the Go defaults.
+	CurvePreferences []tls.CurveID
+	// NextProtos is the ALPN protocols requested by the client in order of preference.
+	NextProtos []string
+	// KeyLogWriter logs the tls secrets of the client, see ServerTlsConfigBuilder.KeyLogWriter.
+	KeyLogWriter io.Writer
+	// ClientSessionCacheSize is the number of the tls sessions cached for resumption, which are
+	// shared by the reconnections of the client. 0 means no resumption.
+	ClientSessionCacheSize int
+
+	cacheOnce    sync.Once
+	sessionCache tls.ClientSessionCache
+}
+
+// checkTlsVersions checks the pinned tls versions
+func checkTlsVersions(minVersion, maxVersion uint16) error {
+	if minVersion != 0 && maxVersion != 0 && minVersion > maxVersion {
+		return perrors.Errorf("tls MinVersion %s is greater than MaxVersion %s",
+			tls.VersionName(minVersion), tls.VersionName(maxVersion))
+	}
+
+	return nil
 }
 
 // BuildTlsConfig impl TlsConfigBuilder method
 func (c *ClientTlsConfigBuilder) BuildTlsConfig() (*tls.Config, error) {
+	if err := checkTlsVersions(c.MinVersion, c.MaxVersion); err != nil {
+		return nil, err
+	}
 	cert, err := tls.LoadX509KeyPair(c.ClientKeyCertChainPath, c.ClientPrivateKeyPath)
 	if err != nil {
 		log.Error(fmt.Sprintf("Unable to load X509 Key Pair %v", err))
@@ -106,8 +336,26 @@ func (c *ClientTlsConfigBuilder) BuildTlsConfig() (*tls.Config, error) {
 		return nil, err
 	}
 	return &tls.Config{
-		RootCAs:            clientCertPool,
-		Certificates:       []tls.Certificate{cert},
-		InsecureSkipVerify: true,
+		RootCAs:               clientCertPool,
+		Certificates:          []tls.Certificate{cert},
+		InsecureSkipVerify:    true,
+		VerifyPeerCertificate: c.VerifyPeerCertificate,
+		MinVersion:            c.MinVersion,
+		MaxVersion:            c.MaxVersion,
+		CipherSuites:          c.CipherSuites,
+		CurvePreferences:      c.CurvePreferences,
+		ClientSessionCache:    c.clientSessionCache(),
+		NextProtos:            c.NextProtos,
+		KeyLogWriter:          c.KeyLogWriter,
 	}, nil
 }
+
+func (c *ClientTlsConfigBuilder) clientSessionCache() tls.ClientSessionCache {
+	c.cacheOnce.Do(func() {
+		if c.ClientSessionCacheSize > 0 {
+			c.sessionCache = tls.NewLRUClientSessionCache(c.ClientSessionCacheSize)
+		}
+	})
+
+	return c.sessionCache
+}
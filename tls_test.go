@@ -0,0 +1,475 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package getty
+
+import (
+	"bytes"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"errors"
+	"math/big"
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+import (
+	"github.com/stretchr/testify/assert"
+)
+
+// writeTestCertificate writes a self-signed certificate of @cn into @dir.
+func writeTestCertificate(t *testing.T, dir, cn string) CertificatePath {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	assert.Nil(t, err)
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(time.Now().UnixNano()),
+		Subject:      pkix.Name{CommonName: cn},
+		DNSNames:     []string{cn},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth, x509.ExtKeyUsageClientAuth},
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	assert.Nil(t, err)
+	keyDer, err := x509.MarshalECPrivateKey(key)
+	assert.Nil(t, err)
+
+	path := CertificatePath{
+		KeyCertChainPath: filepath.Join(dir, cn+".pem"),
+		PrivateKeyPath:   filepath.Join(dir, cn+".key"),
+	}
+	assert.Nil(t, os.WriteFile(path.KeyCertChainPath, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), 0o600))
+	assert.Nil(t, os.WriteFile(path.PrivateKeyPath, pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDer}), 0o600))
+
+	return path
+}
+
+func commonName(t *testing.T, cert *tls.Certificate) string {
+	leaf, err := x509.ParseCertificate(cert.Certificate[0])
+	assert.Nil(t, err)
+	return leaf.Subject.CommonName
+}
+
+func TestServerTlsConfigBuilderSNI(t *testing.T) {
+	dir := t.TempDir()
+	defaultCert := writeTestCertificate(t, dir, "default.example.com")
+	builder := &ServerTlsConfigBuilder{
+		ServerKeyCertChainPath: defaultCert.KeyCertChainPath,
+		ServerPrivateKeyPath:   defaultCert.PrivateKeyPath,
+		SNICertificates: map[string]CertificatePath{
+			"a.example.com":   writeTestCertificate(t, dir, "a.example.com"),
+			"*.b.example.com": writeTestCertificate(t, dir, "wildcard.b.example.com"),
+		},
+	}
+	config, err := builder.BuildTlsConfig()
+	assert.Nil(t, err)
+
+	cert, err := config.GetCertificate(&tls.ClientHelloInfo{ServerName: "A.example.com"})
+	assert.Nil(t, err)
+	assert.Equal(t, "a.example.com", commonName(t, cert))
+	cert, err = config.GetCertificate(&tls.ClientHelloInfo{ServerName: "x.b.example.com"})
+	assert.Nil(t, err)
+	assert.Equal(t, "wildcard.b.example.com", commonName(t, cert))
+	cert, err = config.GetCertificate(&tls.ClientHelloInfo{ServerName: "c.example.com"})
+	assert.Nil(t, err)
+	assert.Equal(t, "default.example.com", commonName(t, cert))
+
+	// the default certificate is optional if the SNI certificates are given
+	builder.ServerKeyCertChainPath, builder.ServerPrivateKeyPath = "", ""
+	config, err = builder.BuildTlsConfig()
+	assert.Nil(t, err)
+	_, err = config.GetCertificate(&tls.ClientHelloInfo{ServerName: "c.example.com"})
+	assert.NotNil(t, err)
+
+	builder.SNICertificates["c.example.com"] = CertificatePath{KeyCertChainPath: filepath.Join(dir, "none.pem")}
+	_, err = builder.BuildTlsConfig()
+	assert.NotNil(t, err)
+}
+
+func TestServerTlsConfigBuilderReload(t *testing.T) {
+	dir := t.TempDir()
+	path := writeTestCertificate(t, dir, "old.example.com")
+	builder := &ServerTlsConfigBuilder{
+		ServerKeyCertChainPath:    path.KeyCertChainPath,
+		ServerPrivateKeyPath:      path.PrivateKeyPath,
+		CertificateReloadInterval: 10 * time.Millisecond,
+	}
+	config, err := builder.BuildTlsConfig()
+	assert.Nil(t, err)
+	cert, err := config.GetCertificate(&tls.ClientHelloInfo{})
+	assert.Nil(t, err)
+	assert.Equal(t, "old.example.com", commonName(t, cert))
+
+	// the renewed files are picked up by the watcher
+	newPath := writeTestCertificate(t, dir, "new.example.com")
+	later := time.Now().Add(time.Second)
+	for _, f := range [][2]string{{newPath.KeyCertChainPath, path.KeyCertChainPath}, {newPath.PrivateKeyPath, path.PrivateKeyPath}} {
+		assert.Nil(t, os.Rename(f[0], f[1]))
+		assert.Nil(t, os.Chtimes(f[1], later, later))
+	}
+	assert.Eventually(t, func() bool {
+		cert, err := config.GetCertificate(&tls.ClientHelloInfo{})
+		return err == nil && commonName(t, cert) == "new.example.com"
+	}, 3*time.Second, 10*time.Millisecond)
+
+	// the former certificate is kept if the files are broken
+	path = writeTestCertificate(t, dir, "kept.example.com")
+	builder = &ServerTlsConfigBuilder{ServerKeyCertChainPath: path.KeyCertChainPath, ServerPrivateKeyPath: path.PrivateKeyPath}
+	config, err = builder.BuildTlsConfig()
+	assert.Nil(t, err)
+	assert.Nil(t, os.WriteFile(path.PrivateKeyPath, []byte("broken"), 0o600))
+	assert.NotNil(t, builder.ReloadCertificate())
+	cert, err = config.GetCertificate(&tls.ClientHelloInfo{})
+	assert.Nil(t, err)
+	assert.Equal(t, "kept.example.com", commonName(t, cert))
+}
+
+func TestMutualTLS(t *testing.T) {
+	dir := t.TempDir()
+	serverCert := writeTestCertificate(t, dir, "server")
+	clientCert := writeTestCertificate(t, dir, "client")
+	evilCert := writeTestCertificate(t, dir, "evil")
+	builder := &ServerTlsConfigBuilder{
+		ServerKeyCertChainPath:        serverCert.KeyCertChainPath,
+		ServerPrivateKeyPath:          serverCert.PrivateKeyPath,
+		ServerTrustCertCollectionPath: clientCert.KeyCertChainPath,
+		VerifyPeerCertificate: func(_ [][]byte, verifiedChains [][]*x509.Certificate) error {
+			if len(verifiedChains) == 0 || verifiedChains[0][0].Subject.CommonName != "client" {
+				return errors.New("unauthorized peer")
+			}
+			return nil
+		},
+	}
+	s := NewTCPServer(
+		WithLocalAddress("127.0.0.1:0"),
+		WithServerSslEnabled(true),
+		WithServerTlsConfigBuilder(builder),
+		WithClientAuth(tls.RequireAndVerifyClientCert),
+	).(*server)
+	defer s.Close()
+	peers := make(chan string, 2)
+	s.RunEventLoop(func(session Session) error {
+		state, ok := session.TLSConnectionState()
+		assert.True(t, ok)
+		assert.True(t, state.HandshakeComplete)
+		peers <- state.VerifiedChains[0][0].Subject.CommonName
+		session.SetPkgHandler(&stringPkgHandler{})
+		session.SetEventListener(newChanMessageHandler())
+		return nil
+	})
+
+	// the peer which is not authorized by VerifyPeerCertificate
+	cert, err := tls.LoadX509KeyPair(evilCert.KeyCertChainPath, evilCert.PrivateKeyPath)
+	assert.Nil(t, err)
+	conn, err := tls.Dial("tcp", s.addr, &tls.Config{Certificates: []tls.Certificate{cert}, InsecureSkipVerify: true})
+	if err == nil {
+		// the client finishes the tls 1.3 handshake before the server verifies its certificate
+		_, err = conn.Read(make([]byte, 1))
+		conn.Close()
+	}
+	assert.NotNil(t, err)
+
+	clt := NewTCPClient(
+		WithServerAddress(s.addr),
+		WithConnectionNumber(1),
+		WithReconnectInterval(1e7),
+		WithClientSslEnabled(true),
+		WithClientTlsConfigBuilder(&ClientTlsConfigBuilder{
+			ClientKeyCertChainPath:        clientCert.KeyCertChainPath,
+			ClientPrivateKeyPath:          clientCert.PrivateKeyPath,
+			ClientTrustCertCollectionPath: serverCert.KeyCertChainPath,
+		}),
+	)
+	defer clt.Close()
+	clientHandler := newChanMessageHandler()
+	clt.RunEventLoop(func(session Session) error {
+		session.SetPkgHandler(&stringPkgHandler{})
+		session.SetEventListener(clientHandler)
+		return nil
+	})
+	assert.Equal(t, 1, clientHandler.SessionNumber())
+	state, ok := clientHandler.array[0].TLSConnectionState()
+	assert.True(t, ok)
+	assert.Equal(t, uint16(tls.VersionTLS13), state.Version)
+	assert.NotZero(t, state.CipherSuite)
+	assert.Equal(t, "server", state.PeerCertificates[0].Subject.CommonName)
+	select {
+	case cn := <-peers:
+		assert.Equal(t, "client", cn)
+	case <-time.After(3 * time.Second):
+		t.Fatal("server did not accept the connection")
+	}
+	assert.Equal(t, 0, len(peers))
+}
+
+func TestTlsConfigBuilderVersions(t *testing.T) {
+	dir := t.TempDir()
+	serverCert := writeTestCertificate(t, dir, "server")
+	clientCert := writeTestCertificate(t, dir, "client")
+	serverBuilder := &ServerTlsConfigBuilder{
+		ServerKeyCertChainPath: serverCert.KeyCertChainPath,
+		ServerPrivateKeyPath:   serverCert.PrivateKeyPath,
+		MinVersion:             tls.VersionTLS12,
+		MaxVersion:             tls.VersionTLS12,
+		CipherSuites:           []uint16{tls.TLS_ECDHE_ECDSA_WITH_AES_128_GCM_SHA256},
+		CurvePreferences:       []tls.CurveID{tls.CurveP256},
+	}
+	clientBuilder := &ClientTlsConfigBuilder{
+		ClientKeyCertChainPath:        clientCert.KeyCertChainPath,
+		ClientPrivateKeyPath:          clientCert.PrivateKeyPath,
+		ClientTrustCertCollectionPath: serverCert.KeyCertChainPath,
+		CurvePreferences:              []tls.CurveID{tls.CurveP256},
+	}
+	serverConfig, err := serverBuilder.BuildTlsConfig()
+	assert.Nil(t, err)
+	clientConfig, err := clientBuilder.BuildTlsConfig()
+	assert.Nil(t, err)
+
+	handshake := func() (tls.ConnectionState, error) {
+		c, s := net.Pipe()
+		defer c.Close()
+		defer s.Close()
+		go tls.Server(s, serverConfig).Handshake()
+		conn := tls.Client(c, clientConfig)
+		err := conn.Handshake()
+		return conn.ConnectionState(), err
+	}
+	state, err := handshake()
+	assert.Nil(t, err)
+	assert.Equal(t, uint16(tls.VersionTLS12), state.Version)
+	assert.Equal(t, tls.TLS_ECDHE_ECDSA_WITH_AES_128_GCM_SHA256, state.CipherSuite)
+
+	clientBuilder.MinVersion = tls.VersionTLS13
+	clientConfig, err = clientBuilder.BuildTlsConfig()
+	assert.Nil(t, err)
+	_, err = handshake()
+	assert.NotNil(t, err)
+
+	clientBuilder.MaxVersion = tls.VersionTLS12
+	_, err = clientBuilder.BuildTlsConfig()
+	assert.NotNil(t, err)
+}
+
+func TestTlsSessionResumption(t *testing.T) {
+	dir := t.TempDir()
+	serverCert := writeTestCertificate(t, dir, "server")
+	clientCert := writeTestCertificate(t, dir, "client")
+	newServerBuilder := func() *ServerTlsConfigBuilder {
+		return &ServerTlsConfigBuilder{
+			ServerKeyCertChainPath: serverCert.KeyCertChainPath,
+			ServerPrivateKeyPath:   serverCert.PrivateKeyPath,
+		}
+	}
+	clientBuilder := &ClientTlsConfigBuilder{
+		ClientKeyCertChainPath:        clientCert.KeyCertChainPath,
+		ClientPrivateKeyPath:          clientCert.PrivateKeyPath,
+		ClientTrustCertCollectionPath: serverCert.KeyCertChainPath,
+		ClientSessionCacheSize:        8,
+	}
+	handshake := func(serverBuilder *ServerTlsConfigBuilder) bool {
+		serverConfig, err := serverBuilder.BuildTlsConfig()
+		assert.Nil(t, err)
+		clientConfig, err := clientBuilder.BuildTlsConfig()
+		assert.Nil(t, err)
+		clientConfig.ServerName = "server"
+
+		// net.Pipe deadlocks as the both sides write at the same time in the resumption
+		ln, err := net.Listen("tcp", "127.0.0.1:0")
+		assert.Nil(t, err)
+		defer ln.Close()
+		go func() {
+			s, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			defer s.Close()
+			conn := tls.Server(s, serverConfig)
+			if conn.Handshake() == nil {
+				conn.Write([]byte{1})
+				conn.Read(make([]byte, 1))
+			}
+		}()
+		c, err := net.Dial("tcp", ln.Addr().String())
+		assert.Nil(t, err)
+		defer c.Close()
+		conn := tls.Client(c, clientConfig)
+		assert.Nil(t, conn.Handshake())
+		// the session ticket is received before the data
+		_, err = conn.Read(make([]byte, 1))
+		assert.Nil(t, err)
+		return conn.ConnectionState().DidResume
+	}
+
+	keys := [][32]byte{{1}, {2}}
+	instance1, instance2, other := newServerBuilder(), newServerBuilder(), newServerBuilder()
+	assert.NotNil(t, instance1.SetSessionTicketKeys(nil))
+	assert.Nil(t, instance1.SetSessionTicketKeys(keys))
+	assert.Nil(t, instance2.SetSessionTicketKeys(keys))
+	assert.False(t, handshake(instance1))
+	assert.True(t, handshake(instance1))
+	// the instances sharing the keys resume the sessions of each other
+	assert.True(t, handshake(instance2))
+	assert.False(t, handshake(other))
+
+	rotated := newServerBuilder()
+	rotated.SessionTicketKeyRotationInterval = time.Hour
+	assert.False(t, handshake(rotated))
+	assert.True(t, handshake(rotated))
+	keys, err := rotated.tickets.next()
+	assert.Nil(t, err)
+	assert.Equal(t, 2, len(keys))
+	assert.Nil(t, rotated.tickets.set(keys))
+	// the tickets of the former key are still accepted
+	assert.True(t, handshake(rotated))
+}
+
+func TestTlsALPN(t *testing.T) {
+	dir := t.TempDir()
+	serverCert := writeTestCertificate(t, dir, "server")
+	clientCert := writeTestCertificate(t, dir, "client")
+	s := NewTCPServer(
+		WithLocalAddress("127.0.0.1:0"),
+		WithServerSslEnabled(true),
+		WithServerTlsConfigBuilder(&ServerTlsConfigBuilder{
+			ServerKeyCertChainPath: serverCert.KeyCertChainPath,
+			ServerPrivateKeyPath:   serverCert.PrivateKeyPath,
+			NextProtos:             []string{"dubbo", "echo"},
+		}),
+	).(*server)
+	defer s.Close()
+	protocols := make(chan string, 2)
+	s.RunEventLoop(func(session Session) error {
+		state, _ := session.TLSConnectionState()
+		protocols <- state.NegotiatedProtocol
+		session.SetPkgHandler(&stringPkgHandler{})
+		session.SetEventListener(newChanMessageHandler())
+		return nil
+	})
+
+	for _, protocol := range []string{"echo", "dubbo"} {
+		config, err := (&ClientTlsConfigBuilder{
+			ClientKeyCertChainPath:        clientCert.KeyCertChainPath,
+			ClientPrivateKeyPath:          clientCert.PrivateKeyPath,
+			ClientTrustCertCollectionPath: serverCert.KeyCertChainPath,
+			NextProtos:                    []string{protocol},
+		}).BuildTlsConfig()
+		assert.Nil(t, err)
+		conn, err := tls.Dial("tcp", s.addr, config)
+		assert.Nil(t, err)
+		assert.Equal(t, protocol, conn.ConnectionState().NegotiatedProtocol)
+		select {
+		case negotiated := <-protocols:
+			assert.Equal(t, protocol, negotiated)
+		case <-time.After(3 * time.Second):
+			t.Fatal("server did not accept the connection")
+		}
+		conn.Close()
+	}
+}
+
+func TestTlsKeyLogWriter(t *testing.T) {
+	dir := t.TempDir()
+	serverCert := writeTestCertificate(t, dir, "server")
+	clientCert := writeTestCertificate(t, dir, "client")
+	var serverLog, clientLog bytes.Buffer
+	serverConfig, err := (&ServerTlsConfigBuilder{
+		ServerKeyCertChainPath: serverCert.KeyCertChainPath,
+		ServerPrivateKeyPath:   serverCert.PrivateKeyPath,
+		KeyLogWriter:           &serverLog,
+	}).BuildTlsConfig()
+	assert.Nil(t, err)
+	clientConfig, err := (&ClientTlsConfigBuilder{
+		ClientKeyCertChainPath:        clientCert.KeyCertChainPath,
+		ClientPrivateKeyPath:          clientCert.PrivateKeyPath,
+		ClientTrustCertCollectionPath: serverCert.KeyCertChainPath,
+		KeyLogWriter:                  &clientLog,
+	}).BuildTlsConfig()
+	assert.Nil(t, err)
+
+	c, s := net.Pipe()
+	defer c.Close()
+	defer s.Close()
+	done := make(chan error, 1)
+	go func() {
+		done <- tls.Server(s, serverConfig).Handshake()
+	}()
+	assert.Nil(t, tls.Client(c, clientConfig).Handshake())
+	assert.Nil(t, <-done)
+
+	assert.Contains(t, clientLog.String(), "CLIENT_TRAFFIC_SECRET_0 ")
+	assert.Contains(t, serverLog.String(), "SERVER_TRAFFIC_SECRET_0 ")
+}
+
+func TestWSSClientCertificate(t *testing.T) {
+	dir := t.TempDir()
+	serverCert := writeTestCertificate(t, dir, "server")
+	clientCert := writeTestCertificate(t, dir, "client")
+	s := NewWSSServer(
+		WithLocalAddress("127.0.0.1:0"),
+		WithWebsocketServerPath("/getty"),
+		WithWebsocketServerCert(serverCert.KeyCertChainPath),
+		WithWebsocketServerPrivateKey(serverCert.PrivateKeyPath),
+		// require the client certificate
+		WithWebsocketServerRootCert(clientCert.KeyCertChainPath),
+	).(*server)
+	peers := make(chan string, 1)
+	s.RunEventLoop(func(session Session) error {
+		state, ok := session.TLSConnectionState()
+		assert.True(t, ok)
+		peers <- state.PeerCertificates[0].Subject.CommonName
+		session.SetPkgHandler(&stringPkgHandler{})
+		session.SetEventListener(newChanMessageHandler())
+		return nil
+	})
+
+	clt := NewWSSClient(
+		WithServerAddress("wss://"+s.addr+"/getty"),
+		WithConnectionNumber(1),
+		WithReconnectInterval(1e7),
+		WithClientTlsConfigBuilder(&ClientTlsConfigBuilder{
+			ClientKeyCertChainPath:        clientCert.KeyCertChainPath,
+			ClientPrivateKeyPath:          clientCert.PrivateKeyPath,
+			ClientTrustCertCollectionPath: serverCert.KeyCertChainPath,
+		}),
+	)
+	defer clt.Close()
+	clientHandler := newChanMessageHandler()
+	clt.RunEventLoop(func(session Session) error {
+		session.SetPkgHandler(&stringPkgHandler{})
+		session.SetEventListener(clientHandler)
+		return nil
+	})
+	assert.Equal(t, 1, clientHandler.SessionNumber())
+	state, ok := clientHandler.array[0].TLSConnectionState()
+	assert.True(t, ok)
+	assert.Equal(t, "server", state.PeerCertificates[0].Subject.CommonName)
+	select {
+	case cn := <-peers:
+		assert.Equal(t, "client", cn)
+	case <-time.After(3 * time.Second):
+		t.Fatal("server did not accept the connection")
+	}
+}
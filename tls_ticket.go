@@ -0,0 +1,103 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package getty
+
+import (
+	"crypto/rand"
+	"crypto/tls"
+	"sync"
+	"time"
+)
+
+import (
+	perrors "github.com/pkg/errors"
+)
+
+// sessionTicketKeyNum is the number of the rotated session ticket keys, the newest one
+// encrypts the tickets and the two former ones still decrypt.
+const sessionTicketKeyNum = 3
+
+// sessionTicketKeys keeps the session ticket keys of the tls configs built by ServerTlsConfigBuilder.
+type sessionTicketKeys struct {
+	lock    sync.Mutex
+	configs []*tls.Config
+	keys    [][32]byte
+
+	rotateOnce sync.Once
+}
+
+// add applies the current keys to @config, whose later keys are set by set.
+func (k *sessionTicketKeys) add(config *tls.Config) {
+	k.lock.Lock()
+	defer k.lock.Unlock()
+
+	k.configs = append(k.configs, config)
+	if len(k.keys) != 0 {
+		config.SetSessionTicketKeys(k.keys)
+	}
+}
+
+func (k *sessionTicketKeys) set(keys [][32]byte) error {
+	if len(keys) == 0 {
+		return perrors.New("no session ticket key")
+	}
+
+	k.lock.Lock()
+	defer k.lock.Unlock()
+
+	k.keys = append([][32]byte(nil), keys...)
+	for _, config := range k.configs {
+		config.SetSessionTicketKeys(k.keys)
+	}
+
+	return nil
+}
+
+// next returns the current keys with a new random key in front.
+func (k *sessionTicketKeys) next() ([][32]byte, error) {
+	var key [32]byte
+	if _, err := rand.Read(key[:]); err != nil {
+		return nil, perrors.WithStack(err)
+	}
+
+	k.lock.Lock()
+	defer k.lock.Unlock()
+
+	keys := append([][32]byte{key}, k.keys...)
+	if len(keys) > sessionTicketKeyNum {
+		keys = keys[:sessionTicketKeyNum]
+	}
+	return keys, nil
+}
+
+// rotate generates a new session ticket key every @interval.
+func (k *sessionTicketKeys) rotate(interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		keys, err := k.next()
+		if err == nil {
+			err = k.set(keys)
+		}
+		if err != nil {
+			log.Warnf("rotate session ticket keys error:%+v", err)
+		}
+		<-ticker.C
+	}
+}
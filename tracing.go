@@ -0,0 +1,120 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package getty
+
+import (
+	"context"
+	"strconv"
+	"time"
+)
+
+// Span is a span of the Tracing, eg: the adapter of an opentelemetry trace.Span.
+type Span interface {
+	SetAttributes(attrs map[string]string)
+	RecordError(err error)
+	End()
+}
+
+// Tracer starts the spans of the Tracing, eg: the adapter of an opentelemetry trace.Tracer
+// returned by NewTracer of github.com/apache/dubbo-getty/contrib/otel.
+type Tracer interface {
+	// Start starts the span @name as a child of the span in @ctx, and returns the context of the
+	// new span.
+	Start(ctx context.Context, name string) (context.Context, Span)
+}
+
+// TraceCarrier propagates the trace context by the packages, eg: by the headers of the rpc packages
+// and an opentelemetry TextMapPropagator.
+type TraceCarrier interface {
+	// Extract returns @ctx with the trace context carried by @pkg.
+	Extract(ctx context.Context, pkg interface{}) context.Context
+	// Inject puts the trace context of @ctx into @pkg.
+	Inject(ctx context.Context, pkg interface{})
+}
+
+// Tracing starts a span per OnMessage and per WritePkg by its Interceptor. The span of OnMessage is
+// the child of the trace context extracted from the received package, and is injected back into
+// the package for the handler. The span of WritePkg is the child of the trace context extracted
+// from the package, eg: the one injected in OnMessage, and is injected into the package before it
+// is encoded. Without a Carrier, the spans are the children of the context of the session.
+type Tracing struct {
+	Tracer  Tracer
+	Carrier TraceCarrier
+}
+
+// Interceptor returns the Interceptor which traces the sessions.
+func (t *Tracing) Interceptor() Interceptor {
+	return Interceptor{
+		Listener: func(next EventListener) EventListener {
+			return &tracingListener{EventListener: next, tracing: t}
+		},
+		Write: func(next WriteFunc) WriteFunc {
+			return func(session Session, pkg interface{}, timeout time.Duration) (int, int, error) {
+				span := t.start(session, pkg, "getty.WritePkg")
+				defer span.End()
+				total, sent, err := next(session, pkg, timeout)
+				if err != nil {
+					span.RecordError(err)
+				}
+				return total, sent, err
+			}
+		},
+	}
+}
+
+// start starts the span @name of @pkg.
+func (t *Tracing) start(session Session, pkg interface{}, name string) Span {
+	ctx := session.Context()
+	if t.Carrier != nil {
+		ctx = t.Carrier.Extract(ctx, pkg)
+	}
+	ctx, span := t.Tracer.Start(ctx, name)
+	span.SetAttributes(sessionAttributes(session))
+	if t.Carrier != nil {
+		t.Carrier.Inject(ctx, pkg)
+	}
+
+	return span
+}
+
+// sessionAttributes returns the attributes of the spans of @session, and its labels are prefixed
+// by "getty.label.".
+func sessionAttributes(session Session) map[string]string {
+	labels := session.Stats().Labels
+	attrs := make(map[string]string, len(labels)+4)
+	for k, v := range labels {
+		attrs["getty.label."+k] = v
+	}
+	attrs["getty.endpoint"] = session.EndPoint().EndPointType().String()
+	attrs["getty.session.id"] = strconv.FormatUint(uint64(session.ID()), 10)
+	attrs["net.sock.host.addr"] = session.LocalAddr()
+	attrs["net.sock.peer.addr"] = session.RemoteAddr()
+
+	return attrs
+}
+
+type tracingListener struct {
+	EventListener
+	tracing *Tracing
+}
+
+func (l *tracingListener) OnMessage(session Session, pkg interface{}) {
+	span := l.tracing.start(session, pkg, "getty.OnMessage")
+	defer span.End()
+	l.EventListener.OnMessage(session, pkg)
+}
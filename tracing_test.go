@@ -0,0 +1,113 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package getty
+
+import (
+	"context"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+)
+
+import (
+	"github.com/stretchr/testify/assert"
+)
+
+type traceIDCtxKey struct{}
+
+type testSpan struct {
+	name   string
+	parent string
+	attrs  map[string]string
+	err    error
+	ended  bool
+}
+
+func (s *testSpan) SetAttributes(attrs map[string]string) { s.attrs = attrs }
+func (s *testSpan) RecordError(err error)                 { s.err = err }
+func (s *testSpan) End()                                  { s.ended = true }
+
+// testTracer names the spans by their order.
+type testTracer struct {
+	lock  sync.Mutex
+	spans []*testSpan
+}
+
+func (t *testTracer) Start(ctx context.Context, name string) (context.Context, Span) {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+	parent, _ := ctx.Value(traceIDCtxKey{}).(string)
+	span := &testSpan{name: name, parent: parent}
+	t.spans = append(t.spans, span)
+	return context.WithValue(ctx, traceIDCtxKey{}, name+"#"+strconv.Itoa(len(t.spans))), span
+}
+
+// tracedPkg carries the trace id.
+type tracedPkg struct {
+	traceID string
+}
+
+type testCarrier struct{}
+
+func (testCarrier) Extract(ctx context.Context, pkg interface{}) context.Context {
+	if p, ok := pkg.(*tracedPkg); ok && p.traceID != "" {
+		return context.WithValue(ctx, traceIDCtxKey{}, p.traceID)
+	}
+	return ctx
+}
+
+func (testCarrier) Inject(ctx context.Context, pkg interface{}) {
+	if p, ok := pkg.(*tracedPkg); ok {
+		p.traceID, _ = ctx.Value(traceIDCtxKey{}).(string)
+	}
+}
+
+func TestTracing(t *testing.T) {
+	ss, _ := newPipeSessions(t)
+	ss.SetLabel("tenant", "t1")
+	tracer := &testTracer{}
+	tracing := &Tracing{Tracer: tracer, Carrier: testCarrier{}}
+	handler := newChanMessageHandler()
+	listener := tracing.Interceptor().Listener(handler)
+
+	pkg := &tracedPkg{traceID: "remote#0"}
+	listener.OnMessage(ss, pkg)
+	assert.Equal(t, pkg, <-handler.msgs)
+	assert.Equal(t, "getty.OnMessage#1", pkg.traceID)
+	span := tracer.spans[0]
+	assert.Equal(t, "remote#0", span.parent)
+	assert.True(t, span.ended)
+	assert.Equal(t, "t1", span.attrs["getty.label.tenant"])
+	assert.Equal(t, "PIPE_CLIENT", span.attrs["getty.endpoint"])
+	assert.Equal(t, ss.RemoteAddr(), span.attrs["net.sock.peer.addr"])
+
+	// the reply is the child of the span of OnMessage
+	var written *tracedPkg
+	write := tracing.Interceptor().Write(func(session Session, pkg interface{}, _ time.Duration) (int, int, error) {
+		written = pkg.(*tracedPkg)
+		return 0, 0, ErrSessionClosed
+	})
+	_, _, err := write(ss, pkg, 0)
+	assert.Equal(t, ErrSessionClosed, err)
+	assert.Equal(t, "getty.WritePkg#2", written.traceID)
+	span = tracer.spans[1]
+	assert.Equal(t, "getty.OnMessage#1", span.parent)
+	assert.Equal(t, ErrSessionClosed, span.err)
+	assert.True(t, span.ended)
+}
@@ -0,0 +1,80 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package getty
+
+import (
+	"sync/atomic"
+)
+
+// TrafficDirection is the direction of the bytes passed to a TrafficTap.
+type TrafficDirection int
+
+const (
+	TrafficIn TrafficDirection = iota
+	TrafficOut
+)
+
+func (d TrafficDirection) String() string {
+	if d == TrafficIn {
+		return "in"
+	}
+
+	return "out"
+}
+
+// TrafficTap is invoked with the raw bytes read or written by the connection of @session, which
+// are decrypted by tls but not decoded by the codec, eg: to mirror the traffic to a pcap file or a
+// ring buffer when diagnosing a codec. @data is only valid during the call, and the tap should be
+// fast as it runs in the reading and writing goroutines.
+type TrafficTap func(session Session, direction TrafficDirection, data []byte)
+
+// trafficTapper holds the TrafficTap of an endpoint, which can be replaced at runtime.
+type trafficTapper struct {
+	tap atomic.Pointer[TrafficTap]
+}
+
+// SetTrafficTap replaces the TrafficTap of the sessions, nil disables the tapping.
+func (t *trafficTapper) SetTrafficTap(tap TrafficTap) {
+	if tap == nil {
+		t.tap.Store(nil)
+		return
+	}
+	t.tap.Store(&tap)
+}
+
+func (t *trafficTapper) currentTap() TrafficTap {
+	if tap := t.tap.Load(); tap != nil {
+		return *tap
+	}
+
+	return nil
+}
+
+// tap passes @data to the TrafficTap of the endpoint of the session of the connection.
+func (c *gettyConn) tap(direction TrafficDirection, data []byte) {
+	if c.ss == nil || len(data) == 0 {
+		return
+	}
+	t, ok := c.ss.EndPoint().(interface{ currentTap() TrafficTap })
+	if !ok {
+		return
+	}
+	if tap := t.currentTap(); tap != nil {
+		tap(c.ss, direction, data)
+	}
+}
@@ -0,0 +1,74 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package getty
+
+import (
+	"testing"
+	"time"
+)
+
+import (
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTrafficTap(t *testing.T) {
+	type tapped struct {
+		direction TrafficDirection
+		data      string
+	}
+	taps := make(chan tapped, 16)
+	tap := func(session Session, direction TrafficDirection, data []byte) {
+		taps <- tapped{direction: direction, data: string(data)}
+	}
+	serverHandler := newChanMessageHandler()
+	s := NewTCPServer(WithLocalAddress("127.0.0.1:0"), WithTrafficTap(tap)).(*server)
+	defer s.Close()
+	s.RunEventLoop(func(session Session) error {
+		session.SetPkgHandler(&stringPkgHandler{})
+		session.SetEventListener(serverHandler)
+		session.SetReadTimeout(50 * time.Millisecond)
+		return nil
+	})
+
+	clt := NewTCPClient(WithServerAddress(s.addr), WithConnectionNumber(1), WithReconnectInterval(1e7))
+	defer clt.Close()
+	clientHandler := newChanMessageHandler()
+	clt.RunEventLoop(func(session Session) error {
+		session.SetPkgHandler(&stringPkgHandler{})
+		session.SetEventListener(clientHandler)
+		session.SetReadTimeout(50 * time.Millisecond)
+		return nil
+	})
+	assert.Equal(t, 1, clientHandler.SessionNumber())
+
+	_, _, err := clientHandler.array[0].WritePkg("ping", 0)
+	assert.Nil(t, err)
+	assert.Equal(t, "ping", <-serverHandler.msgs)
+	assert.Equal(t, tapped{direction: TrafficIn, data: "\x00\x00\x00\x04ping"}, <-taps)
+	_, _, err = serverHandler.array[0].WritePkg("pong", 0)
+	assert.Nil(t, err)
+	assert.Equal(t, "pong", <-clientHandler.msgs)
+	assert.Equal(t, tapped{direction: TrafficOut, data: "\x00\x00\x00\x04pong"}, <-taps)
+
+	// the tap is disabled at runtime
+	s.SetTrafficTap(nil)
+	_, _, err = clientHandler.array[0].WritePkg("ping", 0)
+	assert.Nil(t, err)
+	assert.Equal(t, "ping", <-serverHandler.msgs)
+	assert.Equal(t, 0, len(taps))
+}
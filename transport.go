@@ -0,0 +1,84 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package getty
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"io"
+	"net"
+	"time"
+)
+
+import (
+	perrors "github.com/pkg/errors"
+)
+
+// Transport provides the stream connections of the transports other than tcp, eg: the quic, kcp
+// and sctp ones of the modules under contrib/, which are served as the tcp connections. The
+// methods should be goroutine safe.
+type Transport interface {
+	// Listen announces on @addr, @config is the tls config of WithServerTlsConfigBuilder or nil.
+	Listen(addr string, config *tls.Config) (net.Listener, error)
+	// Dial connects to @addr before the deadline of @ctx, @config is the tls config of
+	// WithClientTlsConfigBuilder or nil.
+	Dial(ctx context.Context, addr string, config *tls.Config) (net.Conn, error)
+	// Close releases the resources shared by the dialed connections, eg: a quic connection
+	// or a sctp association. It is called when the client is closed.
+	Close() error
+}
+
+// transportSessionName returns the default session name of the transport endpoint type @t.
+func transportSessionName(t EndPointType) string {
+	switch t {
+	case QUIC_CLIENT, QUIC_SERVER:
+		return defaultQUICSessionName
+	case KCP_CLIENT, KCP_SERVER:
+		return defaultKCPSessionName
+	case SCTP_CLIENT, SCTP_SERVER:
+		return defaultSCTPSessionName
+	}
+
+	return defaultTCPSessionName
+}
+
+// writeConnectPing sends connectPingPackage on a new connection of a Transport, so that the
+// peer knows it asap, eg: a quic stream is invisible to its peer until some data is sent on it.
+func writeConnectPing(conn net.Conn) error {
+	conn.SetWriteDeadline(time.Now().Add(connectTimeout))
+	defer conn.SetWriteDeadline(time.Time{})
+	_, err := conn.Write(connectPingPackage)
+
+	return perrors.WithStack(err)
+}
+
+// readConnectPing reads the connectPingPackage sent by writeConnectPing.
+func readConnectPing(conn net.Conn) (net.Conn, error) {
+	buf := make([]byte, len(connectPingPackage))
+	conn.SetReadDeadline(time.Now().Add(connectTimeout))
+	defer conn.SetReadDeadline(time.Time{})
+	if _, err := io.ReadFull(conn, buf); err != nil {
+		return nil, perrors.WithStack(err)
+	}
+	if !bytes.Equal(buf, connectPingPackage) {
+		return nil, perrors.Errorf("illegal connect ping package %q", buf)
+	}
+
+	return conn, nil
+}
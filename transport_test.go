@@ -0,0 +1,113 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package getty
+
+import (
+	"context"
+	"crypto/tls"
+	"net"
+	"testing"
+	"time"
+)
+
+import (
+	"github.com/stretchr/testify/assert"
+
+	uatomic "go.uber.org/atomic"
+)
+
+// tcpTransport is a Transport of the plain tcp connections.
+type tcpTransport struct {
+	closed uatomic.Int32
+}
+
+func (t *tcpTransport) Listen(addr string, _ *tls.Config) (net.Listener, error) {
+	return net.Listen("tcp", addr)
+}
+
+func (t *tcpTransport) Dial(ctx context.Context, addr string, _ *tls.Config) (net.Conn, error) {
+	var dialer net.Dialer
+	return dialer.DialContext(ctx, "tcp", addr)
+}
+
+func (t *tcpTransport) Close() error {
+	t.closed.Inc()
+	return nil
+}
+
+func TestTransportEndpoint(t *testing.T) {
+	s := NewTransportServer(KCP_SERVER, &tcpTransport{}, WithLocalAddress("127.0.0.1:0")).(*server)
+	defer s.Close()
+	serverHandler := newChanMessageHandler()
+	s.RunEventLoop(func(session Session) error {
+		session.SetPkgHandler(&stringPkgHandler{})
+		session.SetEventListener(serverHandler)
+		return nil
+	})
+
+	transport := &tcpTransport{}
+	clt := NewTransportClient(KCP_CLIENT, transport, WithServerAddress(s.addr), WithConnectionNumber(2)).(*client)
+	clientHandler := newChanMessageHandler()
+	clt.RunEventLoop(func(session Session) error {
+		session.SetPkgHandler(&stringPkgHandler{})
+		session.SetEventListener(clientHandler)
+		return nil
+	})
+	assert.Equal(t, 2, clt.sessionNum())
+	assert.Equal(t, defaultKCPSessionName, clientHandler.array[0].(*session).name)
+
+	_, _, err := clientHandler.array[0].WritePkg("hello", 0)
+	assert.Nil(t, err)
+	select {
+	case msg := <-serverHandler.msgs:
+		assert.Equal(t, "hello", msg)
+	case <-time.After(3 * time.Second):
+		t.Fatal("server did not receive the package")
+	}
+	assert.Eventually(t, func() bool { return serverHandler.SessionNumber() == 2 }, time.Second, 10*time.Millisecond)
+	assert.Equal(t, defaultKCPSessionName, serverHandler.array[0].(*session).name)
+
+	clt.Close()
+	assert.Equal(t, int32(1), transport.closed.Load())
+	assert.Panics(t, func() { NewTransportClient(KCP_CLIENT, nil, WithServerAddress(s.addr)) })
+}
+
+func TestTransportServerSilentPeer(t *testing.T) {
+	s := NewTransportServer(QUIC_SERVER, &tcpTransport{}, WithLocalAddress("127.0.0.1:0")).(*server)
+	defer s.Close()
+	serverHandler := newChanMessageHandler()
+	s.RunEventLoop(func(session Session) error {
+		session.SetPkgHandler(&stringPkgHandler{})
+		session.SetEventListener(serverHandler)
+		return nil
+	})
+
+	// the peer which never completes its connect ping does not block the accepting
+	silent, err := net.Dial("tcp", s.addr)
+	assert.Nil(t, err)
+	defer silent.Close()
+	_, err = silent.Write(connectPingPackage[:4])
+	assert.Nil(t, err)
+	time.Sleep(50 * time.Millisecond)
+
+	conn, err := net.Dial("tcp", s.addr)
+	assert.Nil(t, err)
+	defer conn.Close()
+	assert.Nil(t, writeConnectPing(conn))
+	assert.Eventually(t, func() bool { return serverHandler.SessionNumber() == 1 }, time.Second, 10*time.Millisecond)
+}
@@ -0,0 +1,342 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package getty
+
+import (
+	"errors"
+	"net"
+	"os"
+	"sync"
+	"time"
+)
+
+import (
+	perrors "github.com/pkg/errors"
+)
+
+const (
+	// udpMaxPacketLen is the max length of a udp payload
+	udpMaxPacketLen = 64 << 10
+	// udpPeerBacklog is the max number of the packets queued for a peer session,
+	// the later packets are dropped as the udp socket does.
+	udpPeerBacklog = 256
+	// udpPeerMinSweepInterval is the min interval to check the idle peer sessions
+	udpPeerMinSweepInterval = 100 * time.Millisecond
+)
+
+// udpConn is a udp socket, or the virtual connected socket of a peer demultiplexed from it.
+type udpConn interface {
+	net.Conn
+	ReadFromUDP(b []byte) (int, *net.UDPAddr, error)
+	WriteMsgUDP(b, oob []byte, addr *net.UDPAddr) (n, oobn int, err error)
+}
+
+// udpDemux demultiplexes the packets of a udp server socket by their remote address,
+// and serves every peer by its own virtual session.
+type udpDemux struct {
+	server      *server
+	conn        *net.UDPConn
+	newSession  NewSessionCallback
+	idleTimeout time.Duration
+
+	lock  sync.Mutex
+	peers map[string]*udpPeerConn
+}
+
+func newUDPDemux(server *server, conn *net.UDPConn, newSession NewSessionCallback, idleTimeout time.Duration) *udpDemux {
+	return &udpDemux{
+		server:      server,
+		conn:        conn,
+		newSession:  newSession,
+		idleTimeout: idleTimeout,
+		peers:       make(map[string]*udpPeerConn),
+	}
+}
+
+func (d *udpDemux) run() {
+	if d.idleTimeout > 0 {
+		done := make(chan struct{})
+		defer close(done)
+		go d.sweep(done)
+	}
+	defer d.closePeers()
+
+	buf := make([]byte, udpMaxPacketLen)
+	for {
+		n, addr, err := d.conn.ReadFromUDP(buf)
+		if err != nil {
+			if errors.Is(err, net.ErrClosed) || d.server.IsClosed() {
+				return
+			}
+			if netErr, ok := err.(net.Error); ok && netErr.Timeout() {
+				continue
+			}
+			endPointLogger(log, d.server).Errorw("ReadFromUDP failed", "err", err)
+			return
+		}
+
+		peer := d.peer(addr)
+		if peer == nil {
+			continue
+		}
+		pkt := make([]byte, n)
+		copy(pkt, buf[:n])
+		peer.push(pkt)
+	}
+}
+
+// peer returns the virtual connection of @addr, a new session is created for the new peer.
+func (d *udpDemux) peer(addr *net.UDPAddr) *udpPeerConn {
+	key := addr.String()
+	d.lock.Lock()
+	peer, ok := d.peers[key]
+	d.lock.Unlock()
+	if ok {
+		return peer
+	}
+
+	peer = newUDPPeerConn(d, addr)
+	ss := newUDPSession(peer, d.server)
+	if err := d.newSession(ss); err != nil {
+		log.with(ss.Logger()).Warnw("newSession failed", "err", err)
+		peer.Close()
+		return nil
+	}
+	peer.ss = ss
+	d.lock.Lock()
+	d.peers[key] = peer
+	d.lock.Unlock()
+	d.server.addSession(ss)
+	ss.(*session).run()
+
+	return peer
+}
+
+func (d *udpDemux) remove(peer *udpPeerConn) {
+	key := peer.peer.String()
+	d.lock.Lock()
+	if d.peers[key] == peer {
+		delete(d.peers, key)
+	}
+	d.lock.Unlock()
+}
+
+// sweep closes the peer sessions which have received nothing for idleTimeout.
+func (d *udpDemux) sweep(done chan struct{}) {
+	interval := d.idleTimeout / 2
+	if interval < udpPeerMinSweepInterval {
+		interval = udpPeerMinSweepInterval
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-done:
+			return
+		case <-ticker.C:
+		}
+
+		var idlePeers []*udpPeerConn
+		d.lock.Lock()
+		for key, peer := range d.peers {
+			if time.Since(peer.lastActive()) > d.idleTimeout {
+				// the later packets of the peer start a new session
+				delete(d.peers, key)
+				idlePeers = append(idlePeers, peer)
+			}
+		}
+		d.lock.Unlock()
+		for _, peer := range idlePeers {
+			log.with(peer.ss.Logger()).Infow("close the idle peer session")
+			peer.ss.Close()
+		}
+	}
+}
+
+func (d *udpDemux) closePeers() {
+	d.lock.Lock()
+	peers := d.peers
+	d.peers = make(map[string]*udpPeerConn)
+	d.lock.Unlock()
+
+	for _, peer := range peers {
+		peer.ss.Close()
+	}
+}
+
+// udpPeerConn is the virtual connected udp socket of a peer, whose packets are
+// pushed by udpDemux and written by the server socket.
+type udpPeerConn struct {
+	demux *udpDemux
+	peer  *net.UDPAddr
+	ss    Session
+
+	lock      sync.Mutex
+	pkts      [][]byte
+	active    time.Time
+	rDeadline time.Time
+	wDeadline time.Time
+	notify    chan struct{}
+
+	once sync.Once
+	done chan struct{}
+}
+
+func newUDPPeerConn(demux *udpDemux, peer *net.UDPAddr) *udpPeerConn {
+	return &udpPeerConn{
+		demux:  demux,
+		peer:   peer,
+		active: time.Now(),
+		notify: make(chan struct{}, 1),
+		done:   make(chan struct{}),
+	}
+}
+
+func (c *udpPeerConn) push(pkt []byte) {
+	c.lock.Lock()
+	c.active = time.Now()
+	if len(c.pkts) >= udpPeerBacklog {
+		c.lock.Unlock()
+		endPointLogger(log, c.demux.server).Warnw("drop a packet as the backlog of the peer is full", "peer", c.peer)
+		return
+	}
+	c.pkts = append(c.pkts, pkt)
+	c.lock.Unlock()
+
+	select {
+	case c.notify <- struct{}{}:
+	default:
+	}
+}
+
+func (c *udpPeerConn) lastActive() time.Time {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	return c.active
+}
+
+// ReadFromUDP reads a packet of the peer. The rest of the packet is discarded if @b is too short.
+func (c *udpPeerConn) ReadFromUDP(b []byte) (int, *net.UDPAddr, error) {
+	for {
+		c.lock.Lock()
+		if len(c.pkts) > 0 {
+			n := copy(b, c.pkts[0])
+			c.pkts = c.pkts[1:]
+			c.lock.Unlock()
+			return n, c.peer, nil
+		}
+		deadline := c.rDeadline
+		c.lock.Unlock()
+
+		var timer *time.Timer
+		var timeout <-chan time.Time
+		if !deadline.IsZero() {
+			d := time.Until(deadline)
+			if d <= 0 {
+				return 0, nil, os.ErrDeadlineExceeded
+			}
+			timer = time.NewTimer(d)
+			timeout = timer.C
+		}
+
+		var err error
+		select {
+		case <-c.notify:
+		case <-c.done:
+			err = net.ErrClosed
+		case <-timeout:
+			err = os.ErrDeadlineExceeded
+		}
+		if timer != nil {
+			timer.Stop()
+		}
+		if err != nil {
+			return 0, nil, err
+		}
+	}
+}
+
+func (c *udpPeerConn) Read(b []byte) (int, error) {
+	n, _, err := c.ReadFromUDP(b)
+	return n, err
+}
+
+// WriteMsgUDP writes a packet to @addr by the server socket, @addr is the peer if it is nil.
+func (c *udpPeerConn) WriteMsgUDP(b, oob []byte, addr *net.UDPAddr) (int, int, error) {
+	select {
+	case <-c.done:
+		return 0, 0, perrors.WithStack(net.ErrClosed)
+	default:
+	}
+	c.lock.Lock()
+	deadline := c.wDeadline
+	c.lock.Unlock()
+	if !deadline.IsZero() && time.Now().After(deadline) {
+		return 0, 0, os.ErrDeadlineExceeded
+	}
+
+	if addr == nil {
+		addr = c.peer
+	}
+	return c.demux.conn.WriteMsgUDP(b, oob, addr)
+}
+
+func (c *udpPeerConn) Write(b []byte) (int, error) {
+	n, _, err := c.WriteMsgUDP(b, nil, nil)
+	return n, err
+}
+
+func (c *udpPeerConn) Close() error {
+	c.once.Do(func() {
+		close(c.done)
+		c.demux.remove(c)
+	})
+
+	return nil
+}
+
+func (c *udpPeerConn) LocalAddr() net.Addr {
+	return c.demux.conn.LocalAddr()
+}
+
+func (c *udpPeerConn) RemoteAddr() net.Addr {
+	return c.peer
+}
+
+func (c *udpPeerConn) SetDeadline(t time.Time) error {
+	c.lock.Lock()
+	c.rDeadline, c.wDeadline = t, t
+	c.lock.Unlock()
+	return nil
+}
+
+func (c *udpPeerConn) SetReadDeadline(t time.Time) error {
+	c.lock.Lock()
+	c.rDeadline = t
+	c.lock.Unlock()
+	return nil
+}
+
+func (c *udpPeerConn) SetWriteDeadline(t time.Time) error {
+	c.lock.Lock()
+	c.wDeadline = t
+	c.lock.Unlock()
+	return nil
+}
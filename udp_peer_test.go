@@ -0,0 +1,95 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package getty
+
+import (
+	"net"
+	"testing"
+	"time"
+)
+
+import (
+	"github.com/stretchr/testify/assert"
+)
+
+// udpStringPkgHandler is the stringPkgHandler of udp sessions
+type udpStringPkgHandler struct {
+	stringPkgHandler
+}
+
+func (h *udpStringPkgHandler) Write(ss Session, pkg interface{}) ([]byte, error) {
+	if ctx, ok := pkg.(UDPContext); ok {
+		pkg = ctx.Pkg
+	}
+	return h.stringPkgHandler.Write(ss, pkg)
+}
+
+func TestUDPPeerSession(t *testing.T) {
+	s := NewUDPEndPoint(WithLocalAddress("127.0.0.1:0"), WithUDPPeerSession(300*time.Millisecond)).(*server)
+	defer s.Close()
+
+	serverHandler := newChanMessageHandler()
+	s.RunEventLoop(func(session Session) error {
+		session.SetPkgHandler(&udpStringPkgHandler{})
+		session.SetEventListener(serverHandler)
+		return nil
+	})
+
+	var conns []*net.UDPConn
+	for _, msg := range []string{"a", "b"} {
+		conn, err := net.DialUDP("udp", nil, s.pktListener.LocalAddr().(*net.UDPAddr))
+		assert.Nil(t, err)
+		defer conn.Close()
+		conns = append(conns, conn)
+
+		pkg, _ := (&stringPkgHandler{}).Write(nil, msg)
+		_, err = conn.Write(pkg)
+		assert.Nil(t, err)
+		select {
+		case ctx := <-serverHandler.msgs:
+			assert.Equal(t, msg, ctx.(UDPContext).Pkg)
+			assert.Equal(t, conn.LocalAddr().String(), ctx.(UDPContext).PeerAddr.String())
+		case <-time.After(3 * time.Second):
+			t.Fatal("server did not receive the package")
+		}
+	}
+	assert.Equal(t, 2, serverHandler.SessionNumber())
+	assert.Equal(t, 2, s.SessionCount())
+
+	// every peer session writes to its own peer without UDPContext.PeerAddr
+	for i, ss := range serverHandler.array {
+		assert.Equal(t, conns[i].LocalAddr().String(), ss.RemoteAddr())
+		_, _, err := ss.WritePkg(UDPContext{Pkg: ss.RemoteAddr()}, 0)
+		assert.Nil(t, err)
+
+		buf := make([]byte, 128)
+		conns[i].SetReadDeadline(time.Now().Add(3 * time.Second))
+		n, err := conns[i].Read(buf)
+		assert.Nil(t, err)
+		pkg, _, _ := (&stringPkgHandler{}).Read(nil, buf[:n])
+		assert.Equal(t, ss.RemoteAddr(), pkg)
+	}
+
+	// the idle peer sessions are closed
+	select {
+	case <-serverHandler.closed:
+	case <-time.After(3 * time.Second):
+		t.Fatal("the idle peer session is not closed")
+	}
+	assert.Eventually(t, func() bool { return s.SessionCount() == 0 }, 3*time.Second, 10*time.Millisecond)
+}
@@ -0,0 +1,224 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package getty
+
+import (
+	"fmt"
+	"net"
+	"os"
+	"sync"
+	"time"
+)
+
+import (
+	perrors "github.com/pkg/errors"
+)
+
+const (
+	// upgradeFdEnv is the env of the new process which carries the fd of the unix socket
+	// over which the old process hands the listeners over.
+	upgradeFdEnv = "GETTY_UPGRADE_FD"
+	// upgradeReadyTimeout is the max time to wait for the new process to take the listeners.
+	upgradeReadyTimeout = 30 * time.Second
+	// upgradeMaxFiles is the max number of listeners handed over in an upgrade.
+	upgradeMaxFiles = 256
+)
+
+var (
+	upgradeOnce  sync.Once
+	upgradeLock  sync.Mutex
+	upgradeFiles map[string][]*os.File
+)
+
+// filer is implemented by *net.TCPListener, *net.UnixListener and *net.UDPConn.
+type filer interface {
+	File() (*os.File, error)
+}
+
+// Upgrader hands the listeners of its servers over to a new process of the same binary,
+// so that a long-lived gateway can be upgraded without refusing any connection:
+//
+//	upgrader := getty.NewUpgrader(30 * time.Second)
+//	upgrader.AddServer(server)
+//	server.RunEventLoop(newSession)
+//	upgrader.ListenSignal()
+//	<-upgrader.Exit()
+//
+// On SIGUSR2 the upgrader starts the new binary and passes the listener fds to it over a
+// unix socket. The servers of the new process take the listeners which have the same type
+// and local addresses instead of binding them. After the new process has received the
+// listeners, the old servers stop accepting and drain their sessions.
+type Upgrader struct {
+	drainTimeout time.Duration
+
+	lock      sync.Mutex
+	servers   []*server
+	upgrading bool
+
+	once sync.Once
+	exit chan struct{}
+}
+
+// NewUpgrader builds an upgrader. @drainTimeout is the max time to wait for the sessions
+// of the old process to be closed by their peers, the rest of them are closed then.
+func NewUpgrader(drainTimeout time.Duration) *Upgrader {
+	return &Upgrader{
+		drainTimeout: drainTimeout,
+		exit:         make(chan struct{}),
+	}
+}
+
+// AddServer adds a server whose listeners are handed over on upgrade.
+func (u *Upgrader) AddServer(s Server) {
+	ss, ok := s.(*server)
+	if !ok {
+		panic(fmt.Sprintf("illegal server %T", s))
+	}
+
+	u.lock.Lock()
+	u.servers = append(u.servers, ss)
+	u.lock.Unlock()
+}
+
+// Exit is closed after the sessions of the servers are drained on upgrade,
+// and then the old process should exit.
+func (u *Upgrader) Exit() <-chan struct{} {
+	return u.exit
+}
+
+// Upgrade starts the new process and hands the listeners over to it.
+func (u *Upgrader) Upgrade() error {
+	u.lock.Lock()
+	if u.upgrading {
+		u.lock.Unlock()
+		return perrors.New("the upgrade is in progress")
+	}
+	u.upgrading = true
+	servers := append([]*server{}, u.servers...)
+	u.lock.Unlock()
+
+	keys, files, err := listenerFiles(servers)
+	defer func() {
+		for _, f := range files {
+			f.Close()
+		}
+	}()
+	if err == nil {
+		err = startUpgradeProcess(keys, files)
+	}
+	if err != nil {
+		u.lock.Lock()
+		u.upgrading = false
+		u.lock.Unlock()
+		return perrors.WithStack(err)
+	}
+
+	var wg sync.WaitGroup
+	for _, s := range servers {
+		for _, l := range s.rawListeners {
+			if ul, ok := l.(*net.UnixListener); ok {
+				// keep the socket file for the new process
+				ul.SetUnlinkOnClose(false)
+			}
+		}
+		s.stopAccepting()
+		wg.Add(1)
+		go func(s *server) {
+			defer wg.Done()
+			s.drain(u.drainTimeout)
+			s.Close()
+		}(s)
+	}
+	go func() {
+		wg.Wait()
+		u.once.Do(func() {
+			close(u.exit)
+		})
+	}()
+
+	return nil
+}
+
+// listenerFiles returns the dup files of the listeners of @servers and their upgrade keys.
+func listenerFiles(servers []*server) ([]string, []*os.File, error) {
+	var (
+		keys  []string
+		files []*os.File
+	)
+
+	for _, s := range servers {
+		var listeners []interface{}
+		if s.endPointType == UDP_ENDPOINT {
+			for _, l := range s.pktListeners {
+				listeners = append(listeners, l)
+			}
+		} else {
+			for _, l := range s.rawListeners {
+				listeners = append(listeners, l)
+			}
+		}
+
+		for _, l := range listeners {
+			fl, ok := l.(filer)
+			if !ok {
+				endPointLogger(log, s).Warnw("listener can not be handed over", "listener", fmt.Sprintf("%T", l))
+				continue
+			}
+			f, err := fl.File()
+			if err != nil {
+				return keys, files, perrors.Wrapf(err, "server{%s} listener.File()", s.addr)
+			}
+			keys = append(keys, s.upgradeKey())
+			files = append(files, f)
+		}
+	}
+	if len(files) > upgradeMaxFiles {
+		return keys, files, perrors.Errorf("too many listeners %d, the max is %d", len(files), upgradeMaxFiles)
+	}
+
+	return keys, files, nil
+}
+
+// upgradeListenFiles returns the listener files of @key handed over by the old process.
+// It returns nil if the current process is not started by an upgrade.
+func upgradeListenFiles(key string) []*os.File {
+	upgradeOnce.Do(func() {
+		fd := os.Getenv(upgradeFdEnv)
+		if fd == "" {
+			return
+		}
+		os.Unsetenv(upgradeFdEnv)
+
+		keys, files, err := recvUpgradeProcessFiles(fd)
+		if err != nil {
+			log.Errorf("recvUpgradeProcessFiles(fd:%s) = error:%+v", fd, err)
+			return
+		}
+		upgradeFiles = make(map[string][]*os.File, len(keys))
+		for i, k := range keys {
+			upgradeFiles[k] = append(upgradeFiles[k], files[i])
+		}
+	})
+
+	upgradeLock.Lock()
+	defer upgradeLock.Unlock()
+	files := upgradeFiles[key]
+	delete(upgradeFiles, key)
+
+	return files
+}
@@ -0,0 +1,42 @@
+//go:build !linux && !darwin && !dragonfly && !freebsd && !netbsd && !openbsd
+
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package getty
+
+import (
+	"os"
+	"runtime"
+)
+
+import (
+	perrors "github.com/pkg/errors"
+)
+
+// ListenSignal does nothing because the upgrade is not supported on this platform.
+func (u *Upgrader) ListenSignal() {
+	log.Warnf("the upgrade is not supported on %s", runtime.GOOS)
+}
+
+func startUpgradeProcess(keys []string, files []*os.File) error {
+	return perrors.Errorf("the upgrade is not supported on %s", runtime.GOOS)
+}
+
+func recvUpgradeProcessFiles(fd string) ([]string, []*os.File, error) {
+	return nil, nil, perrors.Errorf("the upgrade is not supported on %s", runtime.GOOS)
+}
@@ -0,0 +1,119 @@
+//go:build linux || darwin || dragonfly || freebsd || netbsd || openbsd
+
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package getty
+
+import (
+	"net"
+	"os"
+	"testing"
+	"time"
+)
+
+import (
+	"github.com/stretchr/testify/assert"
+
+	"golang.org/x/sys/unix"
+)
+
+func TestUpgradeFilesHandOver(t *testing.T) {
+	s := NewTCPServer(WithLocalAddress("127.0.0.1:0")).(*server)
+	defer s.Close()
+	s.RunEventLoop(func(session Session) error {
+		session.SetPkgHandler(&stringPkgHandler{})
+		session.SetEventListener(newChanMessageHandler())
+		return nil
+	})
+
+	keys, files, err := listenerFiles([]*server{s})
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"TCP_SERVER|127.0.0.1:0"}, keys)
+
+	fds, err := unix.Socketpair(unix.AF_UNIX, unix.SOCK_STREAM, 0)
+	assert.Nil(t, err)
+	newUnixConn := func(fd int) *net.UnixConn {
+		f := os.NewFile(uintptr(fd), "")
+		defer f.Close()
+		conn, err := net.FileConn(f)
+		assert.Nil(t, err)
+		return conn.(*net.UnixConn)
+	}
+	parent, child := newUnixConn(fds[0]), newUnixConn(fds[1])
+	defer parent.Close()
+	defer child.Close()
+
+	errs := make(chan error, 1)
+	go func() {
+		errs <- sendUpgradeFiles(parent, keys, files)
+	}()
+	gotKeys, gotFiles, err := recvUpgradeFiles(child)
+	assert.Nil(t, err)
+	assert.Nil(t, <-errs)
+	assert.Equal(t, keys, gotKeys)
+	assert.Equal(t, 1, len(gotFiles))
+	for _, f := range files {
+		f.Close()
+	}
+
+	// the handed over listener shares the socket with the old one
+	s.stopAccepting()
+	ln, err := net.FileListener(gotFiles[0])
+	assert.Nil(t, err)
+	defer ln.Close()
+	assert.Equal(t, s.addr, ln.Addr().String())
+	go func() {
+		if conn, err := net.Dial("tcp", s.addr); err == nil {
+			conn.Close()
+		}
+	}()
+	conn, err := ln.Accept()
+	assert.Nil(t, err)
+	conn.Close()
+}
+
+func TestServerDrain(t *testing.T) {
+	s := NewTCPServer(WithLocalAddress("127.0.0.1:0")).(*server)
+	defer s.Close()
+	serverHandler := newChanMessageHandler()
+	s.RunEventLoop(func(session Session) error {
+		session.SetPkgHandler(&stringPkgHandler{})
+		session.SetEventListener(serverHandler)
+		return nil
+	})
+
+	clt := NewTCPClient(WithServerAddress(s.addr), WithConnectionNumber(1), WithReconnectInterval(1e7))
+	defer clt.Close()
+	clt.RunEventLoop(func(session Session) error {
+		session.SetPkgHandler(&stringPkgHandler{})
+		session.SetEventListener(newChanMessageHandler())
+		return nil
+	})
+	assert.Eventually(t, func() bool { return s.SessionCount() == 1 }, time.Second, 10*time.Millisecond)
+
+	s.stopAccepting()
+	_, err := net.Dial("tcp", s.addr)
+	assert.NotNil(t, err)
+	s.drain(200 * time.Millisecond)
+	select {
+	case <-serverHandler.closed:
+	case <-time.After(3 * time.Second):
+		t.Fatal("server session is not closed")
+	}
+	assert.Eventually(t, func() bool { return s.SessionCount() == 0 }, time.Second, 10*time.Millisecond)
+}
@@ -0,0 +1,197 @@
+//go:build linux || darwin || dragonfly || freebsd || netbsd || openbsd
+
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package getty
+
+import (
+	"encoding/json"
+	"net"
+	"os"
+	"os/exec"
+	"os/signal"
+	"strconv"
+	"syscall"
+	"time"
+)
+
+import (
+	perrors "github.com/pkg/errors"
+
+	"golang.org/x/sys/unix"
+)
+
+// ListenSignal upgrades the process on SIGUSR2.
+func (u *Upgrader) ListenSignal() {
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, syscall.SIGUSR2)
+	go func() {
+		defer signal.Stop(signals)
+		for {
+			select {
+			case <-signals:
+				if err := u.Upgrade(); err != nil {
+					log.Errorf("Upgrader.Upgrade() = error:%+v", err)
+				}
+			case <-u.exit:
+				return
+			}
+		}
+	}()
+}
+
+// startUpgradeProcess starts the new process, and returns after it has received the listener files.
+func startUpgradeProcess(keys []string, files []*os.File) error {
+	path, err := os.Executable()
+	if err != nil {
+		return perrors.WithStack(err)
+	}
+
+	fds, err := unix.Socketpair(unix.AF_UNIX, unix.SOCK_STREAM, 0)
+	if err != nil {
+		return perrors.Wrapf(err, "unix.Socketpair()")
+	}
+	unix.CloseOnExec(fds[0])
+	unix.CloseOnExec(fds[1])
+	parentFile := os.NewFile(uintptr(fds[0]), "getty-upgrade")
+	childFile := os.NewFile(uintptr(fds[1]), "getty-upgrade-child")
+	defer childFile.Close()
+	conn, err := net.FileConn(parentFile)
+	parentFile.Close()
+	if err != nil {
+		return perrors.Wrapf(err, "net.FileConn()")
+	}
+	defer conn.Close()
+
+	cmd := exec.Command(path, os.Args[1:]...)
+	// ExtraFiles[0] is the fd 3 of the new process
+	cmd.Env = append(os.Environ(), upgradeFdEnv+"=3")
+	cmd.Stdin = os.Stdin
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+	cmd.ExtraFiles = []*os.File{childFile}
+	if err = cmd.Start(); err != nil {
+		return perrors.Wrapf(err, "exec.Command(%s).Start()", path)
+	}
+
+	if err = sendUpgradeFiles(conn.(*net.UnixConn), keys, files); err != nil {
+		cmd.Process.Kill()
+		cmd.Wait()
+		return perrors.WithStack(err)
+	}
+	log.Infof("the new process %d has taken %d listeners", cmd.Process.Pid, len(files))
+	go cmd.Wait()
+
+	return nil
+}
+
+// sendUpgradeFiles sends the listener files and waits for the ack of the peer.
+func sendUpgradeFiles(conn *net.UnixConn, keys []string, files []*os.File) error {
+	header, err := json.Marshal(keys)
+	if err != nil {
+		return perrors.WithStack(err)
+	}
+	fds := make([]int, 0, len(files))
+	for _, f := range files {
+		// f.Fd() would set the fd to blocking mode, which is shared with the listener
+		rawConn, err := f.SyscallConn()
+		if err != nil {
+			return perrors.WithStack(err)
+		}
+		if err = rawConn.Control(func(fd uintptr) {
+			fds = append(fds, int(fd))
+		}); err != nil {
+			return perrors.WithStack(err)
+		}
+	}
+	if _, _, err = conn.WriteMsgUnix(header, unix.UnixRights(fds...), nil); err != nil {
+		return perrors.Wrapf(err, "WriteMsgUnix()")
+	}
+
+	ack := make([]byte, 1)
+	conn.SetReadDeadline(time.Now().Add(upgradeReadyTimeout))
+	if _, err = conn.Read(ack); err != nil {
+		return perrors.Wrapf(err, "read upgrade ack")
+	}
+
+	return nil
+}
+
+// recvUpgradeFiles receives the listener files and acks the peer.
+func recvUpgradeFiles(conn *net.UnixConn) ([]string, []*os.File, error) {
+	var (
+		keys  []string
+		files []*os.File
+	)
+
+	buf := make([]byte, 64*1024)
+	oob := make([]byte, unix.CmsgSpace(upgradeMaxFiles*4))
+	n, oobn, _, _, err := conn.ReadMsgUnix(buf, oob)
+	if err != nil {
+		return nil, nil, perrors.Wrapf(err, "ReadMsgUnix()")
+	}
+	if err = json.Unmarshal(buf[:n], &keys); err != nil {
+		return nil, nil, perrors.WithStack(err)
+	}
+	msgs, err := unix.ParseSocketControlMessage(oob[:oobn])
+	if err != nil {
+		return nil, nil, perrors.WithStack(err)
+	}
+	for i := range msgs {
+		fds, err := unix.ParseUnixRights(&msgs[i])
+		if err != nil {
+			return nil, nil, perrors.WithStack(err)
+		}
+		for _, fd := range fds {
+			files = append(files, os.NewFile(uintptr(fd), "getty-upgrade-listener"))
+		}
+	}
+	if len(keys) != len(files) {
+		for _, f := range files {
+			f.Close()
+		}
+		return nil, nil, perrors.Errorf("got %d listener keys but %d files", len(keys), len(files))
+	}
+
+	if _, err = conn.Write([]byte{1}); err != nil {
+		return nil, nil, perrors.Wrapf(err, "write upgrade ack")
+	}
+
+	return keys, files, nil
+}
+
+func recvUpgradeProcessFiles(fd string) ([]string, []*os.File, error) {
+	n, err := strconv.Atoi(fd)
+	if err != nil {
+		return nil, nil, perrors.WithStack(err)
+	}
+	f := os.NewFile(uintptr(n), "getty-upgrade")
+	conn, err := net.FileConn(f)
+	f.Close()
+	if err != nil {
+		return nil, nil, perrors.Wrapf(err, "net.FileConn()")
+	}
+	defer conn.Close()
+
+	unixConn, ok := conn.(*net.UnixConn)
+	if !ok {
+		return nil, nil, perrors.Errorf("fd %s is not a unix socket", fd)
+	}
+
+	return recvUpgradeFiles(unixConn)
+}
@@ -0,0 +1,84 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package getty
+
+import (
+	"time"
+)
+
+// WritePriority is the priority of a package queued by WritePkgAsync. The packages of a higher
+// priority are written ahead of the queued ones of a lower priority, eg: heartbeats and control
+// frames ahead of bulk data.
+type WritePriority int
+
+const (
+	WritePriorityHigh WritePriority = iota
+	WritePriorityNormal
+	WritePriorityLow
+	writePriorityNum
+)
+
+type WriteOption func(*writeOptions)
+
+type writeOptions struct {
+	deadline time.Time
+	priority WritePriority
+}
+
+func newWriteOptions(opts []WriteOption) writeOptions {
+	o := writeOptions{priority: WritePriorityNormal}
+	for _, opt := range opts {
+		opt(&o)
+	}
+
+	return o
+}
+
+// expired returns true if the package should not be written any more.
+func (o *writeOptions) expired() bool {
+	return !o.deadline.IsZero() && !time.Now().Before(o.deadline)
+}
+
+// writeTimeout returns @timeout limited by the deadline.
+func (o *writeOptions) writeTimeout(timeout time.Duration) time.Duration {
+	if o.deadline.IsZero() {
+		return timeout
+	}
+	if left := time.Until(o.deadline); timeout <= 0 || left < timeout {
+		return left
+	}
+
+	return timeout
+}
+
+// WithDeadline @deadline after which the package is dropped with ErrWriteExpired rather than written late
+func WithDeadline(deadline time.Time) WriteOption {
+	return func(o *writeOptions) {
+		o.deadline = deadline
+	}
+}
+
+// WithPriority @priority of the package in the queue of WritePkgAsync
+func WithPriority(priority WritePriority) WriteOption {
+	return func(o *writeOptions) {
+		if priority < WritePriorityHigh || priority >= writePriorityNum {
+			panic("illegal write priority")
+		}
+		o.priority = priority
+	}
+}
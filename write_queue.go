@@ -0,0 +1,330 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package getty
+
+import (
+	"sync"
+	"time"
+)
+
+const (
+	defaultWriteLowWatermark  = 32 << 10
+	defaultWriteHighWatermark = 64 << 10
+)
+
+// WriteOverflowPolicy is what WritePkgAsync does when the pending bytes of a session would exceed
+// the limit of Session.SetWriteOverflowPolicy, eg: for a slow consumer.
+type WriteOverflowPolicy int
+
+const (
+	// WriteOverflowBlock blocks WritePkgAsync until the package fits in the limit.
+	WriteOverflowBlock WriteOverflowPolicy = iota
+	// WriteOverflowDropNewest drops the new package.
+	WriteOverflowDropNewest
+	// WriteOverflowDropOldest drops the oldest queued packages to make room for the new one.
+	WriteOverflowDropOldest
+	// WriteOverflowClose closes the session.
+	WriteOverflowClose
+)
+
+// WriteOverflowStats counts the actions of the WriteOverflowPolicy of a session.
+type WriteOverflowStats struct {
+	Blocked       uint64 // the times WritePkgAsync is blocked
+	DroppedNewest uint64 // the dropped new packages
+	DroppedOldest uint64 // the dropped queued packages
+	Closed        uint64 // 1 if the session is closed for overflow
+}
+
+// asyncWrite is a package queued by WritePkgAsync.
+type asyncWrite struct {
+	data     interface{} // the encoded package
+	size     int
+	timeout  time.Duration
+	opts     writeOptions
+	callback func(error)
+}
+
+// writeQueue writes the queued packages of a session in the order of their priorities, and then
+// in the order of their pushes. The expired packages are dropped when popped. Its goroutine is started by
+// the first package and exits once the queue is empty, so an idle session costs no goroutine.
+//
+// The session turns unwritable when the bytes of the queued packages exceed the high watermark,
+// and turns writable again, notified by WritableListener, when they drop to the low watermark.
+type writeQueue struct {
+	ss *session
+
+	lock       sync.Mutex
+	items      [writePriorityNum][]*asyncWrite
+	running    bool
+	pending    int // the bytes of the queued and the writing packages
+	writing    int // the number of the writing packages
+	low        int
+	high       int
+	unwritable bool
+	limit      int // 0 means no limit
+	policy     WriteOverflowPolicy
+	room       *sync.Cond    // signaled when the pending bytes drop or the session is closed
+	drained    chan struct{} // closed when the pending bytes drop to 0, created by flush
+	stats      WriteOverflowStats
+}
+
+func (q *writeQueue) init(ss *session) {
+	q.ss = ss
+	q.low, q.high = defaultWriteLowWatermark, defaultWriteHighWatermark
+	q.room = sync.NewCond(&q.lock)
+}
+
+// overflow applies the overflow policy if @w does not fit in the limit, it returns the dropped
+// queued packages, or an error if @w should not be queued. It is called with the lock held.
+func (q *writeQueue) overflow(w *asyncWrite) ([]*asyncWrite, error) {
+	fits := func() bool {
+		// a package larger than the limit is written alone
+		return q.limit <= 0 || q.pending+w.size <= q.limit || q.pending == 0
+	}
+	if fits() {
+		return nil, nil
+	}
+
+	switch q.policy {
+	case WriteOverflowBlock:
+		q.stats.Blocked++
+		for !fits() && !q.ss.IsClosed() {
+			q.room.Wait()
+		}
+		if q.ss.IsClosed() {
+			return nil, ErrSessionClosed
+		}
+	case WriteOverflowDropNewest:
+		q.stats.DroppedNewest++
+		return nil, ErrWriteOverflow
+	case WriteOverflowDropOldest:
+		// drop the oldest packages of the lowest priority first
+		var dropped []*asyncWrite
+		for p := writePriorityNum - 1; p >= WritePriorityHigh && !fits(); p-- {
+			items := q.items[p]
+			for !fits() && len(items) > 0 {
+				dropped = append(dropped, items[0])
+				q.pending -= items[0].size
+				items[0] = nil
+				items = items[1:]
+			}
+			q.items[p] = items
+		}
+		q.stats.DroppedOldest += uint64(len(dropped))
+		return dropped, nil
+	case WriteOverflowClose:
+		q.stats.Closed = 1
+		return nil, ErrWriteOverflow
+	}
+
+	return nil, nil
+}
+
+// push queues @w, or returns an error if it is refused by the overflow policy.
+func (q *writeQueue) push(w *asyncWrite) error {
+	q.lock.Lock()
+	dropped, err := q.overflow(w)
+	if err != nil {
+		q.lock.Unlock()
+		if err == ErrWriteOverflow && q.policy == WriteOverflowClose {
+			q.ss.logger(log).Warnw("close the session for write queue overflow")
+			q.ss.Close()
+		}
+		return err
+	}
+	for _, d := range dropped {
+		if d.callback != nil {
+			defer d.callback(ErrWriteOverflow)
+		}
+	}
+	q.items[w.opts.priority] = append(q.items[w.opts.priority], w)
+	q.pending += w.size
+	if q.pending > q.high {
+		q.unwritable = true
+	}
+	// the dropped packages may release the bytes down to the low watermark
+	writable := len(dropped) > 0 && q.turnWritable()
+	running := q.running
+	q.running = true
+	q.lock.Unlock()
+
+	if writable {
+		q.notifyWritable()
+	}
+	if !running {
+		go q.run()
+	}
+	return nil
+}
+
+func (q *writeQueue) pop() *asyncWrite {
+	q.lock.Lock()
+	defer q.lock.Unlock()
+
+	for p := range q.items {
+		if items := q.items[p]; len(items) > 0 {
+			w := items[0]
+			items[0] = nil
+			q.items[p] = items[1:]
+			q.writing++
+			return w
+		}
+	}
+	q.running = false
+
+	return nil
+}
+
+// done releases the bytes of @w, and returns true if the session turns writable.
+func (q *writeQueue) done(w *asyncWrite) bool {
+	q.lock.Lock()
+	defer q.lock.Unlock()
+
+	q.pending -= w.size
+	q.writing--
+	if q.pending == 0 && q.drained != nil {
+		close(q.drained)
+		q.drained = nil
+	}
+	if q.limit > 0 {
+		q.room.Broadcast()
+	}
+
+	return q.turnWritable()
+}
+
+// turnWritable turns the session writable if the pending bytes drop to the low watermark, and
+// returns true if it does. It is called with the lock held.
+func (q *writeQueue) turnWritable() bool {
+	if q.unwritable && q.pending <= q.low {
+		q.unwritable = false
+		return true
+	}
+
+	return false
+}
+
+// notifyWritable notifies the WritableListener of the session that it turns writable.
+func (q *writeQueue) notifyWritable() {
+	if l, ok := q.ss.listener.(WritableListener); ok {
+		l.OnWritable(q.ss)
+	}
+}
+
+func (q *writeQueue) run() {
+	for w := q.pop(); w != nil; w = q.pop() {
+		err := ErrSessionClosed
+		if w.opts.expired() {
+			err = ErrWriteExpired
+		} else if !q.ss.IsClosed() {
+			_, err = q.ss.sendPkg(w.data, w.opts.writeTimeout(w.timeout))
+		}
+		writable := q.done(w)
+		if w.callback != nil {
+			w.callback(err)
+		}
+		if writable {
+			q.notifyWritable()
+		}
+	}
+}
+
+func (q *writeQueue) pendingBytes() int {
+	q.lock.Lock()
+	defer q.lock.Unlock()
+
+	return q.pending
+}
+
+// flush waits until the queued packages are written, the session is closed or @timeout, and
+// returns true if they are written.
+func (q *writeQueue) flush(timeout time.Duration) bool {
+	q.lock.Lock()
+	if q.pending == 0 {
+		q.lock.Unlock()
+		return true
+	}
+	if q.drained == nil {
+		q.drained = make(chan struct{})
+	}
+	drained := q.drained
+	q.lock.Unlock()
+
+	timer := time.NewTimer(timeout)
+	defer timer.Stop()
+	select {
+	case <-drained:
+		return true
+	case <-timer.C:
+	case <-q.ss.done:
+	}
+
+	return false
+}
+
+// depth returns the number and the bytes of the queued and the writing packages.
+func (q *writeQueue) depth() (int, int) {
+	q.lock.Lock()
+	defer q.lock.Unlock()
+
+	num := q.writing
+	for _, items := range q.items {
+		num += len(items)
+	}
+
+	return num, q.pending
+}
+
+func (q *writeQueue) writable() bool {
+	q.lock.Lock()
+	defer q.lock.Unlock()
+
+	return !q.unwritable
+}
+
+func (q *writeQueue) setWatermark(low, high int) {
+	q.lock.Lock()
+	defer q.lock.Unlock()
+
+	q.low, q.high = low, high
+	if q.pending > q.high {
+		q.unwritable = true
+	}
+}
+
+func (q *writeQueue) setOverflowPolicy(policy WriteOverflowPolicy, limit int) {
+	q.lock.Lock()
+	defer q.lock.Unlock()
+
+	q.policy, q.limit = policy, limit
+	q.room.Broadcast()
+}
+
+func (q *writeQueue) overflowStats() WriteOverflowStats {
+	q.lock.Lock()
+	defer q.lock.Unlock()
+
+	return q.stats
+}
+
+// wakeup wakes up the blocked WritePkgAsync when the session is closed.
+func (q *writeQueue) wakeup() {
+	q.lock.Lock()
+	q.room.Broadcast()
+	q.lock.Unlock()
+}
@@ -0,0 +1,83 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package getty
+
+import (
+	"errors"
+	"io"
+	"net"
+	"syscall"
+	"time"
+)
+
+import (
+	gxtime "github.com/dubbogo/gost/time"
+)
+
+const writeRetryInterval = 10 * time.Millisecond
+
+// isConnLoss returns true if the write error @err means the connection is lost, and the package
+// can be sent by another session. The timeouts are not, as the package may be being sent.
+func isConnLoss(err error) bool {
+	return errors.Is(err, ErrSessionClosed) || errors.Is(err, io.EOF) || errors.Is(err, io.ErrClosedPipe) ||
+		errors.Is(err, net.ErrClosed) || errors.Is(err, syscall.EPIPE) || errors.Is(err, syscall.ECONNRESET)
+}
+
+func (c *client) WritePkg(key string, pkg interface{}, timeout time.Duration) (int, int, error) {
+	ss, err := c.PickSession(key)
+	if err != nil {
+		return 0, 0, err
+	}
+	size, n, err := ss.WritePkg(pkg, timeout)
+	if err == nil || c.writeRetries <= 0 || !isConnLoss(err) ||
+		(c.writeRetryable != nil && !c.writeRetryable(pkg)) {
+		return size, n, err
+	}
+
+	var deadline time.Time
+	if c.writeRetryDeadline > 0 {
+		deadline = time.Now().Add(c.writeRetryDeadline)
+	}
+	tried := map[Session]struct{}{ss: {}}
+	for retries := 0; retries < c.writeRetries; {
+		if !deadline.IsZero() && time.Now().After(deadline) {
+			break
+		}
+		if c.IsClosed() {
+			return size, n, ErrClientClosed
+		}
+		next, pickErr := c.pickSession(key, tried)
+		if pickErr != nil {
+			if deadline.IsZero() {
+				break
+			}
+			// wait for the reconnecting sessions
+			<-gxtime.After(writeRetryInterval)
+			continue
+		}
+
+		retries++
+		tried[next] = struct{}{}
+		log.with(ss.Logger()).Infow("resend the failed package", "by", next.ID(), "err", err)
+		ss = next
+		if size, n, err = ss.WritePkg(pkg, timeout); err == nil || !isConnLoss(err) {
+			break
+		}
+	}
+	return size, n, err
+}
@@ -0,0 +1,100 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package getty
+
+import (
+	"bytes"
+	"io"
+	"net"
+	"testing"
+	"time"
+)
+
+import (
+	"github.com/stretchr/testify/assert"
+)
+
+// firstBalancer picks the session of the smallest id.
+type firstBalancer struct{}
+
+func (firstBalancer) Pick(sessions []Session, key string) Session {
+	return sessions[0]
+}
+
+func TestIsConnLoss(t *testing.T) {
+	assert.True(t, isConnLoss(ErrSessionClosed))
+	assert.True(t, isConnLoss(io.EOF))
+	assert.False(t, isConnLoss(ErrWriteExpired))
+	assert.False(t, isConnLoss(nil))
+}
+
+func TestClientWriteRetry(t *testing.T) {
+	// the server never closes the connections, so the sessions keep open after CloseWrite
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	assert.Nil(t, err)
+	defer ln.Close()
+	received := make(chan []byte, 16)
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			go func() {
+				buf := make([]byte, 64)
+				for {
+					n, err := conn.Read(buf)
+					if err != nil {
+						return
+					}
+					received <- bytes.Clone(buf[:n])
+				}
+			}()
+		}
+	}()
+
+	newClient := func(retryable func(pkg interface{}) bool) PoolClient {
+		clt := NewTCPClient(WithServerAddress(ln.Addr().String()), WithConnectionNumber(2),
+			WithReconnectInterval(1e7), WithLoadBalancer(firstBalancer{}), WithWriteRetry(2, time.Second, retryable)).(PoolClient)
+		clt.RunEventLoop(func(session Session) error {
+			session.SetPkgHandler(&stringPkgHandler{})
+			session.SetEventListener(newChanMessageHandler())
+			session.SetReadTimeout(50 * time.Millisecond)
+			return nil
+		})
+		return clt
+	}
+
+	clt := newClient(nil)
+	defer clt.Close()
+	ss, err := clt.PickSession("")
+	assert.Nil(t, err)
+	assert.Nil(t, tcpConnOf(ss.Conn()).CloseWrite())
+	_, _, err = clt.WritePkg("", "hello", 0)
+	assert.Nil(t, err)
+	assert.Equal(t, "\x00\x00\x00\x05hello", string(<-received))
+
+	// the non-idempotent packages are not resent
+	clt2 := newClient(func(pkg interface{}) bool { return pkg != "once" })
+	defer clt2.Close()
+	ss, err = clt2.PickSession("")
+	assert.Nil(t, err)
+	assert.Nil(t, tcpConnOf(ss.Conn()).CloseWrite())
+	_, _, err = clt2.WritePkg("", "once", 0)
+	assert.True(t, isConnLoss(err), "%+v", err)
+}
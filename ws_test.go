@@ -0,0 +1,205 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package getty
+
+import (
+	"bufio"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+import (
+	"github.com/gorilla/websocket"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestWSSubprotocols(t *testing.T) {
+	s := NewWSServer(WithLocalAddress("127.0.0.1:0"), WithWebsocketServerPath("/getty"),
+		WithServerWSSubprotocols([]string{"getty.v2", "getty.v1"}),
+		WithServerWSHeader(http.Header{"X-Server": []string{"getty"}})).(*server)
+	defer s.Close()
+	serverHandler := newChanMessageHandler()
+	s.RunEventLoop(func(session Session) error {
+		session.SetPkgHandler(&stringPkgHandler{})
+		session.SetEventListener(serverHandler)
+		return nil
+	})
+
+	clt := NewWSClient(WithServerAddress("ws://"+s.addr+"/getty"), WithConnectionNumber(1),
+		WithClientWSSubprotocols([]string{"getty.v1"}),
+		WithClientWSHeader(http.Header{"Authorization": []string{"Bearer token"}}))
+	defer clt.Close()
+	clientHandler := newChanMessageHandler()
+	clt.RunEventLoop(func(session Session) error {
+		session.SetPkgHandler(&stringPkgHandler{})
+		session.SetEventListener(clientHandler)
+		return nil
+	})
+
+	assert.Equal(t, 1, clientHandler.SessionNumber())
+	assert.Equal(t, "getty.v1", clientHandler.array[0].Subprotocol())
+	assert.Eventually(t, func() bool { return serverHandler.SessionNumber() == 1 }, 3*time.Second, 10*time.Millisecond)
+	assert.Equal(t, "getty.v1", serverHandler.array[0].Subprotocol())
+}
+
+func TestWSHandler(t *testing.T) {
+	s := NewWSServer()
+	defer s.Close()
+	serverHandler := newChanMessageHandler()
+	mux := http.NewServeMux()
+	mux.Handle("/getty", s.(WSServer).WSHandler(func(session Session) error {
+		session.SetPkgHandler(&stringPkgHandler{})
+		session.SetEventListener(serverHandler)
+		return nil
+	}))
+	mux.HandleFunc("/health", func(w http.ResponseWriter, _ *http.Request) { w.WriteHeader(http.StatusOK) })
+	httpServer := httptest.NewServer(mux)
+	defer httpServer.Close()
+
+	clt := NewWSClient(WithServerAddress(strings.Replace(httpServer.URL, "http://", "ws://", 1)+"/getty"),
+		WithConnectionNumber(1))
+	defer clt.Close()
+	clientHandler := newChanMessageHandler()
+	clt.RunEventLoop(func(session Session) error {
+		session.SetPkgHandler(&stringPkgHandler{})
+		session.SetEventListener(clientHandler)
+		return nil
+	})
+	assert.Equal(t, 1, clientHandler.SessionNumber())
+	_, _, err := clientHandler.array[0].WritePkg("hello", 0)
+	assert.Nil(t, err)
+
+	select {
+	case msg := <-serverHandler.msgs:
+		assert.Equal(t, "hello", msg)
+	case <-time.After(3 * time.Second):
+		t.Fatal("server did not receive the package")
+	}
+	assert.Equal(t, 1, s.(*server).SessionCount())
+}
+
+func TestWSCompression(t *testing.T) {
+	for _, enabled := range []bool{true, false} {
+		s := NewWSServer(WithLocalAddress("127.0.0.1:0"), WithWebsocketServerPath("/getty"),
+			WithServerWSCompression(WSCompressionOptions{Enabled: enabled, Level: CompressBestCompression})).(*server)
+		serverHandler := newChanMessageHandler()
+		s.RunEventLoop(func(session Session) error {
+			session.SetPkgHandler(&stringPkgHandler{})
+			session.SetEventListener(serverHandler)
+			return nil
+		})
+
+		dialer := websocket.Dialer{EnableCompression: true}
+		conn, resp, err := dialer.Dial("ws://"+s.addr+"/getty", nil)
+		assert.Nil(t, err)
+		assert.Equal(t, enabled, strings.Contains(resp.Header.Get("Sec-Websocket-Extensions"), "permessage-deflate"))
+
+		pkg, _ := (&stringPkgHandler{}).Write(nil, strings.Repeat("getty", 100))
+		assert.Nil(t, conn.WriteMessage(websocket.BinaryMessage, pkg))
+		select {
+		case msg := <-serverHandler.msgs:
+			assert.Equal(t, strings.Repeat("getty", 100), msg)
+		case <-time.After(3 * time.Second):
+			t.Fatal("server did not receive the package")
+		}
+		conn.Close()
+		s.Close()
+	}
+}
+
+func TestWSWriteCompression(t *testing.T) {
+	for _, compress := range []bool{true, false} {
+		opts := WSCompressionOptions{Enabled: true, CompressWrites: compress, Level: CompressBestSpeed}
+		s := NewWSServer(WithLocalAddress("127.0.0.1:0"), WithWebsocketServerPath("/getty"),
+			WithServerWSCompression(opts)).(*server)
+		sessions := make(chan Session, 1)
+		s.RunEventLoop(func(session Session) error {
+			session.SetPkgHandler(&stringPkgHandler{})
+			session.SetEventListener(newChanMessageHandler())
+			sessions <- session
+			return nil
+		})
+
+		// handshake by hand to see the RSV1 bit of the frames written by the server
+		conn, err := net.Dial("tcp", s.addr)
+		assert.Nil(t, err)
+		conn.SetDeadline(time.Now().Add(3 * time.Second))
+		_, err = conn.Write([]byte("GET /getty HTTP/1.1\r\nHost: " + s.addr + "\r\nUpgrade: websocket\r\n" +
+			"Connection: Upgrade\r\nSec-WebSocket-Key: dGhlIHNhbXBsZSBub25jZQ==\r\nSec-WebSocket-Version: 13\r\n" +
+			"Sec-WebSocket-Extensions: permessage-deflate; server_no_context_takeover; client_no_context_takeover\r\n\r\n"))
+		assert.Nil(t, err)
+		r := bufio.NewReader(conn)
+		resp, err := http.ReadResponse(r, nil)
+		assert.Nil(t, err)
+		assert.Contains(t, resp.Header.Get("Sec-Websocket-Extensions"), "permessage-deflate")
+
+		select {
+		case ss := <-sessions:
+			_, _, err = ss.WritePkg(strings.Repeat("getty", 100), 0)
+			assert.Nil(t, err)
+		case <-time.After(3 * time.Second):
+			t.Fatal("server did not accept the connection")
+		}
+		header, err := r.ReadByte()
+		assert.Nil(t, err)
+		assert.Equal(t, byte(websocket.BinaryMessage), header&0x0f)
+		assert.Equal(t, compress, header&0x40 != 0)
+
+		conn.Close()
+		s.Close()
+	}
+}
+
+func TestWSRoutes(t *testing.T) {
+	chatHandler, metricsHandler := newChanMessageHandler(), newChanMessageHandler()
+	newCallback := func(handler *chanMessageHandler) NewSessionCallback {
+		return func(session Session) error {
+			session.SetPkgHandler(&stringPkgHandler{})
+			session.SetEventListener(handler)
+			return nil
+		}
+	}
+	s := NewWSServer(WithLocalAddress("127.0.0.1:0"), WithWebsocketServerPath("/chat"),
+		WithWebsocketRoute("/metrics", newCallback(metricsHandler))).(*server)
+	defer s.Close()
+	s.RunEventLoop(newCallback(chatHandler))
+
+	for path, handler := range map[string]*chanMessageHandler{"/chat": chatHandler, "/metrics": metricsHandler} {
+		clt := NewWSClient(WithServerAddress("ws://"+s.addr+path), WithConnectionNumber(1))
+		clientHandler := newChanMessageHandler()
+		clt.RunEventLoop(newCallback(clientHandler))
+		assert.Equal(t, 1, clientHandler.SessionNumber())
+		_, _, err := clientHandler.array[0].WritePkg(path, 0)
+		assert.Nil(t, err)
+
+		select {
+		case msg := <-handler.msgs:
+			assert.Equal(t, path, msg)
+		case <-time.After(3 * time.Second):
+			t.Fatalf("%s did not receive the package", path)
+		}
+		clt.Close()
+	}
+	assert.Equal(t, 1, chatHandler.SessionNumber())
+	assert.Equal(t, 1, metricsHandler.SessionNumber())
+}